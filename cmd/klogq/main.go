@@ -0,0 +1,187 @@
+// klogq 查询 klogger 输出的日志文件
+//
+// 用法:
+//
+//	klogq [选项] 日志文件...
+//
+// 示例:
+//
+//	klogq -level warn -since 1h logs/app.log                 # 最近1小时 WARN 及以上的日志
+//	klogq -all -caller kredis/ -grep "timeout|refused" logs/app.log  # 查询所有滚动文件
+//	klogq -n 100 -f logs/app.log                              # 输出最后100条并持续跟踪, 自动跟随 rotatelogs 软链接
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/khan-lau/kutils/datetime"
+	"github.com/khan-lau/kutils/klogger"
+	"github.com/khan-lau/kutils/klogger/klogreader"
+)
+
+func main() {
+	since := flag.String("since", "", "起始时间, 支持 `2006-01-02 15:04:05[.000]`, `2006-01-02`, RFC3339 或相对时长(如 30m, 2h)")
+	until := flag.String("until", "", "结束时间, 格式同 -since")
+	level := flag.String("level", "", "最低日志等级, debug info warn error dpanic panic fatal")
+	levels := flag.String("levels", "", "只输出指定等级, 逗号分隔, 如 warn,error")
+	caller := flag.String("caller", "", "调用位置包含的字符串, 如 kredis/ 或 redisdb.go:120")
+	grep := flag.String("grep", "", "正则表达式, 匹配日志原始文本")
+	tailN := flag.Int("n", 0, "只输出最后 n 条日志, 0 表示全部")
+	follow := flag.Bool("f", false, "持续跟踪日志文件的新增内容, 仅支持单个文件")
+	all := flag.Bool("all", false, "同时读取 rotatelogs 产生的所有滚动文件")
+	interval := flag.Duration("interval", 500*time.Millisecond, "跟踪模式下的轮询周期")
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: klogq [options] logfile...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	filter, err := buildFilter(*since, *until, *level, *levels, *caller, *grep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "klogq: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *follow {
+		if len(files) != 1 {
+			fmt.Fprintln(os.Stderr, "klogq: -f supports only one file")
+			os.Exit(2)
+		}
+		if err := runFollow(files[0], *tailN, filter, *interval); err != nil {
+			fmt.Fprintf(os.Stderr, "klogq: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := runQuery(files, *all, *tailN, filter); err != nil {
+		fmt.Fprintf(os.Stderr, "klogq: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func buildFilter(since, until, level, levels, caller, grep string) (*klogreader.Filter, error) {
+	filter := klogreader.NewFilter().SetCaller(caller)
+
+	if since != "" {
+		tm, err := parseTime(since)
+		if err != nil {
+			return nil, err
+		}
+		filter.SetSince(tm)
+	}
+	if until != "" {
+		tm, err := parseTime(until)
+		if err != nil {
+			return nil, err
+		}
+		filter.SetUntil(tm)
+	}
+	if level != "" {
+		lvl, err := klogger.ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		filter.SetMinLevel(lvl)
+	}
+	if levels != "" {
+		lvls := make([]klogger.Level, 0, 4)
+		for _, name := range strings.Split(levels, ",") {
+			lvl, err := klogger.ParseLevel(name)
+			if err != nil {
+				return nil, err
+			}
+			lvls = append(lvls, lvl)
+		}
+		filter.SetLevels(lvls...)
+	}
+	if grep != "" {
+		pattern, err := regexp.Compile(grep)
+		if err != nil {
+			return nil, err
+		}
+		filter.SetPattern(pattern)
+	}
+	return filter, nil
+}
+
+// parseTime 解析命令行中的时间, 相对时长表示距今多久之前
+func parseTime(str string) (time.Time, error) {
+	if duration, err := time.ParseDuration(str); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	layouts := []string{datetime.DATETIME_FORMATTER_Mill, datetime.DATETIME_FORMATTER, "2006-01-02", time.RFC3339}
+	for _, layout := range layouts {
+		if tm, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return tm, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", str)
+}
+
+func runQuery(files []string, all bool, tailN int, filter *klogreader.Filter) error {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if all {
+			rotated, err := klogreader.RotatedFiles(file)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, rotated...)
+		} else {
+			paths = append(paths, file)
+		}
+	}
+
+	if tailN <= 0 {
+		return klogreader.ReadFiles(paths, filter, printEntry)
+	}
+
+	entries := make([]*klogreader.Entry, 0, tailN)
+	err := klogreader.ReadFiles(paths, filter, func(entry *klogreader.Entry) bool {
+		if len(entries) == tailN {
+			copy(entries, entries[1:])
+			entries = entries[:tailN-1]
+		}
+		entries = append(entries, entry)
+		return true
+	})
+	for _, entry := range entries {
+		printEntry(entry)
+	}
+	return err
+}
+
+func runFollow(file string, tailN int, filter *klogreader.Filter, interval time.Duration) error {
+	offset := int64(-1)
+	if tailN > 0 {
+		entries, end, err := klogreader.Tail(file, tailN, filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			printEntry(entry)
+		}
+		offset = end
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return klogreader.Follow(ctx, file, offset, filter, interval, printEntry)
+}
+
+func printEntry(entry *klogreader.Entry) bool {
+	fmt.Println(entry.Raw)
+	return true
+}
@@ -215,7 +215,7 @@ func TrimmedCopy(args []any) ([]any, error) {
 	}
 
 	trimmedLen := len(args) - 1
-	trimmed := make([]any, 0, trimmedLen)
+	trimmed := make([]any, trimmedLen)
 
 	if trimmedLen > 0 {
 		copy(trimmed, args[:trimmedLen])
@@ -224,6 +224,23 @@ func TrimmedCopy(args []any) ([]any, error) {
 	return trimmed, nil
 }
 
+// @bref Counts the formatting anchors `{}` in 'messagePattern', escaped anchors `\{}` are not counted
+//   - @param messagePattern The message pattern which will be parsed
+//   - @return the number of arguments the pattern consumes
+func CountPlaceholders(messagePattern string) int {
+	count := 0
+	for i := 0; ; {
+		j := IndexOf(messagePattern, DELIM_STR, i)
+		if j == -1 {
+			return count
+		}
+		if !isEscapedDelimeter(messagePattern, j) || isDoubleEscaped(messagePattern, j) {
+			count++
+		}
+		i = j + 2
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////
 
 func isEscapedDelimeter(messagePattern string, delimeterStartIndex int) bool {
@@ -0,0 +1,682 @@
+package kredistest
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	flagWrite       = 1 << iota // 修改数据, 成功后更新 key 版本号并唤醒阻塞命令
+	flagNoAuth                  // 认证前允许执行
+	flagPubSub                  // RESP2 订阅状态下允许执行
+	flagTx                      // 事务中不排队, 立即执行
+	flagNoScript                // 不能在脚本中调用
+	flagMovableKeys             // key 的位置由参数决定, firstkey/lastkey/step 不能描述
+)
+
+const serverVersion = "7.2.0"
+
+type command struct {
+	handler func(c *conn, w *writer, args []string)
+	arity   int // 含命令名的参数个数, 负数表示至少 -arity 个
+	flags   int
+	first   int // 第一个 key 的位置, 0 表示没有 key
+	last    int // 最后一个 key 的位置, 负数从末尾计算
+	step    int
+}
+
+// keys 返回命令访问的 key, 与 Redis 命令表的 firstkey/lastkey/step 含义相同
+func (that *command) keys(args []string) []string {
+	if that.first == 0 || that.first >= len(args) {
+		return nil
+	}
+	last := that.last
+	if last < 0 {
+		last += len(args)
+	}
+	last = min(last, len(args)-1)
+	keys := make([]string, 0, last-that.first+1)
+	for i := that.first; i <= last; i += that.step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = make(map[string]*command)
+	// 命令名, 处理函数, arity, flags, firstkey, lastkey, step
+	register := func(name string, handler func(c *conn, w *writer, args []string), arity int, flags int, first int, last int, step int) {
+		commands[name] = &command{handler: handler, arity: arity, flags: flags, first: first, last: last, step: step}
+	}
+
+	// 连接与服务端
+	register("ping", cmdPing, -1, flagPubSub, 0, 0, 0)
+	register("echo", cmdEcho, 2, 0, 0, 0, 0)
+	register("hello", cmdHello, -1, flagNoAuth|flagPubSub|flagNoScript, 0, 0, 0)
+	register("auth", cmdAuth, -2, flagNoAuth|flagPubSub|flagNoScript, 0, 0, 0)
+	register("quit", cmdQuit, -1, flagNoAuth|flagPubSub|flagTx|flagNoScript, 0, 0, 0)
+	register("reset", cmdReset, 1, flagNoAuth|flagPubSub|flagTx|flagNoScript, 0, 0, 0)
+	register("select", cmdSelect, 2, 0, 0, 0, 0)
+	register("client", cmdClient, -2, 0, 0, 0, 0)
+	register("command", cmdCommand, -1, 0, 0, 0, 0)
+	register("info", cmdInfo, -1, 0, 0, 0, 0)
+	register("config", cmdConfig, -2, 0, 0, 0, 0)
+	register("dbsize", cmdDBSize, 1, 0, 0, 0, 0)
+	register("flushdb", cmdFlushDB, -1, flagWrite, 0, 0, 0)
+	register("flushall", cmdFlushAll, -1, flagWrite, 0, 0, 0)
+	register("time", cmdTime, 1, 0, 0, 0, 0)
+	register("multi", cmdMulti, 1, flagTx|flagNoScript, 0, 0, 0)
+	register("exec", cmdExec, 1, flagTx|flagNoScript, 0, 0, 0)
+	register("discard", cmdDiscard, 1, flagTx|flagNoScript, 0, 0, 0)
+	register("watch", cmdWatch, -2, flagTx|flagNoScript, 1, -1, 1)
+	register("unwatch", cmdUnwatch, 1, flagTx|flagNoScript, 0, 0, 0)
+
+	// 通用 key 命令
+	register("del", cmdDel, -2, flagWrite, 1, -1, 1)
+	register("unlink", cmdDel, -2, flagWrite, 1, -1, 1)
+	register("exists", cmdExists, -2, 0, 1, -1, 1)
+	register("touch", cmdExists, -2, 0, 1, -1, 1)
+	register("type", cmdType, 2, 0, 1, 1, 1)
+	register("expire", cmdExpire, -3, flagWrite, 1, 1, 1)
+	register("pexpire", cmdExpire, -3, flagWrite, 1, 1, 1)
+	register("expireat", cmdExpire, -3, flagWrite, 1, 1, 1)
+	register("pexpireat", cmdExpire, -3, flagWrite, 1, 1, 1)
+	register("ttl", cmdTTL, 2, 0, 1, 1, 1)
+	register("pttl", cmdTTL, 2, 0, 1, 1, 1)
+	register("expiretime", cmdTTL, 2, 0, 1, 1, 1)
+	register("pexpiretime", cmdTTL, 2, 0, 1, 1, 1)
+	register("persist", cmdPersist, 2, flagWrite, 1, 1, 1)
+	register("keys", cmdKeys, 2, 0, 0, 0, 0)
+	register("scan", cmdScan, -2, 0, 0, 0, 0)
+	register("randomkey", cmdRandomKey, 1, 0, 0, 0, 0)
+	register("rename", cmdRename, 3, flagWrite, 1, 2, 1)
+	register("renamenx", cmdRename, 3, flagWrite, 1, 2, 1)
+	register("dump", cmdDump, 2, 0, 1, 1, 1)
+	register("restore", cmdRestore, -4, flagWrite, 1, 1, 1)
+
+	// 字符串
+	register("get", cmdGet, 2, 0, 1, 1, 1)
+	register("set", cmdSet, -3, flagWrite, 1, 1, 1)
+	register("setnx", cmdSetNX, 3, flagWrite, 1, 1, 1)
+	register("setex", cmdSetEX, 4, flagWrite, 1, 1, 1)
+	register("psetex", cmdSetEX, 4, flagWrite, 1, 1, 1)
+	register("getset", cmdGetSet, 3, flagWrite, 1, 1, 1)
+	register("getdel", cmdGetDel, 2, flagWrite, 1, 1, 1)
+	register("getex", cmdGetEX, -2, flagWrite, 1, 1, 1)
+	register("mget", cmdMGet, -2, 0, 1, -1, 1)
+	register("mset", cmdMSet, -3, flagWrite, 1, -1, 2)
+	register("msetnx", cmdMSet, -3, flagWrite, 1, -1, 2)
+	register("incr", cmdIncr, 2, flagWrite, 1, 1, 1)
+	register("decr", cmdIncr, 2, flagWrite, 1, 1, 1)
+	register("incrby", cmdIncr, 3, flagWrite, 1, 1, 1)
+	register("decrby", cmdIncr, 3, flagWrite, 1, 1, 1)
+	register("incrbyfloat", cmdIncrByFloat, 3, flagWrite, 1, 1, 1)
+	register("append", cmdAppend, 3, flagWrite, 1, 1, 1)
+	register("strlen", cmdStrLen, 2, 0, 1, 1, 1)
+	register("getrange", cmdGetRange, 4, 0, 1, 1, 1)
+	register("setrange", cmdSetRange, 4, flagWrite, 1, 1, 1)
+
+	// 哈希
+	register("hset", cmdHSet, -4, flagWrite, 1, 1, 1)
+	register("hmset", cmdHSet, -4, flagWrite, 1, 1, 1)
+	register("hsetnx", cmdHSetNX, 4, flagWrite, 1, 1, 1)
+	register("hget", cmdHGet, 3, 0, 1, 1, 1)
+	register("hmget", cmdHMGet, -3, 0, 1, 1, 1)
+	register("hgetall", cmdHGetAll, 2, 0, 1, 1, 1)
+	register("hdel", cmdHDel, -3, flagWrite, 1, 1, 1)
+	register("hexists", cmdHExists, 3, 0, 1, 1, 1)
+	register("hlen", cmdHLen, 2, 0, 1, 1, 1)
+	register("hkeys", cmdHKeys, 2, 0, 1, 1, 1)
+	register("hvals", cmdHKeys, 2, 0, 1, 1, 1)
+	register("hstrlen", cmdHStrLen, 3, 0, 1, 1, 1)
+	register("hincrby", cmdHIncrBy, 4, flagWrite, 1, 1, 1)
+	register("hincrbyfloat", cmdHIncrByFloat, 4, flagWrite, 1, 1, 1)
+	register("hscan", cmdHScan, -3, 0, 1, 1, 1)
+
+	// 列表
+	register("lpush", cmdPush, -3, flagWrite, 1, 1, 1)
+	register("rpush", cmdPush, -3, flagWrite, 1, 1, 1)
+	register("lpushx", cmdPush, -3, flagWrite, 1, 1, 1)
+	register("rpushx", cmdPush, -3, flagWrite, 1, 1, 1)
+	register("lpop", cmdPop, -2, flagWrite, 1, 1, 1)
+	register("rpop", cmdPop, -2, flagWrite, 1, 1, 1)
+	register("llen", cmdLLen, 2, 0, 1, 1, 1)
+	register("lrange", cmdLRange, 4, 0, 1, 1, 1)
+	register("lindex", cmdLIndex, 3, 0, 1, 1, 1)
+	register("lset", cmdLSet, 4, flagWrite, 1, 1, 1)
+	register("lrem", cmdLRem, 4, flagWrite, 1, 1, 1)
+	register("ltrim", cmdLTrim, 4, flagWrite, 1, 1, 1)
+	register("linsert", cmdLInsert, 5, flagWrite, 1, 1, 1)
+	register("lpos", cmdLPos, -3, 0, 1, 1, 1)
+	register("lmove", cmdLMove, 5, flagWrite, 1, 2, 1)
+	register("rpoplpush", cmdLMove, 3, flagWrite, 1, 2, 1)
+	register("blpop", cmdBPop, -3, flagWrite, 1, -2, 1)
+	register("brpop", cmdBPop, -3, flagWrite, 1, -2, 1)
+	register("blmove", cmdLMove, 6, flagWrite, 1, 2, 1)
+	register("brpoplpush", cmdLMove, 4, flagWrite, 1, 2, 1)
+
+	// 集合
+	register("sadd", cmdSAdd, -3, flagWrite, 1, 1, 1)
+	register("srem", cmdSRem, -3, flagWrite, 1, 1, 1)
+	register("smembers", cmdSMembers, 2, 0, 1, 1, 1)
+	register("sismember", cmdSIsMember, 3, 0, 1, 1, 1)
+	register("smismember", cmdSMIsMember, -3, 0, 1, 1, 1)
+	register("scard", cmdSCard, 2, 0, 1, 1, 1)
+	register("spop", cmdSPop, -2, flagWrite, 1, 1, 1)
+	register("srandmember", cmdSRandMember, -2, 0, 1, 1, 1)
+	register("smove", cmdSMove, 4, flagWrite, 1, 2, 1)
+	register("sinter", cmdSetOp, -2, 0, 1, -1, 1)
+	register("sunion", cmdSetOp, -2, 0, 1, -1, 1)
+	register("sdiff", cmdSetOp, -2, 0, 1, -1, 1)
+	register("sinterstore", cmdSetOp, -3, flagWrite, 1, 1, 1)
+	register("sunionstore", cmdSetOp, -3, flagWrite, 1, 1, 1)
+	register("sdiffstore", cmdSetOp, -3, flagWrite, 1, 1, 1)
+	register("sscan", cmdSScan, -3, 0, 1, 1, 1)
+
+	// 有序集合
+	register("zadd", cmdZAdd, -4, flagWrite, 1, 1, 1)
+	register("zincrby", cmdZIncrBy, 4, flagWrite, 1, 1, 1)
+	register("zrem", cmdZRem, -3, flagWrite, 1, 1, 1)
+	register("zscore", cmdZScore, 3, 0, 1, 1, 1)
+	register("zmscore", cmdZMScore, -3, 0, 1, 1, 1)
+	register("zcard", cmdZCard, 2, 0, 1, 1, 1)
+	register("zcount", cmdZCount, 4, 0, 1, 1, 1)
+	register("zlexcount", cmdZCount, 4, 0, 1, 1, 1)
+	register("zrank", cmdZRank, -3, 0, 1, 1, 1)
+	register("zrevrank", cmdZRank, -3, 0, 1, 1, 1)
+	register("zrange", cmdZRange, -4, 0, 1, 1, 1)
+	register("zrangestore", cmdZRange, -5, flagWrite, 1, 1, 1)
+	register("zrevrange", cmdZRange, -4, 0, 1, 1, 1)
+	register("zrangebyscore", cmdZRange, -4, 0, 1, 1, 1)
+	register("zrevrangebyscore", cmdZRange, -4, 0, 1, 1, 1)
+	register("zrangebylex", cmdZRange, -4, 0, 1, 1, 1)
+	register("zrevrangebylex", cmdZRange, -4, 0, 1, 1, 1)
+	register("zremrangebyrank", cmdZRemRange, 4, flagWrite, 1, 1, 1)
+	register("zremrangebyscore", cmdZRemRange, 4, flagWrite, 1, 1, 1)
+	register("zremrangebylex", cmdZRemRange, 4, flagWrite, 1, 1, 1)
+	register("zpopmin", cmdZPop, -2, flagWrite, 1, 1, 1)
+	register("zpopmax", cmdZPop, -2, flagWrite, 1, 1, 1)
+	register("zscan", cmdZScan, -3, 0, 1, 1, 1)
+
+	// Stream, XREAD/XREADGROUP 的 key 位于 STREAMS 之后, 不能用固定位置表示
+	register("xadd", cmdXAdd, -5, flagWrite, 1, 1, 1)
+	register("xlen", cmdXLen, 2, 0, 1, 1, 1)
+	register("xrange", cmdXRange, -4, 0, 1, 1, 1)
+	register("xrevrange", cmdXRange, -4, 0, 1, 1, 1)
+	register("xdel", cmdXDel, -3, flagWrite, 1, 1, 1)
+	register("xtrim", cmdXTrim, -4, flagWrite, 1, 1, 1)
+	register("xread", cmdXRead, -4, flagMovableKeys, 0, 0, 0)
+	register("xreadgroup", cmdXRead, -7, flagWrite|flagMovableKeys, 0, 0, 0)
+	register("xgroup", cmdXGroup, -2, flagWrite, 2, 2, 1)
+	register("xack", cmdXAck, -4, flagWrite, 1, 1, 1)
+	register("xpending", cmdXPending, -3, 0, 1, 1, 1)
+	register("xclaim", cmdXClaim, -6, flagWrite, 1, 1, 1)
+	register("xautoclaim", cmdXAutoClaim, -6, flagWrite, 1, 1, 1)
+
+	// 发布订阅
+	register("publish", cmdPublish, 3, 0, 0, 0, 0)
+	register("spublish", cmdPublish, 3, 0, 0, 0, 0)
+	register("subscribe", cmdSubscribe, -2, flagPubSub|flagNoScript, 0, 0, 0)
+	register("psubscribe", cmdSubscribe, -2, flagPubSub|flagNoScript, 0, 0, 0)
+	register("ssubscribe", cmdSubscribe, -2, flagPubSub|flagNoScript, 0, 0, 0)
+	register("unsubscribe", cmdUnsubscribe, -1, flagPubSub|flagNoScript, 0, 0, 0)
+	register("punsubscribe", cmdUnsubscribe, -1, flagPubSub|flagNoScript, 0, 0, 0)
+	register("sunsubscribe", cmdUnsubscribe, -1, flagPubSub|flagNoScript, 0, 0, 0)
+	register("pubsub", cmdPubSub, -2, 0, 0, 0, 0)
+
+	// 脚本
+	register("eval", cmdEval, -3, flagNoScript|flagMovableKeys, 0, 0, 0)
+	register("evalsha", cmdEval, -3, flagNoScript|flagMovableKeys, 0, 0, 0)
+	register("eval_ro", cmdEval, -3, flagNoScript|flagMovableKeys, 0, 0, 0)
+	register("evalsha_ro", cmdEval, -3, flagNoScript|flagMovableKeys, 0, 0, 0)
+	register("script", cmdScript, -2, flagNoScript, 0, 0, 0)
+}
+
+func defaultConfig() map[string]string {
+	return map[string]string{
+		"appendonly":             "no",
+		"databases":              strconv.Itoa(databases),
+		"maxmemory":              "0",
+		"maxmemory-policy":       "noeviction",
+		"notify-keyspace-events": "",
+		"save":                   "",
+		"timeout":                "0",
+	}
+}
+
+func cmdPing(c *conn, w *writer, args []string) {
+	if len(args) > 2 {
+		w.errorf("ERR wrong number of arguments for '%s' command", "ping")
+		return
+	}
+	if c.proto == 2 && c.subscriptions() > 0 { // RESP2 订阅状态下以消息的形式回复
+		w.array(2)
+		w.bulk("pong")
+		w.bulk(strings.Join(args[1:], ""))
+		return
+	}
+	if len(args) == 2 {
+		w.bulk(args[1])
+		return
+	}
+	w.status("PONG")
+}
+
+func cmdEcho(c *conn, w *writer, args []string) {
+	w.bulk(args[1])
+}
+
+// cmdHello HELLO [protover [AUTH username password] [SETNAME clientname]]
+func cmdHello(c *conn, w *writer, args []string) {
+	proto := c.proto
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			w.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if n != 2 && n != 3 {
+			w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = n
+	}
+
+	authed := c.authed
+	name := c.name
+	for i := 2; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "AUTH") && i+2 < len(args):
+			if !c.server.checkPassword(w, args[i+1], args[i+2]) {
+				return
+			}
+			authed = true
+			i += 2
+		case strings.EqualFold(args[i], "SETNAME") && i+1 < len(args):
+			name = args[i+1]
+			i++
+		default:
+			w.errorf("ERR Syntax error in HELLO option '%s'", args[i])
+			return
+		}
+	}
+	if !authed {
+		w.error("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return
+	}
+
+	c.authed, c.name, c.proto = true, name, proto
+	w.proto = proto
+	w.mapHeader(7)
+	w.bulk("server")
+	w.bulk("redis")
+	w.bulk("version")
+	w.bulk(serverVersion)
+	w.bulk("proto")
+	w.integer(int64(proto))
+	w.bulk("id")
+	w.integer(c.id)
+	w.bulk("mode")
+	w.bulk("standalone")
+	w.bulk("role")
+	w.bulk("master")
+	w.bulk("modules")
+	w.array(0)
+}
+
+// cmdAuth AUTH [username] password
+func cmdAuth(c *conn, w *writer, args []string) {
+	if len(args) > 3 {
+		w.error(errSyntax)
+		return
+	}
+	username, password := "default", args[len(args)-1]
+	if len(args) == 3 {
+		username = args[1]
+	}
+	if c.server.password == "" && len(args) == 2 {
+		w.error("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		return
+	}
+	if c.server.checkPassword(w, username, password) {
+		c.authed = true
+		w.ok()
+	}
+}
+
+// checkPassword 只有 default 用户, 未设置密码时接受任意密码
+func (that *Server) checkPassword(w *writer, username string, password string) bool {
+	if username != "default" || (that.password != "" && password != that.password) {
+		w.error("WRONGPASS invalid username-password pair or user is disabled.")
+		return false
+	}
+	return true
+}
+
+func cmdQuit(c *conn, w *writer, args []string) {
+	c.quit = true
+	w.ok()
+}
+
+// cmdReset 恢复连接的初始状态: 取消事务, 订阅与 WATCH, 回到 db 0 与 RESP2
+func cmdReset(c *conn, w *writer, args []string) {
+	c.multi, c.multiErr, c.watched = nil, false, nil
+	c.unsubscribeAll()
+	c.db, c.name, c.proto = 0, "", 2
+	c.authed = c.server.password == ""
+	w.proto = 2
+	w.status("RESET")
+}
+
+func cmdSelect(c *conn, w *writer, args []string) {
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		w.error(errNotInteger)
+		return
+	}
+	if n < 0 || n >= databases {
+		w.error("ERR DB index is out of range")
+		return
+	}
+	c.db = n
+	w.ok()
+}
+
+// cmdClient 支持 CLIENT ID/SETNAME/GETNAME/SETINFO/LIST/INFO/NO-EVICT/NO-TOUCH
+func cmdClient(c *conn, w *writer, args []string) {
+	sub := strings.ToUpper(args[1])
+	switch {
+	case sub == "ID" && len(args) == 2:
+		w.integer(c.id)
+	case sub == "SETNAME" && len(args) == 3:
+		if strings.ContainsAny(args[2], " \n") {
+			w.error("ERR Client names cannot contain spaces, newlines or special characters.")
+			return
+		}
+		c.name = args[2]
+		w.ok()
+	case sub == "GETNAME" && len(args) == 2:
+		if c.name == "" {
+			w.null()
+			return
+		}
+		w.bulk(c.name)
+	case sub == "SETINFO" && len(args) == 4, (sub == "NO-EVICT" || sub == "NO-TOUCH") && len(args) == 3:
+		w.ok()
+	case sub == "INFO" && len(args) == 2:
+		w.bulk(c.info() + "\n")
+	case sub == "LIST" && len(args) == 2:
+		conns := make([]*conn, 0, len(c.server.conns))
+		for other := range c.server.conns {
+			conns = append(conns, other)
+		}
+		sort.Slice(conns, func(i, j int) bool { return conns[i].id < conns[j].id })
+		var b strings.Builder
+		for _, other := range conns {
+			b.WriteString(other.info() + "\n")
+		}
+		w.bulk(b.String())
+	default:
+		w.errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try CLIENT HELP.", args[1])
+	}
+}
+
+func (that *conn) info() string {
+	return "id=" + strconv.FormatInt(that.id, 10) + " addr=" + that.netConn.RemoteAddr().String() +
+		" name=" + that.name + " db=" + strconv.Itoa(that.db) + " sub=" + strconv.Itoa(len(that.channels)) +
+		" psub=" + strconv.Itoa(len(that.patterns)) + " ssub=" + strconv.Itoa(len(that.shards)) +
+		" multi=" + strconv.Itoa(len(that.multi)-boolInt(that.multi == nil)) + " resp=" + strconv.Itoa(that.proto)
+}
+
+// cmdCommand COMMAND 与 COMMAND INFO 返回 Redis 6 格式的命令信息(不含 ACL 分类), 不提供命令文档, COMMAND DOCS 返回空结果
+func cmdCommand(c *conn, w *writer, args []string) {
+	if len(args) == 1 {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w.array(len(names))
+		for _, name := range names {
+			writeCommandInfo(w, name, commands[name])
+		}
+		return
+	}
+	switch strings.ToUpper(args[1]) {
+	case "COUNT":
+		w.integer(int64(len(commands)))
+	case "DOCS":
+		w.mapHeader(0)
+	case "INFO":
+		w.array(len(args) - 2)
+		for _, name := range args[2:] {
+			name = strings.ToLower(name)
+			if cmd, ok := commands[name]; ok {
+				writeCommandInfo(w, name, cmd)
+			} else {
+				w.nullArray()
+			}
+		}
+	default:
+		w.array(0)
+	}
+}
+
+// writeCommandInfo 输出 name, arity, flags, firstkey, lastkey, step, ACL 分类
+func writeCommandInfo(w *writer, name string, cmd *command) {
+	flags := make([]string, 0, 4)
+	switch {
+	case cmd.flags&flagWrite != 0:
+		flags = append(flags, "write")
+	case cmd.first > 0 || cmd.flags&flagMovableKeys != 0:
+		flags = append(flags, "readonly")
+	}
+	for _, flag := range []struct {
+		mask int
+		name string
+	}{{flagNoAuth, "no_auth"}, {flagPubSub, "pubsub"}, {flagNoScript, "noscript"}, {flagMovableKeys, "movablekeys"}} {
+		if cmd.flags&flag.mask != 0 {
+			flags = append(flags, flag.name)
+		}
+	}
+
+	w.array(7)
+	w.bulk(name)
+	w.integer(int64(cmd.arity))
+	w.array(len(flags))
+	for _, flag := range flags {
+		w.status(flag)
+	}
+	w.integer(int64(cmd.first))
+	w.integer(int64(cmd.last))
+	w.integer(int64(cmd.step))
+	w.array(0)
+}
+
+func cmdInfo(c *conn, w *writer, args []string) {
+	var b strings.Builder
+	b.WriteString("# Server\r\nredis_version:" + serverVersion + "\r\nredis_mode:standalone\r\n")
+	if addr, ok := c.netConn.LocalAddr().(*net.TCPAddr); ok {
+		b.WriteString("tcp_port:" + strconv.Itoa(addr.Port) + "\r\n")
+	}
+	b.WriteString("\r\n# Clients\r\nconnected_clients:" + strconv.Itoa(len(c.server.conns)) + "\r\n")
+	b.WriteString("\r\n# Replication\r\nrole:master\r\nconnected_slaves:0\r\n")
+	b.WriteString("\r\n# Keyspace\r\n")
+	for i := range c.server.dbs {
+		keys := c.server.keys(i)
+		if len(keys) == 0 {
+			continue
+		}
+		expires := 0
+		for _, key := range keys {
+			if !c.server.dbs[i].items[key].expires.IsZero() {
+				expires++
+			}
+		}
+		b.WriteString("db" + strconv.Itoa(i) + ":keys=" + strconv.Itoa(len(keys)) + ",expires=" + strconv.Itoa(expires) + ",avg_ttl=0\r\n")
+	}
+	w.bulk(b.String())
+}
+
+// cmdConfig 支持 CONFIG GET/SET/RESETSTAT/REWRITE, 参数只保存在内存中, 只有 notify-keyspace-events 会影响行为
+func cmdConfig(c *conn, w *writer, args []string) {
+	switch strings.ToUpper(args[1]) {
+	case "GET":
+		if len(args) < 3 {
+			w.errorf("ERR wrong number of arguments for '%s' command", "config|get")
+			return
+		}
+		names := make([]string, 0, len(c.server.config))
+		for name := range c.server.config {
+			for _, pattern := range args[2:] {
+				if matchGlob(strings.ToLower(pattern), name) {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+		sort.Strings(names)
+		w.mapHeader(len(names))
+		for _, name := range names {
+			w.bulk(name)
+			w.bulk(c.server.config[name])
+		}
+	case "SET":
+		if len(args) < 4 || len(args)%2 != 0 {
+			w.errorf("ERR wrong number of arguments for '%s' command", "config|set")
+			return
+		}
+		for i := 2; i < len(args); i += 2 {
+			name := strings.ToLower(args[i])
+			if name == "notify-keyspace-events" && strings.Trim(args[i+1], "KEg$lshzxetmdnA") != "" {
+				w.errorf("ERR CONFIG SET failed (possibly related to argument '%s') - Invalid event class character. Use 'Ag$lshzxeKEtmdn'.", args[i])
+				return
+			}
+		}
+		for i := 2; i < len(args); i += 2 {
+			c.server.config[strings.ToLower(args[i])] = args[i+1]
+		}
+		w.ok()
+	case "RESETSTAT", "REWRITE":
+		w.ok()
+	default:
+		w.errorf("ERR unknown subcommand '%s'. Try CONFIG HELP.", args[1])
+	}
+}
+
+func cmdDBSize(c *conn, w *writer, args []string) {
+	w.integer(int64(len(c.server.keys(c.db))))
+}
+
+// cmdFlushDB FLUSHDB [ASYNC|SYNC]
+func cmdFlushDB(c *conn, w *writer, args []string) {
+	if !flushMode(w, args) {
+		return
+	}
+	c.server.flush(c.db)
+	w.ok()
+}
+
+func cmdFlushAll(c *conn, w *writer, args []string) {
+	if !flushMode(w, args) {
+		return
+	}
+	for i := range c.server.dbs {
+		c.server.flush(i)
+	}
+	w.ok()
+}
+
+func flushMode(w *writer, args []string) bool {
+	if len(args) > 2 || (len(args) == 2 && !strings.EqualFold(args[1], "ASYNC") && !strings.EqualFold(args[1], "SYNC")) {
+		w.error(errSyntax)
+		return false
+	}
+	return true
+}
+
+func cmdTime(c *conn, w *writer, args []string) {
+	now := c.server.now()
+	w.array(2)
+	w.bulk(strconv.FormatInt(now.Unix(), 10))
+	w.bulk(strconv.FormatInt(int64(now.Nanosecond()/int(time.Microsecond)), 10))
+}
+
+func cmdMulti(c *conn, w *writer, args []string) {
+	if c.multi != nil {
+		w.error("ERR MULTI calls can not be nested")
+		return
+	}
+	c.multi = [][]string{}
+	c.multiErr = false
+	w.ok()
+}
+
+// cmdExec 依次执行排队的命令; 排队时出错返回 EXECABORT, WATCH 的 key 被修改时返回空数组
+func cmdExec(c *conn, w *writer, args []string) {
+	if c.multi == nil {
+		w.error("ERR EXEC without MULTI")
+		return
+	}
+	queued, failed, watched := c.multi, c.multiErr, c.watched
+	c.multi, c.multiErr, c.watched = nil, false, nil
+	if failed {
+		w.error("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+	for key, version := range watched {
+		c.server.lookup(key.db, key.key) // 已过期的 key 视为被修改
+		if c.server.dbs[key.db].versions[key.key] != version {
+			w.nullArray()
+			return
+		}
+	}
+
+	c.inExec = true
+	defer func() { c.inExec = false }()
+	w.array(len(queued))
+	for _, queuedArgs := range queued {
+		c.call(commands[strings.ToLower(queuedArgs[0])], w, queuedArgs)
+	}
+}
+
+func cmdDiscard(c *conn, w *writer, args []string) {
+	if c.multi == nil {
+		w.error("ERR DISCARD without MULTI")
+		return
+	}
+	c.multi, c.multiErr, c.watched = nil, false, nil
+	w.ok()
+}
+
+func cmdWatch(c *conn, w *writer, args []string) {
+	if c.multi != nil {
+		w.error("ERR WATCH inside MULTI is not allowed")
+		return
+	}
+	if c.watched == nil {
+		c.watched = make(map[watchKey]uint64)
+	}
+	for _, key := range args[1:] {
+		c.server.lookup(c.db, key)
+		c.watched[watchKey{db: c.db, key: key}] = c.server.dbs[c.db].versions[key]
+	}
+	w.ok()
+}
+
+func cmdUnwatch(c *conn, w *writer, args []string) {
+	c.watched = nil
+	w.ok()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
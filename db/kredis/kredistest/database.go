@@ -0,0 +1,417 @@
+package kredistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kindString = "string"
+	kindHash   = "hash"
+	kindList   = "list"
+	kindSet    = "set"
+	kindZSet   = "zset"
+	kindStream = "stream"
+
+	errWrongType  = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	errNotInteger = "ERR value is not an integer or out of range"
+	errNotFloat   = "ERR value is not a valid float"
+	errSyntax     = "ERR syntax error"
+	errNoSuchKey  = "ERR no such key"
+	errOutOfRange = "ERR index out of range"
+
+	maxCursors       = 4096 // 游标表的容量, 超过时清空, 被丢弃的游标从头开始遍历
+	defaultScanCount = 10
+)
+
+// item 一个 key 的值, kind 决定使用哪个字段
+type item struct {
+	kind    string
+	str     string
+	hash    map[string]string
+	list    []string
+	set     map[string]struct{}
+	zset    map[string]float64
+	stream  *streamValue
+	expires time.Time // 零值表示永不过期
+}
+
+func newItem(kind string) *item {
+	that := &item{kind: kind}
+	switch kind {
+	case kindHash:
+		that.hash = make(map[string]string)
+	case kindSet:
+		that.set = make(map[string]struct{})
+	case kindZSet:
+		that.zset = make(map[string]float64)
+	case kindStream:
+		that.stream = &streamValue{groups: make(map[string]*streamGroup)}
+	}
+	return that
+}
+
+// empty 容器类型没有元素时应删除 key, 与 Redis 相同 Stream 为空时保留
+func (that *item) empty() bool {
+	switch that.kind {
+	case kindHash:
+		return len(that.hash) == 0
+	case kindList:
+		return len(that.list) == 0
+	case kindSet:
+		return len(that.set) == 0
+	case kindZSet:
+		return len(that.zset) == 0
+	}
+	return false
+}
+
+type database struct {
+	items    map[string]*item
+	versions map[string]uint64
+}
+
+func newDatabase() *database {
+	return &database{
+		items:    make(map[string]*item),
+		versions: make(map[string]uint64),
+	}
+}
+
+// lookup 返回 key 的值, 不存在时返回 nil, 已过期的 key 在此时删除
+func (that *Server) lookup(db int, key string) *item {
+	it := that.dbs[db].items[key]
+	if it == nil {
+		return nil
+	}
+	if !it.expires.IsZero() && !that.now().Before(it.expires) {
+		that.expire(db, key)
+		return nil
+	}
+	return it
+}
+
+// expire 删除到期的 key 并发送 expired 通知
+func (that *Server) expire(db int, key string) {
+	delete(that.dbs[db].items, key)
+	that.touch(db, key)
+	that.notify(db, 'x', "expired", key)
+}
+
+// touch 更新 key 的版本号, 使 WATCH 了该 key 的事务失败
+func (that *Server) touch(db int, key string) {
+	that.version++
+	that.dbs[db].versions[key] = that.version
+}
+
+func (that *Server) flush(db int) {
+	for key := range that.dbs[db].items {
+		that.touch(db, key)
+	}
+	that.dbs[db].items = make(map[string]*item)
+}
+
+// sweep 删除所有到期的 key
+func (that *Server) sweep() {
+	now := that.now()
+	for db := range that.dbs {
+		for key, it := range that.dbs[db].items {
+			if !it.expires.IsZero() && !now.Before(it.expires) {
+				that.expire(db, key)
+			}
+		}
+	}
+}
+
+// keys 返回未过期的 key, 按字典序排列
+func (that *Server) keys(db int) []string {
+	now := that.now()
+	keys := make([]string, 0, len(that.dbs[db].items))
+	for key, it := range that.dbs[db].items {
+		if it.expires.IsZero() || now.Before(it.expires) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lookupKind 返回指定类型的值, key 不存在时返回 (nil, true), 类型不符时写入 WRONGTYPE 并返回 false
+func (that *conn) lookupKind(w *writer, key string, kind string) (*item, bool) {
+	it := that.server.lookup(that.db, key)
+	if it != nil && it.kind != kind {
+		w.error(errWrongType)
+		return nil, false
+	}
+	return it, true
+}
+
+// lookupOrCreate 返回指定类型的值, key 不存在时创建
+func (that *conn) lookupOrCreate(w *writer, key string, kind string) (*item, bool) {
+	it, ok := that.lookupKind(w, key, kind)
+	if !ok {
+		return nil, false
+	}
+	if it == nil {
+		it = newItem(kind)
+		that.server.dbs[that.db].items[key] = it
+	}
+	return it, true
+}
+
+// store 写入新值, 覆盖原有的值与过期时间
+func (that *conn) store(key string, it *item) {
+	that.server.dbs[that.db].items[key] = it
+}
+
+// remove 删除 key, 返回 key 是否存在
+func (that *conn) remove(key string) bool {
+	if that.server.lookup(that.db, key) == nil {
+		return false
+	}
+	delete(that.server.dbs[that.db].items, key)
+	return true
+}
+
+// removeIfEmpty 容器中最后一个元素被删除后删除 key
+func (that *conn) removeIfEmpty(key string, it *item) {
+	if it.empty() {
+		delete(that.server.dbs[that.db].items, key)
+		that.notify('g', "del", key)
+	}
+}
+
+func (that *conn) notify(class byte, event string, key string) {
+	that.server.notify(that.db, class, event, key)
+}
+
+// notify 按 notify-keyspace-events 配置发送键空间通知, class 为事件所属的类别标志
+func (that *Server) notify(db int, class byte, event string, key string) {
+	flags := that.config["notify-keyspace-events"]
+	if flags == "" {
+		return
+	}
+	// A 是 g$lshztxe 的别名
+	if strings.IndexByte(flags, class) < 0 && (strings.IndexByte(flags, 'A') < 0 || strings.IndexByte("g$lshztxe", class) < 0) {
+		return
+	}
+	dbNum := strconv.Itoa(db)
+	if strings.Contains(flags, "K") {
+		that.publish("__keyspace@"+dbNum+"__:"+key, event, false)
+	}
+	if strings.Contains(flags, "E") {
+		that.publish("__keyevent@"+dbNum+"__:"+event, key, false)
+	}
+}
+
+// expireAt 根据相对时间计算过期时刻, 单位由 unit 指定
+func (that *Server) expireAt(n int64, unit time.Duration) time.Time {
+	return that.now().Add(time.Duration(n) * unit)
+}
+
+// unixTime 绝对时间戳转换为过期时刻
+func unixTime(n int64, unit time.Duration) time.Time {
+	if unit == time.Second {
+		return time.Unix(n, 0)
+	}
+	return time.UnixMilli(n)
+}
+
+// scanPage 从游标处按字典序返回最多 count 个元素, 返回下一页的游标, 0 表示遍历结束.
+// 游标记录上一页的最后一个元素, 遍历期间一直存在的元素一定会被返回且只返回一次
+func (that *Server) scanPage(cursor uint64, names []string, count int) ([]string, uint64) {
+	start := 0
+	if last, ok := that.cursors[cursor]; ok && cursor != 0 {
+		start = sort.SearchStrings(names, last)
+		if start < len(names) && names[start] == last {
+			start++
+		}
+	}
+	end := min(start+count, len(names))
+	page := names[start:end]
+	if end >= len(names) {
+		return page, 0
+	}
+
+	if len(that.cursors) >= maxCursors {
+		clear(that.cursors)
+	}
+	that.cursorID++
+	that.cursors[that.cursorID] = names[end-1]
+	return page, that.cursorID
+}
+
+// parseScanArgs 解析 SCAN 系列命令的 cursor [MATCH pattern] [COUNT count] [TYPE type]
+func parseScanArgs(w *writer, args []string, allowType bool) (cursor uint64, match string, count int, kind string, ok bool) {
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		w.error("ERR invalid cursor")
+		return 0, "", 0, "", false
+	}
+	count = defaultScanCount
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			w.error(errSyntax)
+			return 0, "", 0, "", false
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "COUNT":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				w.error(errNotInteger)
+				return 0, "", 0, "", false
+			}
+			if n < 1 {
+				w.error(errSyntax)
+				return 0, "", 0, "", false
+			}
+			count = n
+		case "TYPE":
+			if !allowType {
+				w.error(errSyntax)
+				return 0, "", 0, "", false
+			}
+			kind = strings.ToLower(args[i+1])
+		default:
+			w.error(errSyntax)
+			return 0, "", 0, "", false
+		}
+	}
+	return cursor, match, count, kind, true
+}
+
+// matchGlob 与 Redis stringmatchlen 相同的通配符匹配, 支持 * ? [abc] [^abc] [a-z] 与 \ 转义
+func matchGlob(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			matched := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				switch {
+				case pattern[0] == '\\' && len(pattern) >= 2:
+					matched = matched || pattern[1] == s[0]
+					pattern = pattern[2:]
+				case len(pattern) >= 3 && pattern[1] == '-':
+					lo, hi := min(pattern[0], pattern[2]), max(pattern[0], pattern[2])
+					matched = matched || (s[0] >= lo && s[0] <= hi)
+					pattern = pattern[3:]
+				default:
+					matched = matched || pattern[0] == s[0]
+					pattern = pattern[1:]
+				}
+			}
+			if matched == not {
+				return false
+			}
+			if len(pattern) > 0 { // 跳过 ']', 缺少 ']' 时视为到达模式末尾
+				pattern = pattern[1:]
+			}
+			s = s[1:]
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+func parseInt(w *writer, s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		w.error(errNotInteger)
+		return 0, false
+	}
+	return n, true
+}
+
+func parseFloat(w *writer, s string) (float64, bool) {
+	f, err := parseScore(s)
+	if err != nil {
+		w.error(errNotFloat)
+		return 0, false
+	}
+	return f, true
+}
+
+// parseScore 解析浮点数, 支持 inf/+inf/-inf, 不接受 NaN
+func parseScore(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		err = strconv.ErrSyntax
+	}
+	return f, err
+}
+
+// parseTimeout 解析阻塞命令的超时时间, 单位 秒, 可以是小数
+func parseTimeout(w *writer, s string) (time.Duration, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		w.error("ERR timeout is not a float or out of range")
+		return 0, false
+	}
+	if f < 0 {
+		w.error("ERR timeout is negative")
+		return 0, false
+	}
+	return time.Duration(f * float64(time.Second)), true
+}
+
+// normalizeRange 将可以为负数的下标范围转换为 [start, stop], 范围为空时返回 false
+func normalizeRange(start int64, stop int64, n int) (int, int, bool) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	start = max(start, 0)
+	if start > stop || start >= int64(n) {
+		return 0, 0, false
+	}
+	stop = min(stop, int64(n)-1)
+	return int(start), int(stop), true
+}
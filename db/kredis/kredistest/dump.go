@@ -0,0 +1,202 @@
+package kredistest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dumpMagic DUMP 序列化格式的前缀; 格式与 Redis 的 RDB 不兼容, 只能在 kredistest 之间 RESTORE
+const dumpMagic = "KRT1"
+
+const errBadPayload = "ERR DUMP payload version or checksum are wrong"
+
+// dumpValue DUMP 的序列化内容, 不包含过期时间
+type dumpValue struct {
+	Kind   string             `json:"kind"`
+	Str    string             `json:"str,omitempty"`
+	Hash   map[string]string  `json:"hash,omitempty"`
+	List   []string           `json:"list,omitempty"`
+	Set    []string           `json:"set,omitempty"`
+	ZSet   map[string]float64 `json:"zset,omitempty"`
+	Stream *dumpStream        `json:"stream,omitempty"`
+}
+
+type dumpStream struct {
+	LastID  string              `json:"lastID"`
+	Entries []dumpStreamEntry   `json:"entries"`
+	Groups  map[string][]string `json:"groups"` // 消费组 -> [lastID, 待确认 ID, 消费者, ...]
+}
+
+type dumpStreamEntry struct {
+	ID     string   `json:"id"`
+	Fields []string `json:"fields"`
+}
+
+// encodeItem 序列化为 magic + JSON + CRC32
+func encodeItem(it *item) string {
+	v := dumpValue{Kind: it.kind, Str: it.str, Hash: it.hash, List: it.list, ZSet: it.zset}
+	for member := range it.set {
+		v.Set = append(v.Set, member)
+	}
+	if it.stream != nil {
+		s := &dumpStream{LastID: it.stream.lastID.String(), Groups: make(map[string][]string)}
+		for _, entry := range it.stream.entries {
+			s.Entries = append(s.Entries, dumpStreamEntry{ID: entry.id.String(), Fields: entry.fields})
+		}
+		for name, group := range it.stream.groups {
+			g := []string{group.lastID.String()}
+			for _, id := range group.pendingIDs("") {
+				g = append(g, id.String(), group.pending[id].consumer)
+			}
+			s.Groups[name] = g
+		}
+		v.Stream = s
+	}
+	body, _ := json.Marshal(v)
+	payload := append([]byte(dumpMagic), body...)
+	return string(binary.BigEndian.AppendUint32(payload, crc32.ChecksumIEEE(payload)))
+}
+
+// decodeItem 反序列化 encodeItem 的结果, 校验失败返回 false
+func decodeItem(payload string, now time.Time) (*item, bool) {
+	if len(payload) < len(dumpMagic)+4 || !strings.HasPrefix(payload, dumpMagic) {
+		return nil, false
+	}
+	body, sum := payload[:len(payload)-4], payload[len(payload)-4:]
+	if crc32.ChecksumIEEE([]byte(body)) != binary.BigEndian.Uint32([]byte(sum)) {
+		return nil, false
+	}
+	var v dumpValue
+	if err := json.Unmarshal([]byte(body[len(dumpMagic):]), &v); err != nil {
+		return nil, false
+	}
+
+	it := newItem(v.Kind)
+	switch v.Kind {
+	case kindString:
+		it.str = v.Str
+	case kindHash:
+		for field, value := range v.Hash {
+			it.hash[field] = value
+		}
+	case kindList:
+		it.list = v.List
+	case kindSet:
+		for _, member := range v.Set {
+			it.set[member] = struct{}{}
+		}
+	case kindZSet:
+		for member, score := range v.ZSet {
+			it.zset[member] = score
+		}
+	case kindStream:
+		if v.Stream == nil {
+			return nil, false
+		}
+		var ok bool
+		if it.stream.lastID, ok = parseStreamID(v.Stream.LastID, 0); !ok {
+			return nil, false
+		}
+		for _, entry := range v.Stream.Entries {
+			id, ok := parseStreamID(entry.ID, 0)
+			if !ok {
+				return nil, false
+			}
+			it.stream.entries = append(it.stream.entries, streamEntry{id: id, fields: entry.Fields})
+		}
+		for name, g := range v.Stream.Groups {
+			if len(g)%2 != 1 {
+				return nil, false
+			}
+			lastID, ok := parseStreamID(g[0], 0)
+			if !ok {
+				return nil, false
+			}
+			group := &streamGroup{lastID: lastID, pending: make(map[streamID]*pendingEntry), consumers: make(map[string]time.Time)}
+			for i := 1; i < len(g); i += 2 {
+				id, ok := parseStreamID(g[i], 0)
+				if !ok {
+					return nil, false
+				}
+				group.pending[id] = &pendingEntry{consumer: g[i+1], delivered: now, count: 1}
+				group.consumers[g[i+1]] = now
+			}
+			it.stream.groups[name] = group
+		}
+	default:
+		return nil, false
+	}
+	return it, true
+}
+
+// cmdDump DUMP key, key 不存在时返回空值
+func cmdDump(c *conn, w *writer, args []string) {
+	it := c.server.lookup(c.db, args[1])
+	if it == nil {
+		w.null()
+		return
+	}
+	w.bulk(encodeItem(it))
+}
+
+// cmdRestore RESTORE key ttl serialized-value [REPLACE] [ABSTTL] [IDLETIME seconds] [FREQ frequency]
+func cmdRestore(c *conn, w *writer, args []string) {
+	ttl, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		w.error(errNotInteger)
+		return
+	}
+	if ttl < 0 {
+		w.error("ERR Invalid TTL value, must be >= 0")
+		return
+	}
+	replace, absTTL := false, false
+	for i := 4; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "REPLACE":
+			replace = true
+		case opt == "ABSTTL":
+			absTTL = true
+		case (opt == "IDLETIME" || opt == "FREQ") && i+1 < len(args):
+			if _, ok := parseInt(w, args[i+1]); !ok {
+				return
+			}
+			i++
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+
+	key := args[1]
+	if !replace && c.server.lookup(c.db, key) != nil {
+		w.error("BUSYKEY Target key name already exists.")
+		return
+	}
+	it, ok := decodeItem(args[3], c.server.now())
+	if !ok {
+		w.error(errBadPayload)
+		return
+	}
+	if ttl > 0 {
+		if absTTL {
+			it.expires = unixTime(ttl, time.Millisecond)
+		} else {
+			it.expires = c.server.expireAt(ttl, time.Millisecond)
+		}
+		if !it.expires.After(c.server.now()) { // 已经过期的值不写入, 与 Redis 相同只删除原有的 key
+			if c.remove(key) {
+				c.notify('g', "del", key)
+			}
+			w.ok()
+			return
+		}
+	}
+	c.store(key, it)
+	c.notify('g', "restore", key)
+	w.ok()
+}
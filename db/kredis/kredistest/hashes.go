@@ -0,0 +1,283 @@
+package kredistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cmdHSet HSET/HMSET key field value [field value ...], HSET 返回新增字段数, HMSET 返回 OK
+func cmdHSet(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	if len(args)%2 != 0 {
+		w.errorf("ERR wrong number of arguments for '%s' command", name)
+		return
+	}
+	it, ok := c.lookupOrCreate(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	added := int64(0)
+	for i := 2; i < len(args); i += 2 {
+		if _, exists := it.hash[args[i]]; !exists {
+			added++
+		}
+		it.hash[args[i]] = args[i+1]
+	}
+	c.notify('h', "hset", args[1])
+	if name == "hmset" {
+		w.ok()
+		return
+	}
+	w.integer(added)
+}
+
+func cmdHSetNX(c *conn, w *writer, args []string) {
+	it, ok := c.lookupOrCreate(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if _, exists := it.hash[args[2]]; exists {
+		w.integer(0)
+		return
+	}
+	it.hash[args[2]] = args[3]
+	c.notify('h', "hset", args[1])
+	w.integer(1)
+}
+
+func cmdHGet(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.null()
+		return
+	}
+	value, exists := it.hash[args[2]]
+	if !exists {
+		w.null()
+		return
+	}
+	w.bulk(value)
+}
+
+func cmdHMGet(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	w.array(len(args) - 2)
+	for _, field := range args[2:] {
+		if it == nil {
+			w.null()
+			continue
+		}
+		value, exists := it.hash[field]
+		if !exists {
+			w.null()
+			continue
+		}
+		w.bulk(value)
+	}
+}
+
+func cmdHGetAll(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.mapHeader(0)
+		return
+	}
+	fields := sortedFields(it.hash)
+	w.mapHeader(len(fields))
+	for _, field := range fields {
+		w.bulk(field)
+		w.bulk(it.hash[field])
+	}
+}
+
+func cmdHDel(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	deleted := int64(0)
+	for _, field := range args[2:] {
+		if _, exists := it.hash[field]; exists {
+			delete(it.hash, field)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		c.notify('h', "hdel", args[1])
+		c.removeIfEmpty(args[1], it)
+	}
+	w.integer(deleted)
+}
+
+func cmdHExists(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	_, exists := it.hash[args[2]]
+	w.boolean(exists)
+}
+
+func cmdHLen(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(it.hash)))
+}
+
+// cmdHKeys HKEYS/HVALS, 按字段名排序
+func cmdHKeys(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.array(0)
+		return
+	}
+	fields := sortedFields(it.hash)
+	if strings.EqualFold(args[0], "hvals") {
+		for i, field := range fields {
+			fields[i] = it.hash[field]
+		}
+	}
+	w.bulks(fields)
+}
+
+func cmdHStrLen(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(it.hash[args[2]])))
+}
+
+func cmdHIncrBy(c *conn, w *writer, args []string) {
+	delta, ok := parseInt(w, args[3])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	if it != nil {
+		if value, exists := it.hash[args[2]]; exists {
+			var err error
+			if n, err = strconv.ParseInt(value, 10, 64); err != nil {
+				w.error("ERR hash value is not an integer")
+				return
+			}
+		}
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		w.error("ERR increment or decrement would overflow")
+		return
+	}
+	n += delta
+	c.hashSet(args[1], it, args[2], strconv.FormatInt(n, 10))
+	c.notify('h', "hincrby", args[1])
+	w.integer(n)
+}
+
+func cmdHIncrByFloat(c *conn, w *writer, args []string) {
+	delta, ok := parseFloat(w, args[3])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	f := 0.0
+	if it != nil {
+		if value, exists := it.hash[args[2]]; exists {
+			var err error
+			if f, err = parseScore(value); err != nil {
+				w.error("ERR hash value is not a float")
+				return
+			}
+		}
+	}
+	f += delta
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		w.error("ERR increment would produce NaN or Infinity")
+		return
+	}
+	c.hashSet(args[1], it, args[2], formatFloat(f))
+	c.notify('h', "hincrbyfloat", args[1])
+	w.bulk(formatFloat(f))
+}
+
+// hashSet 设置字段, it 为 nil 时创建 key
+func (that *conn) hashSet(key string, it *item, field string, value string) {
+	if it == nil {
+		it = newItem(kindHash)
+		that.store(key, it)
+	}
+	it.hash[field] = value
+}
+
+// cmdHScan HSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdHScan(c *conn, w *writer, args []string) {
+	cursor, match, count, _, ok := parseScanArgs(w, args[2:], false)
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindHash)
+	if !ok {
+		return
+	}
+	var fields []string
+	if it != nil {
+		fields = sortedFields(it.hash)
+	}
+	page, next := c.server.scanPage(cursor, fields, count)
+	values := make([]string, 0, len(page)*2)
+	for _, field := range page {
+		if match == "" || matchGlob(match, field) {
+			values = append(values, field, it.hash[field])
+		}
+	}
+	w.array(2)
+	w.bulk(strconv.FormatUint(next, 10))
+	w.bulks(values)
+}
+
+func sortedFields[V any](m map[string]V) []string {
+	fields := make([]string, 0, len(m))
+	for field := range m {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
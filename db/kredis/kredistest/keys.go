@@ -0,0 +1,202 @@
+package kredistest
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func cmdDel(c *conn, w *writer, args []string) {
+	deleted := int64(0)
+	for _, key := range args[1:] {
+		if c.remove(key) {
+			c.notify('g', "del", key)
+			deleted++
+		}
+	}
+	w.integer(deleted)
+}
+
+// cmdExists 同时用于 TOUCH, 重复的 key 重复计数
+func cmdExists(c *conn, w *writer, args []string) {
+	count := int64(0)
+	for _, key := range args[1:] {
+		if c.server.lookup(c.db, key) != nil {
+			count++
+		}
+	}
+	w.integer(count)
+}
+
+func cmdType(c *conn, w *writer, args []string) {
+	it := c.server.lookup(c.db, args[1])
+	if it == nil {
+		w.status("none")
+		return
+	}
+	w.status(it.kind)
+}
+
+// cmdExpire EXPIRE/PEXPIRE/EXPIREAT/PEXPIREAT key time [NX|XX|GT|LT], 过期时间不晚于当前时间时删除 key
+func cmdExpire(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	n, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	var nx, xx, gt, lt bool
+	for _, opt := range args[3:] {
+		switch strings.ToUpper(opt) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		default:
+			w.errorf("ERR Unsupported option %s", opt)
+			return
+		}
+	}
+	if nx && (xx || gt || lt) {
+		w.error("ERR NX and XX, GT or LT options at the same time are not compatible")
+		return
+	}
+	if gt && lt {
+		w.error("ERR GT and LT options at the same time are not compatible")
+		return
+	}
+
+	unit := time.Second
+	if strings.HasPrefix(name, "p") {
+		unit = time.Millisecond
+	}
+	var at time.Time
+	if strings.HasSuffix(name, "at") {
+		at = unixTime(n, unit)
+	} else {
+		at = c.server.expireAt(n, unit)
+	}
+
+	it := c.server.lookup(c.db, args[1])
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	// 没有过期时间的 key 视为无限长, GT 永远不成立, LT 永远成立
+	if (nx && !it.expires.IsZero()) || (xx && it.expires.IsZero()) ||
+		(gt && (it.expires.IsZero() || !at.After(it.expires))) || (lt && !it.expires.IsZero() && !at.Before(it.expires)) {
+		w.integer(0)
+		return
+	}
+	if !at.After(c.server.now()) {
+		delete(c.server.dbs[c.db].items, args[1])
+		c.notify('g', "del", args[1])
+	} else {
+		it.expires = at
+		c.notify('g', "expire", args[1])
+	}
+	w.integer(1)
+}
+
+// cmdTTL TTL/PTTL 返回剩余时间, EXPIRETIME/PEXPIRETIME 返回过期时间戳; key 不存在返回 -2, 没有过期时间返回 -1
+func cmdTTL(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	it := c.server.lookup(c.db, args[1])
+	switch {
+	case it == nil:
+		w.integer(-2)
+	case it.expires.IsZero():
+		w.integer(-1)
+	case name == "ttl":
+		w.integer((it.expires.Sub(c.server.now()).Milliseconds() + 500) / 1000)
+	case name == "pttl":
+		w.integer(it.expires.Sub(c.server.now()).Milliseconds())
+	case name == "expiretime":
+		w.integer(it.expires.Unix())
+	default:
+		w.integer(it.expires.UnixMilli())
+	}
+}
+
+func cmdPersist(c *conn, w *writer, args []string) {
+	it := c.server.lookup(c.db, args[1])
+	if it == nil || it.expires.IsZero() {
+		w.integer(0)
+		return
+	}
+	it.expires = time.Time{}
+	c.notify('g', "persist", args[1])
+	w.integer(1)
+}
+
+func cmdKeys(c *conn, w *writer, args []string) {
+	keys := c.server.keys(c.db)
+	matched := keys[:0]
+	for _, key := range keys {
+		if matchGlob(args[1], key) {
+			matched = append(matched, key)
+		}
+	}
+	w.bulks(matched)
+}
+
+// cmdScan SCAN cursor [MATCH pattern] [COUNT count] [TYPE type], 与 Redis 一样先取 COUNT 个 key 再过滤, 一页可能少于 COUNT 个
+func cmdScan(c *conn, w *writer, args []string) {
+	cursor, match, count, kind, ok := parseScanArgs(w, args[1:], true)
+	if !ok {
+		return
+	}
+	page, next := c.server.scanPage(cursor, c.server.keys(c.db), count)
+	keys := make([]string, 0, len(page))
+	for _, key := range page {
+		if match != "" && !matchGlob(match, key) {
+			continue
+		}
+		if kind != "" && c.server.dbs[c.db].items[key].kind != kind {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	w.array(2)
+	w.bulk(strconv.FormatUint(next, 10))
+	w.bulks(keys)
+}
+
+func cmdRandomKey(c *conn, w *writer, args []string) {
+	keys := c.server.keys(c.db)
+	if len(keys) == 0 {
+		w.null()
+		return
+	}
+	w.bulk(keys[rand.Intn(len(keys))])
+}
+
+// cmdRename RENAME/RENAMENX key newkey, 保留过期时间
+func cmdRename(c *conn, w *writer, args []string) {
+	key, newKey := args[1], args[2]
+	it := c.server.lookup(c.db, key)
+	if it == nil {
+		w.error(errNoSuchKey)
+		return
+	}
+	nx := strings.EqualFold(args[0], "renamenx")
+	if nx && c.server.lookup(c.db, newKey) != nil {
+		w.integer(0)
+		return
+	}
+	if key != newKey {
+		delete(c.server.dbs[c.db].items, key)
+		c.store(newKey, it)
+		c.notify('g', "rename_from", key)
+		c.notify('g', "rename_to", newKey)
+	}
+	if nx {
+		w.integer(1)
+	} else {
+		w.ok()
+	}
+}
@@ -0,0 +1,426 @@
+package kredistest
+
+import (
+	"slices"
+	"strings"
+)
+
+// cmdPush LPUSH/RPUSH/LPUSHX/RPUSHX key element [element ...], X 版本只在 key 存在时插入
+func cmdPush(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		if strings.HasSuffix(name, "x") {
+			w.integer(0)
+			return
+		}
+		it = newItem(kindList)
+		c.store(args[1], it)
+	}
+	for _, value := range args[2:] {
+		if name[0] == 'l' {
+			it.list = slices.Insert(it.list, 0, value)
+		} else {
+			it.list = append(it.list, value)
+		}
+	}
+	c.notify('l', name[:5], args[1])
+	w.integer(int64(len(it.list)))
+}
+
+// listPop 弹出一个元素, 列表为空时删除 key
+func (that *conn) listPop(key string, it *item, left bool) string {
+	var value string
+	if left {
+		value, it.list = it.list[0], it.list[1:]
+		that.notify('l', "lpop", key)
+	} else {
+		value, it.list = it.list[len(it.list)-1], it.list[:len(it.list)-1]
+		that.notify('l', "rpop", key)
+	}
+	that.removeIfEmpty(key, it)
+	return value
+}
+
+// cmdPop LPOP/RPOP key [count]
+func cmdPop(c *conn, w *writer, args []string) {
+	if len(args) > 3 {
+		w.error(errSyntax)
+		return
+	}
+	count := int64(-1)
+	if len(args) == 3 {
+		n, ok := parseInt(w, args[2])
+		if !ok {
+			return
+		}
+		if n < 0 {
+			w.error("ERR value is out of range, must be positive")
+			return
+		}
+		count = n
+	}
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		if count < 0 {
+			w.null()
+		} else {
+			w.nullArray()
+		}
+		return
+	}
+
+	left := strings.EqualFold(args[0], "lpop")
+	if count < 0 {
+		w.bulk(c.listPop(args[1], it, left))
+		return
+	}
+	values := make([]string, 0, min(count, int64(len(it.list))))
+	for int64(len(values)) < count && len(it.list) > 0 {
+		values = append(values, c.listPop(args[1], it, left))
+	}
+	w.bulks(values)
+}
+
+// cmdBPop BLPOP/BRPOP key [key ...] timeout, 从第一个非空列表弹出, 都为空时等待
+func cmdBPop(c *conn, w *writer, args []string) {
+	timeout, ok := parseTimeout(w, args[len(args)-1])
+	if !ok {
+		return
+	}
+	keys := args[1 : len(args)-1]
+	for _, key := range keys {
+		if _, ok := c.lookupKind(w, key, kindList); !ok {
+			return
+		}
+	}
+	for _, key := range keys {
+		if it := c.server.lookup(c.db, key); it != nil {
+			w.array(2)
+			w.bulk(key)
+			w.bulk(c.listPop(key, it, strings.EqualFold(args[0], "blpop")))
+			return
+		}
+	}
+	c.block(w, timeout)
+}
+
+// cmdLMove LMOVE/BLMOVE source destination LEFT|RIGHT LEFT|RIGHT [timeout], RPOPLPUSH/BRPOPLPUSH source destination [timeout]
+func cmdLMove(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	blocking := name[0] == 'b'
+	from, to := "RIGHT", "LEFT"
+	if strings.HasSuffix(name, "lmove") {
+		from, to = strings.ToUpper(args[3]), strings.ToUpper(args[4])
+		if (from != "LEFT" && from != "RIGHT") || (to != "LEFT" && to != "RIGHT") {
+			w.error(errSyntax)
+			return
+		}
+	}
+	src, dst := args[1], args[2]
+
+	it, ok := c.lookupKind(w, src, kindList)
+	if !ok {
+		return
+	}
+	if _, ok = c.lookupKind(w, dst, kindList); !ok {
+		return
+	}
+	if it == nil {
+		if !blocking {
+			w.null()
+			return
+		}
+		timeout, ok := parseTimeout(w, args[len(args)-1])
+		if !ok {
+			return
+		}
+		c.block(w, timeout)
+		return
+	}
+
+	value := c.listPop(src, it, from == "LEFT")
+	target, _ := c.lookupOrCreate(w, dst, kindList)
+	if to == "LEFT" {
+		target.list = slices.Insert(target.list, 0, value)
+		c.notify('l', "lpush", dst)
+	} else {
+		target.list = append(target.list, value)
+		c.notify('l', "rpush", dst)
+	}
+	w.bulk(value)
+}
+
+func cmdLLen(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(it.list)))
+}
+
+func cmdLRange(c *conn, w *writer, args []string) {
+	start, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	stop, ok := parseInt(w, args[3])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.array(0)
+		return
+	}
+	from, to, ok := normalizeRange(start, stop, len(it.list))
+	if !ok {
+		w.array(0)
+		return
+	}
+	w.bulks(it.list[from : to+1])
+}
+
+func cmdLIndex(c *conn, w *writer, args []string) {
+	index, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.null()
+		return
+	}
+	if index < 0 {
+		index += int64(len(it.list))
+	}
+	if index < 0 || index >= int64(len(it.list)) {
+		w.null()
+		return
+	}
+	w.bulk(it.list[index])
+}
+
+func cmdLSet(c *conn, w *writer, args []string) {
+	index, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.error(errNoSuchKey)
+		return
+	}
+	if index < 0 {
+		index += int64(len(it.list))
+	}
+	if index < 0 || index >= int64(len(it.list)) {
+		w.error(errOutOfRange)
+		return
+	}
+	it.list[index] = args[3]
+	c.notify('l', "lset", args[1])
+	w.ok()
+}
+
+// cmdLRem LREM key count element, count > 0 从头删除, count < 0 从尾删除, count = 0 删除全部
+func cmdLRem(c *conn, w *writer, args []string) {
+	count, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+
+	removed := int64(0)
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	if count >= 0 {
+		list := it.list[:0]
+		for _, value := range it.list {
+			if value == args[3] && (limit == 0 || removed < limit) {
+				removed++
+				continue
+			}
+			list = append(list, value)
+		}
+		it.list = list
+	} else {
+		for i := len(it.list) - 1; i >= 0 && removed < limit; i-- {
+			if it.list[i] == args[3] {
+				it.list = slices.Delete(it.list, i, i+1)
+				removed++
+			}
+		}
+	}
+	if removed > 0 {
+		c.notify('l', "lrem", args[1])
+		c.removeIfEmpty(args[1], it)
+	}
+	w.integer(removed)
+}
+
+func cmdLTrim(c *conn, w *writer, args []string) {
+	start, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	stop, ok := parseInt(w, args[3])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.ok()
+		return
+	}
+	if from, to, ok := normalizeRange(start, stop, len(it.list)); ok {
+		it.list = slices.Clone(it.list[from : to+1])
+	} else {
+		it.list = nil
+	}
+	c.notify('l', "ltrim", args[1])
+	c.removeIfEmpty(args[1], it)
+	w.ok()
+}
+
+// cmdLInsert LINSERT key BEFORE|AFTER pivot element, 找不到 pivot 返回 -1
+func cmdLInsert(c *conn, w *writer, args []string) {
+	where := strings.ToUpper(args[2])
+	if where != "BEFORE" && where != "AFTER" {
+		w.error(errSyntax)
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	i := slices.Index(it.list, args[3])
+	if i < 0 {
+		w.integer(-1)
+		return
+	}
+	if where == "AFTER" {
+		i++
+	}
+	it.list = slices.Insert(it.list, i, args[4])
+	c.notify('l', "linsert", args[1])
+	w.integer(int64(len(it.list)))
+}
+
+// cmdLPos LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func cmdLPos(c *conn, w *writer, args []string) {
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 3; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			w.error(errSyntax)
+			return
+		}
+		n, ok := parseInt(w, args[i+1])
+		if !ok {
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				w.error("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+				return
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				w.error("ERR COUNT can't be negative")
+				return
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				w.error("ERR MAXLEN can't be negative")
+				return
+			}
+			maxLen = n
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+
+	it, ok := c.lookupKind(w, args[1], kindList)
+	if !ok {
+		return
+	}
+	var matches []int64
+	if it != nil {
+		skip := max(rank, -rank) - 1
+		limit := count
+		if limit == 0 {
+			limit = int64(len(it.list))
+		} else if limit < 0 {
+			limit = 1
+		}
+		for scanned := int64(0); scanned < int64(len(it.list)) && int64(len(matches)) < limit; scanned++ {
+			if maxLen > 0 && scanned >= maxLen {
+				break
+			}
+			i := scanned
+			if rank < 0 {
+				i = int64(len(it.list)) - 1 - scanned
+			}
+			if it.list[i] != args[2] {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			matches = append(matches, i)
+		}
+	}
+
+	if count < 0 {
+		if len(matches) == 0 {
+			w.null()
+			return
+		}
+		w.integer(matches[0])
+		return
+	}
+	w.array(len(matches))
+	for _, i := range matches {
+		w.integer(i)
+	}
+}
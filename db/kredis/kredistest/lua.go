@@ -0,0 +1,792 @@
+package kredistest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 本文件实现 Redis 脚本使用的 Lua 5.1 子集的词法与语法分析: 局部变量, 全局只读, 表, 闭包,
+// if/while/repeat/数值与泛型 for, 多返回值, 方法调用语法; 不支持元表, 协程与模式匹配
+
+const (
+	tokEOF = iota
+	tokName
+	tokNumber
+	tokString
+	tokSymbol // 关键字与运算符
+)
+
+type luaToken struct {
+	kind int
+	text string // 名字, 字符串内容或符号
+	num  float64
+	line int
+}
+
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "if": true, "in": true, "local": true,
+	"nil": true, "not": true, "or": true, "repeat": true, "return": true, "then": true,
+	"true": true, "until": true, "while": true,
+}
+
+// luaSyntaxError 编译错误, 与 Redis 一样以 user_script:行号 开头
+type luaSyntaxError struct {
+	line int
+	msg  string
+}
+
+func (that *luaSyntaxError) Error() string {
+	return "user_script:" + strconv.Itoa(that.line) + ": " + that.msg
+}
+
+type luaLexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (that *luaLexer) errorf(format string, args ...any) {
+	panic(&luaSyntaxError{line: that.line, msg: fmt.Sprintf(format, args...)})
+}
+
+func (that *luaLexer) peekByte(offset int) byte {
+	if that.pos+offset < len(that.src) {
+		return that.src[that.pos+offset]
+	}
+	return 0
+}
+
+// next 读取下一个 token, 语法错误时 panic(*luaSyntaxError), 由 compileLua 恢复
+func (that *luaLexer) next() luaToken {
+	that.skipSpace()
+	if that.pos >= len(that.src) {
+		return luaToken{kind: tokEOF, line: that.line}
+	}
+
+	line := that.line
+	c := that.src[that.pos]
+	switch {
+	case c == '_' || isLetter(c):
+		start := that.pos
+		for that.pos < len(that.src) && (that.src[that.pos] == '_' || isLetter(that.src[that.pos]) || isDigit(that.src[that.pos])) {
+			that.pos++
+		}
+		word := that.src[start:that.pos]
+		if luaKeywords[word] {
+			return luaToken{kind: tokSymbol, text: word, line: line}
+		}
+		return luaToken{kind: tokName, text: word, line: line}
+	case isDigit(c) || (c == '.' && isDigit(that.peekByte(1))):
+		return that.number()
+	case c == '"' || c == '\'':
+		return luaToken{kind: tokString, text: that.quoted(c), line: line}
+	case c == '[' && (that.peekByte(1) == '[' || that.peekByte(1) == '='):
+		if s, ok := that.longBracket(); ok {
+			return luaToken{kind: tokString, text: s, line: line}
+		}
+	}
+
+	for _, symbol := range []string{"...", "..", "==", "~=", "<=", ">="} {
+		if strings.HasPrefix(that.src[that.pos:], symbol) {
+			that.pos += len(symbol)
+			return luaToken{kind: tokSymbol, text: symbol, line: line}
+		}
+	}
+	if strings.IndexByte("+-*/%^#<>=(){}[];:,.", c) >= 0 {
+		that.pos++
+		return luaToken{kind: tokSymbol, text: string(c), line: line}
+	}
+	that.errorf("unexpected symbol near '%c'", c)
+	return luaToken{}
+}
+
+func (that *luaLexer) skipSpace() {
+	for that.pos < len(that.src) {
+		c := that.src[that.pos]
+		switch {
+		case c == '\n':
+			that.line++
+			that.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			that.pos++
+		case c == '-' && that.peekByte(1) == '-':
+			that.pos += 2
+			if that.peekByte(0) == '[' {
+				if _, ok := that.longBracket(); ok {
+					continue
+				}
+			}
+			for that.pos < len(that.src) && that.src[that.pos] != '\n' {
+				that.pos++
+			}
+		case c == '#' && that.pos == 0: // 首行的 #! 注释
+			for that.pos < len(that.src) && that.src[that.pos] != '\n' {
+				that.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// longBracket 读取 [[...]] 或 [==[...]==], 不是长括号时返回 false 且不移动位置
+func (that *luaLexer) longBracket() (string, bool) {
+	level := 0
+	for that.peekByte(1+level) == '=' {
+		level++
+	}
+	if that.peekByte(1+level) != '[' {
+		return "", false
+	}
+	that.pos += level + 2
+	if that.peekByte(0) == '\r' {
+		that.pos++
+	}
+	if that.peekByte(0) == '\n' { // 紧跟开括号的换行被忽略
+		that.line++
+		that.pos++
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := strings.Index(that.src[that.pos:], closing)
+	if end < 0 {
+		that.errorf("unfinished long string")
+	}
+	s := that.src[that.pos : that.pos+end]
+	that.line += strings.Count(s, "\n")
+	that.pos += end + len(closing)
+	return s, true
+}
+
+func (that *luaLexer) quoted(quote byte) string {
+	that.pos++
+	var b strings.Builder
+	for {
+		if that.pos >= len(that.src) || that.src[that.pos] == '\n' {
+			that.errorf("unfinished string")
+		}
+		c := that.src[that.pos]
+		that.pos++
+		if c == quote {
+			return b.String()
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+
+		if that.pos >= len(that.src) {
+			that.errorf("unfinished string")
+		}
+		c = that.src[that.pos]
+		that.pos++
+		switch c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '\n':
+			that.line++
+			b.WriteByte('\n')
+		case 'x':
+			if that.pos+2 > len(that.src) {
+				that.errorf("hexadecimal digit expected")
+			}
+			n, err := strconv.ParseUint(that.src[that.pos:that.pos+2], 16, 8)
+			if err != nil {
+				that.errorf("hexadecimal digit expected")
+			}
+			b.WriteByte(byte(n))
+			that.pos += 2
+		default:
+			if !isDigit(c) {
+				b.WriteByte(c) // \\ \" \' 等
+				continue
+			}
+			n := int(c - '0')
+			for i := 0; i < 2 && isDigit(that.peekByte(0)); i++ {
+				n = n*10 + int(that.src[that.pos]-'0')
+				that.pos++
+			}
+			if n > 255 {
+				that.errorf("escape sequence too large")
+			}
+			b.WriteByte(byte(n))
+		}
+	}
+}
+
+func (that *luaLexer) number() luaToken {
+	start := that.pos
+	if that.src[that.pos] == '0' && (that.peekByte(1) == 'x' || that.peekByte(1) == 'X') {
+		that.pos += 2
+	}
+	for that.pos < len(that.src) {
+		c := that.src[that.pos]
+		if isDigit(c) || isLetter(c) || (c == '.' && that.peekByte(1) != '.') || ((c == '+' || c == '-') && (that.src[that.pos-1] == 'e' || that.src[that.pos-1] == 'E')) {
+			that.pos++
+			continue
+		}
+		break
+	}
+	text := that.src[start:that.pos]
+	n, ok := parseLuaNumber(text)
+	if !ok {
+		that.errorf("malformed number near '%s'", text)
+	}
+	return luaToken{kind: tokNumber, num: n, text: text, line: that.line}
+}
+
+// parseLuaNumber 解析十进制或十六进制数字, 允许首尾空白, 与 tonumber 相同
+func parseLuaNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	body := strings.TrimLeft(s, "+-")
+	if strings.HasPrefix(body, "0x") || strings.HasPrefix(body, "0X") {
+		n, err := strconv.ParseUint(body[2:], 16, 64)
+		if err != nil {
+			return 0, false
+		}
+		if s[0] == '-' {
+			return -float64(n), true
+		}
+		return float64(n), true
+	}
+	lower := strings.ToLower(body)
+	if strings.HasPrefix(lower, "inf") || strings.HasPrefix(lower, "nan") { // strconv 接受, Lua 不接受
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil && !math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+///////////////////////////////////////////////////////////////
+
+type luaExpr interface{}
+
+type (
+	exConst  struct{ value any }
+	exVararg struct{}
+	exName   struct{ name string }
+	exIndex  struct{ obj, key luaExpr }
+	exParen  struct{ x luaExpr } // 括号把多返回值截断为一个
+	exCall   struct {
+		fn     luaExpr
+		method string // obj:method(args) 中的 method
+		args   []luaExpr
+		line   int
+	}
+	exFunction struct {
+		params []string
+		vararg bool
+		body   *luaBlock
+	}
+	exBinary struct {
+		op   string
+		l, r luaExpr
+		line int
+	}
+	exUnary struct {
+		op   string
+		x    luaExpr
+		line int
+	}
+	exTable struct{ items []tableItem }
+)
+
+type tableItem struct {
+	key   luaExpr // nil 表示按顺序追加
+	value luaExpr
+}
+
+type luaStmt interface{}
+
+type (
+	stLocal struct {
+		names []string
+		exprs []luaExpr
+	}
+	stAssign struct {
+		targets []luaExpr
+		exprs   []luaExpr
+	}
+	stCall  struct{ call *exCall }
+	stDo    struct{ body *luaBlock }
+	stWhile struct {
+		cond luaExpr
+		body *luaBlock
+	}
+	stRepeat struct {
+		body *luaBlock
+		cond luaExpr
+	}
+	stIf struct {
+		conds  []luaExpr
+		blocks []*luaBlock
+		orElse *luaBlock
+	}
+	stNumFor struct {
+		name               string
+		start, limit, step luaExpr
+		body               *luaBlock
+	}
+	stGenFor struct {
+		names []string
+		exprs []luaExpr
+		body  *luaBlock
+	}
+	stLocalFunction struct {
+		name string
+		fn   *exFunction
+	}
+	stReturn struct{ exprs []luaExpr }
+	stBreak  struct{}
+)
+
+type luaBlock struct {
+	stmts []luaStmt
+	lines []int
+}
+
+// compileLua 编译脚本
+func compileLua(src string) (block *luaBlock, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*luaSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = syntaxErr
+		}
+	}()
+
+	p := &luaParser{lexer: &luaLexer{src: src, line: 1}}
+	p.advance()
+	block = p.block()
+	if p.tok.kind != tokEOF {
+		p.errorf("'<eof>' expected near '%s'", p.tok.text)
+	}
+	return block, nil
+}
+
+type luaParser struct {
+	lexer *luaLexer
+	tok   luaToken
+	ahead *luaToken
+}
+
+func (that *luaParser) errorf(format string, args ...any) {
+	panic(&luaSyntaxError{line: that.tok.line, msg: fmt.Sprintf(format, args...)})
+}
+
+func (that *luaParser) advance() {
+	if that.ahead != nil {
+		that.tok, that.ahead = *that.ahead, nil
+		return
+	}
+	that.tok = that.lexer.next()
+}
+
+func (that *luaParser) peek() luaToken {
+	if that.ahead == nil {
+		tok := that.lexer.next()
+		that.ahead = &tok
+	}
+	return *that.ahead
+}
+
+func (that *luaParser) is(symbol string) bool {
+	return that.tok.kind == tokSymbol && that.tok.text == symbol
+}
+
+func (that *luaParser) accept(symbol string) bool {
+	if that.is(symbol) {
+		that.advance()
+		return true
+	}
+	return false
+}
+
+func (that *luaParser) expect(symbol string) {
+	if !that.accept(symbol) {
+		that.errorf("'%s' expected near '%s'", symbol, that.tokenText())
+	}
+}
+
+func (that *luaParser) tokenText() string {
+	if that.tok.kind == tokEOF {
+		return "<eof>"
+	}
+	return that.tok.text
+}
+
+func (that *luaParser) name() string {
+	if that.tok.kind != tokName {
+		that.errorf("<name> expected near '%s'", that.tokenText())
+	}
+	name := that.tok.text
+	that.advance()
+	return name
+}
+
+// blockEnd 当前 token 是否结束一个语句块
+func (that *luaParser) blockEnd() bool {
+	if that.tok.kind == tokEOF {
+		return true
+	}
+	if that.tok.kind != tokSymbol {
+		return false
+	}
+	switch that.tok.text {
+	case "end", "else", "elseif", "until":
+		return true
+	}
+	return false
+}
+
+func (that *luaParser) block() *luaBlock {
+	block := &luaBlock{}
+	for !that.blockEnd() {
+		if that.accept(";") {
+			continue
+		}
+		line := that.tok.line
+		if that.is("return") {
+			that.advance()
+			stmt := &stReturn{}
+			if !that.blockEnd() && !that.is(";") {
+				stmt.exprs = that.exprList()
+			}
+			that.accept(";")
+			block.stmts = append(block.stmts, stmt)
+			block.lines = append(block.lines, line)
+			if !that.blockEnd() {
+				that.errorf("'end' expected near '%s'", that.tokenText())
+			}
+			break
+		}
+		block.stmts = append(block.stmts, that.statement())
+		block.lines = append(block.lines, line)
+	}
+	return block
+}
+
+func (that *luaParser) statement() luaStmt {
+	switch {
+	case that.accept("if"):
+		stmt := &stIf{}
+		for {
+			stmt.conds = append(stmt.conds, that.expr())
+			that.expect("then")
+			stmt.blocks = append(stmt.blocks, that.block())
+			if !that.accept("elseif") {
+				break
+			}
+		}
+		if that.accept("else") {
+			stmt.orElse = that.block()
+		}
+		that.expect("end")
+		return stmt
+	case that.accept("while"):
+		cond := that.expr()
+		that.expect("do")
+		body := that.block()
+		that.expect("end")
+		return &stWhile{cond: cond, body: body}
+	case that.accept("do"):
+		body := that.block()
+		that.expect("end")
+		return &stDo{body: body}
+	case that.accept("repeat"):
+		body := that.block()
+		that.expect("until")
+		return &stRepeat{body: body, cond: that.expr()}
+	case that.accept("for"):
+		return that.forStatement()
+	case that.accept("function"):
+		var target luaExpr = &exName{name: that.name()}
+		method := ""
+		for that.is(".") || that.is(":") {
+			colon := that.is(":")
+			that.advance()
+			key := that.name()
+			if colon {
+				method = key
+			}
+			target = &exIndex{obj: target, key: &exConst{value: key}}
+			if colon {
+				break
+			}
+		}
+		fn := that.functionBody(method != "")
+		return &stAssign{targets: []luaExpr{target}, exprs: []luaExpr{fn}}
+	case that.accept("local"):
+		if that.accept("function") {
+			name := that.name()
+			return &stLocalFunction{name: name, fn: that.functionBody(false)}
+		}
+		stmt := &stLocal{names: []string{that.name()}}
+		for that.accept(",") {
+			stmt.names = append(stmt.names, that.name())
+		}
+		if that.accept("=") {
+			stmt.exprs = that.exprList()
+		}
+		return stmt
+	case that.accept("break"):
+		return &stBreak{}
+	}
+
+	target := that.suffixedExpr()
+	if that.is("=") || that.is(",") {
+		targets := []luaExpr{target}
+		for that.accept(",") {
+			targets = append(targets, that.suffixedExpr())
+		}
+		that.expect("=")
+		for _, t := range targets {
+			switch t.(type) {
+			case *exName, *exIndex:
+			default:
+				that.errorf("syntax error near '='")
+			}
+		}
+		return &stAssign{targets: targets, exprs: that.exprList()}
+	}
+	call, ok := target.(*exCall)
+	if !ok {
+		that.errorf("syntax error near '%s'", that.tokenText())
+	}
+	return &stCall{call: call}
+}
+
+func (that *luaParser) forStatement() luaStmt {
+	first := that.name()
+	if that.accept("=") {
+		stmt := &stNumFor{name: first, start: that.expr()}
+		that.expect(",")
+		stmt.limit = that.expr()
+		if that.accept(",") {
+			stmt.step = that.expr()
+		}
+		that.expect("do")
+		stmt.body = that.block()
+		that.expect("end")
+		return stmt
+	}
+
+	stmt := &stGenFor{names: []string{first}}
+	for that.accept(",") {
+		stmt.names = append(stmt.names, that.name())
+	}
+	that.expect("in")
+	stmt.exprs = that.exprList()
+	that.expect("do")
+	stmt.body = that.block()
+	that.expect("end")
+	return stmt
+}
+
+// functionBody 解析参数列表与函数体, method 为 true 时第一个参数为隐含的 self
+func (that *luaParser) functionBody(method bool) *exFunction {
+	fn := &exFunction{}
+	if method {
+		fn.params = append(fn.params, "self")
+	}
+	that.expect("(")
+	if !that.is(")") {
+		for {
+			if that.accept("...") {
+				fn.vararg = true
+				break
+			}
+			fn.params = append(fn.params, that.name())
+			if !that.accept(",") {
+				break
+			}
+		}
+	}
+	that.expect(")")
+	fn.body = that.block()
+	that.expect("end")
+	return fn
+}
+
+func (that *luaParser) exprList() []luaExpr {
+	exprs := []luaExpr{that.expr()}
+	for that.accept(",") {
+		exprs = append(exprs, that.expr())
+	}
+	return exprs
+}
+
+// 二元运算符优先级 {左, 右}, 右结合的运算符右侧优先级更低
+var luaBinaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"..": {5, 4}, "+": {6, 6}, "-": {6, 6}, "*": {7, 7}, "/": {7, 7}, "%": {7, 7}, "^": {10, 9},
+}
+
+const luaUnaryPriority = 8
+
+func (that *luaParser) expr() luaExpr {
+	return that.subExpr(0)
+}
+
+func (that *luaParser) subExpr(limit int) luaExpr {
+	var left luaExpr
+	if that.is("not") || that.is("-") || that.is("#") {
+		op, line := that.tok.text, that.tok.line
+		that.advance()
+		left = &exUnary{op: op, x: that.subExpr(luaUnaryPriority), line: line}
+	} else {
+		left = that.simpleExpr()
+	}
+
+	for that.tok.kind == tokSymbol {
+		priority, ok := luaBinaryPriority[that.tok.text]
+		if !ok || priority[0] <= limit {
+			break
+		}
+		op, line := that.tok.text, that.tok.line
+		that.advance()
+		left = &exBinary{op: op, l: left, r: that.subExpr(priority[1]), line: line}
+	}
+	return left
+}
+
+func (that *luaParser) simpleExpr() luaExpr {
+	tok := that.tok
+	switch tok.kind {
+	case tokNumber:
+		that.advance()
+		return &exConst{value: tok.num}
+	case tokString:
+		that.advance()
+		return &exConst{value: tok.text}
+	case tokSymbol:
+		switch tok.text {
+		case "nil":
+			that.advance()
+			return &exConst{value: nil}
+		case "true", "false":
+			that.advance()
+			return &exConst{value: tok.text == "true"}
+		case "...":
+			that.advance()
+			return &exVararg{}
+		case "{":
+			return that.tableConstructor()
+		case "function":
+			that.advance()
+			return that.functionBody(false)
+		}
+	}
+	return that.suffixedExpr()
+}
+
+func (that *luaParser) primaryExpr() luaExpr {
+	if that.tok.kind == tokName {
+		return &exName{name: that.name()}
+	}
+	if that.accept("(") {
+		x := that.expr()
+		that.expect(")")
+		return &exParen{x: x}
+	}
+	that.errorf("unexpected symbol near '%s'", that.tokenText())
+	return nil
+}
+
+func (that *luaParser) suffixedExpr() luaExpr {
+	x := that.primaryExpr()
+	for {
+		switch {
+		case that.accept("."):
+			x = &exIndex{obj: x, key: &exConst{value: that.name()}}
+		case that.accept("["):
+			key := that.expr()
+			that.expect("]")
+			x = &exIndex{obj: x, key: key}
+		case that.is(":"):
+			that.advance()
+			method, line := that.name(), that.tok.line
+			x = &exCall{fn: x, method: method, args: that.callArgs(), line: line}
+		case that.is("(") || that.is("{") || that.tok.kind == tokString:
+			line := that.tok.line
+			x = &exCall{fn: x, args: that.callArgs(), line: line}
+		default:
+			return x
+		}
+	}
+}
+
+func (that *luaParser) callArgs() []luaExpr {
+	switch {
+	case that.tok.kind == tokString:
+		s := that.tok.text
+		that.advance()
+		return []luaExpr{&exConst{value: s}}
+	case that.is("{"):
+		return []luaExpr{that.tableConstructor()}
+	}
+	that.expect("(")
+	if that.accept(")") {
+		return nil
+	}
+	args := that.exprList()
+	that.expect(")")
+	return args
+}
+
+func (that *luaParser) tableConstructor() luaExpr {
+	that.expect("{")
+	table := &exTable{}
+	for !that.is("}") {
+		switch {
+		case that.is("["):
+			that.advance()
+			key := that.expr()
+			that.expect("]")
+			that.expect("=")
+			table.items = append(table.items, tableItem{key: key, value: that.expr()})
+		case that.tok.kind == tokName && that.peek().kind == tokSymbol && that.peek().text == "=":
+			key := that.name()
+			that.advance()
+			table.items = append(table.items, tableItem{key: &exConst{value: key}, value: that.expr()})
+		default:
+			table.items = append(table.items, tableItem{value: that.expr()})
+		}
+		if !that.accept(",") && !that.accept(";") {
+			break
+		}
+	}
+	that.expect("}")
+	return table
+}
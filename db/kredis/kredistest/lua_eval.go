@@ -0,0 +1,1364 @@
+package kredistest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lua 值使用 Go 类型表示: nil, bool, float64, string, *luaTable, *luaFunction
+
+type luaTable struct {
+	arr  []any       // arr[i] 为 t[i+1]
+	hash map[any]any // 其余的键, 数字键统一为 float64
+}
+
+func newLuaTable() *luaTable {
+	return &luaTable{hash: make(map[any]any)}
+}
+
+// arrayIndex 键为 arr 范围内(含追加位置)的正整数时返回下标
+func (that *luaTable) arrayIndex(key any) (int, bool) {
+	f, ok := key.(float64)
+	if !ok || f != math.Floor(f) || f < 1 || f > float64(len(that.arr)+1) {
+		return 0, false
+	}
+	return int(f) - 1, true
+}
+
+func (that *luaTable) get(key any) any {
+	if i, ok := that.arrayIndex(key); ok {
+		if i < len(that.arr) {
+			return that.arr[i]
+		}
+		return nil
+	}
+	return that.hash[key]
+}
+
+func (that *luaTable) set(key any, value any) error {
+	switch k := key.(type) {
+	case nil:
+		return fmt.Errorf("table index is nil")
+	case float64:
+		if math.IsNaN(k) {
+			return fmt.Errorf("table index is NaN")
+		}
+	}
+
+	i, ok := that.arrayIndex(key)
+	if !ok {
+		if value == nil {
+			delete(that.hash, key)
+		} else {
+			that.hash[key] = value
+		}
+		return nil
+	}
+	if i < len(that.arr) {
+		that.arr[i] = value
+		for len(that.arr) > 0 && that.arr[len(that.arr)-1] == nil {
+			that.arr = that.arr[:len(that.arr)-1]
+		}
+		return nil
+	}
+	if value == nil {
+		return nil
+	}
+	that.arr = append(that.arr, value)
+	// 之前存放在 hash 中的后续下标移入 arr
+	for {
+		next := float64(len(that.arr) + 1)
+		v, exists := that.hash[next]
+		if !exists {
+			return nil
+		}
+		delete(that.hash, next)
+		that.arr = append(that.arr, v)
+	}
+}
+
+func (that *luaTable) length() int {
+	return len(that.arr)
+}
+
+// keys 返回所有非 nil 值的键, 数组部分在前
+func (that *luaTable) keys() []any {
+	keys := make([]any, 0, len(that.arr)+len(that.hash))
+	for i, v := range that.arr {
+		if v != nil {
+			keys = append(keys, float64(i+1))
+		}
+	}
+	rest := make([]any, 0, len(that.hash))
+	for k := range that.hash {
+		rest = append(rest, k)
+	}
+	sort.Slice(rest, func(i, j int) bool { // 固定顺序, 便于复现问题
+		return fmt.Sprint(rest[i]) < fmt.Sprint(rest[j])
+	})
+	return append(keys, rest...)
+}
+
+type luaFunction struct {
+	name   string
+	native func(l *luaState, args []any) ([]any, error)
+	def    *exFunction
+	scope  *luaScope
+}
+
+// luaError 运行时错误, value 为 error() 的参数或 redis.call 返回的错误表
+type luaError struct {
+	value any
+}
+
+func (that *luaError) Error() string {
+	if t, ok := that.value.(*luaTable); ok {
+		if msg, ok := t.get("err").(string); ok {
+			return msg
+		}
+	}
+	return luaToString(that.value)
+}
+
+type luaScope struct {
+	vars   map[string]*any
+	parent *luaScope
+}
+
+func (that *luaScope) lookup(name string) *any {
+	for s := that; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (that *luaScope) declare(name string, value any) {
+	v := value
+	that.vars[name] = &v
+}
+
+func newScope(parent *luaScope) *luaScope {
+	return &luaScope{vars: make(map[string]*any), parent: parent}
+}
+
+const (
+	flowNormal = iota
+	flowBreak
+	flowReturn
+)
+
+// luaState 执行一个脚本的解释器状态
+type luaState struct {
+	globals *luaTable
+	line    int
+	depth   int
+}
+
+const luaMaxDepth = 200
+
+func (that *luaState) errorf(format string, args ...any) error {
+	return &luaError{value: "user_script:" + strconv.Itoa(that.line) + ": " + fmt.Sprintf(format, args...)}
+}
+
+// run 执行编译后的脚本, 返回脚本的返回值
+func (that *luaState) run(block *luaBlock) (any, error) {
+	flow, values, err := that.exec(block, newScope(nil))
+	if err != nil {
+		return nil, err
+	}
+	if flow == flowReturn && len(values) > 0 {
+		return values[0], nil
+	}
+	return nil, nil
+}
+
+func (that *luaState) exec(block *luaBlock, scope *luaScope) (int, []any, error) {
+	for i, stmt := range block.stmts {
+		that.line = block.lines[i]
+		flow, values, err := that.execStmt(stmt, scope)
+		if err != nil || flow != flowNormal {
+			return flow, values, err
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+func (that *luaState) execStmt(stmt luaStmt, scope *luaScope) (int, []any, error) {
+	switch s := stmt.(type) {
+	case *stLocal:
+		values, err := that.evalList(s.exprs, scope, len(s.names))
+		if err != nil {
+			return 0, nil, err
+		}
+		for i, name := range s.names {
+			scope.declare(name, values[i])
+		}
+	case *stLocalFunction:
+		scope.declare(s.name, nil)
+		*scope.lookup(s.name) = &luaFunction{name: s.name, def: s.fn, scope: scope}
+	case *stAssign:
+		values, err := that.evalList(s.exprs, scope, len(s.targets))
+		if err != nil {
+			return 0, nil, err
+		}
+		for i, target := range s.targets {
+			if err := that.assign(target, values[i], scope); err != nil {
+				return 0, nil, err
+			}
+		}
+	case *stCall:
+		if _, err := that.call(s.call, scope); err != nil {
+			return 0, nil, err
+		}
+	case *stDo:
+		return that.exec(s.body, newScope(scope))
+	case *stWhile:
+		for {
+			cond, err := that.eval(s.cond, scope)
+			if err != nil {
+				return 0, nil, err
+			}
+			if !luaTruthy(cond) {
+				break
+			}
+			flow, values, err := that.exec(s.body, newScope(scope))
+			if err != nil || flow == flowReturn {
+				return flow, values, err
+			}
+			if flow == flowBreak {
+				break
+			}
+		}
+	case *stRepeat:
+		for {
+			inner := newScope(scope) // until 可以访问循环体中的局部变量
+			flow, values, err := that.exec(s.body, inner)
+			if err != nil || flow == flowReturn {
+				return flow, values, err
+			}
+			if flow == flowBreak {
+				break
+			}
+			cond, err := that.eval(s.cond, inner)
+			if err != nil {
+				return 0, nil, err
+			}
+			if luaTruthy(cond) {
+				break
+			}
+		}
+	case *stIf:
+		for i, condExpr := range s.conds {
+			cond, err := that.eval(condExpr, scope)
+			if err != nil {
+				return 0, nil, err
+			}
+			if luaTruthy(cond) {
+				return that.exec(s.blocks[i], newScope(scope))
+			}
+		}
+		if s.orElse != nil {
+			return that.exec(s.orElse, newScope(scope))
+		}
+	case *stNumFor:
+		return that.numericFor(s, scope)
+	case *stGenFor:
+		return that.genericFor(s, scope)
+	case *stReturn:
+		values, err := that.evalList(s.exprs, scope, -1)
+		return flowReturn, values, err
+	case *stBreak:
+		return flowBreak, nil, nil
+	}
+	return flowNormal, nil, nil
+}
+
+func (that *luaState) numericFor(s *stNumFor, scope *luaScope) (int, []any, error) {
+	var bounds [3]float64
+	for i, x := range []luaExpr{s.start, s.limit, s.step} {
+		if x == nil {
+			bounds[i] = 1
+			continue
+		}
+		v, err := that.eval(x, scope)
+		if err != nil {
+			return 0, nil, err
+		}
+		n, ok := luaToNumber(v)
+		if !ok {
+			return 0, nil, that.errorf("'for' %s must be a number", []string{"initial value", "limit", "step"}[i])
+		}
+		bounds[i] = n
+	}
+
+	start, limit, step := bounds[0], bounds[1], bounds[2]
+	for i := start; (step > 0 && i <= limit) || (step <= 0 && i >= limit); i += step {
+		inner := newScope(scope)
+		inner.declare(s.name, i)
+		flow, values, err := that.exec(s.body, inner)
+		if err != nil || flow == flowReturn {
+			return flow, values, err
+		}
+		if flow == flowBreak {
+			break
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+func (that *luaState) genericFor(s *stGenFor, scope *luaScope) (int, []any, error) {
+	values, err := that.evalList(s.exprs, scope, 3)
+	if err != nil {
+		return 0, nil, err
+	}
+	iterator, state, control := values[0], values[1], values[2]
+	for {
+		results, err := that.callValue(iterator, []any{state, control})
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(results) == 0 || results[0] == nil {
+			break
+		}
+		control = results[0]
+
+		inner := newScope(scope)
+		for i, name := range s.names {
+			var v any
+			if i < len(results) {
+				v = results[i]
+			}
+			inner.declare(name, v)
+		}
+		flow, values, err := that.exec(s.body, inner)
+		if err != nil || flow == flowReturn {
+			return flow, values, err
+		}
+		if flow == flowBreak {
+			break
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+func (that *luaState) assign(target luaExpr, value any, scope *luaScope) error {
+	switch t := target.(type) {
+	case *exName:
+		if v := scope.lookup(t.name); v != nil {
+			*v = value
+			return nil
+		}
+		// 与 Redis 相同, 脚本不能创建或修改全局变量
+		return that.errorf("Attempt to modify a readonly table")
+	case *exIndex:
+		obj, err := that.eval(t.obj, scope)
+		if err != nil {
+			return err
+		}
+		key, err := that.eval(t.key, scope)
+		if err != nil {
+			return err
+		}
+		table, ok := obj.(*luaTable)
+		if !ok {
+			return that.errorf("attempt to index a %s value", luaType(obj))
+		}
+		if table == that.globals {
+			return that.errorf("Attempt to modify a readonly table")
+		}
+		if err := table.set(key, value); err != nil {
+			return that.errorf("%s", err.Error())
+		}
+	}
+	return nil
+}
+
+// evalList 计算表达式列表, 最后一个表达式展开为多个值; want >= 0 时补齐或截断到 want 个
+func (that *luaState) evalList(exprs []luaExpr, scope *luaScope, want int) ([]any, error) {
+	values := make([]any, 0, len(exprs))
+	for i, x := range exprs {
+		if i == len(exprs)-1 {
+			multi, err := that.evalMulti(x, scope)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, multi...)
+			break
+		}
+		v, err := that.eval(x, scope)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	if want >= 0 {
+		for len(values) < want {
+			values = append(values, nil)
+		}
+		values = values[:want]
+	}
+	return values, nil
+}
+
+// evalMulti 函数调用与 ... 返回全部值, 其余表达式返回一个值
+func (that *luaState) evalMulti(x luaExpr, scope *luaScope) ([]any, error) {
+	switch e := x.(type) {
+	case *exCall:
+		return that.call(e, scope)
+	case *exVararg:
+		if v := scope.lookup("..."); v != nil {
+			if t, ok := (*v).(*luaTable); ok {
+				return append([]any(nil), t.arr...), nil
+			}
+		}
+		return nil, that.errorf("cannot use '...' outside a vararg function")
+	}
+	v, err := that.eval(x, scope)
+	if err != nil {
+		return nil, err
+	}
+	return []any{v}, nil
+}
+
+func (that *luaState) eval(x luaExpr, scope *luaScope) (any, error) {
+	switch e := x.(type) {
+	case *exConst:
+		return e.value, nil
+	case *exName:
+		if v := scope.lookup(e.name); v != nil {
+			return *v, nil
+		}
+		v := that.globals.get(e.name)
+		if v == nil {
+			return nil, that.errorf("Script attempted to access nonexistent global variable '%s'", e.name)
+		}
+		return v, nil
+	case *exIndex:
+		obj, err := that.eval(e.obj, scope)
+		if err != nil {
+			return nil, err
+		}
+		key, err := that.eval(e.key, scope)
+		if err != nil {
+			return nil, err
+		}
+		return that.index(obj, key)
+	case *exParen:
+		return that.eval(e.x, scope)
+	case *exCall, *exVararg:
+		values, err := that.evalMulti(x, scope)
+		if err != nil || len(values) == 0 {
+			return nil, err
+		}
+		return values[0], nil
+	case *exFunction:
+		return &luaFunction{def: e, scope: scope}, nil
+	case *exTable:
+		return that.table(e, scope)
+	case *exUnary:
+		v, err := that.eval(e.x, scope)
+		if err != nil {
+			return nil, err
+		}
+		that.line = e.line
+		return that.unary(e.op, v)
+	case *exBinary:
+		return that.binary(e, scope)
+	}
+	return nil, that.errorf("unsupported expression")
+}
+
+func (that *luaState) index(obj any, key any) (any, error) {
+	switch o := obj.(type) {
+	case *luaTable:
+		return o.get(key), nil
+	case string: // 字符串方法, 例如 s:sub(1, 2)
+		return that.globals.get("string").(*luaTable).get(key), nil
+	}
+	return nil, that.errorf("attempt to index a %s value", luaType(obj))
+}
+
+func (that *luaState) table(e *exTable, scope *luaScope) (any, error) {
+	table := newLuaTable()
+	n := 0
+	for i, item := range e.items {
+		if item.key != nil {
+			key, err := that.eval(item.key, scope)
+			if err != nil {
+				return nil, err
+			}
+			value, err := that.eval(item.value, scope)
+			if err != nil {
+				return nil, err
+			}
+			if err := table.set(key, value); err != nil {
+				return nil, that.errorf("%s", err.Error())
+			}
+			continue
+		}
+
+		values := []any{nil}
+		if i == len(e.items)-1 { // 最后一个元素展开多返回值
+			multi, err := that.evalMulti(item.value, scope)
+			if err != nil {
+				return nil, err
+			}
+			values = multi
+		} else {
+			v, err := that.eval(item.value, scope)
+			if err != nil {
+				return nil, err
+			}
+			values[0] = v
+		}
+		for _, v := range values {
+			n++
+			table.set(float64(n), v)
+		}
+	}
+	return table, nil
+}
+
+func (that *luaState) unary(op string, v any) (any, error) {
+	switch op {
+	case "not":
+		return !luaTruthy(v), nil
+	case "-":
+		n, ok := luaToNumber(v)
+		if !ok {
+			return nil, that.errorf("attempt to perform arithmetic on a %s value", luaType(v))
+		}
+		return -n, nil
+	}
+	switch o := v.(type) {
+	case string:
+		return float64(len(o)), nil
+	case *luaTable:
+		return float64(o.length()), nil
+	}
+	return nil, that.errorf("attempt to get length of a %s value", luaType(v))
+}
+
+func (that *luaState) binary(e *exBinary, scope *luaScope) (any, error) {
+	l, err := that.eval(e.l, scope)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op { // 短路求值
+	case "and":
+		if !luaTruthy(l) {
+			return l, nil
+		}
+		return that.eval(e.r, scope)
+	case "or":
+		if luaTruthy(l) {
+			return l, nil
+		}
+		return that.eval(e.r, scope)
+	}
+	r, err := that.eval(e.r, scope)
+	if err != nil {
+		return nil, err
+	}
+	that.line = e.line
+
+	switch e.op {
+	case "==":
+		return luaEqual(l, r), nil
+	case "~=":
+		return !luaEqual(l, r), nil
+	case "<", ">", "<=", ">=":
+		return that.compare(e.op, l, r)
+	case "..":
+		ls, lok := luaConcatString(l)
+		rs, rok := luaConcatString(r)
+		if !lok {
+			return nil, that.errorf("attempt to concatenate a %s value", luaType(l))
+		}
+		if !rok {
+			return nil, that.errorf("attempt to concatenate a %s value", luaType(r))
+		}
+		return ls + rs, nil
+	}
+
+	a, aok := luaToNumber(l)
+	b, bok := luaToNumber(r)
+	if !aok {
+		return nil, that.errorf("attempt to perform arithmetic on a %s value", luaType(l))
+	}
+	if !bok {
+		return nil, that.errorf("attempt to perform arithmetic on a %s value", luaType(r))
+	}
+	switch e.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		return a / b, nil
+	case "%":
+		return a - math.Floor(a/b)*b, nil
+	}
+	return math.Pow(a, b), nil
+}
+
+func (that *luaState) compare(op string, l any, r any) (any, error) {
+	var less, equal bool
+	switch a := l.(type) {
+	case float64:
+		b, ok := r.(float64)
+		if !ok {
+			return nil, that.errorf("attempt to compare %s with %s", luaType(l), luaType(r))
+		}
+		less, equal = a < b, a == b
+	case string:
+		b, ok := r.(string)
+		if !ok {
+			return nil, that.errorf("attempt to compare %s with %s", luaType(l), luaType(r))
+		}
+		less, equal = a < b, a == b
+	default:
+		return nil, that.errorf("attempt to compare two %s values", luaType(l))
+	}
+	switch op {
+	case "<":
+		return less, nil
+	case "<=":
+		return less || equal, nil
+	case ">":
+		return !less && !equal, nil
+	}
+	return !less, nil
+}
+
+func (that *luaState) call(e *exCall, scope *luaScope) ([]any, error) {
+	fn, err := that.eval(e.fn, scope)
+	if err != nil {
+		return nil, err
+	}
+	var args []any
+	if e.method != "" { // obj:method(...) 等价于 obj.method(obj, ...)
+		self := fn
+		if fn, err = that.index(self, e.method); err != nil {
+			return nil, err
+		}
+		args = append(args, self)
+	}
+	values, err := that.evalList(e.args, scope, -1)
+	if err != nil {
+		return nil, err
+	}
+	that.line = e.line
+	return that.callValue(fn, append(args, values...))
+}
+
+func (that *luaState) callValue(fn any, args []any) ([]any, error) {
+	f, ok := fn.(*luaFunction)
+	if !ok {
+		return nil, that.errorf("attempt to call a %s value", luaType(fn))
+	}
+	if f.native != nil {
+		return f.native(that, args)
+	}
+
+	if that.depth >= luaMaxDepth {
+		return nil, that.errorf("stack overflow")
+	}
+	that.depth++
+	defer func() { that.depth-- }()
+
+	line := that.line
+	scope := newScope(f.scope)
+	for i, name := range f.def.params {
+		var v any
+		if i < len(args) {
+			v = args[i]
+		}
+		scope.declare(name, v)
+	}
+	if f.def.vararg {
+		rest := newLuaTable()
+		for i := len(f.def.params); i < len(args); i++ {
+			rest.arr = append(rest.arr, args[i])
+		}
+		scope.declare("...", rest)
+	}
+	flow, values, err := that.exec(f.def.body, scope)
+	if err == nil {
+		that.line = line
+	}
+	if flow != flowReturn {
+		values = nil
+	}
+	return values, err
+}
+
+///////////////////////////////////////////////////////////////
+
+func luaTruthy(v any) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+func luaEqual(l any, r any) bool {
+	return l == r // float64, string, bool 按值比较, 表与函数按引用比较
+}
+
+func luaType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *luaTable:
+		return "table"
+	case *luaFunction:
+		return "function"
+	}
+	return "userdata"
+}
+
+// luaToNumber 数字或可以转换为数字的字符串
+func luaToNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		return parseLuaNumber(n)
+	}
+	return 0, false
+}
+
+func luaConcatString(v any) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case float64:
+		return luaFormatNumber(s), true
+	}
+	return "", false
+}
+
+// luaFormatNumber 与 Lua 5.1 的 %.14g 相同
+func luaFormatNumber(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "inf"
+	case math.IsInf(n, -1):
+		return "-inf"
+	case math.IsNaN(n):
+		return "nan"
+	case n == math.Trunc(n) && math.Abs(n) < 1e15:
+		return strconv.FormatInt(int64(n), 10)
+	}
+	return strconv.FormatFloat(n, 'g', 14, 64)
+}
+
+func luaToString(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(s)
+	case float64:
+		return luaFormatNumber(s)
+	case string:
+		return s
+	}
+	return fmt.Sprintf("%s: %p", luaType(v), v)
+}
+
+///////////////////////////////////////////////////////////////
+
+func nativeFunc(name string, fn func(l *luaState, args []any) ([]any, error)) *luaFunction {
+	return &luaFunction{name: name, native: fn}
+}
+
+func luaLibrary(functions map[string]func(l *luaState, args []any) ([]any, error)) *luaTable {
+	table := newLuaTable()
+	for name, fn := range functions {
+		table.set(name, nativeFunc(name, fn))
+	}
+	return table
+}
+
+func argNumber(l *luaState, fn string, args []any, i int) (float64, error) {
+	var v any
+	if i < len(args) {
+		v = args[i]
+	}
+	n, ok := luaToNumber(v)
+	if !ok {
+		return 0, l.errorf("bad argument #%d to '%s' (number expected, got %s)", i+1, fn, luaType(v))
+	}
+	return n, nil
+}
+
+func argString(l *luaState, fn string, args []any, i int) (string, error) {
+	var v any
+	if i < len(args) {
+		v = args[i]
+	}
+	s, ok := luaConcatString(v)
+	if !ok {
+		return "", l.errorf("bad argument #%d to '%s' (string expected, got %s)", i+1, fn, luaType(v))
+	}
+	return s, nil
+}
+
+func argTable(l *luaState, fn string, args []any, i int) (*luaTable, error) {
+	var v any
+	if i < len(args) {
+		v = args[i]
+	}
+	t, ok := v.(*luaTable)
+	if !ok {
+		return nil, l.errorf("bad argument #%d to '%s' (table expected, got %s)", i+1, fn, luaType(v))
+	}
+	return t, nil
+}
+
+// newLuaGlobals 创建脚本可用的全局变量, redis 表由调用方加入
+func newLuaGlobals() *luaTable {
+	g := newLuaTable()
+	for name, fn := range map[string]func(l *luaState, args []any) ([]any, error){
+		"tonumber": luaTonumber,
+		"tostring": func(l *luaState, args []any) ([]any, error) {
+			if len(args) == 0 {
+				return nil, l.errorf("bad argument #1 to 'tostring' (value expected)")
+			}
+			return []any{luaToString(args[0])}, nil
+		},
+		"type": func(l *luaState, args []any) ([]any, error) {
+			if len(args) == 0 {
+				return nil, l.errorf("bad argument #1 to 'type' (value expected)")
+			}
+			return []any{luaType(args[0])}, nil
+		},
+		"ipairs": func(l *luaState, args []any) ([]any, error) {
+			t, err := argTable(l, "ipairs", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			next := nativeFunc("ipairs_iterator", func(l *luaState, args []any) ([]any, error) {
+				i := args[1].(float64) + 1
+				if v := t.get(i); v != nil {
+					return []any{i, v}, nil
+				}
+				return []any{nil}, nil
+			})
+			return []any{next, t, float64(0)}, nil
+		},
+		"pairs": func(l *luaState, args []any) ([]any, error) {
+			t, err := argTable(l, "pairs", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			keys, i := t.keys(), 0
+			next := nativeFunc("pairs_iterator", func(l *luaState, args []any) ([]any, error) {
+				for ; i < len(keys); i++ {
+					if v := t.get(keys[i]); v != nil {
+						i++
+						return []any{keys[i-1], v}, nil
+					}
+				}
+				return []any{nil}, nil
+			})
+			return []any{next, t, nil}, nil
+		},
+		"select": func(l *luaState, args []any) ([]any, error) {
+			if len(args) > 0 && args[0] == "#" {
+				return []any{float64(len(args) - 1)}, nil
+			}
+			n, err := argNumber(l, "select", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			if n < 1 {
+				return nil, l.errorf("bad argument #1 to 'select' (index out of range)")
+			}
+			if int(n) >= len(args) {
+				return nil, nil
+			}
+			return args[int(n):], nil
+		},
+		"unpack": luaUnpack,
+		"error": func(l *luaState, args []any) ([]any, error) {
+			var v any
+			if len(args) > 0 {
+				v = args[0]
+			}
+			if s, ok := v.(string); ok && (len(args) < 2 || args[1] != float64(0)) {
+				v = "user_script:" + strconv.Itoa(l.line) + ": " + s
+			}
+			return nil, &luaError{value: v}
+		},
+		"assert": func(l *luaState, args []any) ([]any, error) {
+			if len(args) > 0 && luaTruthy(args[0]) {
+				return args, nil
+			}
+			msg := any("assertion failed!")
+			if len(args) > 1 {
+				msg = args[1]
+			}
+			return nil, &luaError{value: msg}
+		},
+		"pcall": func(l *luaState, args []any) ([]any, error) {
+			if len(args) == 0 {
+				return nil, l.errorf("bad argument #1 to 'pcall' (value expected)")
+			}
+			depth := l.depth
+			values, err := l.callValue(args[0], args[1:])
+			if err != nil {
+				luaErr, ok := err.(*luaError)
+				if !ok {
+					return nil, err
+				}
+				l.depth = depth
+				return []any{false, luaErr.value}, nil
+			}
+			return append([]any{true}, values...), nil
+		},
+		"rawget": func(l *luaState, args []any) ([]any, error) {
+			t, err := argTable(l, "rawget", args, 0)
+			if err != nil || len(args) < 2 {
+				return nil, err
+			}
+			return []any{t.get(args[1])}, nil
+		},
+		"rawequal": func(l *luaState, args []any) ([]any, error) {
+			if len(args) < 2 {
+				return nil, l.errorf("bad argument #2 to 'rawequal' (value expected)")
+			}
+			return []any{luaEqual(args[0], args[1])}, nil
+		},
+	} {
+		g.set(name, nativeFunc(name, fn))
+	}
+
+	g.set("string", luaLibrary(map[string]func(l *luaState, args []any) ([]any, error){
+		"len": func(l *luaState, args []any) ([]any, error) {
+			s, err := argString(l, "len", args, 0)
+			return []any{float64(len(s))}, err
+		},
+		"sub":     luaStringSub,
+		"upper":   luaStringMap("upper", strings.ToUpper),
+		"lower":   luaStringMap("lower", strings.ToLower),
+		"reverse": luaStringMap("reverse", reverseString),
+		"rep": func(l *luaState, args []any) ([]any, error) {
+			s, err := argString(l, "rep", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			n, err := argNumber(l, "rep", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return []any{strings.Repeat(s, max(int(n), 0))}, nil
+		},
+		"byte": func(l *luaState, args []any) ([]any, error) {
+			s, err := argString(l, "byte", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			i, j := 1, 1
+			if len(args) > 1 {
+				n, err := argNumber(l, "byte", args, 1)
+				if err != nil {
+					return nil, err
+				}
+				i, j = int(n), int(n)
+			}
+			if len(args) > 2 {
+				n, err := argNumber(l, "byte", args, 2)
+				if err != nil {
+					return nil, err
+				}
+				j = int(n)
+			}
+			start, end := stringRange(len(s), i, j)
+			values := make([]any, 0, end-start)
+			for k := start; k < end; k++ {
+				values = append(values, float64(s[k]))
+			}
+			return values, nil
+		},
+		"char": func(l *luaState, args []any) ([]any, error) {
+			buf := make([]byte, len(args))
+			for i := range args {
+				n, err := argNumber(l, "char", args, i)
+				if err != nil {
+					return nil, err
+				}
+				buf[i] = byte(n)
+			}
+			return []any{string(buf)}, nil
+		},
+		"find":   luaStringFind,
+		"format": luaStringFormat,
+	}))
+
+	g.set("table", luaLibrary(map[string]func(l *luaState, args []any) ([]any, error){
+		"insert": func(l *luaState, args []any) ([]any, error) {
+			t, err := argTable(l, "insert", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			switch len(args) {
+			case 2:
+				t.set(float64(t.length()+1), args[1])
+			case 3:
+				pos, err := argNumber(l, "insert", args, 1)
+				if err != nil {
+					return nil, err
+				}
+				n := t.length()
+				for i := n; i >= int(pos); i-- {
+					t.set(float64(i+1), t.get(float64(i)))
+				}
+				t.set(pos, args[2])
+			default:
+				return nil, l.errorf("wrong number of arguments to 'insert'")
+			}
+			return nil, nil
+		},
+		"remove": func(l *luaState, args []any) ([]any, error) {
+			t, err := argTable(l, "remove", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			n := t.length()
+			if n == 0 {
+				return []any{nil}, nil
+			}
+			pos := float64(n)
+			if len(args) > 1 {
+				if pos, err = argNumber(l, "remove", args, 1); err != nil {
+					return nil, err
+				}
+			}
+			removed := t.get(pos)
+			for i := int(pos); i < n; i++ {
+				t.set(float64(i), t.get(float64(i+1)))
+			}
+			t.set(float64(n), nil)
+			return []any{removed}, nil
+		},
+		"concat": func(l *luaState, args []any) ([]any, error) {
+			t, err := argTable(l, "concat", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			sep := ""
+			if len(args) > 1 {
+				if sep, err = argString(l, "concat", args, 1); err != nil {
+					return nil, err
+				}
+			}
+			parts := make([]string, 0, t.length())
+			for i := 1; i <= t.length(); i++ {
+				s, ok := luaConcatString(t.get(float64(i)))
+				if !ok {
+					return nil, l.errorf("invalid value (at index %d) in table for 'concat'", i)
+				}
+				parts = append(parts, s)
+			}
+			return []any{strings.Join(parts, sep)}, nil
+		},
+		"getn": func(l *luaState, args []any) ([]any, error) {
+			t, err := argTable(l, "getn", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return []any{float64(t.length())}, nil
+		},
+		"unpack": luaUnpack,
+	}))
+
+	math1 := func(name string, fn func(float64) float64) func(l *luaState, args []any) ([]any, error) {
+		return func(l *luaState, args []any) ([]any, error) {
+			n, err := argNumber(l, name, args, 0)
+			return []any{fn(n)}, err
+		}
+	}
+	mathLib := luaLibrary(map[string]func(l *luaState, args []any) ([]any, error){
+		"floor": math1("floor", math.Floor),
+		"ceil":  math1("ceil", math.Ceil),
+		"abs":   math1("abs", math.Abs),
+		"sqrt":  math1("sqrt", math.Sqrt),
+		"exp":   math1("exp", math.Exp),
+		"log":   math1("log", math.Log),
+		"fmod": func(l *luaState, args []any) ([]any, error) {
+			a, err := argNumber(l, "fmod", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			b, err := argNumber(l, "fmod", args, 1)
+			return []any{math.Mod(a, b)}, err
+		},
+		"pow": func(l *luaState, args []any) ([]any, error) {
+			a, err := argNumber(l, "pow", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			b, err := argNumber(l, "pow", args, 1)
+			return []any{math.Pow(a, b)}, err
+		},
+		"min": luaMinMax("min", func(a, b float64) bool { return a < b }),
+		"max": luaMinMax("max", func(a, b float64) bool { return a > b }),
+	})
+	mathLib.set("huge", math.Inf(1))
+	mathLib.set("pi", math.Pi)
+	g.set("math", mathLib)
+	return g
+}
+
+func luaTonumber(l *luaState, args []any) ([]any, error) {
+	if len(args) == 0 {
+		return nil, l.errorf("bad argument #1 to 'tonumber' (value expected)")
+	}
+	if len(args) > 1 && args[1] != nil {
+		base, err := argNumber(l, "tonumber", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := luaConcatString(args[0])
+		if !ok {
+			return []any{nil}, nil
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(s), int(base), 64)
+		if err != nil {
+			return []any{nil}, nil
+		}
+		return []any{float64(n)}, nil
+	}
+	if n, ok := luaToNumber(args[0]); ok {
+		return []any{n}, nil
+	}
+	return []any{nil}, nil
+}
+
+func luaUnpack(l *luaState, args []any) ([]any, error) {
+	t, err := argTable(l, "unpack", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	i, j := 1, t.length()
+	if len(args) > 1 && args[1] != nil {
+		n, err := argNumber(l, "unpack", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		i = int(n)
+	}
+	if len(args) > 2 && args[2] != nil {
+		n, err := argNumber(l, "unpack", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		j = int(n)
+	}
+	values := make([]any, 0, max(j-i+1, 0))
+	for k := i; k <= j; k++ {
+		values = append(values, t.get(float64(k)))
+	}
+	return values, nil
+}
+
+func luaMinMax(name string, better func(a, b float64) bool) func(l *luaState, args []any) ([]any, error) {
+	return func(l *luaState, args []any) ([]any, error) {
+		result, err := argNumber(l, name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(args); i++ {
+			n, err := argNumber(l, name, args, i)
+			if err != nil {
+				return nil, err
+			}
+			if better(n, result) {
+				result = n
+			}
+		}
+		return []any{result}, nil
+	}
+}
+
+func luaStringMap(name string, fn func(string) string) func(l *luaState, args []any) ([]any, error) {
+	return func(l *luaState, args []any) ([]any, error) {
+		s, err := argString(l, name, args, 0)
+		return []any{fn(s)}, err
+	}
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// stringRange 将 Lua 的 1 起始, 负数从末尾计算的闭区间转换为 Go 的半开区间
+func stringRange(n int, i int, j int) (int, int) {
+	if i < 0 {
+		i = max(n+i+1, 1)
+	} else if i == 0 {
+		i = 1
+	}
+	if j < 0 {
+		j = n + j + 1
+	} else if j > n {
+		j = n
+	}
+	if i > j {
+		return 0, 0
+	}
+	return i - 1, j
+}
+
+func luaStringSub(l *luaState, args []any) ([]any, error) {
+	s, err := argString(l, "sub", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	i, j := 1.0, -1.0
+	if len(args) > 1 {
+		if i, err = argNumber(l, "sub", args, 1); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) > 2 && args[2] != nil {
+		if j, err = argNumber(l, "sub", args, 2); err != nil {
+			return nil, err
+		}
+	}
+	start, end := stringRange(len(s), int(i), int(j))
+	return []any{s[start:end]}, nil
+}
+
+// luaStringFind 只支持普通字符串查找, 不支持模式匹配
+func luaStringFind(l *luaState, args []any) ([]any, error) {
+	s, err := argString(l, "find", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := argString(l, "find", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	init := 1
+	if len(args) > 2 && args[2] != nil {
+		n, err := argNumber(l, "find", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		init = int(n)
+	}
+	plain := len(args) > 3 && luaTruthy(args[3])
+	if !plain && strings.ContainsAny(pattern, "^$*+?.([%-") {
+		return nil, l.errorf("string.find with patterns is not supported, pass plain = true")
+	}
+	start, _ := stringRange(len(s), init, len(s))
+	if init > len(s)+1 {
+		return []any{nil}, nil
+	}
+	i := strings.Index(s[start:], pattern)
+	if i < 0 {
+		return []any{nil}, nil
+	}
+	return []any{float64(start + i + 1), float64(start + i + len(pattern))}, nil
+}
+
+// luaStringFormat 支持 %d %i %u %c %x %X %o %e %E %f %g %G %q %s %%
+func luaStringFormat(l *luaState, args []any) ([]any, error) {
+	format, err := argString(l, "format", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	arg := 1
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j >= len(format) {
+			return nil, l.errorf("invalid option in 'format'")
+		}
+		spec, verb := format[i+1:j], format[j]
+		i = j
+		if verb == '%' {
+			b.WriteByte('%')
+			continue
+		}
+
+		switch verb {
+		case 'd', 'i', 'u', 'c', 'x', 'X', 'o':
+			n, err := argNumber(l, "format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			switch verb {
+			case 'c':
+				b.WriteByte(byte(n))
+			case 'i', 'u':
+				fmt.Fprintf(&b, "%"+spec+"d", int64(n))
+			default:
+				fmt.Fprintf(&b, "%"+spec+string(verb), int64(n))
+			}
+		case 'e', 'E', 'f', 'g', 'G':
+			n, err := argNumber(l, "format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "%"+spec+string(verb), n)
+		case 's':
+			s, err := argString(l, "format", args, arg)
+			if err != nil {
+				if arg >= len(args) {
+					return nil, err
+				}
+				s = luaToString(args[arg])
+			}
+			fmt.Fprintf(&b, "%"+spec+"s", s)
+		case 'q':
+			s, err := argString(l, "format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(strconv.Quote(s))
+		default:
+			return nil, l.errorf("invalid option '%%%c' to 'format'", verb)
+		}
+		arg++
+	}
+	return []any{b.String()}, nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+package kredistest
+
+import (
+	"sort"
+	"strings"
+)
+
+func (that *conn) subscriptions() int {
+	return len(that.channels) + len(that.patterns) + len(that.shards)
+}
+
+// registry 返回订阅类型对应的服务端订阅表与连接的订阅集合
+func (that *conn) registry(kind string) (map[string]map[*conn]struct{}, *map[string]struct{}) {
+	switch kind {
+	case "p":
+		return that.server.patterns, &that.patterns
+	case "s":
+		return that.server.shards, &that.shards
+	}
+	return that.server.channels, &that.channels
+}
+
+// cmdSubscribe SUBSCRIBE/PSUBSCRIBE/SSUBSCRIBE, 每个频道回复一条确认消息, 计数为该连接订阅的总数
+func cmdSubscribe(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	kind := name[:len(name)-len("subscribe")]
+	registry, own := c.registry(kind)
+	if *own == nil {
+		*own = make(map[string]struct{})
+	}
+	for _, channel := range args[1:] {
+		if _, exists := (*own)[channel]; !exists {
+			(*own)[channel] = struct{}{}
+			if registry[channel] == nil {
+				registry[channel] = make(map[*conn]struct{})
+			}
+			registry[channel][c] = struct{}{}
+		}
+		w.push(3)
+		w.bulk(name)
+		w.bulk(channel)
+		w.integer(int64(subscriptionCount(c, kind)))
+	}
+}
+
+// subscriptionCount 分片频道的计数只包含分片订阅, 与 Redis 相同
+func subscriptionCount(c *conn, kind string) int {
+	if kind == "s" {
+		return len(c.shards)
+	}
+	return len(c.channels) + len(c.patterns)
+}
+
+// cmdUnsubscribe UNSUBSCRIBE/PUNSUBSCRIBE/SUNSUBSCRIBE [channel ...], 不带参数时取消该类型的全部订阅
+func cmdUnsubscribe(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	kind := name[:len(name)-len("unsubscribe")]
+	_, own := c.registry(kind)
+	channels := args[1:]
+	if len(channels) == 0 {
+		channels = sortedFields(*own)
+		if len(channels) == 0 {
+			w.push(3)
+			w.bulk(name)
+			w.null()
+			w.integer(int64(subscriptionCount(c, kind)))
+			return
+		}
+	}
+	for _, channel := range channels {
+		c.unsubscribe(kind, channel)
+		w.push(3)
+		w.bulk(name)
+		w.bulk(channel)
+		w.integer(int64(subscriptionCount(c, kind)))
+	}
+}
+
+func (that *conn) unsubscribe(kind string, channel string) {
+	registry, own := that.registry(kind)
+	delete(*own, channel)
+	delete(registry[channel], that)
+	if len(registry[channel]) == 0 {
+		delete(registry, channel)
+	}
+}
+
+// unsubscribeAll 连接关闭或 RESET 时取消所有订阅, 调用方持有 server.mu
+func (that *conn) unsubscribeAll() {
+	for _, kind := range []string{"", "p", "s"} {
+		_, own := that.registry(kind)
+		for channel := range *own {
+			that.unsubscribe(kind, channel)
+		}
+	}
+}
+
+// cmdPublish PUBLISH/SPUBLISH channel message, 返回收到消息的连接数
+func cmdPublish(c *conn, w *writer, args []string) {
+	w.integer(int64(c.server.publish(args[1], args[2], strings.EqualFold(args[0], "spublish"))))
+}
+
+// publish 向订阅了频道或匹配模式的连接发送消息, 调用方持有 server.mu
+func (that *Server) publish(channel string, message string, shard bool) int {
+	if shard {
+		for c := range that.shards[channel] {
+			mw := &writer{proto: c.proto}
+			mw.push(3)
+			mw.bulk("smessage")
+			mw.bulk(channel)
+			mw.bulk(message)
+			c.send(mw.buf)
+		}
+		return len(that.shards[channel])
+	}
+
+	receivers := 0
+	for c := range that.channels[channel] {
+		mw := &writer{proto: c.proto}
+		mw.push(3)
+		mw.bulk("message")
+		mw.bulk(channel)
+		mw.bulk(message)
+		c.send(mw.buf)
+		receivers++
+	}
+	for pattern, conns := range that.patterns {
+		if !matchGlob(pattern, channel) {
+			continue
+		}
+		for c := range conns {
+			mw := &writer{proto: c.proto}
+			mw.push(4)
+			mw.bulk("pmessage")
+			mw.bulk(pattern)
+			mw.bulk(channel)
+			mw.bulk(message)
+			c.send(mw.buf)
+			receivers++
+		}
+	}
+	return receivers
+}
+
+// cmdPubSub PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT | SHARDCHANNELS [pattern] | SHARDNUMSUB [channel ...]
+func cmdPubSub(c *conn, w *writer, args []string) {
+	switch sub := strings.ToUpper(args[1]); sub {
+	case "CHANNELS", "SHARDCHANNELS":
+		registry := c.server.channels
+		if sub == "SHARDCHANNELS" {
+			registry = c.server.shards
+		}
+		channels := make([]string, 0, len(registry))
+		for channel := range registry {
+			if len(args) < 3 || matchGlob(args[2], channel) {
+				channels = append(channels, channel)
+			}
+		}
+		sort.Strings(channels)
+		w.bulks(channels)
+	case "NUMSUB", "SHARDNUMSUB":
+		registry := c.server.channels
+		if sub == "SHARDNUMSUB" {
+			registry = c.server.shards
+		}
+		w.mapHeader(len(args) - 2)
+		for _, channel := range args[2:] {
+			w.bulk(channel)
+			w.integer(int64(len(registry[channel])))
+		}
+	case "NUMPAT":
+		w.integer(int64(len(c.server.patterns)))
+	default:
+		w.errorf("ERR unknown subcommand '%s'. Try PUBSUB HELP.", args[1])
+	}
+}
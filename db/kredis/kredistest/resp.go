@@ -0,0 +1,209 @@
+package kredistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxBulkLen      = 512 * 1024 * 1024 // 与 Redis proto-max-bulk-len 默认值相同
+	maxMultiBulkLen = 1024 * 1024
+)
+
+// protocolError 请求格式错误, 回复错误后关闭连接
+type protocolError string
+
+func (that protocolError) Error() string {
+	return "ERR Protocol error: " + string(that)
+}
+
+// readCommand 读取一条命令, 支持 RESP 数组与 telnet 风格的内联命令, 空行返回 nil
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := readLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxMultiBulkLen {
+		return nil, protocolError("invalid multibulk length")
+	}
+	args := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		line, err = readLine(rd)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" || line[0] != '$' {
+			return nil, protocolError(fmt.Sprintf("expected '$', got '%.1s'", line))
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, protocolError("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return "", protocolError("too big inline request")
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writer 按连接协商的协议版本编码回复, RESP2 下 map/set/double/push 退化为数组与字符串
+type writer struct {
+	buf   []byte
+	proto int
+}
+
+func (that *writer) status(s string) {
+	that.buf = append(that.buf, '+')
+	that.buf = append(that.buf, s...)
+	that.buf = append(that.buf, "\r\n"...)
+}
+
+func (that *writer) ok() {
+	that.status("OK")
+}
+
+// error 写入错误, msg 应以错误码开头, 例如 "ERR ..." 或 "WRONGTYPE ..."
+func (that *writer) error(msg string) {
+	that.buf = append(that.buf, '-')
+	that.buf = append(that.buf, msg...)
+	that.buf = append(that.buf, "\r\n"...)
+}
+
+func (that *writer) errorf(format string, args ...any) {
+	that.error(fmt.Sprintf(format, args...))
+}
+
+func (that *writer) integer(n int64) {
+	that.buf = append(that.buf, ':')
+	that.buf = strconv.AppendInt(that.buf, n, 10)
+	that.buf = append(that.buf, "\r\n"...)
+}
+
+func (that *writer) boolean(b bool) {
+	if b {
+		that.integer(1)
+	} else {
+		that.integer(0)
+	}
+}
+
+func (that *writer) bulk(s string) {
+	that.buf = append(that.buf, '$')
+	that.buf = strconv.AppendInt(that.buf, int64(len(s)), 10)
+	that.buf = append(that.buf, "\r\n"...)
+	that.buf = append(that.buf, s...)
+	that.buf = append(that.buf, "\r\n"...)
+}
+
+func (that *writer) null() {
+	if that.proto == 3 {
+		that.buf = append(that.buf, "_\r\n"...)
+	} else {
+		that.buf = append(that.buf, "$-1\r\n"...)
+	}
+}
+
+func (that *writer) nullArray() {
+	if that.proto == 3 {
+		that.buf = append(that.buf, "_\r\n"...)
+	} else {
+		that.buf = append(that.buf, "*-1\r\n"...)
+	}
+}
+
+func (that *writer) header(prefix byte, n int) {
+	that.buf = append(that.buf, prefix)
+	that.buf = strconv.AppendInt(that.buf, int64(n), 10)
+	that.buf = append(that.buf, "\r\n"...)
+}
+
+func (that *writer) array(n int) {
+	that.header('*', n)
+}
+
+// mapHeader n 为键值对数量
+func (that *writer) mapHeader(n int) {
+	if that.proto == 3 {
+		that.header('%', n)
+	} else {
+		that.header('*', n*2)
+	}
+}
+
+func (that *writer) setHeader(n int) {
+	if that.proto == 3 {
+		that.header('~', n)
+	} else {
+		that.header('*', n)
+	}
+}
+
+// push 发布订阅消息, RESP3 下为 push 类型, 可以与普通回复在同一个连接上交错
+func (that *writer) push(n int) {
+	if that.proto == 3 {
+		that.header('>', n)
+	} else {
+		that.header('*', n)
+	}
+}
+
+func (that *writer) double(f float64) {
+	if that.proto == 3 {
+		that.buf = append(that.buf, ',')
+		that.buf = append(that.buf, formatFloat(f)...)
+		that.buf = append(that.buf, "\r\n"...)
+	} else {
+		that.bulk(formatFloat(f))
+	}
+}
+
+func (that *writer) bulks(values []string) {
+	that.array(len(values))
+	for _, value := range values {
+		that.bulk(value)
+	}
+}
+
+// failed 从 mark 开始的回复是否为错误
+func (that *writer) failed(mark int) bool {
+	return len(that.buf) > mark && that.buf[mark] == '-'
+}
+
+// formatFloat 与 Redis 相同的浮点数文本格式, 例如 1.5, 3, 1e+21, inf
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case f == 0 || math.Abs(f) >= 1e-6 && math.Abs(f) < 1e21:
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
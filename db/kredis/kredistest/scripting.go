@@ -0,0 +1,298 @@
+package kredistest
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// cmdEval EVAL/EVAL_RO script numkeys [key ...] [arg ...], EVALSHA/EVALSHA_RO sha1 numkeys [key ...] [arg ...]
+func cmdEval(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	numKeys, err := strconv.Atoi(args[2])
+	if err != nil {
+		w.error(errNotInteger)
+		return
+	}
+	if numKeys < 0 {
+		w.error("ERR Number of keys can't be negative")
+		return
+	}
+	if numKeys > len(args)-3 {
+		w.error("ERR Number of keys can't be greater than number of args")
+		return
+	}
+
+	var sha string
+	if strings.HasPrefix(name, "evalsha") {
+		sha = strings.ToLower(args[1])
+		if c.server.scripts[sha] == nil {
+			w.error("NOSCRIPT No matching script. Please use EVAL.")
+			return
+		}
+	} else if sha, err = c.server.loadScript(args[1]); err != nil {
+		w.error("ERR Error compiling script (new function): " + err.Error())
+		return
+	}
+
+	c.runScript(w, sha, args[3:3+numKeys], args[3+numKeys:], strings.HasSuffix(name, "_ro"))
+}
+
+// cmdScript SCRIPT LOAD script | EXISTS sha1 [sha1 ...] | FLUSH [ASYNC|SYNC] | KILL
+func cmdScript(c *conn, w *writer, args []string) {
+	switch sub := strings.ToUpper(args[1]); {
+	case sub == "LOAD" && len(args) == 3:
+		sha, err := c.server.loadScript(args[2])
+		if err != nil {
+			w.error("ERR Error compiling script (new function): " + err.Error())
+			return
+		}
+		w.bulk(sha)
+	case sub == "EXISTS" && len(args) > 2:
+		w.array(len(args) - 2)
+		for _, sha := range args[2:] {
+			w.boolean(c.server.scripts[strings.ToLower(sha)] != nil)
+		}
+	case sub == "FLUSH" && flushMode(w, args[1:]):
+		c.server.scripts = make(map[string]*luaBlock)
+		w.ok()
+	case sub == "KILL" && len(args) == 2:
+		w.error("NOTBUSY No scripts in execution right now.")
+	case sub != "FLUSH":
+		w.errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try SCRIPT HELP.", args[1])
+	}
+}
+
+// loadScript 编译脚本并缓存, 返回脚本的 SHA1
+func (that *Server) loadScript(src string) (string, error) {
+	sha := sha1Hex(src)
+	if that.scripts[sha] != nil {
+		return sha, nil
+	}
+	block, err := compileLua(src)
+	if err != nil {
+		return "", err
+	}
+	that.scripts[sha] = block
+	return sha, nil
+}
+
+// runScript 在 server.mu 下执行脚本, 执行期间不会有其他命令插入, 与 Redis 一样具有原子性
+func (that *conn) runScript(w *writer, sha string, keys []string, argv []string, readOnly bool) {
+	l := &luaState{globals: newLuaGlobals()}
+	l.globals.set("KEYS", stringsTable(keys))
+	l.globals.set("ARGV", stringsTable(argv))
+	l.globals.set("redis", that.redisLibrary(sha, readOnly))
+
+	// 脚本中的阻塞命令与事务中一样立即返回
+	inExec := that.inExec
+	that.inExec = true
+	result, err := l.run(that.server.scripts[sha])
+	that.inExec = inExec
+
+	if err != nil {
+		msg := err.Error()
+		if luaErr, ok := err.(*luaError); ok {
+			if _, isTable := luaErr.value.(*luaTable); !isTable && !hasErrorCode(msg) {
+				msg = "ERR " + msg // 与 Redis 相同, 普通的运行时错误加上 ERR 前缀
+			}
+		}
+		w.error(msg + " script: " + sha + ", on @user_script:" + strconv.Itoa(l.line) + ".")
+		return
+	}
+	writeLuaValue(w, result)
+}
+
+// hasErrorCode 错误信息是否已经以大写的错误码开头, 例如 "WRONGTYPE ..."
+func hasErrorCode(msg string) bool {
+	code, _, found := strings.Cut(msg, " ")
+	return found && code != "" && strings.ToUpper(code) == code && strings.IndexFunc(code, func(r rune) bool { return r < 'A' || r > 'Z' }) < 0
+}
+
+func stringsTable(values []string) *luaTable {
+	t := newLuaTable()
+	for _, v := range values {
+		t.arr = append(t.arr, v)
+	}
+	return t
+}
+
+func (that *conn) redisLibrary(sha string, readOnly bool) *luaTable {
+	lib := luaLibrary(map[string]func(l *luaState, args []any) ([]any, error){
+		"call": func(l *luaState, args []any) ([]any, error) {
+			return that.scriptCall(l, args, readOnly, true)
+		},
+		"pcall": func(l *luaState, args []any) ([]any, error) {
+			return that.scriptCall(l, args, readOnly, false)
+		},
+		"error_reply": func(l *luaState, args []any) ([]any, error) {
+			msg, err := argString(l, "error_reply", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasPrefix(msg, "-") && !hasErrorCode(msg) {
+				msg = "ERR " + msg
+			}
+			t := newLuaTable()
+			t.set("err", strings.TrimPrefix(msg, "-"))
+			return []any{t}, nil
+		},
+		"status_reply": func(l *luaState, args []any) ([]any, error) {
+			msg, err := argString(l, "status_reply", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			t := newLuaTable()
+			t.set("ok", msg)
+			return []any{t}, nil
+		},
+		"sha1hex": func(l *luaState, args []any) ([]any, error) {
+			s, err := argString(l, "sha1hex", args, 0)
+			return []any{sha1Hex(s)}, err
+		},
+		"log": func(l *luaState, args []any) ([]any, error) {
+			return nil, nil
+		},
+		"setresp": func(l *luaState, args []any) ([]any, error) {
+			if n, _ := argNumber(l, "setresp", args, 0); n != 2 {
+				return nil, l.errorf("RESP3 replies in scripts are not supported")
+			}
+			return nil, nil
+		},
+		"replicate_commands": func(l *luaState, args []any) ([]any, error) {
+			return []any{true}, nil
+		},
+		"set_repl": func(l *luaState, args []any) ([]any, error) {
+			return nil, nil
+		},
+	})
+	for name, value := range map[string]float64{
+		"LOG_DEBUG": 0, "LOG_VERBOSE": 1, "LOG_NOTICE": 2, "LOG_WARNING": 3,
+		"REPL_NONE": 0, "REPL_AOF": 1, "REPL_SLAVE": 2, "REPL_REPLICA": 2, "REPL_ALL": 3,
+	} {
+		lib.set(name, value)
+	}
+	return lib
+}
+
+// scriptCall redis.call 与 redis.pcall; raise 为 true 时命令返回的错误作为 Lua 错误抛出
+func (that *conn) scriptCall(l *luaState, args []any, readOnly bool, raise bool) ([]any, error) {
+	if len(args) == 0 {
+		return nil, l.errorf("Please specify at least one argument for this redis lib call")
+	}
+	cmdArgs := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := luaConcatString(arg)
+		if !ok {
+			return nil, l.errorf("Lua redis lib command arguments must be strings or integers")
+		}
+		cmdArgs[i] = s
+	}
+
+	w := &writer{proto: 2} // 脚本总是以 RESP2 接收命令的回复
+	name := strings.ToLower(cmdArgs[0])
+	cmd, ok := commands[name]
+	switch {
+	case !ok:
+		w.error("ERR Unknown Redis command called from script")
+	case (cmd.arity > 0 && len(cmdArgs) != cmd.arity) || (cmd.arity < 0 && len(cmdArgs) < -cmd.arity):
+		w.error("ERR Wrong number of args calling Redis command from script")
+	case cmd.flags&flagNoScript != 0:
+		w.error("ERR This Redis command is not allowed from script")
+	case readOnly && cmd.flags&flagWrite != 0:
+		w.error("ERR Write commands are not allowed from read-only scripts.")
+	default:
+		that.call(cmd, w, cmdArgs)
+	}
+
+	reply, _ := parseReply(w.buf)
+	if t, ok := reply.(*luaTable); ok && raise {
+		if _, failed := t.get("err").(string); failed {
+			return nil, &luaError{value: t}
+		}
+	}
+	return []any{reply}, nil
+}
+
+// parseReply 将 RESP2 回复转换为 Lua 值, 与 Redis 的转换规则相同
+func parseReply(buf []byte) (any, []byte) {
+	if len(buf) == 0 {
+		return nil, buf
+	}
+	end := bytes.Index(buf, []byte("\r\n"))
+	line, rest := string(buf[1:end]), buf[end+2:]
+	switch buf[0] {
+	case '+':
+		t := newLuaTable()
+		t.set("ok", line)
+		return t, rest
+	case '-':
+		t := newLuaTable()
+		t.set("err", line)
+		return t, rest
+	case ':':
+		n, _ := strconv.ParseInt(line, 10, 64)
+		return float64(n), rest
+	case '$':
+		n, _ := strconv.Atoi(line)
+		if n < 0 {
+			return false, rest
+		}
+		return string(rest[:n]), rest[n+2:]
+	case '*':
+		n, _ := strconv.Atoi(line)
+		if n < 0 {
+			return false, rest
+		}
+		t := newLuaTable()
+		for i := 0; i < n; i++ {
+			var item any
+			item, rest = parseReply(rest)
+			t.arr = append(t.arr, item)
+		}
+		return t, rest
+	}
+	return nil, nil
+}
+
+// writeLuaValue 将脚本的返回值转换为回复: 数字截断为整数, false 与 nil 为空值, 表按数组输出直到第一个 nil
+func writeLuaValue(w *writer, v any) {
+	switch value := v.(type) {
+	case nil:
+		w.null()
+	case bool:
+		if value {
+			w.integer(1)
+		} else {
+			w.null()
+		}
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			w.integer(math.MinInt64)
+			return
+		}
+		w.integer(int64(value))
+	case string:
+		w.bulk(value)
+	case *luaTable:
+		if msg, ok := value.get("err").(string); ok {
+			w.error(msg)
+			return
+		}
+		if msg, ok := value.get("ok").(string); ok {
+			w.status(msg)
+			return
+		}
+		n := 0
+		for n < len(value.arr) && value.arr[n] != nil {
+			n++
+		}
+		w.array(n)
+		for _, item := range value.arr[:n] {
+			writeLuaValue(w, item)
+		}
+	default:
+		w.null()
+	}
+}
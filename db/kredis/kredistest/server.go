@@ -0,0 +1,420 @@
+// Package kredistest 提供进程内的 Redis 服务, 用于在没有真实 Redis 的环境(例如 CI)中运行基于 kredis 的测试.
+//
+// 服务端支持 RESP2 与 RESP3(HELLO), 实现字符串, 哈希, 列表, 集合, 有序集合, Streams(含消费组), 过期时间, 发布订阅(含模式,
+// 分片频道与键空间通知), SCAN 系列命令, MULTI/EXEC/WATCH 事务以及 BLPOP 等阻塞命令.
+// DUMP/RESTORE 使用自定义的序列化格式, 只能在 kredistest 之间互相导入, 不能导入真实 Redis 的 DUMP 结果.
+//
+// EVAL/EVALSHA 使用内置的 Lua 5.1 子集解释器执行, 支持 Redis 脚本常用的语法, redis.call/pcall,
+// 以及 string, table, math 库中的常用函数; 不支持元表, 协程, 模式匹配, cjson 与 Redis 7 函数(FUNCTION/FCALL).
+// 不支持 RedisJSON 与集群模式, 这些命令返回 "ERR unknown command".
+//
+// 示例:
+//
+//	func TestCounter(t *testing.T) {
+//		srv := kredistest.Run(t)
+//		client := kredis.NewKRedis(kcontext.NewContextTree("test").GetRoot(), srv.Addr(), "", "", 0)
+//		defer client.Stop()
+//		...
+//	}
+package kredistest
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	databases     = 16
+	sweepInterval = 100 * time.Millisecond // 主动删除过期 key 的间隔, 触发 expired 通知
+	outputQueue   = 4096                   // 每个连接待发送回复的数量上限, 超过时断开连接
+)
+
+// Server 进程内的 Redis 服务, 所有命令在同一把锁下串行执行, 与 Redis 单线程模型一致
+type Server struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	dbs      [databases]*database
+	offset   time.Duration // FastForward 累计的时钟偏移
+	password string
+	config   map[string]string
+	version  uint64               // key 修改版本号, 供 WATCH 检测冲突
+	cursorID uint64               // SCAN 系列命令的游标编号
+	cursors  map[uint64]string    // 游标 -> 上一页最后一个元素
+	scripts  map[string]*luaBlock // SHA1 -> 编译后的脚本
+	wake     chan struct{}        // 每次写命令后关闭并替换, 唤醒阻塞命令
+	conns    map[*conn]struct{}
+	nextID   int64
+	channels map[string]map[*conn]struct{}
+	patterns map[string]map[*conn]struct{}
+	shards   map[string]map[*conn]struct{}
+	closed   bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewServer 在 127.0.0.1 的随机端口上启动服务
+func NewServer() (*Server, error) {
+	return NewServerWithAddr("127.0.0.1:0")
+}
+
+// NewServerWithAddr 在指定地址上启动服务
+func NewServerWithAddr(addr string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	that := &Server{
+		listener: listener,
+		config:   defaultConfig(),
+		cursors:  make(map[uint64]string),
+		scripts:  make(map[string]*luaBlock),
+		wake:     make(chan struct{}),
+		conns:    make(map[*conn]struct{}),
+		channels: make(map[string]map[*conn]struct{}),
+		patterns: make(map[string]map[*conn]struct{}),
+		shards:   make(map[string]map[*conn]struct{}),
+		done:     make(chan struct{}),
+	}
+	for i := range that.dbs {
+		that.dbs[i] = newDatabase()
+	}
+
+	that.wg.Add(2)
+	go that.acceptLoop()
+	go that.sweepLoop()
+	return that, nil
+}
+
+// Run 启动服务, 失败时终止测试, 测试结束时自动关闭服务
+func Run(tb testing.TB) *Server {
+	tb.Helper()
+	that, err := NewServer()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(that.Close)
+	return that
+}
+
+// Addr 返回服务监听的地址, 例如 "127.0.0.1:53124"
+func (that *Server) Addr() string {
+	return that.listener.Addr().String()
+}
+
+// Close 关闭服务与所有连接, 重复调用无效
+func (that *Server) Close() {
+	that.mu.Lock()
+	if that.closed {
+		that.mu.Unlock()
+		return
+	}
+	that.closed = true
+	that.listener.Close()
+	close(that.done)
+	for c := range that.conns {
+		c.netConn.Close()
+	}
+	that.mu.Unlock()
+
+	that.wg.Wait()
+}
+
+// SetPassword 设置 default 用户的密码, 之后新的连接需要 AUTH 或 HELLO AUTH 认证, 空字符串表示不需要认证
+func (that *Server) SetPassword(password string) {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.password = password
+}
+
+// FlushAll 清空所有数据库
+func (that *Server) FlushAll() {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for i := range that.dbs {
+		that.flush(i)
+	}
+}
+
+// FastForward 将服务端时钟向前拨动 d, 到期的 key 立即删除, 用于测试过期逻辑而不必等待
+func (that *Server) FastForward(d time.Duration) {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.offset += d
+	that.sweep()
+}
+
+// Keys 返回数据库 db 中所有未过期的 key, 按字典序排列
+func (that *Server) Keys(db int) []string {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return that.keys(db)
+}
+
+// now 服务端时钟, 包含 FastForward 的偏移
+func (that *Server) now() time.Time {
+	return time.Now().Add(that.offset)
+}
+
+// signal 唤醒等待数据的阻塞命令
+func (that *Server) signal() {
+	close(that.wake)
+	that.wake = make(chan struct{})
+}
+
+func (that *Server) acceptLoop() {
+	defer that.wg.Done()
+	for {
+		netConn, err := that.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		that.mu.Lock()
+		if that.closed {
+			that.mu.Unlock()
+			netConn.Close()
+			return
+		}
+		that.nextID++
+		c := &conn{
+			server:  that,
+			netConn: netConn,
+			id:      that.nextID,
+			proto:   2,
+			authed:  that.password == "",
+			out:     make(chan []byte, outputQueue),
+		}
+		that.conns[c] = struct{}{}
+		that.wg.Add(2)
+		that.mu.Unlock()
+
+		go c.readLoop()
+		go c.writeLoop()
+	}
+}
+
+func (that *Server) sweepLoop() {
+	defer that.wg.Done()
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-that.done:
+			return
+		case <-ticker.C:
+			that.mu.Lock()
+			that.sweep()
+			that.mu.Unlock()
+		}
+	}
+}
+
+// conn 客户端连接, readLoop 解析并执行命令, writeLoop 按顺序发送回复与订阅消息
+type conn struct {
+	server  *Server
+	netConn net.Conn
+	out     chan []byte
+	id      int64
+
+	// 以下字段只在 readLoop 中或持有 server.mu 时访问
+	proto     int
+	db        int
+	authed    bool
+	name      string
+	quit      bool
+	multi     [][]string          // MULTI 之后排队的命令, nil 表示不在事务中
+	multiErr  bool                // 排队时出现错误, EXEC 返回 EXECABORT
+	watched   map[watchKey]uint64 // WATCH 时 key 的版本号
+	inExec    bool
+	blocking  bool       // 正在等待数据的阻塞命令
+	blocked   bool       // 本次执行没有可用数据
+	deadline  time.Time  // 阻塞命令的超时时刻, 零值表示一直等待
+	streamIDs []streamID // 阻塞的 XREAD 第一次执行时确定的起始 ID
+	channels  map[string]struct{}
+	patterns  map[string]struct{}
+	shards    map[string]struct{}
+}
+
+type watchKey struct {
+	db  int
+	key string
+}
+
+func (that *conn) readLoop() {
+	defer that.server.wg.Done()
+	defer that.close()
+
+	rd := bufio.NewReader(that.netConn)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			var protoErr protocolError
+			if errors.As(err, &protoErr) {
+				w := &writer{proto: that.proto}
+				w.error(protoErr.Error())
+				that.send(w.buf)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if !that.execute(args) {
+			return
+		}
+	}
+}
+
+// execute 执行一条命令并发送回复, 阻塞命令在没有数据时等待写命令或超时后重试; 返回 false 表示关闭连接
+func (that *conn) execute(args []string) bool {
+	for {
+		w := &writer{proto: that.proto}
+		that.server.mu.Lock()
+		wake := that.server.wake
+		that.dispatch(w, args)
+		blocked := that.blocked
+		that.blocked = false
+		that.server.mu.Unlock()
+
+		if !blocked {
+			that.blocking = false
+			that.send(w.buf)
+			return !that.quit
+		}
+
+		if !that.wait(wake) {
+			return false
+		}
+	}
+}
+
+// wait 等待写命令或阻塞超时, 服务关闭时返回 false
+func (that *conn) wait(wake <-chan struct{}) bool {
+	var timeout <-chan time.Time
+	if !that.deadline.IsZero() {
+		timer := time.NewTimer(time.Until(that.deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-wake:
+	case <-timeout:
+	case <-that.server.done:
+		return false
+	}
+	return true
+}
+
+// dispatch 检查参数, 认证与连接状态后执行命令, 调用方持有 server.mu
+func (that *conn) dispatch(w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		that.multiErr = that.multi != nil
+		w.errorf("ERR unknown command '%s', with args beginning with: %s", args[0], quoteArgs(args[1:]))
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		that.multiErr = that.multi != nil
+		w.errorf("ERR wrong number of arguments for '%s' command", name)
+		return
+	}
+	if !that.authed && cmd.flags&flagNoAuth == 0 {
+		w.error("NOAUTH Authentication required.")
+		return
+	}
+	if that.proto == 2 && that.subscriptions() > 0 && cmd.flags&flagPubSub == 0 {
+		w.errorf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", name)
+		return
+	}
+	if that.multi != nil && cmd.flags&flagTx == 0 {
+		that.multi = append(that.multi, args)
+		w.status("QUEUED")
+		return
+	}
+	that.call(cmd, w, args)
+}
+
+// call 执行命令, 写命令成功后更新 key 的版本号并唤醒阻塞命令
+func (that *conn) call(cmd *command, w *writer, args []string) {
+	mark := len(w.buf)
+	cmd.handler(that, w, args)
+	if cmd.flags&flagWrite == 0 || that.blocked || w.failed(mark) {
+		return
+	}
+	for _, key := range cmd.keys(args) {
+		that.server.touch(that.db, key)
+	}
+	that.server.signal()
+}
+
+// block 阻塞命令没有可用数据时调用, timeout 为 0 时一直等待, 超时或在事务中执行时回复空数组
+func (that *conn) block(w *writer, timeout time.Duration) {
+	if that.inExec {
+		w.nullArray()
+		return
+	}
+	if !that.blocking {
+		that.blocking = true
+		that.deadline = time.Time{}
+		if timeout > 0 {
+			that.deadline = time.Now().Add(timeout)
+		}
+	} else if !that.deadline.IsZero() && !time.Now().Before(that.deadline) {
+		w.nullArray()
+		return
+	}
+	that.blocked = true
+}
+
+// send 将回复加入发送队列, 队列已满说明客户端没有读取订阅消息, 与 Redis 的 client-output-buffer-limit 一样断开连接
+func (that *conn) send(b []byte) {
+	select {
+	case that.out <- b:
+	default:
+		that.netConn.Close()
+	}
+}
+
+func (that *conn) writeLoop() {
+	defer that.server.wg.Done()
+	defer that.netConn.Close()
+
+	bw := bufio.NewWriter(that.netConn)
+	failed := false
+	for b := range that.out {
+		if failed {
+			continue
+		}
+		bw.Write(b)
+		if len(that.out) == 0 && bw.Flush() != nil { // 队列中还有数据时合并写入
+			failed = true
+			that.netConn.Close()
+		}
+	}
+}
+
+// close 取消订阅并注销连接, 之后不会再有数据进入发送队列
+func (that *conn) close() {
+	that.server.mu.Lock()
+	that.unsubscribeAll()
+	delete(that.server.conns, that)
+	that.server.mu.Unlock()
+	close(that.out)
+}
+
+func quoteArgs(args []string) string {
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString("'" + arg + "' ")
+	}
+	return b.String()
+}
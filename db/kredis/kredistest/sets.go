@@ -0,0 +1,327 @@
+package kredistest
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+func cmdSAdd(c *conn, w *writer, args []string) {
+	it, ok := c.lookupOrCreate(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	added := int64(0)
+	for _, member := range args[2:] {
+		if _, exists := it.set[member]; !exists {
+			it.set[member] = struct{}{}
+			added++
+		}
+	}
+	if added > 0 {
+		c.notify('s', "sadd", args[1])
+	}
+	w.integer(added)
+}
+
+func cmdSRem(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	removed := int64(0)
+	for _, member := range args[2:] {
+		if _, exists := it.set[member]; exists {
+			delete(it.set, member)
+			removed++
+		}
+	}
+	if removed > 0 {
+		c.notify('s', "srem", args[1])
+		c.removeIfEmpty(args[1], it)
+	}
+	w.integer(removed)
+}
+
+// writeSet 按字典序输出集合, RESP3 下为 set 类型
+func writeSet(w *writer, set map[string]struct{}) {
+	members := sortedFields(set)
+	w.setHeader(len(members))
+	for _, member := range members {
+		w.bulk(member)
+	}
+}
+
+func cmdSMembers(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.setHeader(0)
+		return
+	}
+	writeSet(w, it.set)
+}
+
+func cmdSIsMember(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	_, exists := it.set[args[2]]
+	w.boolean(exists)
+}
+
+func cmdSMIsMember(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	w.array(len(args) - 2)
+	for _, member := range args[2:] {
+		exists := false
+		if it != nil {
+			_, exists = it.set[member]
+		}
+		w.boolean(exists)
+	}
+}
+
+func cmdSCard(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(it.set)))
+}
+
+// cmdSPop SPOP key [count], 随机弹出元素
+func cmdSPop(c *conn, w *writer, args []string) {
+	if len(args) > 3 {
+		w.error(errSyntax)
+		return
+	}
+	count := int64(-1)
+	if len(args) == 3 {
+		n, ok := parseInt(w, args[2])
+		if !ok {
+			return
+		}
+		if n < 0 {
+			w.error("ERR value is out of range, must be positive")
+			return
+		}
+		count = n
+	}
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		if count < 0 {
+			w.null()
+		} else {
+			w.setHeader(0)
+		}
+		return
+	}
+
+	members := randomMembers(it.set, max(count, 1), false)
+	for _, member := range members {
+		delete(it.set, member)
+	}
+	if len(members) > 0 {
+		c.notify('s', "spop", args[1])
+		c.removeIfEmpty(args[1], it)
+	}
+	if count < 0 {
+		w.bulk(members[0])
+		return
+	}
+	w.setHeader(len(members))
+	for _, member := range members {
+		w.bulk(member)
+	}
+}
+
+// cmdSRandMember SRANDMEMBER key [count], count 为负数时允许重复
+func cmdSRandMember(c *conn, w *writer, args []string) {
+	if len(args) > 3 {
+		w.error(errSyntax)
+		return
+	}
+	count, hasCount := int64(1), len(args) == 3
+	if hasCount {
+		n, ok := parseInt(w, args[2])
+		if !ok {
+			return
+		}
+		count = n
+	}
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		if hasCount {
+			w.array(0)
+		} else {
+			w.null()
+		}
+		return
+	}
+
+	members := randomMembers(it.set, max(count, -count), count < 0)
+	if !hasCount {
+		w.bulk(members[0])
+		return
+	}
+	w.bulks(members)
+}
+
+// randomMembers 随机选取 count 个元素, repeat 为 true 时允许重复
+func randomMembers(set map[string]struct{}, count int64, repeat bool) []string {
+	members := sortedFields(set)
+	if repeat {
+		picked := make([]string, 0, count)
+		for int64(len(picked)) < count && len(members) > 0 {
+			picked = append(picked, members[rand.Intn(len(members))])
+		}
+		return picked
+	}
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	return members[:min(count, int64(len(members)))]
+}
+
+// cmdSMove SMOVE source destination member
+func cmdSMove(c *conn, w *writer, args []string) {
+	src, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	if _, ok = c.lookupKind(w, args[2], kindSet); !ok {
+		return
+	}
+	if src == nil {
+		w.integer(0)
+		return
+	}
+	if _, exists := src.set[args[3]]; !exists {
+		w.integer(0)
+		return
+	}
+	delete(src.set, args[3])
+	c.notify('s', "srem", args[1])
+	c.removeIfEmpty(args[1], src)
+	dst, _ := c.lookupOrCreate(w, args[2], kindSet)
+	dst.set[args[3]] = struct{}{}
+	c.notify('s', "sadd", args[2])
+	w.integer(1)
+}
+
+// cmdSetOp SINTER/SUNION/SDIFF key [key ...] 与对应的 STORE 版本 destination key [key ...]
+func cmdSetOp(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	store := strings.HasSuffix(name, "store")
+	keys := args[1:]
+	if store {
+		keys = args[2:]
+	}
+
+	sets := make([]map[string]struct{}, len(keys))
+	for i, key := range keys {
+		it, ok := c.lookupKind(w, key, kindSet)
+		if !ok {
+			return
+		}
+		if it != nil {
+			sets[i] = it.set
+		}
+	}
+
+	result := make(map[string]struct{})
+	switch name[:5] {
+	case "sinte":
+		for member := range sets[0] {
+			in := true
+			for _, set := range sets[1:] {
+				if _, in = set[member]; !in {
+					break
+				}
+			}
+			if in {
+				result[member] = struct{}{}
+			}
+		}
+	case "sunio":
+		for _, set := range sets {
+			for member := range set {
+				result[member] = struct{}{}
+			}
+		}
+	default:
+		for member := range sets[0] {
+			result[member] = struct{}{}
+		}
+		for _, set := range sets[1:] {
+			for member := range set {
+				delete(result, member)
+			}
+		}
+	}
+
+	if !store {
+		writeSet(w, result)
+		return
+	}
+	if len(result) == 0 {
+		if c.remove(args[1]) {
+			c.notify('g', "del", args[1])
+		}
+	} else {
+		it := newItem(kindSet)
+		it.set = result
+		c.store(args[1], it)
+		c.notify('s', name, args[1])
+	}
+	w.integer(int64(len(result)))
+}
+
+// cmdSScan SSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdSScan(c *conn, w *writer, args []string) {
+	cursor, match, count, _, ok := parseScanArgs(w, args[2:], false)
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindSet)
+	if !ok {
+		return
+	}
+	var members []string
+	if it != nil {
+		members = sortedFields(it.set)
+	}
+	page, next := c.server.scanPage(cursor, members, count)
+	matched := make([]string, 0, len(page))
+	for _, member := range page {
+		if match == "" || matchGlob(match, member) {
+			matched = append(matched, member)
+		}
+	}
+	w.array(2)
+	w.bulk(strconv.FormatUint(next, 10))
+	w.bulks(matched)
+}
@@ -0,0 +1,1046 @@
+package kredistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type streamID struct {
+	ms, seq uint64
+}
+
+var maxStreamID = streamID{ms: math.MaxUint64, seq: math.MaxUint64}
+
+func (that streamID) String() string {
+	return strconv.FormatUint(that.ms, 10) + "-" + strconv.FormatUint(that.seq, 10)
+}
+
+func (that streamID) less(other streamID) bool {
+	return that.ms < other.ms || (that.ms == other.ms && that.seq < other.seq)
+}
+
+// next 紧随其后的 ID, 用于把不包含边界的区间转换为包含边界的区间
+func (that streamID) next() (streamID, bool) {
+	switch {
+	case that == maxStreamID:
+		return that, false
+	case that.seq == math.MaxUint64:
+		return streamID{ms: that.ms + 1}, true
+	}
+	return streamID{ms: that.ms, seq: that.seq + 1}, true
+}
+
+func (that streamID) prev() (streamID, bool) {
+	switch {
+	case that == streamID{}:
+		return that, false
+	case that.seq == 0:
+		return streamID{ms: that.ms - 1, seq: math.MaxUint64}, true
+	}
+	return streamID{ms: that.ms, seq: that.seq - 1}, true
+}
+
+// parseStreamID 解析 ms-seq 或 ms, 只有 ms 时序号取 seq; 支持 - 与 +
+func parseStreamID(s string, seq uint64) (streamID, bool) {
+	switch s {
+	case "-":
+		return streamID{}, true
+	case "+":
+		return maxStreamID, true
+	}
+	msText, seqText, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msText, 10, 64)
+	if err != nil {
+		return streamID{}, false
+	}
+	if hasSeq {
+		if seq, err = strconv.ParseUint(seqText, 10, 64); err != nil {
+			return streamID{}, false
+		}
+	}
+	return streamID{ms: ms, seq: seq}, true
+}
+
+const errInvalidStreamID = "ERR Invalid stream ID specified as stream command argument"
+
+type streamEntry struct {
+	id     streamID
+	fields []string // field value field value ...
+}
+
+type streamValue struct {
+	entries []streamEntry // 按 ID 递增
+	lastID  streamID
+	groups  map[string]*streamGroup
+}
+
+type streamGroup struct {
+	lastID    streamID
+	pending   map[streamID]*pendingEntry
+	consumers map[string]time.Time // 消费者 -> 最近一次活动时间
+}
+
+// pendingEntry 已投递但未确认的消息
+type pendingEntry struct {
+	consumer  string
+	delivered time.Time
+	count     int64
+}
+
+// search 返回第一个 ID 不小于 id 的位置
+func (that *streamValue) search(id streamID) int {
+	return sort.Search(len(that.entries), func(i int) bool { return !that.entries[i].id.less(id) })
+}
+
+func (that *streamValue) find(id streamID) *streamEntry {
+	i := that.search(id)
+	if i < len(that.entries) && that.entries[i].id == id {
+		return &that.entries[i]
+	}
+	return nil
+}
+
+// pendingIDs 返回 consumer 的待确认消息 ID, consumer 为空时返回全部, 按 ID 递增
+func (that *streamGroup) pendingIDs(consumer string) []streamID {
+	ids := make([]streamID, 0, len(that.pending))
+	for id, p := range that.pending {
+		if consumer == "" || p.consumer == consumer {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].less(ids[j]) })
+	return ids
+}
+
+func writeStreamEntry(w *writer, entry *streamEntry) {
+	w.array(2)
+	w.bulk(entry.id.String())
+	w.bulks(entry.fields)
+}
+
+// cmdXAdd XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+func cmdXAdd(c *conn, w *writer, args []string) {
+	i := 2
+	noMkStream := false
+	if strings.EqualFold(args[i], "NOMKSTREAM") {
+		noMkStream = true
+		i++
+	}
+	trim, next, ok := parseStreamTrim(w, args, i)
+	if !ok {
+		return
+	}
+	i = next
+	if i >= len(args) || (len(args)-i-1)%2 != 0 || len(args)-i-1 == 0 {
+		w.errorf("ERR wrong number of arguments for '%s' command", "xadd")
+		return
+	}
+
+	it, ok := c.lookupKind(w, args[1], kindStream)
+	if !ok {
+		return
+	}
+	if it == nil && noMkStream {
+		w.null()
+		return
+	}
+	created := it == nil
+	if created {
+		it = newItem(kindStream)
+	}
+	stream := it.stream
+
+	id, ok := c.server.nextStreamID(w, stream, args[i])
+	if !ok {
+		return
+	}
+	if created {
+		c.store(args[1], it)
+	}
+	stream.entries = append(stream.entries, streamEntry{id: id, fields: append([]string(nil), args[i+1:]...)})
+	stream.lastID = id
+	c.notify('t', "xadd", args[1])
+	if trim != nil && trim.apply(stream) > 0 {
+		c.notify('t', "xtrim", args[1])
+	}
+	w.bulk(id.String())
+}
+
+// nextStreamID 根据 *, ms-* 或明确的 ID 生成新消息的 ID, 必须大于 Stream 中最后的 ID
+func (that *Server) nextStreamID(w *writer, stream *streamValue, spec string) (streamID, bool) {
+	last := stream.lastID
+	if spec == "*" {
+		ms := uint64(that.now().UnixMilli())
+		if ms > last.ms {
+			return streamID{ms: ms}, true
+		}
+		id, ok := last.next()
+		if !ok {
+			w.error("ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+		return id, ok
+	}
+
+	var id streamID
+	if msText, ok := strings.CutSuffix(spec, "-*"); ok {
+		ms, err := strconv.ParseUint(msText, 10, 64)
+		if err != nil {
+			w.error(errInvalidStreamID)
+			return id, false
+		}
+		id = streamID{ms: ms}
+		if ms == last.ms {
+			id.seq = last.seq + 1
+		}
+	} else {
+		var ok bool
+		if id, ok = parseStreamID(spec, 0); !ok || spec == "-" || spec == "+" {
+			w.error(errInvalidStreamID)
+			return id, false
+		}
+	}
+	if id == (streamID{}) {
+		w.error("ERR The ID specified in XADD must be greater than 0-0")
+		return id, false
+	}
+	if !last.less(id) {
+		w.error("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		return id, false
+	}
+	return id, true
+}
+
+// streamTrim MAXLEN/MINID 裁剪条件, 近似裁剪(~)与精确裁剪的效果相同
+type streamTrim struct {
+	maxLen int64
+	minID  *streamID
+}
+
+// apply 裁剪 Stream, 返回删除的消息数量
+func (that *streamTrim) apply(stream *streamValue) int {
+	n := 0
+	if that.minID != nil {
+		n = stream.search(*that.minID)
+	} else if int64(len(stream.entries)) > that.maxLen {
+		n = len(stream.entries) - int(that.maxLen)
+	}
+	stream.entries = stream.entries[n:]
+	return n
+}
+
+// parseStreamTrim 解析 args[i] 开始的 MAXLEN|MINID [=|~] threshold [LIMIT count], 没有裁剪条件时返回 nil
+func parseStreamTrim(w *writer, args []string, i int) (*streamTrim, int, bool) {
+	if i >= len(args) {
+		return nil, i, true
+	}
+	kind := strings.ToUpper(args[i])
+	if kind != "MAXLEN" && kind != "MINID" {
+		return nil, i, true
+	}
+	i++
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		i++
+	}
+	if i >= len(args) {
+		w.error(errSyntax)
+		return nil, i, false
+	}
+
+	trim := &streamTrim{}
+	if kind == "MAXLEN" {
+		n, err := strconv.ParseInt(args[i], 10, 64)
+		if err != nil || n < 0 {
+			w.error("ERR The MAXLEN argument must be >= 0.")
+			return nil, i, false
+		}
+		trim.maxLen = n
+	} else {
+		id, ok := parseStreamID(args[i], 0)
+		if !ok {
+			w.error(errInvalidStreamID)
+			return nil, i, false
+		}
+		trim.minID = &id
+	}
+	i++
+	if i+1 < len(args) && strings.EqualFold(args[i], "LIMIT") {
+		if _, err := strconv.ParseInt(args[i+1], 10, 64); err != nil {
+			w.error(errNotInteger)
+			return nil, i, false
+		}
+		i += 2
+	}
+	return trim, i, true
+}
+
+// cmdXTrim XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]
+func cmdXTrim(c *conn, w *writer, args []string) {
+	trim, next, ok := parseStreamTrim(w, args, 2)
+	if !ok {
+		return
+	}
+	if trim == nil || next != len(args) {
+		w.error(errSyntax)
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindStream)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	n := trim.apply(it.stream)
+	if n > 0 {
+		c.notify('t', "xtrim", args[1])
+	}
+	w.integer(int64(n))
+}
+
+func cmdXLen(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindStream)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(it.stream.entries)))
+}
+
+// parseRangeID 解析 XRANGE 的边界, ( 前缀表示不包含边界
+func parseRangeID(w *writer, s string, seq uint64, start bool) (streamID, bool) {
+	exclusive := strings.HasPrefix(s, "(")
+	id, ok := parseStreamID(strings.TrimPrefix(s, "("), seq)
+	if !ok {
+		w.error(errInvalidStreamID)
+		return id, false
+	}
+	if !exclusive {
+		return id, true
+	}
+	if start {
+		if id, ok = id.next(); !ok {
+			w.error("ERR invalid start ID for the interval")
+		}
+		return id, ok
+	}
+	if id, ok = id.prev(); !ok {
+		w.error("ERR invalid end ID for the interval")
+	}
+	return id, ok
+}
+
+// cmdXRange XRANGE key start end [COUNT count], XREVRANGE key end start [COUNT count]
+func cmdXRange(c *conn, w *writer, args []string) {
+	rev := strings.EqualFold(args[0], "xrevrange")
+	startArg, endArg := args[2], args[3]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, ok := parseRangeID(w, startArg, 0, true)
+	if !ok {
+		return
+	}
+	end, ok := parseRangeID(w, endArg, math.MaxUint64, false)
+	if !ok {
+		return
+	}
+	count := int64(-1)
+	if len(args) == 6 && strings.EqualFold(args[4], "COUNT") {
+		if count, ok = parseInt(w, args[5]); !ok {
+			return
+		}
+	} else if len(args) != 4 {
+		w.error(errSyntax)
+		return
+	}
+
+	it, ok := c.lookupKind(w, args[1], kindStream)
+	if !ok {
+		return
+	}
+	var entries []streamEntry
+	if it != nil && !end.less(start) {
+		from, to := it.stream.search(start), len(it.stream.entries)
+		if next, ok := end.next(); ok {
+			to = it.stream.search(next)
+		}
+		entries = it.stream.entries[from:to]
+	}
+	if count >= 0 && int64(len(entries)) > count {
+		if rev {
+			entries = entries[int64(len(entries))-count:]
+		} else {
+			entries = entries[:count]
+		}
+	}
+	w.array(len(entries))
+	for i := range entries {
+		if rev {
+			writeStreamEntry(w, &entries[len(entries)-1-i])
+		} else {
+			writeStreamEntry(w, &entries[i])
+		}
+	}
+}
+
+// cmdXDel XDEL key id [id ...]
+func cmdXDel(c *conn, w *writer, args []string) {
+	ids := make([]streamID, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		id, ok := parseStreamID(arg, 0)
+		if !ok {
+			w.error(errInvalidStreamID)
+			return
+		}
+		ids = append(ids, id)
+	}
+	it, ok := c.lookupKind(w, args[1], kindStream)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	deleted := 0
+	for _, id := range ids {
+		i := it.stream.search(id)
+		if i < len(it.stream.entries) && it.stream.entries[i].id == id {
+			it.stream.entries = append(it.stream.entries[:i], it.stream.entries[i+1:]...)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		c.notify('t', "xdel", args[1])
+	}
+	w.integer(int64(deleted))
+}
+
+// lookupGroup 返回消费组, Stream 或消费组不存在时写入 NOGROUP 错误
+func (that *conn) lookupGroup(w *writer, key string, group string, command string) (*streamValue, *streamGroup, bool) {
+	it, ok := that.lookupKind(w, key, kindStream)
+	if !ok {
+		return nil, nil, false
+	}
+	if it == nil || it.stream.groups[group] == nil {
+		w.errorf("NOGROUP No such key '%s' or consumer group '%s' in %s command", key, group, command)
+		return nil, nil, false
+	}
+	return it.stream, it.stream.groups[group], true
+}
+
+// cmdXGroup XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD n] | SETID key group id|$ | DESTROY key group |
+// CREATECONSUMER key group consumer | DELCONSUMER key group consumer
+func cmdXGroup(c *conn, w *writer, args []string) {
+	sub := strings.ToUpper(args[1])
+	if len(args) < 4 {
+		w.errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try XGROUP HELP.", args[1])
+		return
+	}
+	key, name := args[2], args[3]
+	switch {
+	case sub == "CREATE" && len(args) >= 5:
+		mkStream := false
+		for i := 5; i < len(args); i++ {
+			switch {
+			case strings.EqualFold(args[i], "MKSTREAM"):
+				mkStream = true
+			case strings.EqualFold(args[i], "ENTRIESREAD") && i+1 < len(args):
+				i++
+			default:
+				w.error(errSyntax)
+				return
+			}
+		}
+		it, ok := c.lookupKind(w, key, kindStream)
+		if !ok {
+			return
+		}
+		if it == nil {
+			if !mkStream {
+				w.error("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+				return
+			}
+			it = newItem(kindStream)
+			c.store(key, it)
+		}
+		if it.stream.groups[name] != nil {
+			w.error("BUSYGROUP Consumer Group name already exists")
+			return
+		}
+		id, ok := groupStartID(w, it.stream, args[4])
+		if !ok {
+			return
+		}
+		it.stream.groups[name] = &streamGroup{lastID: id, pending: make(map[streamID]*pendingEntry), consumers: make(map[string]time.Time)}
+		c.notify('t', "xgroup-create", key)
+		w.ok()
+	case sub == "SETID" && len(args) >= 5:
+		stream, group, ok := c.lookupGroup(w, key, name, "XGROUP")
+		if !ok {
+			return
+		}
+		id, ok := groupStartID(w, stream, args[4])
+		if !ok {
+			return
+		}
+		group.lastID = id
+		c.notify('t', "xgroup-setid", key)
+		w.ok()
+	case sub == "DESTROY" && len(args) == 4:
+		it, ok := c.lookupKind(w, key, kindStream)
+		if !ok {
+			return
+		}
+		if it == nil || it.stream.groups[name] == nil {
+			w.integer(0)
+			return
+		}
+		delete(it.stream.groups, name)
+		c.notify('t', "xgroup-destroy", key)
+		w.integer(1)
+	case sub == "CREATECONSUMER" && len(args) == 5:
+		_, group, ok := c.lookupGroup(w, key, name, "XGROUP")
+		if !ok {
+			return
+		}
+		if _, exists := group.consumers[args[4]]; exists {
+			w.integer(0)
+			return
+		}
+		group.consumers[args[4]] = c.server.now()
+		c.notify('t', "xgroup-createconsumer", key)
+		w.integer(1)
+	case sub == "DELCONSUMER" && len(args) == 5:
+		_, group, ok := c.lookupGroup(w, key, name, "XGROUP")
+		if !ok {
+			return
+		}
+		if _, exists := group.consumers[args[4]]; !exists {
+			w.integer(0)
+			return
+		}
+		ids := group.pendingIDs(args[4])
+		for _, id := range ids {
+			delete(group.pending, id)
+		}
+		delete(group.consumers, args[4])
+		c.notify('t', "xgroup-delconsumer", key)
+		w.integer(int64(len(ids)))
+	default:
+		w.errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try XGROUP HELP.", args[1])
+	}
+}
+
+func groupStartID(w *writer, stream *streamValue, s string) (streamID, bool) {
+	if s == "$" {
+		return stream.lastID, true
+	}
+	id, ok := parseStreamID(s, 0)
+	if !ok {
+		w.error(errInvalidStreamID)
+	}
+	return id, ok
+}
+
+// streamReadResult XREAD/XREADGROUP 一个 Stream 的读取结果
+type streamReadResult struct {
+	key     string
+	entries []*streamEntry
+	ids     []streamID // 已删除的待确认消息只有 ID
+}
+
+// cmdXRead XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+// 与 XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func cmdXRead(c *conn, w *writer, args []string) {
+	group := strings.EqualFold(args[0], "xreadgroup")
+	var groupName, consumer string
+	i := 1
+	if group {
+		if len(args) < 4 || !strings.EqualFold(args[1], "GROUP") {
+			w.error("ERR Missing GROUP option for XREADGROUP")
+			return
+		}
+		groupName, consumer = args[2], args[3]
+		i = 4
+	}
+
+	count, block, noAck := int64(0), time.Duration(-1), false
+	for ; i < len(args) && !strings.EqualFold(args[i], "STREAMS"); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "COUNT" && i+1 < len(args):
+			n, ok := parseInt(w, args[i+1])
+			if !ok {
+				return
+			}
+			count = max(n, 0)
+			i++
+		case opt == "BLOCK" && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				w.error("ERR timeout is not an integer or out of range")
+				return
+			}
+			if n < 0 {
+				w.error("ERR timeout is negative")
+				return
+			}
+			block = time.Duration(n) * time.Millisecond
+			i++
+		case opt == "NOACK" && group:
+			noAck = true
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+	rest := args[min(i+1, len(args)):]
+	if i >= len(args) || len(rest) == 0 || len(rest)%2 != 0 {
+		w.error("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+		return
+	}
+	keys, ids := rest[:len(rest)/2], rest[len(rest)/2:]
+
+	// 阻塞重试时使用第一次执行时确定的起始 ID, 否则 $ 会跳过等待期间写入的消息
+	if !c.blocking || len(c.streamIDs) != len(keys) {
+		c.streamIDs = make([]streamID, len(keys))
+		for k, key := range keys {
+			it, ok := c.lookupKind(w, key, kindStream)
+			if !ok {
+				return
+			}
+			switch {
+			case ids[k] == ">" && group:
+			case ids[k] == "$" && !group:
+				if it != nil {
+					c.streamIDs[k] = it.stream.lastID
+				}
+			case ids[k] == "+" && !group:
+				c.streamIDs[k] = maxStreamID // 读取最后一条消息
+			default:
+				id, ok := parseStreamID(ids[k], 0)
+				if !ok {
+					w.error(errInvalidStreamID)
+					return
+				}
+				c.streamIDs[k] = id
+			}
+		}
+	}
+
+	results := make([]streamReadResult, 0, len(keys))
+	history := false
+	for k, key := range keys {
+		var stream *streamValue
+		if group {
+			s, g, ok := c.lookupGroup(w, key, groupName, "XREADGROUP")
+			if !ok {
+				return
+			}
+			if _, exists := g.consumers[consumer]; !exists {
+				c.notify('t', "xgroup-createconsumer", key)
+			}
+			g.consumers[consumer] = c.server.now()
+			if ids[k] != ">" {
+				history = true
+				results = append(results, c.readPending(key, s, g, consumer, c.streamIDs[k], count))
+				continue
+			}
+			stream = s
+		} else {
+			it, ok := c.lookupKind(w, key, kindStream)
+			if !ok {
+				return
+			}
+			if it == nil {
+				continue
+			}
+			stream = it.stream
+		}
+
+		after := c.streamIDs[k]
+		if group {
+			after = stream.groups[groupName].lastID
+		}
+		var entries []*streamEntry
+		if after == maxStreamID && len(stream.entries) > 0 { // XREAD + 返回最后一条消息
+			entries = append(entries, &stream.entries[len(stream.entries)-1])
+		} else if next, ok := after.next(); ok {
+			for j := stream.search(next); j < len(stream.entries) && (count == 0 || int64(len(entries)) < count); j++ {
+				entries = append(entries, &stream.entries[j])
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if group {
+			g := stream.groups[groupName]
+			now := c.server.now()
+			for _, entry := range entries {
+				g.lastID = entry.id
+				if !noAck {
+					g.pending[entry.id] = &pendingEntry{consumer: consumer, delivered: now, count: 1}
+				}
+			}
+		}
+		results = append(results, streamReadResult{key: key, entries: entries})
+	}
+
+	if len(results) == 0 && !history {
+		if block >= 0 {
+			c.block(w, block)
+			return
+		}
+		w.nullArray()
+		return
+	}
+
+	if w.proto == 3 {
+		w.mapHeader(len(results))
+	} else {
+		w.array(len(results))
+	}
+	for _, r := range results {
+		if w.proto != 3 {
+			w.array(2)
+		}
+		w.bulk(r.key)
+		w.array(len(r.entries) + len(r.ids))
+		for _, entry := range r.entries {
+			writeStreamEntry(w, entry)
+		}
+		for _, id := range r.ids {
+			w.array(2)
+			w.bulk(id.String())
+			w.nullArray()
+		}
+	}
+}
+
+// readPending XREADGROUP 读取 consumer 自己 ID 大于 after 的待确认消息, 同时更新投递时间与次数
+func (that *conn) readPending(key string, stream *streamValue, group *streamGroup, consumer string, after streamID, count int64) streamReadResult {
+	r := streamReadResult{key: key}
+	now := that.server.now()
+	for _, id := range group.pendingIDs(consumer) {
+		if !after.less(id) {
+			continue
+		}
+		if count > 0 && int64(len(r.entries)+len(r.ids)) >= count {
+			break
+		}
+		p := group.pending[id]
+		p.delivered = now
+		p.count++
+		if entry := stream.find(id); entry != nil {
+			r.entries = append(r.entries, entry)
+		} else {
+			r.ids = append(r.ids, id)
+		}
+	}
+	return r
+}
+
+// cmdXAck XACK key group id [id ...]
+func cmdXAck(c *conn, w *writer, args []string) {
+	ids := make([]streamID, 0, len(args)-3)
+	for _, arg := range args[3:] {
+		id, ok := parseStreamID(arg, 0)
+		if !ok {
+			w.error(errInvalidStreamID)
+			return
+		}
+		ids = append(ids, id)
+	}
+	it, ok := c.lookupKind(w, args[1], kindStream)
+	if !ok {
+		return
+	}
+	if it == nil || it.stream.groups[args[2]] == nil {
+		w.integer(0)
+		return
+	}
+	group := it.stream.groups[args[2]]
+	acked := 0
+	for _, id := range ids {
+		if group.pending[id] != nil {
+			delete(group.pending, id)
+			acked++
+		}
+	}
+	w.integer(int64(acked))
+}
+
+// cmdXPending XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func cmdXPending(c *conn, w *writer, args []string) {
+	_, group, ok := c.lookupGroup(w, args[1], args[2], "XPENDING")
+	if !ok {
+		return
+	}
+	if len(args) == 3 {
+		ids := group.pendingIDs("")
+		if len(ids) == 0 {
+			w.array(4)
+			w.integer(0)
+			w.null()
+			w.null()
+			w.nullArray()
+			return
+		}
+		counts := make(map[string]int64)
+		for _, p := range group.pending {
+			counts[p.consumer]++
+		}
+		consumers := sortedFields(counts)
+		w.array(4)
+		w.integer(int64(len(ids)))
+		w.bulk(ids[0].String())
+		w.bulk(ids[len(ids)-1].String())
+		w.array(len(consumers))
+		for _, consumer := range consumers {
+			w.array(2)
+			w.bulk(consumer)
+			w.bulk(strconv.FormatInt(counts[consumer], 10))
+		}
+		return
+	}
+
+	rest := args[3:]
+	minIdle := time.Duration(0)
+	if strings.EqualFold(rest[0], "IDLE") && len(rest) > 1 {
+		n, ok := parseInt(w, rest[1])
+		if !ok {
+			return
+		}
+		minIdle = time.Duration(n) * time.Millisecond
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		w.error(errSyntax)
+		return
+	}
+	start, ok := parseRangeID(w, rest[0], 0, true)
+	if !ok {
+		return
+	}
+	end, ok := parseRangeID(w, rest[1], math.MaxUint64, false)
+	if !ok {
+		return
+	}
+	count, ok := parseInt(w, rest[2])
+	if !ok {
+		return
+	}
+	consumer := ""
+	if len(rest) == 4 {
+		consumer = rest[3]
+	}
+
+	now := c.server.now()
+	type row struct {
+		id streamID
+		p  *pendingEntry
+	}
+	rows := make([]row, 0)
+	for _, id := range group.pendingIDs(consumer) {
+		p := group.pending[id]
+		if id.less(start) || end.less(id) || now.Sub(p.delivered) < minIdle {
+			continue
+		}
+		if int64(len(rows)) >= count {
+			break
+		}
+		rows = append(rows, row{id: id, p: p})
+	}
+	w.array(len(rows))
+	for _, r := range rows {
+		w.array(4)
+		w.bulk(r.id.String())
+		w.bulk(r.p.consumer)
+		w.integer(now.Sub(r.p.delivered).Milliseconds())
+		w.integer(r.p.count)
+	}
+}
+
+// cmdXClaim XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-ms] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID id]
+func cmdXClaim(c *conn, w *writer, args []string) {
+	minIdle, ok := parseInt(w, args[4])
+	if !ok {
+		return
+	}
+	var ids []streamID
+	i := 5
+	for ; i < len(args); i++ {
+		id, ok := parseStreamID(args[i], 0)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	now := c.server.now()
+	delivered, retryCount, force, justID := now, int64(-1), false, false
+	for ; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "IDLE" && i+1 < len(args):
+			n, ok := parseInt(w, args[i+1])
+			if !ok {
+				return
+			}
+			delivered = now.Add(-time.Duration(n) * time.Millisecond)
+			i++
+		case opt == "TIME" && i+1 < len(args):
+			n, ok := parseInt(w, args[i+1])
+			if !ok {
+				return
+			}
+			delivered = time.UnixMilli(n)
+			i++
+		case opt == "RETRYCOUNT" && i+1 < len(args):
+			if retryCount, ok = parseInt(w, args[i+1]); !ok {
+				return
+			}
+			i++
+		case opt == "LASTID" && i+1 < len(args):
+			i++
+		case opt == "FORCE":
+			force = true
+		case opt == "JUSTID":
+			justID = true
+		default:
+			w.errorf("ERR Unrecognized XCLAIM option '%s'", args[i])
+			return
+		}
+	}
+
+	stream, group, ok := c.lookupGroup(w, args[1], args[2], "XCLAIM")
+	if !ok {
+		return
+	}
+	claimed := make([]streamID, 0, len(ids))
+	for _, id := range ids {
+		p := group.pending[id]
+		if p == nil {
+			if !force || stream.find(id) == nil {
+				continue
+			}
+			p = &pendingEntry{}
+			group.pending[id] = p
+		}
+		if now.Sub(p.delivered) < time.Duration(minIdle)*time.Millisecond {
+			continue
+		}
+		if stream.find(id) == nil { // 消息已被删除, 从待确认列表中移除
+			delete(group.pending, id)
+			continue
+		}
+		p.consumer, p.delivered = args[3], delivered
+		if retryCount >= 0 {
+			p.count = retryCount
+		} else if !justID {
+			p.count++
+		}
+		claimed = append(claimed, id)
+	}
+	group.consumers[args[3]] = now
+	if len(claimed) > 0 {
+		c.notify('t', "xclaim", args[1])
+	}
+
+	w.array(len(claimed))
+	for _, id := range claimed {
+		if justID {
+			w.bulk(id.String())
+		} else {
+			writeStreamEntry(w, stream.find(id))
+		}
+	}
+}
+
+// cmdXAutoClaim XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func cmdXAutoClaim(c *conn, w *writer, args []string) {
+	minIdle, ok := parseInt(w, args[4])
+	if !ok {
+		return
+	}
+	start, ok := parseRangeID(w, args[5], 0, true)
+	if !ok {
+		return
+	}
+	count, justID := int64(100), false
+	for i := 6; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "COUNT" && i+1 < len(args):
+			if count, ok = parseInt(w, args[i+1]); !ok {
+				return
+			}
+			if count < 1 {
+				w.error("ERR COUNT must be > 0")
+				return
+			}
+			i++
+		case opt == "JUSTID":
+			justID = true
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+
+	stream, group, ok := c.lookupGroup(w, args[1], args[2], "XAUTOCLAIM")
+	if !ok {
+		return
+	}
+	now := c.server.now()
+	var claimed, deleted []streamID
+	next := streamID{}
+	scanned := int64(0)
+	for _, id := range group.pendingIDs("") {
+		if id.less(start) {
+			continue
+		}
+		if scanned >= count*10 || int64(len(claimed)) >= count { // 与 Redis 相同, 每次最多检查 count*10 条
+			next = id
+			break
+		}
+		scanned++
+		p := group.pending[id]
+		if now.Sub(p.delivered) < time.Duration(minIdle)*time.Millisecond {
+			continue
+		}
+		if stream.find(id) == nil {
+			delete(group.pending, id)
+			deleted = append(deleted, id)
+			continue
+		}
+		p.consumer, p.delivered = args[3], now
+		if !justID {
+			p.count++
+		}
+		claimed = append(claimed, id)
+	}
+	group.consumers[args[3]] = now
+	if len(claimed) > 0 {
+		c.notify('t', "xautoclaim", args[1])
+	}
+
+	w.array(3)
+	w.bulk(next.String())
+	w.array(len(claimed))
+	for _, id := range claimed {
+		if justID {
+			w.bulk(id.String())
+		} else {
+			writeStreamEntry(w, stream.find(id))
+		}
+	}
+	w.array(len(deleted))
+	for _, id := range deleted {
+		w.bulk(id.String())
+	}
+}
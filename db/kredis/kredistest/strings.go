@@ -0,0 +1,401 @@
+package kredistest
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseExpire 解析 EX/PX/EXAT/PXAT 的参数, 返回过期时刻
+func (that *conn) parseExpire(w *writer, opt string, arg string, command string) (time.Time, bool) {
+	n, ok := parseInt(w, arg)
+	if !ok {
+		return time.Time{}, false
+	}
+	if n <= 0 {
+		w.errorf("ERR invalid expire time in '%s' command", command)
+		return time.Time{}, false
+	}
+	switch opt {
+	case "EX":
+		return that.server.expireAt(n, time.Second), true
+	case "PX":
+		return that.server.expireAt(n, time.Millisecond), true
+	case "EXAT":
+		return unixTime(n, time.Second), true
+	}
+	return unixTime(n, time.Millisecond), true
+}
+
+// cmdSet SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|KEEPTTL]
+func cmdSet(c *conn, w *writer, args []string) {
+	key, value := args[1], args[2]
+	var nx, xx, get, keepTTL bool
+	var expires time.Time
+	for i := 3; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if !expires.IsZero() || i+1 >= len(args) {
+				w.error(errSyntax)
+				return
+			}
+			at, ok := c.parseExpire(w, opt, args[i+1], "set")
+			if !ok {
+				return
+			}
+			expires = at
+			i++
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+	if (nx && xx) || (keepTTL && !expires.IsZero()) {
+		w.error(errSyntax)
+		return
+	}
+
+	old := c.server.lookup(c.db, key)
+	if get && old != nil && old.kind != kindString {
+		w.error(errWrongType)
+		return
+	}
+	if (nx && old != nil) || (xx && old == nil) {
+		if get && old != nil {
+			w.bulk(old.str)
+		} else {
+			w.null()
+		}
+		return
+	}
+
+	it := &item{kind: kindString, str: value, expires: expires}
+	if keepTTL && old != nil {
+		it.expires = old.expires
+	}
+	c.store(key, it)
+	c.notify('$', "set", key)
+	switch {
+	case !get:
+		w.ok()
+	case old == nil:
+		w.null()
+	default:
+		w.bulk(old.str)
+	}
+}
+
+func cmdSetNX(c *conn, w *writer, args []string) {
+	if c.server.lookup(c.db, args[1]) != nil {
+		w.integer(0)
+		return
+	}
+	c.store(args[1], &item{kind: kindString, str: args[2]})
+	c.notify('$', "set", args[1])
+	w.integer(1)
+}
+
+// cmdSetEX SETEX key seconds value, PSETEX key milliseconds value
+func cmdSetEX(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	opt := "EX"
+	if name == "psetex" {
+		opt = "PX"
+	}
+	expires, ok := c.parseExpire(w, opt, args[2], name)
+	if !ok {
+		return
+	}
+	c.store(args[1], &item{kind: kindString, str: args[3], expires: expires})
+	c.notify('$', "set", args[1])
+	w.ok()
+}
+
+func cmdGetSet(c *conn, w *writer, args []string) {
+	old, ok := c.lookupKind(w, args[1], kindString)
+	if !ok {
+		return
+	}
+	c.store(args[1], &item{kind: kindString, str: args[2]})
+	c.notify('$', "set", args[1])
+	if old == nil {
+		w.null()
+		return
+	}
+	w.bulk(old.str)
+}
+
+func cmdGet(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindString)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.null()
+		return
+	}
+	w.bulk(it.str)
+}
+
+func cmdGetDel(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindString)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.null()
+		return
+	}
+	delete(c.server.dbs[c.db].items, args[1])
+	c.notify('g', "del", args[1])
+	w.bulk(it.str)
+}
+
+// cmdGetEX GETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|PERSIST]
+func cmdGetEX(c *conn, w *writer, args []string) {
+	var expires time.Time
+	persist := false
+	switch {
+	case len(args) == 2:
+	case len(args) == 3 && strings.EqualFold(args[2], "PERSIST"):
+		persist = true
+	case len(args) == 4:
+		opt := strings.ToUpper(args[2])
+		if opt != "EX" && opt != "PX" && opt != "EXAT" && opt != "PXAT" {
+			w.error(errSyntax)
+			return
+		}
+		at, ok := c.parseExpire(w, opt, args[3], "getex")
+		if !ok {
+			return
+		}
+		expires = at
+	default:
+		w.error(errSyntax)
+		return
+	}
+
+	it, ok := c.lookupKind(w, args[1], kindString)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.null()
+		return
+	}
+	switch {
+	case persist && !it.expires.IsZero():
+		it.expires = time.Time{}
+		c.notify('g', "persist", args[1])
+	case !expires.IsZero():
+		it.expires = expires
+		c.notify('g', "expire", args[1])
+	}
+	w.bulk(it.str)
+}
+
+// cmdMGet 不存在或不是字符串的 key 返回空值
+func cmdMGet(c *conn, w *writer, args []string) {
+	w.array(len(args) - 1)
+	for _, key := range args[1:] {
+		it := c.server.lookup(c.db, key)
+		if it == nil || it.kind != kindString {
+			w.null()
+			continue
+		}
+		w.bulk(it.str)
+	}
+}
+
+// cmdMSet MSET/MSETNX key value [key value ...], MSETNX 在任意 key 已存在时不做任何修改
+func cmdMSet(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	if len(args)%2 != 1 {
+		w.errorf("ERR wrong number of arguments for '%s' command", name)
+		return
+	}
+	nx := name == "msetnx"
+	if nx {
+		for i := 1; i < len(args); i += 2 {
+			if c.server.lookup(c.db, args[i]) != nil {
+				w.integer(0)
+				return
+			}
+		}
+	}
+	for i := 1; i < len(args); i += 2 {
+		c.store(args[i], &item{kind: kindString, str: args[i+1]})
+		c.notify('$', "set", args[i])
+	}
+	if nx {
+		w.integer(1)
+	} else {
+		w.ok()
+	}
+}
+
+// cmdIncr INCR/DECR/INCRBY/DECRBY, 保留原有的过期时间
+func cmdIncr(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	delta := int64(1)
+	if len(args) == 3 {
+		n, ok := parseInt(w, args[2])
+		if !ok {
+			return
+		}
+		delta = n
+	}
+	if strings.HasPrefix(name, "decr") {
+		if delta == math.MinInt64 {
+			w.error("ERR decrement would overflow")
+			return
+		}
+		delta = -delta
+	}
+
+	it, ok := c.lookupOrCreateString(w, args[1], "0")
+	if !ok {
+		return
+	}
+	n, err := strconv.ParseInt(it.str, 10, 64)
+	if err != nil {
+		w.error(errNotInteger)
+		return
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		w.error("ERR increment or decrement would overflow")
+		return
+	}
+	n += delta
+	it.str = strconv.FormatInt(n, 10)
+	c.notify('$', "incrby", args[1])
+	w.integer(n)
+}
+
+func cmdIncrByFloat(c *conn, w *writer, args []string) {
+	delta, ok := parseFloat(w, args[2])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupOrCreateString(w, args[1], "0")
+	if !ok {
+		return
+	}
+	f, err := parseScore(it.str)
+	if err != nil {
+		w.error(errNotFloat)
+		return
+	}
+	f += delta
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		w.error("ERR increment would produce NaN or Infinity")
+		return
+	}
+	it.str = formatFloat(f)
+	c.notify('$', "incrbyfloat", args[1])
+	w.bulk(it.str)
+}
+
+// lookupOrCreateString 返回字符串值, key 不存在时以 initial 创建
+func (that *conn) lookupOrCreateString(w *writer, key string, initial string) (*item, bool) {
+	it, ok := that.lookupKind(w, key, kindString)
+	if !ok {
+		return nil, false
+	}
+	if it == nil {
+		it = &item{kind: kindString, str: initial}
+		that.store(key, it)
+	}
+	return it, true
+}
+
+func cmdAppend(c *conn, w *writer, args []string) {
+	it, ok := c.lookupOrCreateString(w, args[1], "")
+	if !ok {
+		return
+	}
+	it.str += args[2]
+	c.notify('$', "append", args[1])
+	w.integer(int64(len(it.str)))
+}
+
+func cmdStrLen(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindString)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(it.str)))
+}
+
+func cmdGetRange(c *conn, w *writer, args []string) {
+	start, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	end, ok := parseInt(w, args[3])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindString)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.bulk("")
+		return
+	}
+	from, to, ok := normalizeRange(start, end, len(it.str))
+	if !ok {
+		w.bulk("")
+		return
+	}
+	w.bulk(it.str[from : to+1])
+}
+
+// cmdSetRange SETRANGE key offset value, offset 超出长度时用 0 字节填充
+func cmdSetRange(c *conn, w *writer, args []string) {
+	offset, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+	if offset < 0 || offset+int64(len(args[3])) > maxBulkLen {
+		w.error("ERR offset is out of range")
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindString)
+	if !ok {
+		return
+	}
+	if it == nil {
+		if args[3] == "" {
+			w.integer(0)
+			return
+		}
+		it = &item{kind: kindString}
+		c.store(args[1], it)
+	}
+	if args[3] != "" {
+		buf := []byte(it.str)
+		if need := int(offset) + len(args[3]); need > len(buf) {
+			buf = append(buf, make([]byte, need-len(buf))...)
+		}
+		copy(buf[offset:], args[3])
+		it.str = string(buf)
+		c.notify('$', "setrange", args[1])
+	}
+	w.integer(int64(len(it.str)))
+}
@@ -0,0 +1,651 @@
+package kredistest
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type zmember struct {
+	member string
+	score  float64
+}
+
+// sorted 按分数升序排列, 分数相同时按成员字典序
+func (that *item) sorted() []zmember {
+	members := make([]zmember, 0, len(that.zset))
+	for member, score := range that.zset {
+		members = append(members, zmember{member: member, score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].member < members[j].member
+	})
+	return members
+}
+
+// scoreBound 分数区间的端点, 例如 1.5, (1.5, -inf, +inf
+type scoreBound struct {
+	value     float64
+	exclusive bool
+}
+
+func parseScoreBound(s string) (scoreBound, bool) {
+	bound := scoreBound{}
+	if strings.HasPrefix(s, "(") {
+		bound.exclusive = true
+		s = s[1:]
+	}
+	f, err := parseScore(s)
+	bound.value = f
+	return bound, err == nil
+}
+
+func inScoreRange(score float64, lo scoreBound, hi scoreBound) bool {
+	aboveMin := score > lo.value || (!lo.exclusive && score == lo.value)
+	belowMax := score < hi.value || (!hi.exclusive && score == hi.value)
+	return aboveMin && belowMax
+}
+
+// lexBound 字典序区间的端点, 例如 [a, (a, -, +
+type lexBound struct {
+	value     string
+	exclusive bool
+	inf       int // -1 表示 "-", 1 表示 "+"
+}
+
+func parseLexBound(s string) (lexBound, bool) {
+	switch {
+	case s == "-":
+		return lexBound{inf: -1}, true
+	case s == "+":
+		return lexBound{inf: 1}, true
+	case strings.HasPrefix(s, "["):
+		return lexBound{value: s[1:]}, true
+	case strings.HasPrefix(s, "("):
+		return lexBound{value: s[1:], exclusive: true}, true
+	}
+	return lexBound{}, false
+}
+
+func inLexRange(member string, lo lexBound, hi lexBound) bool {
+	aboveMin := lo.inf == -1 || (lo.inf == 0 && (member > lo.value || (!lo.exclusive && member == lo.value)))
+	belowMax := hi.inf == 1 || (hi.inf == 0 && (member < hi.value || (!hi.exclusive && member == hi.value)))
+	return aboveMin && belowMax
+}
+
+// filterRange 返回分数或字典序在 [min, max] 区间内的成员, 保持原有顺序
+func filterRange(w *writer, members []zmember, by string, min string, max string) ([]zmember, bool) {
+	result := make([]zmember, 0, len(members))
+	if by == "score" {
+		lo, ok1 := parseScoreBound(min)
+		hi, ok2 := parseScoreBound(max)
+		if !ok1 || !ok2 {
+			w.error("ERR min or max is not a float")
+			return nil, false
+		}
+		for _, m := range members {
+			if inScoreRange(m.score, lo, hi) {
+				result = append(result, m)
+			}
+		}
+		return result, true
+	}
+
+	lo, ok1 := parseLexBound(min)
+	hi, ok2 := parseLexBound(max)
+	if !ok1 || !ok2 {
+		w.error("ERR min or max not valid string range item")
+		return nil, false
+	}
+	for _, m := range members {
+		if inLexRange(m.member, lo, hi) {
+			result = append(result, m)
+		}
+	}
+	return result, true
+}
+
+// writeScored 输出成员列表, WITHSCORES 时 RESP3 为 [member, score] 数组的数组, RESP2 为交替的成员与分数
+func writeScored(w *writer, members []zmember, withScores bool) {
+	switch {
+	case !withScores:
+		w.array(len(members))
+		for _, m := range members {
+			w.bulk(m.member)
+		}
+	case w.proto == 3:
+		w.array(len(members))
+		for _, m := range members {
+			w.array(2)
+			w.bulk(m.member)
+			w.double(m.score)
+		}
+	default:
+		w.array(len(members) * 2)
+		for _, m := range members {
+			w.bulk(m.member)
+			w.double(m.score)
+		}
+	}
+}
+
+// cmdZAdd ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func cmdZAdd(c *conn, w *writer, args []string) {
+	var nx, xx, gt, lt, ch, incr bool
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		w.error(errSyntax)
+		return
+	}
+	if nx && xx {
+		w.error("ERR XX and NX options at the same time are not compatible")
+		return
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		w.error("ERR GT, LT, and/or NX options at the same time are not compatible")
+		return
+	}
+	if incr && len(pairs) != 2 {
+		w.error("ERR INCR option supports a single increment-element pair")
+		return
+	}
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		f, err := parseScore(pairs[j*2])
+		if err != nil {
+			w.error(errNotFloat)
+			return
+		}
+		scores[j] = f
+	}
+
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	added, changed := int64(0), int64(0)
+	result, applied := 0.0, false
+	for j, score := range scores {
+		member := pairs[j*2+1]
+		var old float64
+		exists := false
+		if it != nil {
+			old, exists = it.zset[member]
+		}
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if incr && exists {
+			score += old
+			if math.IsNaN(score) {
+				w.error("ERR resulting score is not a number (NaN)")
+				return
+			}
+		}
+		if exists && ((gt && score <= old) || (lt && score >= old)) {
+			continue
+		}
+		if it == nil {
+			it = newItem(kindZSet)
+			c.store(args[1], it)
+		}
+		if !exists {
+			added++
+		} else if score != old {
+			changed++
+		}
+		it.zset[member] = score
+		result, applied = score, true
+	}
+
+	if added+changed > 0 {
+		if incr {
+			c.notify('z', "zincr", args[1])
+		} else {
+			c.notify('z', "zadd", args[1])
+		}
+	}
+	switch {
+	case incr && !applied:
+		w.null()
+	case incr:
+		w.double(result)
+	case ch:
+		w.integer(added + changed)
+	default:
+		w.integer(added)
+	}
+}
+
+func cmdZIncrBy(c *conn, w *writer, args []string) {
+	delta, ok := parseFloat(w, args[2])
+	if !ok {
+		return
+	}
+	it, ok := c.lookupOrCreate(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	score := it.zset[args[3]] + delta
+	if math.IsNaN(score) {
+		w.error("ERR resulting score is not a number (NaN)")
+		return
+	}
+	it.zset[args[3]] = score
+	c.notify('z', "zincr", args[1])
+	w.double(score)
+}
+
+func cmdZRem(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	removed := int64(0)
+	for _, member := range args[2:] {
+		if _, exists := it.zset[member]; exists {
+			delete(it.zset, member)
+			removed++
+		}
+	}
+	if removed > 0 {
+		c.notify('z', "zrem", args[1])
+		c.removeIfEmpty(args[1], it)
+	}
+	w.integer(removed)
+}
+
+func cmdZScore(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.null()
+		return
+	}
+	score, exists := it.zset[args[2]]
+	if !exists {
+		w.null()
+		return
+	}
+	w.double(score)
+}
+
+func cmdZMScore(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	w.array(len(args) - 2)
+	for _, member := range args[2:] {
+		if it == nil {
+			w.null()
+			continue
+		}
+		score, exists := it.zset[member]
+		if !exists {
+			w.null()
+			continue
+		}
+		w.double(score)
+	}
+}
+
+func cmdZCard(c *conn, w *writer, args []string) {
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	if it == nil {
+		w.integer(0)
+		return
+	}
+	w.integer(int64(len(it.zset)))
+}
+
+// cmdZCount ZCOUNT key min max (分数) 与 ZLEXCOUNT key min max (字典序)
+func cmdZCount(c *conn, w *writer, args []string) {
+	by := "score"
+	if strings.EqualFold(args[0], "zlexcount") {
+		by = "lex"
+	}
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	var members []zmember
+	if it != nil {
+		members = it.sorted()
+	}
+	matched, ok := filterRange(w, members, by, args[2], args[3])
+	if !ok {
+		return
+	}
+	w.integer(int64(len(matched)))
+}
+
+// cmdZRank ZRANK/ZREVRANK key member [WITHSCORE]
+func cmdZRank(c *conn, w *writer, args []string) {
+	withScore := false
+	if len(args) == 4 {
+		if !strings.EqualFold(args[3], "WITHSCORE") {
+			w.error(errSyntax)
+			return
+		}
+		withScore = true
+	} else if len(args) > 4 {
+		w.error(errSyntax)
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	rank := -1
+	var members []zmember
+	if it != nil {
+		members = it.sorted()
+		rank = slices.IndexFunc(members, func(m zmember) bool { return m.member == args[2] })
+	}
+	if rank < 0 {
+		if withScore {
+			w.nullArray()
+		} else {
+			w.null()
+		}
+		return
+	}
+
+	score := members[rank].score
+	if strings.EqualFold(args[0], "zrevrank") {
+		rank = len(members) - 1 - rank
+	}
+	if !withScore {
+		w.integer(int64(rank))
+		return
+	}
+	w.array(2)
+	w.integer(int64(rank))
+	w.double(score)
+}
+
+// cmdZRange 处理 ZRANGE/ZRANGESTORE 与旧版本的 ZREVRANGE, ZRANGEBYSCORE, ZREVRANGEBYSCORE, ZRANGEBYLEX, ZREVRANGEBYLEX:
+//
+//	ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+//	ZRANGESTORE dst src min max [BYSCORE|BYLEX] [REV] [LIMIT offset count]
+func cmdZRange(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	generic := name == "zrange" || name == "zrangestore"
+	store := name == "zrangestore"
+	pos := 1
+	if store {
+		pos = 2
+	}
+	key, start, stop := args[pos], args[pos+1], args[pos+2]
+
+	by, rev := "rank", strings.HasPrefix(name, "zrev")
+	switch {
+	case strings.HasSuffix(name, "byscore"):
+		by = "score"
+	case strings.HasSuffix(name, "bylex"):
+		by = "lex"
+	}
+	offset, count := int64(0), int64(-1)
+	limit, withScores := false, false
+	for i := pos + 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "WITHSCORES" && !store:
+			withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			n, ok := parseInt(w, args[i+1])
+			if !ok {
+				return
+			}
+			m, ok := parseInt(w, args[i+2])
+			if !ok {
+				return
+			}
+			offset, count, limit = n, m, true
+			i += 2
+		case opt == "BYSCORE" && generic:
+			by = "score"
+		case opt == "BYLEX" && generic:
+			by = "lex"
+		case opt == "REV" && generic:
+			rev = true
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+	if limit && by == "rank" {
+		w.error("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+		return
+	}
+	if withScores && by == "lex" {
+		w.error("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+		return
+	}
+
+	var startRank, stopRank int64
+	if by == "rank" {
+		var ok bool
+		if startRank, ok = parseInt(w, start); !ok {
+			return
+		}
+		if stopRank, ok = parseInt(w, stop); !ok {
+			return
+		}
+	} else if rev { // 倒序时先给出 max 再给出 min
+		start, stop = stop, start
+	}
+
+	it, ok := c.lookupKind(w, key, kindZSet)
+	if !ok {
+		return
+	}
+	var members []zmember
+	if it != nil {
+		members = it.sorted()
+	}
+	if rev {
+		slices.Reverse(members)
+	}
+
+	var result []zmember
+	if by == "rank" {
+		if from, to, ok := normalizeRange(startRank, stopRank, len(members)); ok {
+			result = members[from : to+1]
+		}
+	} else {
+		if result, ok = filterRange(w, members, by, start, stop); !ok {
+			return
+		}
+		if limit {
+			if offset < 0 {
+				result = nil
+			} else {
+				result = result[min(offset, int64(len(result))):]
+			}
+			if count >= 0 {
+				result = result[:min(count, int64(len(result)))]
+			}
+		}
+	}
+
+	if store {
+		c.zstore(args[1], result, "zrangestore")
+		w.integer(int64(len(result)))
+		return
+	}
+	writeScored(w, result, withScores)
+}
+
+// zstore 用 members 覆盖 key, members 为空时删除 key
+func (that *conn) zstore(key string, members []zmember, event string) {
+	if len(members) == 0 {
+		if that.remove(key) {
+			that.notify('g', "del", key)
+		}
+		return
+	}
+	it := newItem(kindZSet)
+	for _, m := range members {
+		it.zset[m.member] = m.score
+	}
+	that.store(key, it)
+	that.notify('z', event, key)
+}
+
+// cmdZRemRange ZREMRANGEBYRANK key start stop, ZREMRANGEBYSCORE key min max, ZREMRANGEBYLEX key min max
+func cmdZRemRange(c *conn, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	var startRank, stopRank int64
+	if name == "zremrangebyrank" {
+		var ok bool
+		if startRank, ok = parseInt(w, args[2]); !ok {
+			return
+		}
+		if stopRank, ok = parseInt(w, args[3]); !ok {
+			return
+		}
+	}
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	var members []zmember
+	if it != nil {
+		members = it.sorted()
+	}
+
+	var removed []zmember
+	switch name {
+	case "zremrangebyrank":
+		if from, to, ok := normalizeRange(startRank, stopRank, len(members)); ok {
+			removed = members[from : to+1]
+		}
+	case "zremrangebyscore":
+		if removed, ok = filterRange(w, members, "score", args[2], args[3]); !ok {
+			return
+		}
+	default:
+		if removed, ok = filterRange(w, members, "lex", args[2], args[3]); !ok {
+			return
+		}
+	}
+	for _, m := range removed {
+		delete(it.zset, m.member)
+	}
+	if len(removed) > 0 {
+		c.notify('z', name, args[1])
+		c.removeIfEmpty(args[1], it)
+	}
+	w.integer(int64(len(removed)))
+}
+
+// cmdZPop ZPOPMIN/ZPOPMAX key [count]
+func cmdZPop(c *conn, w *writer, args []string) {
+	if len(args) > 3 {
+		w.error(errSyntax)
+		return
+	}
+	count, hasCount := int64(1), len(args) == 3
+	if hasCount {
+		n, ok := parseInt(w, args[2])
+		if !ok {
+			return
+		}
+		if n < 0 {
+			w.error("ERR value is out of range, must be positive")
+			return
+		}
+		count = n
+	}
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	var members []zmember
+	if it != nil {
+		members = it.sorted()
+	}
+	name := strings.ToLower(args[0])
+	if name == "zpopmax" {
+		slices.Reverse(members)
+	}
+	popped := members[:min(count, int64(len(members)))]
+	for _, m := range popped {
+		delete(it.zset, m.member)
+	}
+	if len(popped) > 0 {
+		c.notify('z', name, args[1])
+		c.removeIfEmpty(args[1], it)
+	}
+
+	if !hasCount || w.proto == 2 { // 不带 count 时始终为 [member, score]
+		w.array(len(popped) * 2)
+		for _, m := range popped {
+			w.bulk(m.member)
+			w.double(m.score)
+		}
+		return
+	}
+	writeScored(w, popped, true)
+}
+
+// cmdZScan ZSCAN key cursor [MATCH pattern] [COUNT count], 分数以字符串形式返回
+func cmdZScan(c *conn, w *writer, args []string) {
+	cursor, match, count, _, ok := parseScanArgs(w, args[2:], false)
+	if !ok {
+		return
+	}
+	it, ok := c.lookupKind(w, args[1], kindZSet)
+	if !ok {
+		return
+	}
+	var members []string
+	if it != nil {
+		members = sortedFields(it.zset)
+	}
+	page, next := c.server.scanPage(cursor, members, count)
+	values := make([]string, 0, len(page)*2)
+	for _, member := range page {
+		if match == "" || matchGlob(match, member) {
+			values = append(values, member, formatFloat(it.zset[member]))
+		}
+	}
+	w.array(2)
+	w.bulk(strconv.FormatUint(next, 10))
+	w.bulks(values)
+}
@@ -0,0 +1,698 @@
+package kredis
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	kslices "github.com/khan-lau/kutils/container/kslices"
+	"github.com/khan-lau/kutils/data"
+
+	"github.com/andybalholm/brotli"
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// 备份文件格式:
+//
+//	header: "KRDB" | version(1B) | compression(1B) | 创建时间 unix 毫秒(8B)
+//	block:  压缩后长度(4B) | 记录数(4B) | DUMP 时间 unix 毫秒(8B) | 校验和(2B, data.CheckSum) | 压缩后的记录
+//	end:    长度为 0 的 block 头(4B) | 记录总数(8B)
+//
+// 每条记录: key, 类型, PTTL 毫秒(-1 表示永不过期), DUMP 数据, 变长整数长度前缀.
+// 所有整数均为大端序; 每个 block 独立压缩与校验, 导出与导入都是流式的, 内存占用只与 BatchSize 有关
+const (
+	backupMagic         = "KRDB"
+	backupVersion  byte = 1
+	backupMaxBlock      = 256 << 20 // 单个 block 压缩前后的最大长度, 防止损坏的文件导致超大内存分配
+)
+
+var (
+	ErrBackupFormat   = errors.New("kredis: invalid backup file")
+	ErrBackupChecksum = errors.New("kredis: backup block checksum mismatch")
+	ErrBackupKeyBusy  = errors.New("kredis: restore target key already exists")
+)
+
+// BackupCompression 备份文件的压缩算法. 导出时使用 data 包压缩;
+// 导入时直接使用对应的解压器读取, 以便限制解压后的长度, 格式与 data 包的解压函数一致
+type BackupCompression byte
+
+const (
+	BackupCompressNone    BackupCompression = iota // 不压缩
+	BackupCompressDeflate                          // deflate, data.Compress
+	BackupCompressGZip                             // gzip, data.GZip
+	BackupCompressZlib                             // zlib, data.Zip
+	BackupCompressBrotli                           // brotli, data.CompressBr, 压缩率最高, 速度最慢
+)
+
+func (that BackupCompression) compress(raw []byte) ([]byte, error) {
+	switch that {
+	case BackupCompressNone:
+		return raw, nil
+	case BackupCompressDeflate:
+		return data.Compress(raw)
+	case BackupCompressGZip:
+		return data.GZip(raw)
+	case BackupCompressZlib:
+		return data.Zip(raw)
+	case BackupCompressBrotli:
+		return data.CompressBr(raw)
+	}
+	return nil, fmt.Errorf("kredis: unknown backup compression %d", that)
+}
+
+// uncompress 解压一个 block, 解压后超过 limit 字节时返回错误, 防止压缩炸弹耗尽内存
+func (that BackupCompression) uncompress(raw []byte, limit int) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch that {
+	case BackupCompressNone:
+		return raw, nil
+	case BackupCompressDeflate:
+		r = flate.NewReader(bytes.NewReader(raw))
+	case BackupCompressGZip:
+		r, err = gzip.NewReader(bytes.NewReader(raw))
+	case BackupCompressZlib:
+		r, err = zlib.NewReader(bytes.NewReader(raw))
+	case BackupCompressBrotli:
+		r = io.NopCloser(brotli.NewReader(bytes.NewReader(raw)))
+	default:
+		return nil, fmt.Errorf("kredis: unknown backup compression %d", that)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > limit {
+		return nil, fmt.Errorf("uncompressed block exceeds %d bytes", limit)
+	}
+	return out, nil
+}
+
+// RestorePolicy 导入时目标 key 已存在的处理方式
+type RestorePolicy int
+
+const (
+	RestorePolicyReplace RestorePolicy = iota // 覆盖已存在的 key
+	RestorePolicySkip                         // 跳过已存在的 key
+	RestorePolicyFail                         // 遇到已存在的 key 时返回 ErrBackupKeyBusy
+)
+
+// RestoreTTLMode 导入时过期时间的处理方式
+type RestoreTTLMode int
+
+const (
+	RestoreTTLRemaining RestoreTTLMode = iota // 使用导出时剩余的过期时间
+	RestoreTTLAbsolute                        // 扣除从 DUMP 该 key 所在 block 到导入经过的时间, 已过期的 key 被跳过
+	RestoreTTLIgnore                          // 导入的 key 均不过期
+)
+
+// TransferProgress 导出, 导入与复制的进度
+type TransferProgress struct {
+	Scanned  int64 `json:"scanned"`  // 扫描到的 key 数量
+	Exported int64 `json:"exported"` // 导出的 key 数量
+	Restored int64 `json:"restored"` // 导入的 key 数量
+	Skipped  int64 `json:"skipped"`  // 因已存在, 已过期或导出期间被删除而跳过的 key 数量
+	Bytes    int64 `json:"bytes"`    // 写入或读取的文件字节数
+}
+
+// BackupOptions 导出参数
+type BackupOptions struct {
+	Match       string            `json:"match"`       // SCAN MATCH 模式, 在服务端过滤, 默认 "*"
+	Types       []string          `json:"types"`       // 需要导出的数据类型, 如 string, hash; 为空时导出所有类型
+	IgnoreKeys  []string          `json:"ignoreKeys"`  // 黑名单, 规则同 MatchFilter
+	IncludeKeys []string          `json:"includeKeys"` // 白名单, 规则同 MatchFilter, 优先级低于黑名单
+	ScanCount   int64             `json:"scanCount"`   // 每次 SCAN 的数量, 默认1000
+	BatchSize   int               `json:"batchSize"`   // 每个 block 的记录数, 也是 DUMP pipeline 的大小, 默认500
+	Compression BackupCompression `json:"compression"` // 压缩算法, 默认 BackupCompressDeflate
+
+	OnProgress func(progress TransferProgress) `json:"-"` // 每处理一批记录回调一次
+}
+
+func NewBackupOptions() *BackupOptions {
+	return &BackupOptions{
+		Match:       "*",
+		ScanCount:   1000,
+		BatchSize:   500,
+		Compression: BackupCompressDeflate,
+	}
+}
+
+func (that *BackupOptions) SetMatch(match string) *BackupOptions {
+	that.Match = match
+	return that
+}
+
+func (that *BackupOptions) SetTypes(types ...string) *BackupOptions {
+	that.Types = types
+	return that
+}
+
+// 设置黑名单与白名单, 规则同 MatchFilter
+func (that *BackupOptions) SetFilter(ignoreKeys []string, includeKeys []string) *BackupOptions {
+	that.IgnoreKeys = ignoreKeys
+	that.IncludeKeys = includeKeys
+	return that
+}
+
+func (that *BackupOptions) SetBatch(scanCount int64, batchSize int) *BackupOptions {
+	that.ScanCount = scanCount
+	that.BatchSize = batchSize
+	return that
+}
+
+func (that *BackupOptions) SetCompression(compression BackupCompression) *BackupOptions {
+	that.Compression = compression
+	return that
+}
+
+func (that *BackupOptions) SetProgress(fn func(progress TransferProgress)) *BackupOptions {
+	that.OnProgress = fn
+	return that
+}
+
+// RestoreOptions 导入参数
+type RestoreOptions struct {
+	Policy    RestorePolicy  `json:"policy"`    // 目标 key 已存在时的处理方式, 默认覆盖
+	TTLMode   RestoreTTLMode `json:"ttlMode"`   // 过期时间的处理方式, 默认使用导出时剩余的过期时间
+	BatchSize int            `json:"batchSize"` // RESTORE pipeline 的大小, 默认500
+
+	OnProgress func(progress TransferProgress) `json:"-"` // 每处理一批记录回调一次
+}
+
+func NewRestoreOptions() *RestoreOptions {
+	return &RestoreOptions{
+		Policy:    RestorePolicyReplace,
+		TTLMode:   RestoreTTLRemaining,
+		BatchSize: 500,
+	}
+}
+
+func (that *RestoreOptions) SetPolicy(policy RestorePolicy) *RestoreOptions {
+	that.Policy = policy
+	return that
+}
+
+func (that *RestoreOptions) SetTTLMode(mode RestoreTTLMode) *RestoreOptions {
+	that.TTLMode = mode
+	return that
+}
+
+func (that *RestoreOptions) SetBatchSize(size int) *RestoreOptions {
+	that.BatchSize = size
+	return that
+}
+
+func (that *RestoreOptions) SetProgress(fn func(progress TransferProgress)) *RestoreOptions {
+	that.OnProgress = fn
+	return that
+}
+
+///////////////////////////////////////////////////////////////
+
+// Backup 将匹配的 key 以 DUMP 格式流式导出到 w. 集群模式下扫描所有主节点.
+// 导出过程中 key 仍可能被修改, 结果不是某个时间点的快照; opts 为 nil 时使用 NewBackupOptions()
+func Backup(ctx context.Context, client KRedisClient, w io.Writer, opts *BackupOptions) (TransferProgress, error) {
+	if opts == nil {
+		opts = NewBackupOptions()
+	}
+	progress := TransferProgress{}
+	bw := bufio.NewWriter(w)
+	writer := &backupWriter{w: bw, compression: opts.Compression}
+	if err := writer.writeHeader(time.Now()); err != nil {
+		return progress, err
+	}
+
+	err := dumpKeys(ctx, client.UniversalClient(), opts, &progress, func(records []*RedisRecord, dumpedAt time.Time) error {
+		if err := writer.writeBlock(records, dumpedAt); err != nil {
+			return err
+		}
+		progress.Exported += int64(len(records))
+		progress.Bytes = writer.bytes
+		return nil
+	})
+	if err != nil {
+		return progress, err
+	}
+
+	if err := writer.writeEnd(); err != nil {
+		return progress, err
+	}
+	progress.Bytes = writer.bytes
+	return progress, bw.Flush()
+}
+
+// BackupToFile 导出到文件, 先写入同目录下的临时文件, 完成后重命名, 失败时不会留下不完整的备份文件
+func BackupToFile(ctx context.Context, client KRedisClient, path string, opts *BackupOptions) (TransferProgress, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return TransferProgress{}, err
+	}
+	defer os.Remove(file.Name()) // 重命名成功后删除失败, 可以忽略
+
+	progress, err := Backup(ctx, client, file, opts)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return progress, err
+	}
+	return progress, os.Rename(file.Name(), path)
+}
+
+// RestoreBackup 从 r 流式导入 Backup 导出的数据, 每个 block 在写入 Redis 前校验; opts 为 nil 时使用 NewRestoreOptions()
+func RestoreBackup(ctx context.Context, client KRedisClient, r io.Reader, opts *RestoreOptions) (TransferProgress, error) {
+	if opts == nil {
+		opts = NewRestoreOptions()
+	}
+	progress := TransferProgress{}
+	reader := &backupReader{r: bufio.NewReader(r)}
+	if err := reader.readHeader(); err != nil {
+		return progress, err
+	}
+
+	for {
+		records, dumpedAt, err := reader.readBlock()
+		progress.Bytes = reader.bytes
+		if err == io.EOF {
+			return progress, nil
+		}
+		if err != nil {
+			return progress, err
+		}
+
+		elapsed := time.Since(dumpedAt)
+		for start := 0; start < len(records); start += max(opts.BatchSize, 1) {
+			end := min(start+max(opts.BatchSize, 1), len(records))
+			if err := restoreRecords(ctx, client.UniversalClient(), records[start:end], elapsed, opts, &progress); err != nil {
+				return progress, err
+			}
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+	}
+}
+
+// RestoreBackupFromFile 从文件导入
+func RestoreBackupFromFile(ctx context.Context, client KRedisClient, path string, opts *RestoreOptions) (TransferProgress, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return TransferProgress{}, err
+	}
+	defer file.Close()
+	return RestoreBackup(ctx, client, file, opts)
+}
+
+// CopyKeys 不经过文件, 将 src 中匹配的 key 直接复制到 dst, 可用于单机与集群之间的迁移.
+// backupOpts 中的 Compression 不生效, 进度通过 restoreOpts.OnProgress 回调; 选项为 nil 时使用默认值
+func CopyKeys(ctx context.Context, src KRedisClient, dst KRedisClient, backupOpts *BackupOptions, restoreOpts *RestoreOptions) (TransferProgress, error) {
+	if backupOpts == nil {
+		backupOpts = NewBackupOptions()
+	}
+	if restoreOpts == nil {
+		restoreOpts = NewRestoreOptions()
+	}
+	progress := TransferProgress{}
+	scanOpts := *backupOpts
+	scanOpts.OnProgress = nil
+
+	err := dumpKeys(ctx, src.UniversalClient(), &scanOpts, &progress, func(records []*RedisRecord, dumpedAt time.Time) error {
+		progress.Exported += int64(len(records))
+		if err := restoreRecords(ctx, dst.UniversalClient(), records, time.Since(dumpedAt), restoreOpts, &progress); err != nil {
+			return err
+		}
+		if restoreOpts.OnProgress != nil {
+			restoreOpts.OnProgress(progress)
+		}
+		return nil
+	})
+	return progress, err
+}
+
+///////////////////////////////////////////////////////////////
+
+// dumpKeys 扫描匹配的 key, 按 BatchSize 分批 DUMP 后连同 DUMP 时间交给 emit; 集群模式下各主节点并发扫描, emit 与进度更新串行执行
+func dumpKeys(ctx context.Context, client redisHd.UniversalClient, opts *BackupOptions, progress *TransferProgress, emit func(records []*RedisRecord, dumpedAt time.Time) error) error {
+	var mu sync.Mutex
+	batchSize := max(opts.BatchSize, 1)
+	types := make([]string, 0, len(opts.Types))
+	for _, t := range opts.Types {
+		types = append(types, strings.ToLower(t))
+	}
+
+	scanNode := func(ctx context.Context, node redisHd.UniversalClient) error {
+		batch := make([]string, 0, batchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			dumpedAt := time.Now() // 取发送前的时间, 按此计算的过期时刻不会晚于实际过期时刻
+			records, skipped, err := dumpBatch(ctx, node, batch, types)
+			batch = batch[:0]
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			progress.Skipped += skipped
+			if len(records) > 0 {
+				if err := emit(records, dumpedAt); err != nil {
+					return err
+				}
+			}
+			if opts.OnProgress != nil {
+				opts.OnProgress(*progress)
+			}
+			return nil
+		}
+
+		var cursor uint64
+		for {
+			var keys []string
+			var err error
+			if len(types) == 1 { // 单一类型时由服务端过滤
+				keys, cursor, err = node.ScanType(ctx, cursor, opts.Match, max(opts.ScanCount, 1), types[0]).Result()
+			} else {
+				keys, cursor, err = node.Scan(ctx, cursor, opts.Match, max(opts.ScanCount, 1)).Result()
+			}
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			progress.Scanned += int64(len(keys))
+			mu.Unlock()
+			for _, key := range keys {
+				if MatchFilter(opts.IgnoreKeys, key) {
+					continue
+				}
+				if len(opts.IncludeKeys) > 0 && !MatchFilter(opts.IncludeKeys, key) {
+					continue
+				}
+				batch = append(batch, key)
+				if len(batch) >= batchSize {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+			if cursor == 0 {
+				return flush()
+			}
+		}
+	}
+
+	if cluster, ok := client.(*redisHd.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redisHd.Client) error {
+			return scanNode(ctx, node)
+		})
+	}
+	return scanNode(ctx, client)
+}
+
+// dumpBatch 通过 pipeline 获取一批 key 的类型, PTTL 与 DUMP 数据, 返回记录与被跳过的数量
+func dumpBatch(ctx context.Context, node redisHd.UniversalClient, keys []string, types []string) ([]*RedisRecord, int64, error) {
+	typeCmds := make([]*redisHd.StatusCmd, len(keys))
+	ttlCmds := make([]*redisHd.DurationCmd, len(keys))
+	dumpCmds := make([]*redisHd.StringCmd, len(keys))
+	_, err := node.Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		for i, key := range keys {
+			typeCmds[i] = pipe.Type(ctx, key)
+			ttlCmds[i] = pipe.PTTL(ctx, key)
+			dumpCmds[i] = pipe.Dump(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redisHd.Nil) {
+		return nil, 0, err
+	}
+
+	records := make([]*RedisRecord, 0, len(keys))
+	skipped := int64(0)
+	for i, key := range keys {
+		dataType := typeCmds[i].Val()
+		if dataType == "none" || errors.Is(dumpCmds[i].Err(), redisHd.Nil) { // 扫描后被删除或过期
+			skipped++
+			continue
+		}
+		if len(types) > 0 && !kslices.Contains(types, strings.ToLower(dataType)) {
+			continue
+		}
+		if err := dumpCmds[i].Err(); err != nil {
+			return nil, 0, err
+		}
+		records = append(records, &RedisRecord{Key: key, DataType: dataType, PTtl: ttlCmds[i].Val(), Data: dumpCmds[i].Val()})
+	}
+	return records, skipped, nil
+}
+
+// restoreRecords 通过 pipeline 导入一批记录, elapsed 为导出到导入经过的时间
+func restoreRecords(ctx context.Context, client redisHd.UniversalClient, records []*RedisRecord, elapsed time.Duration, opts *RestoreOptions, progress *TransferProgress) error {
+	cmds := make([]*redisHd.StatusCmd, 0, len(records))
+	_, err := client.Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		for _, record := range records {
+			ttl := time.Duration(0)
+			if record.PTtl > 0 {
+				switch opts.TTLMode {
+				case RestoreTTLRemaining:
+					ttl = record.PTtl
+				case RestoreTTLAbsolute:
+					if ttl = record.PTtl - elapsed; ttl <= 0 {
+						progress.Skipped++
+						continue
+					}
+				}
+			}
+
+			if opts.Policy == RestorePolicyReplace {
+				cmds = append(cmds, pipe.RestoreReplace(ctx, record.Key, ttl, record.Data))
+			} else {
+				cmds = append(cmds, pipe.Restore(ctx, record.Key, ttl, record.Data))
+			}
+		}
+		return nil
+	})
+	if err != nil && len(cmds) == 0 {
+		return err
+	}
+
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		switch {
+		case err == nil:
+			progress.Restored++
+		case strings.HasPrefix(err.Error(), "BUSYKEY"):
+			if opts.Policy == RestorePolicyFail {
+				return fmt.Errorf("%w: %s", ErrBackupKeyBusy, cmd.Args()[1])
+			}
+			progress.Skipped++
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+
+type backupWriter struct {
+	w           io.Writer
+	compression BackupCompression
+	count       uint64
+	bytes       int64
+}
+
+func (that *backupWriter) write(p []byte) error {
+	n, err := that.w.Write(p)
+	that.bytes += int64(n)
+	return err
+}
+
+func (that *backupWriter) writeHeader(createdAt time.Time) error {
+	header := make([]byte, 0, 14)
+	header = append(header, backupMagic...)
+	header = append(header, backupVersion, byte(that.compression))
+	header = binary.BigEndian.AppendUint64(header, uint64(createdAt.UnixMilli()))
+	return that.write(header)
+}
+
+func (that *backupWriter) writeBlock(records []*RedisRecord, dumpedAt time.Time) error {
+	raw := bytes.NewBuffer(nil)
+	for _, record := range records {
+		writeBackupString(raw, record.Key)
+		writeBackupString(raw, record.DataType)
+		ttl := int64(-1)
+		if record.PTtl > 0 {
+			ttl = record.PTtl.Milliseconds()
+		}
+		raw.Write(binary.AppendVarint(nil, ttl))
+		writeBackupString(raw, record.Data)
+	}
+
+	if raw.Len() > backupMaxBlock {
+		return fmt.Errorf("kredis: backup block too large (%d bytes), reduce BatchSize", raw.Len())
+	}
+	compressed, err := that.compression.compress(raw.Bytes())
+	if err != nil {
+		return err
+	}
+	if len(compressed) > backupMaxBlock {
+		return fmt.Errorf("kredis: backup block too large (%d bytes), reduce BatchSize", len(compressed))
+	}
+
+	header := make([]byte, 0, 18)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(compressed)))
+	header = binary.BigEndian.AppendUint32(header, uint32(len(records)))
+	header = binary.BigEndian.AppendUint64(header, uint64(dumpedAt.UnixMilli()))
+	header = binary.BigEndian.AppendUint16(header, data.CheckSum(compressed))
+	if err := that.write(header); err != nil {
+		return err
+	}
+	that.count += uint64(len(records))
+	return that.write(compressed)
+}
+
+func (that *backupWriter) writeEnd() error {
+	end := make([]byte, 0, 12)
+	end = binary.BigEndian.AppendUint32(end, 0)
+	end = binary.BigEndian.AppendUint64(end, that.count)
+	return that.write(end)
+}
+
+func writeBackupString(buf *bytes.Buffer, s string) {
+	buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	buf.WriteString(s)
+}
+
+type backupReader struct {
+	r           *bufio.Reader
+	compression BackupCompression
+	count       uint64
+	bytes       int64
+}
+
+func (that *backupReader) read(p []byte) error {
+	n, err := io.ReadFull(that.r, p)
+	that.bytes += int64(n)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: unexpected end of file", ErrBackupFormat)
+	}
+	return err
+}
+
+func (that *backupReader) readHeader() error {
+	header := make([]byte, 14)
+	if err := that.read(header); err != nil {
+		return err
+	}
+	if string(header[:4]) != backupMagic {
+		return fmt.Errorf("%w: bad magic", ErrBackupFormat)
+	}
+	if header[4] != backupVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrBackupFormat, header[4])
+	}
+	that.compression = BackupCompression(header[5])
+	return nil
+}
+
+// readBlock 读取并校验一个 block, 返回记录与 DUMP 时间; 读到结束标记且记录总数一致时返回 io.EOF
+func (that *backupReader) readBlock() ([]*RedisRecord, time.Time, error) {
+	sizeBuf := make([]byte, 4)
+	if err := that.read(sizeBuf); err != nil {
+		return nil, time.Time{}, err
+	}
+	size := binary.BigEndian.Uint32(sizeBuf)
+	if size == 0 {
+		countBuf := make([]byte, 8)
+		if err := that.read(countBuf); err != nil {
+			return nil, time.Time{}, err
+		}
+		if total := binary.BigEndian.Uint64(countBuf); total != that.count {
+			return nil, time.Time{}, fmt.Errorf("%w: expect %d records, got %d", ErrBackupFormat, total, that.count)
+		}
+		return nil, time.Time{}, io.EOF
+	}
+	if size > backupMaxBlock {
+		return nil, time.Time{}, fmt.Errorf("%w: block too large", ErrBackupFormat)
+	}
+
+	header := make([]byte, 14)
+	if err := that.read(header); err != nil {
+		return nil, time.Time{}, err
+	}
+	count := binary.BigEndian.Uint32(header[:4])
+	dumpedAt := time.UnixMilli(int64(binary.BigEndian.Uint64(header[4:12])))
+	compressed := make([]byte, size)
+	if err := that.read(compressed); err != nil {
+		return nil, time.Time{}, err
+	}
+	if data.CheckSum(compressed) != binary.BigEndian.Uint16(header[len(header)-2:]) {
+		return nil, time.Time{}, ErrBackupChecksum
+	}
+
+	raw, err := that.compression.uncompress(compressed, backupMaxBlock)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: %v", ErrBackupFormat, err)
+	}
+
+	buf := bytes.NewReader(raw)
+	records := make([]*RedisRecord, 0, min(int(count), len(raw))) // 记录数来自文件, 不能直接用于分配
+	for i := uint32(0); i < count; i++ {
+		record := &RedisRecord{}
+		var ttl int64
+		if record.Key, err = readBackupString(buf); err == nil {
+			if record.DataType, err = readBackupString(buf); err == nil {
+				if ttl, err = binary.ReadVarint(buf); err == nil {
+					record.Data, err = readBackupString(buf)
+				}
+			}
+		}
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("%w: %v", ErrBackupFormat, err)
+		}
+		if ttl > 0 {
+			record.PTtl = time.Duration(ttl) * time.Millisecond
+		} else {
+			record.PTtl = -1
+		}
+		records = append(records, record)
+	}
+	that.count += uint64(count)
+	return records, dumpedAt, nil
+}
+
+func readBackupString(r *bytes.Reader) (string, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if size > uint64(r.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,361 @@
+package kredis
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/data"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// ErrCacheNotFound 数据不存在.
+// loader 返回该错误时结果会被负缓存 NegativeTTL 时间, 期间 GetOrLoad 直接返回该错误而不再调用 loader
+var ErrCacheNotFound = errors.New("kredis: cache value not found")
+
+// ErrCacheMiss 缓存中没有该 key, 调用方无需依赖 Redis 客户端的 redis.Nil
+var ErrCacheMiss = errors.New("kredis: cache miss")
+
+const (
+	cacheFlagValue    byte = 'v' // 缓存值
+	cacheFlagNotFound byte = 'n' // 负缓存标记
+)
+
+// Codec 缓存值的编解码器
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSONCodec Codec = jsonCodec{} // encoding/json 编码, 可读性好, 便于与其他语言共享缓存
+	GobCodec  Codec = gobCodec{}  // encoding/gob 编码, 只能被 Go 程序读取
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(raw []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(v)
+}
+
+// CompressedCodec 在 inner 编码结果之上使用 data.Compress(deflate) 压缩, 适用于较大的值
+func CompressedCodec(inner Codec) Codec {
+	return compressedCodec{inner: inner}
+}
+
+type compressedCodec struct {
+	inner Codec
+}
+
+func (that compressedCodec) Marshal(v any) ([]byte, error) {
+	raw, err := that.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return data.Compress(raw)
+}
+
+func (that compressedCodec) Unmarshal(raw []byte, v any) error {
+	uncompressed, err := data.Uncompress(raw)
+	if err != nil {
+		return err
+	}
+	return that.inner.Unmarshal(uncompressed, v)
+}
+
+///////////////////////////////////////////////////////////////
+
+// CacheOptions 缓存参数, 时间单位均为毫秒
+type CacheOptions struct {
+	Prefix      string  `json:"prefix"`      // key 前缀, 如 "cache:user:"
+	Jitter      float64 `json:"jitter"`      // TTL 随机增加的最大比例, 避免同一批 key 同时过期造成缓存击穿, 默认0.1
+	NegativeTTL int64   `json:"negativeTTL"` // 负缓存时间, 默认30000, <= 0 时不缓存不存在的结果
+
+	Codec   Codec           `json:"-"` // 编解码器, 默认 JSONCodec
+	OnError func(err error) `json:"-"` // 读写 Redis 或编解码失败时的回调, 这类错误不影响 GetOrLoad 的结果
+}
+
+func NewCacheOptions() *CacheOptions {
+	return &CacheOptions{
+		Jitter:      0.1,
+		NegativeTTL: 30000,
+		Codec:       JSONCodec,
+	}
+}
+
+func (that *CacheOptions) SetPrefix(prefix string) *CacheOptions {
+	that.Prefix = prefix
+	return that
+}
+
+func (that *CacheOptions) SetJitter(jitter float64) *CacheOptions {
+	that.Jitter = jitter
+	return that
+}
+
+// 设置负缓存时间, 单位 毫秒
+func (that *CacheOptions) SetNegativeTTL(ttl int64) *CacheOptions {
+	that.NegativeTTL = ttl
+	return that
+}
+
+func (that *CacheOptions) SetCodec(codec Codec) *CacheOptions {
+	that.Codec = codec
+	return that
+}
+
+func (that *CacheOptions) SetErrorHandler(fn func(err error)) *CacheOptions {
+	that.OnError = fn
+	return that
+}
+
+// Cache 旁路缓存(cache-aside), 通过泛型函数 GetOrLoad, CacheGet, CacheSet 读写
+type Cache struct {
+	client KRedisClient
+	opts   *CacheOptions
+	ctx    *kcontext.ContextNode
+	flight flightGroup
+}
+
+// NewCache 创建缓存, opts 为 nil 时使用默认参数; opts 被复制, 之后修改不影响已创建的缓存
+func NewCache(ctx *kcontext.ContextNode, client KRedisClient, opts *CacheOptions) *Cache {
+	if opts == nil {
+		opts = NewCacheOptions()
+	}
+	copied := *opts
+	if copied.Codec == nil {
+		copied.Codec = JSONCodec
+	}
+	return &Cache{client: client, opts: &copied, ctx: ctx}
+}
+
+// Del 删除缓存, 数据更新后调用使缓存失效
+func (that *Cache) Del(keys ...string) error {
+	return that.DelWithCtx(that.ctx.Context(), keys...)
+}
+
+// DelWithCtx 同 Del, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *Cache) DelWithCtx(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	client := that.client.UniversalClient()
+	if len(keys) == 1 {
+		return client.Del(ctx, that.opts.Prefix+keys[0]).Err()
+	}
+	// 集群模式下多个 key 可能位于不同的槽, 逐个删除
+	_, err := client.Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, that.opts.Prefix+key)
+		}
+		return nil
+	})
+	return err
+}
+
+// jitter 为 ttl 增加 [0, ttl*Jitter) 的随机时间
+func (that *Cache) jitter(ttl time.Duration) time.Duration {
+	if that.opts.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	if n := int64(float64(ttl) * that.opts.Jitter); n > 0 {
+		ttl += time.Duration(rand.Int63n(n))
+	}
+	return ttl
+}
+
+func (that *Cache) onError(err error) {
+	if that.opts.OnError != nil {
+		that.opts.OnError(err)
+	}
+}
+
+// read 读取缓存, 返回 (值, 是否为负缓存, 错误), 不存在时返回 ErrCacheMiss
+func (that *Cache) read(ctx context.Context, key string) ([]byte, bool, error) {
+	raw, err := that.client.UniversalClient().Get(ctx, that.opts.Prefix+key).Bytes()
+	if errors.Is(err, redisHd.Nil) {
+		return nil, false, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(raw) == 0 {
+		return nil, false, fmt.Errorf("kredis: invalid cache value for %s", key)
+	}
+	switch raw[0] {
+	case cacheFlagNotFound:
+		return nil, true, nil
+	case cacheFlagValue:
+		return raw[1:], false, nil
+	}
+	return nil, false, fmt.Errorf("kredis: invalid cache value for %s", key)
+}
+
+func (that *Cache) write(ctx context.Context, key string, value any, ttl time.Duration) error {
+	raw, err := that.opts.Codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, len(raw)+1)
+	buf = append(buf, cacheFlagValue)
+	buf = append(buf, raw...)
+	return that.client.UniversalClient().Set(ctx, that.opts.Prefix+key, buf, that.jitter(ttl)).Err()
+}
+
+func (that *Cache) writeNotFound(ctx context.Context, key string) error {
+	if that.opts.NegativeTTL <= 0 {
+		return nil
+	}
+	ttl := that.jitter(millisecond(that.opts.NegativeTTL))
+	return that.client.UniversalClient().Set(ctx, that.opts.Prefix+key, []byte{cacheFlagNotFound}, ttl).Err()
+}
+
+// CacheGet 读取缓存, 不存在时返回 ErrCacheMiss, 命中负缓存时返回 ErrCacheNotFound
+func CacheGet[T any](cache *Cache, key string) (T, error) {
+	return CacheGetWithCtx[T](cache.ctx.Context(), cache, key)
+}
+
+// CacheGetWithCtx 同 CacheGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func CacheGetWithCtx[T any](ctx context.Context, cache *Cache, key string) (T, error) {
+	var value T
+	raw, notFound, err := cache.read(ctx, key)
+	if err != nil {
+		return value, err
+	}
+	if notFound {
+		return value, ErrCacheNotFound
+	}
+	err = cache.opts.Codec.Unmarshal(raw, &value)
+	return value, err
+}
+
+// CacheSet 写入缓存, 实际过期时间为 ttl 加上随机抖动
+func CacheSet[T any](cache *Cache, key string, value T, ttl time.Duration) error {
+	return CacheSetWithCtx(cache.ctx.Context(), cache, key, value, ttl)
+}
+
+// CacheSetWithCtx 同 CacheSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func CacheSetWithCtx[T any](ctx context.Context, cache *Cache, key string, value T, ttl time.Duration) error {
+	return cache.write(ctx, key, value, ttl)
+}
+
+// GetOrLoad 读取缓存, 未命中时调用 loader 加载并写入缓存.
+//   - 同一进程内同一个 key 的并发未命中只调用一次 loader, 其余调用等待并共享结果
+//   - loader 返回 ErrCacheNotFound 时写入负缓存, 期间直接返回 ErrCacheNotFound
+//   - loader 返回其他错误时不写入缓存
+//   - Redis 不可用或缓存值无法解码时降级为直接调用 loader, 错误通过 OnError 报告
+//
+// 示例:
+//
+//	user, err := kredis.GetOrLoad(cache, "user:1001", 10*time.Minute, func() (*User, error) {
+//		return db.FindUser(1001)
+//	})
+func GetOrLoad[T any](cache *Cache, key string, ttl time.Duration, loader func() (T, error)) (T, error) {
+	return GetOrLoadWithCtx(cache.ctx.Context(), cache, key, ttl, func(context.Context) (T, error) { return loader() })
+}
+
+// GetOrLoadWithCtx 同 GetOrLoad, 使用调用方传入的 ctx.
+// loader 的结果由并发的调用共享, 因此传给 loader 与写入缓存的 ctx 不会随 ctx 取消, 只保留其中的值;
+// 需要限制加载时间时应在 loader 内部设置超时
+func GetOrLoadWithCtx[T any](ctx context.Context, cache *Cache, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	value, err := CacheGetWithCtx[T](ctx, cache, key)
+	if err == nil || errors.Is(err, ErrCacheNotFound) {
+		return value, err
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		cache.onError(err)
+	}
+
+	shared, err := cache.flight.Do(key, func() (any, error) {
+		// 第一个调用方取消时不应使等待同一结果的其他调用方一起失败
+		ctx := context.WithoutCancel(ctx)
+		loaded, err := loader(ctx)
+		if errors.Is(err, ErrCacheNotFound) {
+			if werr := cache.writeNotFound(ctx, key); werr != nil {
+				cache.onError(werr)
+			}
+			return loaded, err
+		}
+		if err != nil {
+			return loaded, err
+		}
+		if werr := cache.write(ctx, key, loaded, ttl); werr != nil {
+			cache.onError(werr)
+		}
+		return loaded, nil
+	})
+
+	if v, ok := shared.(T); ok {
+		value = v
+	}
+	return value, err
+}
+
+///////////////////////////////////////////////////////////////
+
+// flightGroup 合并同一个 key 的并发调用, 与 golang.org/x/sync/singleflight 的 Do 语义相同
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	val any
+	err error
+}
+
+func (that *flightGroup) Do(key string, fn func() (any, error)) (any, error) {
+	that.mu.Lock()
+	if that.calls == nil {
+		that.calls = make(map[string]*flightCall)
+	}
+	if call, ok := that.calls[key]; ok {
+		that.mu.Unlock()
+		call.wg.Wait()
+		return call.val, call.err
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	that.calls[key] = call
+	that.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil { // 唤醒等待者后重新抛出, 避免等待者永久阻塞
+			call.err = fmt.Errorf("kredis: cache loader panic: %v", r)
+			that.finish(key, call)
+			panic(r)
+		}
+	}()
+	call.val, call.err = fn()
+	that.finish(key, call)
+	return call.val, call.err
+}
+
+func (that *flightGroup) finish(key string, call *flightCall) {
+	that.mu.Lock()
+	delete(that.calls, key)
+	that.mu.Unlock()
+	call.wg.Done()
+}
@@ -0,0 +1,522 @@
+package kredis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNoAddrs     = errors.New("redis addrs is empty")
+	ErrInvalidMode = errors.New("invalid redis mode")
+)
+
+// RedisMode 部署方式
+type RedisMode int
+
+const (
+	RedisModeAuto       RedisMode = iota // 自动识别: 设置了 MasterName 为哨兵, 多个地址为集群, 否则为单机
+	RedisModeStandalone                  // 单机
+	RedisModeCluster                     // 集群
+	RedisModeSentinel                    // 哨兵(主从自动切换)
+)
+
+func (that RedisMode) String() string {
+	switch that {
+	case RedisModeAuto:
+		return "auto"
+	case RedisModeStandalone:
+		return "standalone"
+	case RedisModeCluster:
+		return "cluster"
+	case RedisModeSentinel:
+		return "sentinel"
+	}
+	return fmt.Sprintf("RedisMode(%d)", int(that))
+}
+
+// ParseRedisMode 将部署方式名称转换为 RedisMode, 不区分大小写, 空字符串为 RedisModeAuto
+func ParseRedisMode(name string) (RedisMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return RedisModeAuto, nil
+	case "standalone", "single":
+		return RedisModeStandalone, nil
+	case "cluster":
+		return RedisModeCluster, nil
+	case "sentinel", "failover":
+		return RedisModeSentinel, nil
+	}
+	return RedisModeAuto, fmt.Errorf("%w: %s", ErrInvalidMode, name)
+}
+
+// RedisTLSOptions 从文件加载的 TLS 证书配置
+type RedisTLSOptions struct {
+	CertFile           string `json:"certFile"`           // 客户端证书, 服务端要求双向认证时必须设置
+	KeyFile            string `json:"keyFile"`            // 客户端私钥
+	CAFile             string `json:"caFile"`             // 用于校验服务端证书的 CA, 为空时使用系统 CA
+	ServerName         string `json:"serverName"`         // 校验服务端证书使用的主机名, 为空时使用连接地址
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"` // 跳过服务端证书校验, 仅用于测试环境
+}
+
+// RedisOptions 客户端连接参数, 同一份配置可以用于单机, 集群与哨兵三种部署方式.
+// 时间单位均为毫秒, 数值为 0 时使用 go-redis 的默认值, MaxRetries 为 -1 时禁用重试
+type RedisOptions struct {
+	Mode             RedisMode `json:"mode"`             // 部署方式, 默认自动识别
+	Addrs            []string  `json:"addrs"`            // 单机为一个地址; 集群为任意数量的节点地址; 哨兵为哨兵节点地址
+	MasterName       string    `json:"masterName"`       // 哨兵模式下的主节点名称
+	Username         string    `json:"username"`         // redis 6.0以上版本
+	Password         string    `json:"password"`         // 密码
+	DB               int       `json:"db"`               // 数据库编号, 集群模式下忽略
+	SentinelUsername string    `json:"sentinelUsername"` // 哨兵节点的用户名
+	SentinelPassword string    `json:"sentinelPassword"` // 哨兵节点的密码
+	ClientName       string    `json:"clientName"`       // 客户端标识, 通过 CLIENT SETNAME 设置
+	Protocol         int       `json:"protocol"`         // 协议版本, 2 代表 RESP2, 3 代表 RESP3
+
+	MaxRetries      int   `json:"maxRetries"`      // 最大重试次数
+	MinRetryBackoff int64 `json:"minRetryBackoff"` // 重试间隔时间下限, 单位 毫秒
+	MaxRetryBackoff int64 `json:"maxRetryBackoff"` // 重试间隔时间上限, 单位 毫秒
+
+	DialTimeout           int64 `json:"dialTimeout"`           // 连接超时时间, 单位 毫秒
+	ReadTimeout           int64 `json:"readTimeout"`           // socket 读取超时时间, 单位 毫秒
+	WriteTimeout          int64 `json:"writeTimeout"`          // socket 写入超时时间, 单位 毫秒
+	ContextTimeoutEnabled bool  `json:"contextTimeoutEnabled"` // 是否使用调用方 context 的截止时间作为读写超时
+
+	PoolFIFO        bool  `json:"poolFIFO"`        // 空闲连接池队列是否采用先进先出方式
+	PoolSize        int   `json:"poolSize"`        // 连接池大小, 集群模式下为每个节点的连接池大小
+	PoolTimeout     int64 `json:"poolTimeout"`     // 连接池等待超时时间, 单位 毫秒
+	MinIdleConns    int   `json:"minIdleConns"`    // 连接池最小空闲连接数
+	MaxIdleConns    int   `json:"maxIdleConns"`    // 连接池最大空闲连接数
+	MaxActiveConns  int   `json:"maxActiveConns"`  // 最大激活连接数(包含空闲连接和正在使用的连接)
+	ConnMaxIdleTime int64 `json:"connMaxIdleTime"` // 链路最大空闲时间, 单位 毫秒
+	ConnMaxLifetime int64 `json:"connMaxLifetime"` // 链路最长存活时间, 超过后强制轮换, 单位 毫秒
+
+	MaxRedirects   int  `json:"maxRedirects"`   // 集群模式下遇到 MOVED 或 ASK 时的最大重定向次数
+	ReadOnly       bool `json:"readOnly"`       // 集群/哨兵模式下允许将只读命令路由到从节点
+	RouteByLatency bool `json:"routeByLatency"` // 只读命令路由到延迟最低的节点, 隐含 ReadOnly
+	RouteRandomly  bool `json:"routeRandomly"`  // 只读命令随机路由到主从节点, 隐含 ReadOnly
+
+	TLS       *RedisTLSOptions `json:"tls"` // 从文件加载的 TLS 配置, 为空则不使用 TLS
+	TLSConfig *tls.Config      `json:"-"`   // TLS 配置, 优先级高于 TLS
+
+	OnConnect func(ctx context.Context, cn *redisHd.Conn) error `json:"-"` // 建立新连接后的钩子函数
+	Hooks     []redisHd.Hook                                    `json:"-"` // 命令钩子, 创建客户端后通过 AddHook 注册; 单机与哨兵设置钩子时忽略 MinIdleConns
+}
+
+// NewRedisOptions 创建连接参数, 默认值与 NewKRedis 保持一致.
+// 与 NewKRedis 相同默认不启用 ContextTimeoutEnabled, 读写超时由 ReadTimeout/WriteTimeout 决定;
+// 需要 ctx.WithTimeout 中断正在读写的请求时调用 SetContextTimeoutEnabled(true)
+func NewRedisOptions(addrs ...string) *RedisOptions {
+	return &RedisOptions{
+		Mode:            RedisModeAuto,
+		Addrs:           addrs,
+		MaxRetries:      3,     // 自动重连3次, 失败后报错
+		DialTimeout:     10000, // 10s
+		ReadTimeout:     30000, // 30s
+		WriteTimeout:    30000, // 30s
+		PoolSize:        10,
+		PoolTimeout:     30000, // 30s
+		ConnMaxIdleTime: 30000, // 链路最大空闲时间 30s
+	}
+}
+
+func (that *RedisOptions) SetMode(mode RedisMode) *RedisOptions {
+	that.Mode = mode
+	return that
+}
+
+func (that *RedisOptions) SetAddrs(addrs ...string) *RedisOptions {
+	that.Addrs = addrs
+	return that
+}
+
+// SetSentinel 设置哨兵模式的主节点名称, 以及哨兵节点的认证信息(与数据节点相同时可以为空)
+func (that *RedisOptions) SetSentinel(masterName string, sentinelUsername string, sentinelPassword string) *RedisOptions {
+	that.MasterName = masterName
+	that.SentinelUsername = sentinelUsername
+	that.SentinelPassword = sentinelPassword
+	return that
+}
+
+func (that *RedisOptions) SetAuth(username string, password string) *RedisOptions {
+	that.Username = username
+	that.Password = password
+	return that
+}
+
+func (that *RedisOptions) SetDB(dbNum int) *RedisOptions {
+	that.DB = dbNum
+	return that
+}
+
+func (that *RedisOptions) SetClientName(name string) *RedisOptions {
+	that.ClientName = name
+	return that
+}
+
+// 设置协议版本, 2 代表 RESP2, 3 代表 RESP3
+func (that *RedisOptions) SetProtocol(protocol int) *RedisOptions {
+	that.Protocol = protocol
+	return that
+}
+
+// 设置重试次数与重试间隔, 单位 毫秒; maxRetries 为 -1 时禁用重试
+func (that *RedisOptions) SetRetry(maxRetries int, minBackoff int64, maxBackoff int64) *RedisOptions {
+	that.MaxRetries = maxRetries
+	that.MinRetryBackoff = minBackoff
+	that.MaxRetryBackoff = maxBackoff
+	return that
+}
+
+// 设置连接, 读, 写超时时间, 单位 毫秒
+func (that *RedisOptions) SetTimeouts(dial int64, read int64, write int64) *RedisOptions {
+	that.DialTimeout = dial
+	that.ReadTimeout = read
+	that.WriteTimeout = write
+	return that
+}
+
+func (that *RedisOptions) SetContextTimeoutEnabled(enabled bool) *RedisOptions {
+	that.ContextTimeoutEnabled = enabled
+	return that
+}
+
+// 设置连接池大小, 最小/最大空闲连接数, 最大激活连接数
+func (that *RedisOptions) SetPool(size int, minIdle int, maxIdle int, maxActive int) *RedisOptions {
+	that.PoolSize = size
+	that.MinIdleConns = minIdle
+	that.MaxIdleConns = maxIdle
+	that.MaxActiveConns = maxActive
+	return that
+}
+
+// 设置连接池等待超时时间, 单位 毫秒; fifo 为 true 时空闲连接先进先出
+func (that *RedisOptions) SetPoolTimeout(timeout int64, fifo bool) *RedisOptions {
+	that.PoolTimeout = timeout
+	that.PoolFIFO = fifo
+	return that
+}
+
+// 设置链路最大空闲时间与最长存活时间, 单位 毫秒
+func (that *RedisOptions) SetConnLifetime(maxIdleTime int64, maxLifetime int64) *RedisOptions {
+	that.ConnMaxIdleTime = maxIdleTime
+	that.ConnMaxLifetime = maxLifetime
+	return that
+}
+
+func (that *RedisOptions) SetMaxRedirects(maxRedirects int) *RedisOptions {
+	that.MaxRedirects = maxRedirects
+	return that
+}
+
+// SetReplicaRouting 设置只读命令的路由方式, 集群与哨兵模式有效
+//   - readOnly 允许只读命令路由到从节点
+//   - byLatency 路由到延迟最低的节点
+//   - randomly 随机路由到主从节点
+func (that *RedisOptions) SetReplicaRouting(readOnly bool, byLatency bool, randomly bool) *RedisOptions {
+	that.ReadOnly = readOnly
+	that.RouteByLatency = byLatency
+	that.RouteRandomly = randomly
+	return that
+}
+
+func (that *RedisOptions) SetTLS(tlsOpts *RedisTLSOptions) *RedisOptions {
+	that.TLS = tlsOpts
+	return that
+}
+
+func (that *RedisOptions) SetTLSConfig(conf *tls.Config) *RedisOptions {
+	that.TLSConfig = conf
+	return that
+}
+
+func (that *RedisOptions) SetOnConnect(fn func(ctx context.Context, cn *redisHd.Conn) error) *RedisOptions {
+	that.OnConnect = fn
+	return that
+}
+
+// AddHook 添加命令钩子, 可用于统计耗时, 记录慢查询等
+func (that *RedisOptions) AddHook(hooks ...redisHd.Hook) *RedisOptions {
+	that.Hooks = append(that.Hooks, hooks...)
+	return that
+}
+
+// routeToReplicas 是否需要将只读命令路由到从节点
+func (that *RedisOptions) routeToReplicas() bool {
+	return that.ReadOnly || that.RouteByLatency || that.RouteRandomly
+}
+
+// tlsConfig 返回 TLS 配置, 没有配置时返回 nil
+func (that *RedisOptions) tlsConfig() (*tls.Config, error) {
+	if that.TLSConfig != nil {
+		return that.TLSConfig, nil
+	}
+	if that.TLS == nil {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         that.TLS.ServerName,
+		InsecureSkipVerify: that.TLS.InsecureSkipVerify,
+	}
+	if that.TLS.CertFile != "" || that.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(that.TLS.CertFile, that.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load redis client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if that.TLS.CAFile != "" {
+		pem, err := os.ReadFile(that.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load redis ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("load redis ca: no certificate found in %s", that.TLS.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+	return conf, nil
+}
+
+func (that *RedisOptions) standaloneOptions() (*redisHd.Options, error) {
+	tlsConf, err := that.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	addr := ""
+	if len(that.Addrs) > 0 {
+		addr = that.Addrs[0]
+	}
+	return &redisHd.Options{
+		Addr:                  addr,
+		ClientName:            that.ClientName,
+		OnConnect:             that.OnConnect,
+		Protocol:              that.Protocol,
+		Username:              that.Username,
+		Password:              that.Password,
+		DB:                    that.DB,
+		MaxRetries:            that.MaxRetries,
+		MinRetryBackoff:       millisecond(that.MinRetryBackoff),
+		MaxRetryBackoff:       millisecond(that.MaxRetryBackoff),
+		DialTimeout:           millisecond(that.DialTimeout),
+		ReadTimeout:           millisecond(that.ReadTimeout),
+		WriteTimeout:          millisecond(that.WriteTimeout),
+		ContextTimeoutEnabled: that.ContextTimeoutEnabled,
+		PoolFIFO:              that.PoolFIFO,
+		PoolSize:              that.PoolSize,
+		PoolTimeout:           millisecond(that.PoolTimeout),
+		MinIdleConns:          that.MinIdleConns,
+		MaxIdleConns:          that.MaxIdleConns,
+		MaxActiveConns:        that.MaxActiveConns,
+		ConnMaxIdleTime:       millisecond(that.ConnMaxIdleTime),
+		ConnMaxLifetime:       millisecond(that.ConnMaxLifetime),
+		TLSConfig:             tlsConf,
+	}, nil
+}
+
+func (that *RedisOptions) clusterOptions() (*redisHd.ClusterOptions, error) {
+	tlsConf, err := that.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &redisHd.ClusterOptions{
+		Addrs:                 that.Addrs,
+		ClientName:            that.ClientName,
+		MaxRedirects:          that.MaxRedirects,
+		ReadOnly:              that.ReadOnly,
+		RouteByLatency:        that.RouteByLatency,
+		RouteRandomly:         that.RouteRandomly,
+		OnConnect:             that.OnConnect,
+		Protocol:              that.Protocol,
+		Username:              that.Username,
+		Password:              that.Password,
+		MaxRetries:            that.MaxRetries,
+		MinRetryBackoff:       millisecond(that.MinRetryBackoff),
+		MaxRetryBackoff:       millisecond(that.MaxRetryBackoff),
+		DialTimeout:           millisecond(that.DialTimeout),
+		ReadTimeout:           millisecond(that.ReadTimeout),
+		WriteTimeout:          millisecond(that.WriteTimeout),
+		ContextTimeoutEnabled: that.ContextTimeoutEnabled,
+		PoolFIFO:              that.PoolFIFO,
+		PoolSize:              that.PoolSize,
+		PoolTimeout:           millisecond(that.PoolTimeout),
+		MinIdleConns:          that.MinIdleConns,
+		MaxIdleConns:          that.MaxIdleConns,
+		MaxActiveConns:        that.MaxActiveConns,
+		ConnMaxIdleTime:       millisecond(that.ConnMaxIdleTime),
+		ConnMaxLifetime:       millisecond(that.ConnMaxLifetime),
+		TLSConfig:             tlsConf,
+	}, nil
+}
+
+func (that *RedisOptions) failoverOptions() (*redisHd.FailoverOptions, error) {
+	tlsConf, err := that.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &redisHd.FailoverOptions{
+		MasterName:            that.MasterName,
+		SentinelAddrs:         that.Addrs,
+		ClientName:            that.ClientName,
+		SentinelUsername:      that.SentinelUsername,
+		SentinelPassword:      that.SentinelPassword,
+		RouteByLatency:        that.RouteByLatency,
+		RouteRandomly:         that.RouteRandomly,
+		ReplicaOnly:           false,
+		OnConnect:             that.OnConnect,
+		Protocol:              that.Protocol,
+		Username:              that.Username,
+		Password:              that.Password,
+		DB:                    that.DB,
+		MaxRetries:            that.MaxRetries,
+		MinRetryBackoff:       millisecond(that.MinRetryBackoff),
+		MaxRetryBackoff:       millisecond(that.MaxRetryBackoff),
+		DialTimeout:           millisecond(that.DialTimeout),
+		ReadTimeout:           millisecond(that.ReadTimeout),
+		WriteTimeout:          millisecond(that.WriteTimeout),
+		ContextTimeoutEnabled: that.ContextTimeoutEnabled,
+		PoolFIFO:              that.PoolFIFO,
+		PoolSize:              that.PoolSize,
+		PoolTimeout:           millisecond(that.PoolTimeout),
+		MinIdleConns:          that.MinIdleConns,
+		MaxIdleConns:          that.MaxIdleConns,
+		MaxActiveConns:        that.MaxActiveConns,
+		ConnMaxIdleTime:       millisecond(that.ConnMaxIdleTime),
+		ConnMaxLifetime:       millisecond(that.ConnMaxLifetime),
+		TLSConfig:             tlsConf,
+	}, nil
+}
+
+func millisecond(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// ResolveMode 返回实际使用的部署方式, RedisModeAuto 时根据 MasterName 与 Addrs 识别
+func (that *RedisOptions) ResolveMode() RedisMode {
+	if that.Mode != RedisModeAuto {
+		return that.Mode
+	}
+	if that.MasterName != "" {
+		return RedisModeSentinel
+	}
+	if len(that.Addrs) > 1 {
+		return RedisModeCluster
+	}
+	return RedisModeStandalone
+}
+
+// NewKRedisClient 根据 opts 创建单机, 集群或哨兵客户端.
+// 单机与哨兵返回 *KRedis, 集群以及设置了从节点路由(SetReplicaRouting)的哨兵返回 *KRedisCluster
+func NewKRedisClient(ctx *kcontext.ContextNode, opts *RedisOptions) (KRedisClient, error) {
+	if opts == nil || len(opts.Addrs) == 0 {
+		return nil, ErrNoAddrs
+	}
+
+	switch opts.ResolveMode() {
+	case RedisModeStandalone:
+		return NewKRedisWithOptions(ctx, opts)
+	case RedisModeCluster:
+		return NewKRedisClusterWithOptions(ctx, opts)
+	case RedisModeSentinel:
+		if opts.routeToReplicas() {
+			return NewKRedisSentinelCluster(ctx, opts)
+		}
+		return NewKRedisSentinel(ctx, opts)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInvalidMode, opts.Mode)
+}
+
+// NewKRedisWithOptions 根据 opts 创建单机客户端, 只使用 Addrs 中的第一个地址
+func NewKRedisWithOptions(ctx *kcontext.ContextNode, opts *RedisOptions) (*KRedis, error) {
+	if opts == nil || len(opts.Addrs) == 0 {
+		return nil, ErrNoAddrs
+	}
+	clientOpts, err := opts.standaloneOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Hooks) > 0 {
+		clientOpts.MinIdleConns = 0 // 连接池创建时在后台预建空闲连接, 与随后的 AddHook 存在数据竞争
+	}
+	client := redisHd.NewClient(clientOpts)
+	for _, hook := range opts.Hooks {
+		client.AddHook(hook)
+	}
+	return newKRedis(ctx, client), nil
+}
+
+// NewKRedisClusterWithOptions 根据 opts 创建集群客户端
+func NewKRedisClusterWithOptions(ctx *kcontext.ContextNode, opts *RedisOptions) (*KRedisCluster, error) {
+	if opts == nil || len(opts.Addrs) == 0 {
+		return nil, ErrNoAddrs
+	}
+	clientOpts, err := opts.clusterOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	client := redisHd.NewClusterClient(clientOpts)
+	for _, hook := range opts.Hooks {
+		client.AddHook(hook)
+	}
+	return newKRedisCluster(ctx, client), nil
+}
+
+// NewKRedisSentinel 创建哨兵模式的客户端, 主节点切换后自动连接新的主节点
+func NewKRedisSentinel(ctx *kcontext.ContextNode, opts *RedisOptions) (*KRedis, error) {
+	if opts == nil || len(opts.Addrs) == 0 {
+		return nil, ErrNoAddrs
+	}
+	if opts.MasterName == "" {
+		return nil, fmt.Errorf("%w: sentinel mode requires masterName", ErrInvalidMode)
+	}
+	clientOpts, err := opts.failoverOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Hooks) > 0 {
+		clientOpts.MinIdleConns = 0 // 连接池创建时在后台预建空闲连接, 与随后的 AddHook 存在数据竞争
+	}
+	client := redisHd.NewFailoverClient(clientOpts)
+	for _, hook := range opts.Hooks {
+		client.AddHook(hook)
+	}
+	return newKRedis(ctx, client), nil
+}
+
+// NewKRedisSentinelCluster 创建哨兵模式的客户端, 只读命令按 ReadOnly, RouteByLatency, RouteRandomly 路由到从节点
+func NewKRedisSentinelCluster(ctx *kcontext.ContextNode, opts *RedisOptions) (*KRedisCluster, error) {
+	if opts == nil || len(opts.Addrs) == 0 {
+		return nil, ErrNoAddrs
+	}
+	if opts.MasterName == "" {
+		return nil, fmt.Errorf("%w: sentinel mode requires masterName", ErrInvalidMode)
+	}
+	clientOpts, err := opts.failoverOptions()
+	if err != nil {
+		return nil, err
+	}
+	if !clientOpts.RouteByLatency && !clientOpts.RouteRandomly {
+		clientOpts.RouteRandomly = opts.ReadOnly
+	}
+
+	client := redisHd.NewFailoverClusterClient(clientOpts)
+	for _, hook := range opts.Hooks {
+		client.AddHook(hook)
+	}
+	return newKRedisCluster(ctx, client), nil
+}
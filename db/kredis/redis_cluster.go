@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -23,48 +24,63 @@ func redisClusterOnConnect(ctx context.Context, cn *redisHd.Conn) error {
 	return nil
 }
 
+// NewKRedisCluster 创建集群客户端, 使用 NewRedisClusterOptions 的默认参数; 需要 TLS 或调整连接池时使用 NewKRedisClusterWithOptions
 func NewKRedisCluster(ctx *kcontext.ContextNode, addrs []string, user string, password string, dbNum int) *KRedisCluster {
-	client := redisHd.NewClusterClient(&redisHd.ClusterOptions{
-		Addrs:     addrs,
-		Username:  user,
-		Password:  password,
-		OnConnect: redisClusterOnConnect, // 指定连接成功后的钩子函数
-		// Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
-		// 	return net.DialTimeout(network, addr, 10*time.Second)
-		// },
+	opts := NewRedisClusterOptions(addrs...).SetAuth(user, password).SetDB(dbNum).SetOnConnect(redisClusterOnConnect)
+	client, err := NewKRedisClusterWithOptions(ctx, opts)
+	if err != nil { // 只有加载 TLS 证书文件会失败, 这里没有配置, 正常不会出现
+		log.Printf("kredis: NewKRedisCluster %v failed: %v", addrs, err)
+	}
+	return client
+}
+
+// NewRedisClusterOptions 创建集群模式的连接参数, 默认值与 NewKRedisCluster 保持一致
+func NewRedisClusterOptions(addrs ...string) *RedisOptions {
+	return &RedisOptions{
+		Mode:                  RedisModeCluster,
+		Addrs:                 addrs,
+		ClientName:            "kredis_cluster_client", // 客户端标识
 		Protocol:              2,                       // 协议版本, 2 代表 RESP2, 3 代表 RESP3, RESP3 是 Redis 6.0 之后引入的高性能新型协议
-		NewClient:             nil,                     // 当集群客户端发现需要连接某个具体的单机节点时, 会调用这个函数
-		ClusterSlots:          nil,                     // 提供分片信息, 如果没有提供, 会执行一次INFO SHARDS获取分片信息
+		MaxRedirects:          8,                       // 遇到重定向（MOVED 或 ASK 错误）时的最大重试次数
 		ReadOnly:              false,                   // 从节点是否只读, 除非读压力巨大且能容忍短暂脏读, 否则不建议开只读
 		RouteByLatency:        false,                   // 是否根据网络延迟自动路由读请求
-		MaxRedirects:          8,                       // 遇到重定向（MOVED 或 ASK 错误）时的最大重试次数
 		MaxRetries:            3,                       // 最大重试次数
-		MinRetryBackoff:       8 * time.Millisecond,    // 重试间隔时间下限
-		MaxRetryBackoff:       512 * time.Millisecond,  // 重试间隔时间上限
-		DialTimeout:           10 * time.Second,        // 连接超时时间
-		ReadTimeout:           4 * time.Second,         // socket 读取超时时间
-		WriteTimeout:          4 * time.Second,         // socket 写入超时时间
+		MinRetryBackoff:       8,                       // 重试间隔时间下限 8ms
+		MaxRetryBackoff:       512,                     // 重试间隔时间上限 512ms
+		DialTimeout:           10000,                   // 连接超时时间 10s
+		ReadTimeout:           4000,                    // socket 读取超时时间 4s
+		WriteTimeout:          4000,                    // socket 写入超时时间 4s
 		PoolFIFO:              true,                    // 空闲连接池队列是否采用先进先出方式
 		PoolSize:              10,                      // 连接池大小
 		MaxActiveConns:        20,                      // 每个 Redis 节点在同一时刻能够分配的最大激活连接数（包含连接池内的空闲连接和正在使用的连接）
-		PoolTimeout:           4 * time.Second,         // 连接池等待超时时间, 如果获取连接超过这个时间就会失败
+		PoolTimeout:           4000,                    // 连接池等待超时时间 4s, 如果获取连接超过这个时间就会失败
 		MinIdleConns:          2,                       // 连接池最小空闲连接数
 		MaxIdleConns:          4,                       // 连接池最大空闲连接数
-		ConnMaxIdleTime:       5 * time.Minute,         // 【优化】长连接空闲 5 分钟自动回收, 防止占着连接
-		ConnMaxLifetime:       30 * time.Minute,        // 【优化】强制每 30 分钟轮换长连接, 防止隐性底层网络老化,
+		ConnMaxIdleTime:       5 * 60 * 1000,           // 【优化】长连接空闲 5 分钟自动回收, 防止占着连接
+		ConnMaxLifetime:       30 * 60 * 1000,          // 【优化】强制每 30 分钟轮换长连接, 防止隐性底层网络老化,
 		ContextTimeoutEnabled: true,                    // 【优化】推荐开启！允许外部传入的 ctx.WithTimeout 强行中断请求
-		ClientName:            "kredis_cluster_client", // 客户端标识
-		DisableIndentity:      false,                   // 连接建立时是否设置客户端标识, 默认是设置
-		TLSConfig:             nil,                     // TLS 配置
-	})
+	}
+}
 
+// newKRedisCluster 包装已创建的集群客户端
+func newKRedisCluster(ctx *kcontext.ContextNode, client *redisHd.ClusterClient) *KRedisCluster {
 	subCtx := ctx.NewChild("kredis_cluster_client")
 	return &KRedisCluster{Client: client, ctx: subCtx}
 }
 
+// UniversalClient 返回底层的 go-redis 集群客户端
+func (that *KRedisCluster) UniversalClient() redisHd.UniversalClient {
+	return that.Client
+}
+
 // 执行指令
 func (that *KRedisCluster) Do(args ...any) (any, error) {
-	val, err := that.Client.Do(that.ctx.Context(), args...).Result()
+	return that.DoWithCtx(that.ctx.Context(), args...)
+}
+
+// DoWithCtx 同 Do, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) DoWithCtx(ctx context.Context, args ...any) (any, error) {
+	val, err := that.Client.Do(ctx, args...).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -75,7 +91,12 @@ func (that *KRedisCluster) Do(args ...any) (any, error) {
 
 // 获取一个key的值
 func (that *KRedisCluster) Get(key string) (any, error) {
-	val, err := that.Client.Do(that.ctx.Context(), "GET", key).Result()
+	return that.GetWithCtx(that.ctx.Context(), key)
+}
+
+// GetWithCtx 同 Get, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) GetWithCtx(ctx context.Context, key string) (any, error) {
+	val, err := that.Client.Do(ctx, "GET", key).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -86,7 +107,12 @@ func (that *KRedisCluster) Get(key string) (any, error) {
 
 // 设置某个key的值, 并指定ttl
 func (that *KRedisCluster) Set(key string, value any, duration time.Duration) (bool, error) {
-	err := that.Client.Set(that.ctx.Context(), key, value, duration).Err()
+	return that.SetWithCtx(that.ctx.Context(), key, value, duration)
+}
+
+// SetWithCtx 同 Set, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SetWithCtx(ctx context.Context, key string, value any, duration time.Duration) (bool, error) {
+	err := that.Client.Set(ctx, key, value, duration).Err()
 	if err != nil {
 		return false, err
 	}
@@ -95,7 +121,12 @@ func (that *KRedisCluster) Set(key string, value any, duration time.Duration) (b
 
 // 判断某个key是否存在
 func (that *KRedisCluster) Exist(key string) (bool, error) {
-	_, err := that.Client.Get(that.ctx.Context(), key).Result()
+	return that.ExistWithCtx(that.ctx.Context(), key)
+}
+
+// ExistWithCtx 同 Exist, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ExistWithCtx(ctx context.Context, key string) (bool, error) {
+	_, err := that.Client.Get(ctx, key).Result()
 	if err == redisHd.Nil {
 		return false, nil
 	} else if err != nil {
@@ -106,7 +137,12 @@ func (that *KRedisCluster) Exist(key string) (bool, error) {
 
 // 获取一个key的hash字段的值
 func (that *KRedisCluster) HGet(key string, field string) (any, error) {
-	val, err := that.Client.HGet(that.ctx.Context(), key, field).Result()
+	return that.HGetWithCtx(that.ctx.Context(), key, field)
+}
+
+// HGetWithCtx 同 HGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HGetWithCtx(ctx context.Context, key string, field string) (any, error) {
+	val, err := that.Client.HGet(ctx, key, field).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -117,7 +153,12 @@ func (that *KRedisCluster) HGet(key string, field string) (any, error) {
 
 // 设置一个key的hash字段的值
 func (that *KRedisCluster) HSet(key string, field string, value any) error {
-	err := that.Client.HSet(that.ctx.Context(), key, field, value).Err()
+	return that.HSetWithCtx(that.ctx.Context(), key, field, value)
+}
+
+// HSetWithCtx 同 HSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HSetWithCtx(ctx context.Context, key string, field string, value any) error {
+	err := that.Client.HSet(ctx, key, field, value).Err()
 	if err != nil {
 		return err
 	}
@@ -126,7 +167,12 @@ func (that *KRedisCluster) HSet(key string, field string, value any) error {
 
 // 获取一个key的hash字段的值列表
 func (that *KRedisCluster) HGetAll(key string) (map[string]string, error) {
-	valMap, err := that.Client.HGetAll(that.ctx.Context(), key).Result()
+	return that.HGetAllWithCtx(that.ctx.Context(), key)
+}
+
+// HGetAllWithCtx 同 HGetAll, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HGetAllWithCtx(ctx context.Context, key string) (map[string]string, error) {
+	valMap, err := that.Client.HGetAll(ctx, key).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -137,7 +183,12 @@ func (that *KRedisCluster) HGetAll(key string) (map[string]string, error) {
 
 // 设置一个key的hash字段的值列表
 func (that *KRedisCluster) HSetAll(key string, fields map[string]any) error {
-	err := that.Client.HSet(that.ctx.Context(), key, fields).Err()
+	return that.HSetAllWithCtx(that.ctx.Context(), key, fields)
+}
+
+// HSetAllWithCtx 同 HSetAll, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HSetAllWithCtx(ctx context.Context, key string, fields map[string]any) error {
+	err := that.Client.HSet(ctx, key, fields).Err()
 	if err != nil {
 		return err
 	}
@@ -146,7 +197,12 @@ func (that *KRedisCluster) HSetAll(key string, fields map[string]any) error {
 
 // 判断一个key的hash字段是否存在
 func (that *KRedisCluster) HExists(key string, field string) (bool, error) {
-	isExists, err := that.Client.HExists(that.ctx.Context(), key, field).Result()
+	return that.HExistsWithCtx(that.ctx.Context(), key, field)
+}
+
+// HExistsWithCtx 同 HExists, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HExistsWithCtx(ctx context.Context, key string, field string) (bool, error) {
+	isExists, err := that.Client.HExists(ctx, key, field).Result()
 	if nil != err {
 		return false, err
 	}
@@ -155,7 +211,12 @@ func (that *KRedisCluster) HExists(key string, field string) (bool, error) {
 
 // 获取一个key的hash字段的数量
 func (that *KRedisCluster) HLen(key string) (int64, error) {
-	val, err := that.Client.HLen(that.ctx.Context(), key).Result()
+	return that.HLenWithCtx(that.ctx.Context(), key)
+}
+
+// HLenWithCtx 同 HLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HLenWithCtx(ctx context.Context, key string) (int64, error) {
+	val, err := that.Client.HLen(ctx, key).Result()
 	if nil != err {
 		return 0, err
 	}
@@ -191,7 +252,12 @@ func (that *KRedisCluster) HSetNX(ctx context.Context, key, field string, value
 
 // 删除一个key的hash字段的值列表
 func (that *KRedisCluster) HDel(key string, fields ...string) error {
-	_, err := that.Client.HDel(that.ctx.Context(), key, fields...).Result()
+	return that.HDelWithCtx(that.ctx.Context(), key, fields...)
+}
+
+// HDelWithCtx 同 HDel, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HDelWithCtx(ctx context.Context, key string, fields ...string) error {
+	_, err := that.Client.HDel(ctx, key, fields...).Result()
 	if nil != err {
 		return err
 	}
@@ -200,7 +266,12 @@ func (that *KRedisCluster) HDel(key string, fields ...string) error {
 
 // 获取一个key的hash字段的值列表
 func (that *KRedisCluster) HMGet(key string, fields ...string) ([]any, error) {
-	valMap, err := that.Client.HMGet(that.ctx.Context(), key, fields...).Result()
+	return that.HMGetWithCtx(that.ctx.Context(), key, fields...)
+}
+
+// HMGetWithCtx 同 HMGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HMGetWithCtx(ctx context.Context, key string, fields ...string) ([]any, error) {
+	valMap, err := that.Client.HMGet(ctx, key, fields...).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if nil != err {
@@ -211,7 +282,12 @@ func (that *KRedisCluster) HMGet(key string, fields ...string) ([]any, error) {
 
 // 设置一个key的hash字段的值列表, 如果不存在则创建
 func (that *KRedisCluster) HMSet(key string, fields map[string]any) error {
-	err := that.Client.HMSet(that.ctx.Context(), key, fields).Err()
+	return that.HMSetWithCtx(that.ctx.Context(), key, fields)
+}
+
+// HMSetWithCtx 同 HMSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) HMSetWithCtx(ctx context.Context, key string, fields map[string]any) error {
+	err := that.Client.HMSet(ctx, key, fields).Err()
 	if nil != err {
 		return err
 	}
@@ -220,130 +296,275 @@ func (that *KRedisCluster) HMSet(key string, fields map[string]any) error {
 
 // 从列表左边插入数据
 func (that *KRedisCluster) LPush(key string, values ...any) (int64, error) {
-	return that.Client.LPush(that.ctx.Context(), key, values...).Result()
+	return that.LPushWithCtx(that.ctx.Context(), key, values...)
+}
+
+// LPushWithCtx 同 LPush, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LPushWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.LPush(ctx, key, values...).Result()
 }
 
 // 从列表左边插入数据, 如果不存在则不插入数据
 func (that *KRedisCluster) LPushX(key string, values ...any) (int64, error) {
-	return that.Client.LPushX(that.ctx.Context(), key, values...).Result()
+	return that.LPushXWithCtx(that.ctx.Context(), key, values...)
+}
+
+// LPushXWithCtx 同 LPushX, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LPushXWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.LPushX(ctx, key, values...).Result()
 }
 
 // 从列表右边插入数据
 func (that *KRedisCluster) RPush(key string, values ...any) (int64, error) {
-	return that.Client.RPush(that.ctx.Context(), key, values...).Result()
+	return that.RPushWithCtx(that.ctx.Context(), key, values...)
+}
+
+// RPushWithCtx 同 RPush, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) RPushWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.RPush(ctx, key, values...).Result()
 }
 
 // 从列表右边插入数据, 如果不存在则不插入数据
 func (that *KRedisCluster) RPushX(key string, values ...any) (int64, error) {
-	return that.Client.RPushX(that.ctx.Context(), key, values...).Result()
+	return that.RPushXWithCtx(that.ctx.Context(), key, values...)
+}
+
+// RPushXWithCtx 同 RPushX, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) RPushXWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.RPushX(ctx, key, values...).Result()
 }
 
 // 从列表左边弹出数据
 func (that *KRedisCluster) LPop(key string) (string, error) {
-	return that.Client.LPop(that.ctx.Context(), key).Result()
+	return that.LPopWithCtx(that.ctx.Context(), key)
+}
+
+// LPopWithCtx 同 LPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LPopWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.LPop(ctx, key).Result()
 }
 
 // 从列表右边弹出数据
 func (that *KRedisCluster) RPop(key string) (string, error) {
-	return that.Client.RPop(that.ctx.Context(), key).Result()
+	return that.RPopWithCtx(that.ctx.Context(), key)
+}
+
+// RPopWithCtx 同 RPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) RPopWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.RPop(ctx, key).Result()
 }
 
 // 返回列表的一个范围内的数据, 也可以返回全部数据
 func (that *KRedisCluster) LRange(key string, start int64, stop int64) ([]string, error) {
-	return that.Client.LRange(that.ctx.Context(), key, start, stop).Result()
+	return that.LRangeWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// LRangeWithCtx 同 LRange, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return that.Client.LRange(ctx, key, start, stop).Result()
 }
 
 // 返回列表的大小
 func (that *KRedisCluster) LLen(key string) (int64, error) {
-	return that.Client.LLen(that.ctx.Context(), key).Result()
+	return that.LLenWithCtx(that.ctx.Context(), key)
+}
+
+// LLenWithCtx 同 LLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LLenWithCtx(ctx context.Context, key string) (int64, error) {
+	return that.Client.LLen(ctx, key).Result()
 }
 
 func (that *KRedisCluster) LTrim(key string, start int64, stop int64) error {
-	return that.Client.LTrim(that.ctx.Context(), key, start, stop).Err()
+	return that.LTrimWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// LTrimWithCtx 同 LTrim, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LTrimWithCtx(ctx context.Context, key string, start int64, stop int64) error {
+	return that.Client.LTrim(ctx, key, start, stop).Err()
 }
 
 func (that *KRedisCluster) LSet(key string, index int64, value any) error {
-	return that.Client.LSet(that.ctx.Context(), key, index, value).Err()
+	return that.LSetWithCtx(that.ctx.Context(), key, index, value)
+}
+
+// LSetWithCtx 同 LSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LSetWithCtx(ctx context.Context, key string, index int64, value any) error {
+	return that.Client.LSet(ctx, key, index, value).Err()
 }
 
 // 删除列表中的数据
 func (that *KRedisCluster) LRem(key string, count int64, value any) (int64, error) {
-	return that.Client.LRem(that.ctx.Context(), key, count, value).Result()
+	return that.LRemWithCtx(that.ctx.Context(), key, count, value)
+}
+
+// LRemWithCtx 同 LRem, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LRemWithCtx(ctx context.Context, key string, count int64, value any) (int64, error) {
+	return that.Client.LRem(ctx, key, count, value).Result()
 }
 
 // 根据索引坐标, 查询列表中的数据
 func (that *KRedisCluster) LIndex(key string, index int64) (string, error) {
-	return that.Client.LIndex(that.ctx.Context(), key, index).Result()
+	return that.LIndexWithCtx(that.ctx.Context(), key, index)
+}
+
+// LIndexWithCtx 同 LIndex, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LIndexWithCtx(ctx context.Context, key string, index int64) (string, error) {
+	return that.Client.LIndex(ctx, key, index).Result()
 }
 
 // 在指定位置插入数据, 在头部插入用"before", 尾部插入用"after"
 func (that *KRedisCluster) LInsert(key string, position string, pivot any, value any) (int64, error) {
-	return that.Client.LInsert(that.ctx.Context(), key, position, pivot, value).Result()
+	return that.LInsertWithCtx(that.ctx.Context(), key, position, pivot, value)
+}
+
+// LInsertWithCtx 同 LInsert, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) LInsertWithCtx(ctx context.Context, key string, position string, pivot any, value any) (int64, error) {
+	return that.Client.LInsert(ctx, key, position, pivot, value).Result()
 }
 
 func (that *KRedisCluster) SAdd(key string, members ...any) (int64, error) {
-	return that.Client.SAdd(that.ctx.Context(), key, members...).Result()
+	return that.SAddWithCtx(that.ctx.Context(), key, members...)
+}
+
+// SAddWithCtx 同 SAdd, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SAddWithCtx(ctx context.Context, key string, members ...any) (int64, error) {
+	return that.Client.SAdd(ctx, key, members...).Result()
 }
 
 func (that *KRedisCluster) SMembers(key string) ([]string, error) {
-	return that.Client.SMembers(that.ctx.Context(), key).Result()
+	return that.SMembersWithCtx(that.ctx.Context(), key)
+}
+
+// SMembersWithCtx 同 SMembers, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SMembersWithCtx(ctx context.Context, key string) ([]string, error) {
+	return that.Client.SMembers(ctx, key).Result()
 }
 
 func (that *KRedisCluster) SRem(key string, members ...any) (int64, error) {
-	return that.Client.SRem(that.ctx.Context(), key, members...).Result()
+	return that.SRemWithCtx(that.ctx.Context(), key, members...)
+}
+
+// SRemWithCtx 同 SRem, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SRemWithCtx(ctx context.Context, key string, members ...any) (int64, error) {
+	return that.Client.SRem(ctx, key, members...).Result()
 }
 
 func (that *KRedisCluster) SIsMember(key string, member any) (bool, error) {
-	return that.Client.SIsMember(that.ctx.Context(), key, member).Result()
+	return that.SIsMemberWithCtx(that.ctx.Context(), key, member)
+}
+
+// SIsMemberWithCtx 同 SIsMember, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SIsMemberWithCtx(ctx context.Context, key string, member any) (bool, error) {
+	return that.Client.SIsMember(ctx, key, member).Result()
 }
 
 func (that *KRedisCluster) SCard(key string) (int64, error) {
-	return that.Client.SCard(that.ctx.Context(), key).Result()
+	return that.SCardWithCtx(that.ctx.Context(), key)
+}
+
+// SCardWithCtx 同 SCard, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SCardWithCtx(ctx context.Context, key string) (int64, error) {
+	return that.Client.SCard(ctx, key).Result()
 }
 
 func (that *KRedisCluster) SPop(key string) (string, error) {
-	return that.Client.SPop(that.ctx.Context(), key).Result()
+	return that.SPopWithCtx(that.ctx.Context(), key)
+}
+
+// SPopWithCtx 同 SPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SPopWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.SPop(ctx, key).Result()
 }
 
 func (that *KRedisCluster) SPopN(key string, count int64) ([]string, error) {
-	return that.Client.SPopN(that.ctx.Context(), key, count).Result()
+	return that.SPopNWithCtx(that.ctx.Context(), key, count)
+}
+
+// SPopNWithCtx 同 SPopN, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SPopNWithCtx(ctx context.Context, key string, count int64) ([]string, error) {
+	return that.Client.SPopN(ctx, key, count).Result()
 }
 
 func (that *KRedisCluster) SUnion(keys ...string) ([]string, error) {
-	return that.Client.SUnion(that.ctx.Context(), keys...).Result()
+	return that.SUnionWithCtx(that.ctx.Context(), keys...)
+}
+
+// SUnionWithCtx 同 SUnion, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SUnionWithCtx(ctx context.Context, keys ...string) ([]string, error) {
+	return that.Client.SUnion(ctx, keys...).Result()
 }
 
 func (that *KRedisCluster) SUnionStore(destKey string, keys ...string) (int64, error) {
-	return that.Client.SUnionStore(that.ctx.Context(), destKey, keys...).Result()
+	return that.SUnionStoreWithCtx(that.ctx.Context(), destKey, keys...)
+}
+
+// SUnionStoreWithCtx 同 SUnionStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SUnionStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error) {
+	return that.Client.SUnionStore(ctx, destKey, keys...).Result()
 }
 
 func (that *KRedisCluster) SInter(keys ...string) ([]string, error) {
-	return that.Client.SInter(that.ctx.Context(), keys...).Result()
+	return that.SInterWithCtx(that.ctx.Context(), keys...)
+}
+
+// SInterWithCtx 同 SInter, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SInterWithCtx(ctx context.Context, keys ...string) ([]string, error) {
+	return that.Client.SInter(ctx, keys...).Result()
 }
 
 func (that *KRedisCluster) SInterStore(destKey string, keys ...string) (int64, error) {
-	return that.Client.SInterStore(that.ctx.Context(), destKey, keys...).Result()
+	return that.SInterStoreWithCtx(that.ctx.Context(), destKey, keys...)
+}
+
+// SInterStoreWithCtx 同 SInterStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SInterStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error) {
+	return that.Client.SInterStore(ctx, destKey, keys...).Result()
 }
 
 func (that *KRedisCluster) SDiff(keys ...string) ([]string, error) {
-	return that.Client.SDiff(that.ctx.Context(), keys...).Result()
+	return that.SDiffWithCtx(that.ctx.Context(), keys...)
+}
+
+// SDiffWithCtx 同 SDiff, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SDiffWithCtx(ctx context.Context, keys ...string) ([]string, error) {
+	return that.Client.SDiff(ctx, keys...).Result()
 }
 
 func (that *KRedisCluster) SDiffStore(destKey string, keys ...string) (int64, error) {
-	return that.Client.SDiffStore(that.ctx.Context(), destKey, keys...).Result()
+	return that.SDiffStoreWithCtx(that.ctx.Context(), destKey, keys...)
+}
+
+// SDiffStoreWithCtx 同 SDiffStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SDiffStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error) {
+	return that.Client.SDiffStore(ctx, destKey, keys...).Result()
 }
 
 func (that *KRedisCluster) SMove(source, destination string, member any) (bool, error) {
-	return that.Client.SMove(that.ctx.Context(), source, destination, member).Result()
+	return that.SMoveWithCtx(that.ctx.Context(), source, destination, member)
+}
+
+// SMoveWithCtx 同 SMove, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SMoveWithCtx(ctx context.Context, source, destination string, member any) (bool, error) {
+	return that.Client.SMove(ctx, source, destination, member).Result()
 }
 
 func (that *KRedisCluster) SRandMember(key string) (string, error) {
-	return that.Client.SRandMember(that.ctx.Context(), key).Result()
+	return that.SRandMemberWithCtx(that.ctx.Context(), key)
+}
+
+// SRandMemberWithCtx 同 SRandMember, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SRandMemberWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.SRandMember(ctx, key).Result()
 }
 
 // 获取一个key的数据类型, 数据类型全小写
 func (that *KRedisCluster) Type(key string) (string, error) {
-	dataType, err := that.Client.Type(that.ctx.Context(), key).Result()
+	return that.TypeWithCtx(that.ctx.Context(), key)
+}
+
+// TypeWithCtx 同 Type, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) TypeWithCtx(ctx context.Context, key string) (string, error) {
+	dataType, err := that.Client.Type(ctx, key).Result()
 	if nil != err {
 		return "", err
 	}
@@ -352,20 +573,40 @@ func (that *KRedisCluster) Type(key string) (string, error) {
 
 // 返回一个Key的过期时间, 单位为毫秒
 func (that *KRedisCluster) PTTL(key string) (time.Duration, error) {
-	return that.Client.PTTL(that.ctx.Context(), key).Result()
+	return that.PTTLWithCtx(that.ctx.Context(), key)
+}
+
+// PTTLWithCtx 同 PTTL, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) PTTLWithCtx(ctx context.Context, key string) (time.Duration, error) {
+	return that.Client.PTTL(ctx, key).Result()
 }
 
 // 返回一个Key的过期时间, 单位为秒
 func (that *KRedisCluster) TTL(key string) (time.Duration, error) {
-	return that.Client.TTL(that.ctx.Context(), key).Result()
+	return that.TTLWithCtx(that.ctx.Context(), key)
+}
+
+// TTLWithCtx 同 TTL, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) TTLWithCtx(ctx context.Context, key string) (time.Duration, error) {
+	return that.Client.TTL(ctx, key).Result()
 }
 
 func (that *KRedisCluster) Expire(key string, expiration time.Duration) bool {
-	return that.Client.Expire(that.ctx.Context(), key, expiration).Val()
+	return that.ExpireWithCtx(that.ctx.Context(), key, expiration)
+}
+
+// ExpireWithCtx 同 Expire, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ExpireWithCtx(ctx context.Context, key string, expiration time.Duration) bool {
+	return that.Client.Expire(ctx, key, expiration).Val()
 }
 
 func (that *KRedisCluster) ExpireAt(key string, tm time.Time) bool {
-	return that.Client.ExpireAt(that.ctx.Context(), key, tm).Val()
+	return that.ExpireAtWithCtx(that.ctx.Context(), key, tm)
+}
+
+// ExpireAtWithCtx 同 ExpireAt, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ExpireAtWithCtx(ctx context.Context, key string, tm time.Time) bool {
+	return that.Client.ExpireAt(ctx, key, tm).Val()
 }
 
 // JsonGet 封装了 Redis JSON.GET 命令, 并直接返回原始的 JSON 字符串.
@@ -374,13 +615,18 @@ func (that *KRedisCluster) ExpireAt(key string, tm time.Time) bool {
 // paths: 可选的 JSON Path 参数.如果没有提供, 则获取整个 JSON 文档.
 // 返回值：JSON 字符串.如果键或路径不存在, 或结果为空, 则返回空字符串和 nil 错误.
 func (that *KRedisCluster) JsonGet(key string, paths ...string) (string, error) {
+	return that.JsonGetWithCtx(that.ctx.Context(), key, paths...)
+}
+
+// JsonGetWithCtx 同 JsonGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonGetWithCtx(ctx context.Context, key string, paths ...string) (string, error) {
 	args := make([]any, 0, 2+len(paths))
 	args = append(args, "JSON.GET", key)
 	for _, path := range paths {
 		args = append(args, path)
 	}
 	// 执行 Redis 命令
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 	// 检查 Redis 命令执行是否出错
 	if err := cmd.Err(); err != nil {
 		// 如果错误是 redis.Nil (表示键或路径不存在), 则返回空字符串和 nil 错误
@@ -408,9 +654,14 @@ func (that *KRedisCluster) JsonGet(key string, paths ...string) (string, error)
 // value: 要存储的 Go 值, 将被序列化为 JSON.
 // 返回值：如果操作成功则返回 nil, 否则返回错误.
 func (that *KRedisCluster) JsonSet(key string, path string, value string) error {
+	return that.JsonSetWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonSetWithCtx 同 JsonSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonSetWithCtx(ctx context.Context, key string, path string, value string) error {
 	// 构建 Redis 命令参数：JSON.SET key path jsonValueString
 	// `string(jsonValue)` 将字节切片转换为字符串, go-redis 可以接受
-	cmd := that.Client.Do(that.ctx.Context(), "JSON.SET", key, path, value)
+	cmd := that.Client.Do(ctx, "JSON.SET", key, path, value)
 
 	// 检查 Redis 命令执行是否出错
 	if err := cmd.Err(); err != nil {
@@ -427,7 +678,12 @@ func (that *KRedisCluster) JsonSet(key string, path string, value string) error
 // value: 要存储的 Go 值, 将被序列化为 JSON 并与现有值合并.
 // 返回值：如果操作成功则返回 nil, 否则返回错误.
 func (that *KRedisCluster) JsonMerge(key string, path string, value string) error {
-	cmd := that.Client.Do(that.ctx.Context(), "JSON.MERGE", key, path, value)
+	return that.JsonMergeWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonMergeWithCtx 同 JsonMerge, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonMergeWithCtx(ctx context.Context, key string, path string, value string) error {
+	cmd := that.Client.Do(ctx, "JSON.MERGE", key, path, value)
 	if err := cmd.Err(); err != nil {
 		return err
 	}
@@ -439,6 +695,11 @@ func (that *KRedisCluster) JsonMerge(key string, path string, value string) erro
 // path: 可选的 JSON Path.如果为空字符串, 则删除整个 JSON 文档.
 // 返回值：被删除的 JSON 值数量.如果键或路径不存在, 通常返回 0.
 func (that *KRedisCluster) JsonDel(key string, path string) (int64, error) {
+	return that.JsonDelWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonDelWithCtx 同 JsonDel, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonDelWithCtx(ctx context.Context, key string, path string) (int64, error) {
 	// 构建 Redis 命令参数
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.DEL", key)
@@ -446,7 +707,7 @@ func (that *KRedisCluster) JsonDel(key string, path string) (int64, error) {
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	// 检查 Redis 命令执行是否出错
 	if err := cmd.Err(); err != nil {
@@ -468,13 +729,18 @@ func (that *KRedisCluster) JsonDel(key string, path string) (int64, error) {
 // path: 可选的 JSON Path.如果为空字符串, 则返回根路径的类型; 不支持同时指定多个路径.
 // 返回值：一个包含 JSON 值类型的字符串切片.如果键或路径不存在, 则返回 nil 切片和 nil 错误.
 func (that *KRedisCluster) JsonType(key string, path string) ([]string, error) {
+	return that.JsonTypeWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonTypeWithCtx 同 JsonType, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonTypeWithCtx(ctx context.Context, key string, path string) ([]string, error) {
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.TYPE", key)
 	if path != "" { // 如果 path 不为空, 则添加到参数中
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	if err := cmd.Err(); err != nil {
 		if err == redisHd.Nil {
@@ -519,13 +785,18 @@ func (that *KRedisCluster) JsonType(key string, path string) ([]string, error) {
 // path: 可选的 JSON Path.如果为空字符串, 则返回根对象的键.
 // 返回值：一个包含对象键的字符串切片.如果键、路径不存在或路径对应的不是对象, 则返回 nil 切片和 nil 错误.
 func (that *KRedisCluster) JsonObjKeys(key string, path string) ([]string, error) {
+	return that.JsonObjKeysWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonObjKeysWithCtx 同 JsonObjKeys, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonObjKeysWithCtx(ctx context.Context, key string, path string) ([]string, error) {
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.OBJKEYS", key)
 	if path != "" { // 如果 path 不为空, 则添加到参数中
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	if err := cmd.Err(); err != nil {
 		if err == redisHd.Nil {
@@ -561,13 +832,18 @@ func (that *KRedisCluster) JsonObjKeys(key string, path string) ([]string, error
 // path: 可选的 JSON Path.如果为空字符串, 则返回根对象的键.
 // 返回值：一个包含对象键的字符串切片.如果键、路径不存在或路径对应的不是对象, 则返回 nil 切片和 nil 错误.
 func (that *KRedisCluster) JsonObjLen(key string, path string) ([]int64, error) {
+	return that.JsonObjLenWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonObjLenWithCtx 同 JsonObjLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonObjLenWithCtx(ctx context.Context, key string, path string) ([]int64, error) {
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.OBJLEN", key)
 	if path != "" { // 如果 path 不为空, 则添加到参数中
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	if err := cmd.Err(); err != nil {
 		if err == redisHd.Nil {
@@ -606,29 +882,60 @@ func (that *KRedisCluster) Pipeline() redisHd.Pipeliner {
 }
 
 func (that *KRedisCluster) Dump(key string) (string, error) {
-	return that.Client.Dump(that.ctx.Context(), key).Result()
+	return that.DumpWithCtx(that.ctx.Context(), key)
+}
+
+// DumpWithCtx 同 Dump, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) DumpWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.Dump(ctx, key).Result()
 }
 
 func (that *KRedisCluster) RestoreReplace(key string, ttl time.Duration, value string) (string, error) {
-	return that.Client.RestoreReplace(that.ctx.Context(), key, ttl, value).Result()
+	return that.RestoreReplaceWithCtx(that.ctx.Context(), key, ttl, value)
+}
+
+// RestoreReplaceWithCtx 同 RestoreReplace, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) RestoreReplaceWithCtx(ctx context.Context, key string, ttl time.Duration, value string) (string, error) {
+	return that.Client.RestoreReplace(ctx, key, ttl, value).Result()
 }
 
 func (that *KRedisCluster) Restore(key string, ttl time.Duration, value string) (string, error) {
-	return that.Client.Restore(that.ctx.Context(), key, ttl, value).Result()
+	return that.RestoreWithCtx(that.ctx.Context(), key, ttl, value)
+}
+
+// RestoreWithCtx 同 Restore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) RestoreWithCtx(ctx context.Context, key string, ttl time.Duration, value string) (string, error) {
+	return that.Client.Restore(ctx, key, ttl, value).Result()
 }
 
 // 删除一批key
 func (that *KRedisCluster) Del(keys ...string) (int64, error) {
-	return that.Client.Del(that.ctx.Context(), keys...).Result()
+	return that.DelWithCtx(that.ctx.Context(), keys...)
+}
+
+// DelWithCtx 同 Del, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) DelWithCtx(ctx context.Context, keys ...string) (int64, error) {
+	return that.Client.Del(ctx, keys...).Result()
 }
 
 // 探测服务是否正常
 func (that *KRedisCluster) Ping() bool {
-	_, err := that.Client.Ping(that.ctx.Context()).Result()
+	return that.PingWithCtx(that.ctx.Context())
+}
+
+// PingWithCtx 同 Ping, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) PingWithCtx(ctx context.Context) bool {
+	_, err := that.Client.Ping(ctx).Result()
 	return nil == err
 }
 
+// 会把所有 key 读入内存后在客户端过滤, 并逐个执行 TYPE, PTTL 与 DUMP; 数据量较大时使用 ScanKeys 或 ScanRecords
 func (that *KRedisCluster) ScanMatch(limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
+	return that.ScanMatchWithCtx(that.ctx.Context(), limit, aboutTypes, ignoreKeys, includeKeys, needDel, logf)
+}
+
+// ScanMatchWithCtx 同 ScanMatch, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ScanMatchWithCtx(ctx context.Context, limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
 	cursor := uint64(0)
 	allKeys := make([]string, 0, 50000)
 
@@ -636,7 +943,7 @@ func (that *KRedisCluster) ScanMatch(limit int, aboutTypes []string, ignoreKeys
 	for {
 		var keys []string
 		err := error(nil)
-		keys, cursor, err = that.Client.Scan(that.ctx.Context(), cursor, "", int64(limit)).Result()
+		keys, cursor, err = that.Client.Scan(ctx, cursor, "", int64(limit)).Result()
 		if nil != err {
 			return nil, err
 		}
@@ -666,7 +973,7 @@ func (that *KRedisCluster) ScanMatch(limit int, aboutTypes []string, ignoreKeys
 			}
 		}
 
-		dataType, err := that.Type(key)
+		dataType, err := that.TypeWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -680,12 +987,12 @@ func (that *KRedisCluster) ScanMatch(limit int, aboutTypes []string, ignoreKeys
 			continue
 		}
 
-		ttl, err := that.PTTL(key)
+		ttl, err := that.PTTLWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
 
-		data, err := that.Dump(key)
+		data, err := that.DumpWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -696,7 +1003,13 @@ func (that *KRedisCluster) ScanMatch(limit int, aboutTypes []string, ignoreKeys
 	return dataList, nil
 }
 
+// 会把所有 key 读入内存后在客户端过滤, 并逐个执行 TYPE, PTTL 与 DUMP; 数据量较大时使用 ScanKeys 或 ScanRecords
 func (that *KRedisCluster) Scan(limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
+	return that.ScanWithCtx(that.ctx.Context(), limit, aboutTypes, ignoreKeys, includeKeys, needDel, logf)
+}
+
+// ScanWithCtx 同 Scan, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ScanWithCtx(ctx context.Context, limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
 	cursor := uint64(0)
 	allKeys := make([]string, 0, 50000)
 
@@ -706,7 +1019,7 @@ func (that *KRedisCluster) Scan(limit int, aboutTypes []string, ignoreKeys []str
 	for {
 		var keys []string
 		err := error(nil)
-		keys, cursor, err = that.Client.Scan(that.ctx.Context(), cursor, "", int64(limit)).Result()
+		keys, cursor, err = that.Client.Scan(ctx, cursor, "", int64(limit)).Result()
 		if nil != err {
 			return nil, err
 		}
@@ -738,7 +1051,7 @@ func (that *KRedisCluster) Scan(limit int, aboutTypes []string, ignoreKeys []str
 			}
 		}
 
-		dataType, err := that.Type(key)
+		dataType, err := that.TypeWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -748,12 +1061,12 @@ func (that *KRedisCluster) Scan(limit int, aboutTypes []string, ignoreKeys []str
 			continue
 		}
 
-		ttl, err := that.PTTL(key)
+		ttl, err := that.PTTLWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
 
-		data, err := that.Dump(key)
+		data, err := that.DumpWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -770,7 +1083,12 @@ func (that *KRedisCluster) Scan(limit int, aboutTypes []string, ignoreKeys []str
 
 // 向指定topic发布消息
 func (that *KRedisCluster) Publish(topic string, payload any) error {
-	return that.Client.Publish(that.ctx.Context(), topic, payload).Err()
+	return that.PublishWithCtx(that.ctx.Context(), topic, payload)
+}
+
+// PublishWithCtx 同 Publish, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) PublishWithCtx(ctx context.Context, topic string, payload any) error {
+	return that.Client.Publish(ctx, topic, payload).Err()
 }
 
 // 使用pipeline 向指定topic发布多条消息
@@ -0,0 +1,270 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	mrand "math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// ElectionOptions 选主参数, 时间单位均为毫秒
+type ElectionOptions struct {
+	ID            string `json:"id"`            // 候选者标识, 默认 hostname:pid
+	Prefix        string `json:"prefix"`        // key 前缀, 默认 "kredis:election:"
+	TTL           int64  `json:"ttl"`           // 租约有效期, Leader 每 TTL/3 续期一次, 宕机后最多 TTL 后其他副本接任, 默认15000
+	RetryInterval int64  `json:"retryInterval"` // 非 Leader 尝试竞选的间隔, 实际间隔带有随机抖动, 默认1000
+
+	// 当选时的回调, ctx 在失去 Leader 身份(租约丢失, 主动让出或停止)时立即取消, Leader 专属的任务应绑定到 ctx 上;
+	// term 为单调递增的任期, 可作为隔离令牌使用. 回调在选主 goroutine 中执行, 不应阻塞
+	OnElected func(ctx *kcontext.ContextNode, term int64) `json:"-"`
+	OnRevoked func(term int64)                            `json:"-"` // 失去 Leader 身份时的回调, 此时 OnElected 中的 ctx 已被取消
+	OnError   func(err error)                             `json:"-"` // 访问 Redis 失败时的回调, 可用于记录日志
+}
+
+func NewElectionOptions() *ElectionOptions {
+	hostname, _ := os.Hostname()
+	return &ElectionOptions{
+		ID:            hostname + ":" + strconv.Itoa(os.Getpid()),
+		Prefix:        "kredis:election:",
+		TTL:           15000,
+		RetryInterval: 1000,
+	}
+}
+
+func (that *ElectionOptions) SetID(id string) *ElectionOptions {
+	that.ID = id
+	return that
+}
+
+func (that *ElectionOptions) SetPrefix(prefix string) *ElectionOptions {
+	that.Prefix = prefix
+	return that
+}
+
+// 设置租约有效期与竞选间隔, 单位 毫秒
+func (that *ElectionOptions) SetTTL(ttl int64, retryInterval int64) *ElectionOptions {
+	that.TTL = ttl
+	that.RetryInterval = retryInterval
+	return that
+}
+
+func (that *ElectionOptions) SetCallbacks(onElected func(ctx *kcontext.ContextNode, term int64), onRevoked func(term int64)) *ElectionOptions {
+	that.OnElected = onElected
+	that.OnRevoked = onRevoked
+	return that
+}
+
+func (that *ElectionOptions) SetErrorHandler(fn func(err error)) *ElectionOptions {
+	that.OnError = fn
+	return that
+}
+
+// Election 基于分布式锁的选主, 同一个 name 下同一时刻最多一个副本为 Leader.
+//
+// 示例:
+//
+//	opts := kredis.NewElectionOptions().SetCallbacks(func(ctx *kcontext.ContextNode, term int64) {
+//		go computeDailyStatistics(ctx.Context())
+//	}, nil)
+//	election := kredis.NewElection(ctx, client, "aggregator", opts)
+//	election.Start()
+//	defer election.Stop()
+type Election struct {
+	client KRedisClient
+	key    string
+	opts   *ElectionOptions
+	locker *Locker
+
+	ctx      *kcontext.ContextNode
+	mu       sync.Mutex
+	lock     *Lock
+	cooldown time.Time // Resign 后在此之前不参与竞选
+	wg       sync.WaitGroup
+	start    sync.Once
+	stop     sync.Once
+}
+
+// NewElection 创建选主, 调用 Start 后开始竞选; 选主的生命周期绑定到 ctx 下新建的子节点上.
+// opts 为 nil 时使用默认参数, opts 会被复制, 创建后再修改不会生效
+func NewElection(ctx *kcontext.ContextNode, client KRedisClient, name string, opts *ElectionOptions) *Election {
+	if opts == nil {
+		opts = NewElectionOptions()
+	} else {
+		copied := *opts
+		opts = &copied
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 15000
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 1000
+	}
+	return &Election{
+		client: client,
+		key:    opts.Prefix + name,
+		opts:   opts,
+		locker: NewLocker(client, NewLockOptions().SetTTL(opts.TTL)),
+		ctx:    ctx.NewChild("kredis_election:" + name),
+	}
+}
+
+// ID 返回当前候选者标识
+func (that *Election) ID() string {
+	return that.opts.ID
+}
+
+// Start 开始竞选, 重复调用无效
+func (that *Election) Start() {
+	that.start.Do(func() {
+		that.wg.Add(1)
+		go func() {
+			defer that.wg.Done()
+			that.campaign()
+		}()
+	})
+}
+
+// Stop 停止竞选, 是 Leader 时释放租约, 等待 OnRevoked 返回
+func (that *Election) Stop() {
+	that.stop.Do(func() {
+		that.ctx.Cancel()
+		that.wg.Wait()
+		that.ctx.Remove()
+	})
+}
+
+// IsLeader 当前是否为 Leader
+func (that *Election) IsLeader() bool {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return that.lock != nil
+}
+
+// Term 返回当前任期, 不是 Leader 时返回 0
+func (that *Election) Term() int64 {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if that.lock == nil {
+		return 0
+	}
+	return that.lock.Token()
+}
+
+// Context 返回与当前任期绑定的上下文节点, 不是 Leader 时返回 nil
+func (that *Election) Context() *kcontext.ContextNode {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if that.lock == nil {
+		return nil
+	}
+	return that.lock.Context()
+}
+
+// Resign 主动让出 Leader 身份, 之后一个 TTL 内不参与竞选, 以便其他副本接任; 不是 Leader 时无效
+func (that *Election) Resign() error {
+	that.mu.Lock()
+	lock := that.lock
+	if lock != nil {
+		that.cooldown = time.Now().Add(millisecond(that.opts.TTL))
+	}
+	that.mu.Unlock()
+
+	if lock == nil {
+		return nil
+	}
+	return lock.Unlock()
+}
+
+// Leader 返回当前 Leader 的标识, 没有 Leader 时返回空字符串
+func (that *Election) Leader(ctx context.Context) (string, error) {
+	value, err := that.client.UniversalClient().Get(ctx, that.key).Result()
+	if errors.Is(err, redisHd.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if i := strings.LastIndexByte(value, '|'); i >= 0 { // 标识本身可能包含 '|'
+		value = value[:i]
+	}
+	return value, nil
+}
+
+func (that *Election) campaign() {
+	ctx := that.ctx.Context()
+	for ctx.Err() == nil {
+		that.mu.Lock()
+		wait := time.Until(that.cooldown)
+		that.mu.Unlock()
+		if wait <= 0 {
+			if lock := that.tryAcquire(); lock != nil {
+				that.lead(lock)
+				continue
+			}
+			// 随机抖动避免多个副本同时竞选
+			retry := millisecond(that.opts.RetryInterval)
+			wait = retry/2 + time.Duration(mrand.Int63n(int64(retry)))
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (that *Election) tryAcquire() *Lock {
+	nonce, err := randomLockValue()
+	if err != nil {
+		that.onError(err)
+		return nil
+	}
+	// 锁的值带有候选者标识, 供 Leader 查询
+	lock, err := that.locker.tryLock(that.ctx, that.key, that.opts.ID+"|"+nonce)
+	if err != nil {
+		if !errors.Is(err, ErrLockNotObtained) && that.ctx.Context().Err() == nil {
+			that.onError(err)
+		}
+		return nil
+	}
+	return lock
+}
+
+// lead 担任 Leader 直到租约丢失, 主动让出或停止
+func (that *Election) lead(lock *Lock) {
+	that.mu.Lock()
+	that.lock = lock
+	that.mu.Unlock()
+
+	term := lock.Token()
+	if that.opts.OnElected != nil {
+		that.opts.OnElected(lock.Context(), term)
+	}
+
+	<-lock.Context().Context().Done() // 停止时父节点取消, 同样会取消该节点
+
+	that.mu.Lock()
+	that.lock = nil
+	that.mu.Unlock()
+
+	if err := lock.Unlock(); err != nil && !lock.Lost() {
+		that.onError(err)
+	}
+	if that.opts.OnRevoked != nil {
+		that.opts.OnRevoked(term)
+	}
+}
+
+func (that *Election) onError(err error) {
+	if that.opts.OnError != nil {
+		that.opts.OnError(err)
+	}
+}
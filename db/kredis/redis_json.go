@@ -0,0 +1,413 @@
+package kredis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// RedisJSON 数组, 数值与字符串操作. 以下命令中 path 为空时使用 `$`;
+// `$` 开头的 JSONPath 可能匹配多个值, 因此结果均为切片, 与匹配的值一一对应; legacy 路径(如 `.name`)的结果为单个元素的切片
+
+var ErrJsonNotFound = errors.New("kredis: json key or path not found")
+
+// JsonGetAs 读取 key 中 path 的值并反序列化为 T, key 或路径不存在时返回 ErrJsonNotFound.
+// path 为空时读取整个文档; `$` 开头的 JSONPath 返回所有匹配值组成的数组, 此时 T 应为切片
+func JsonGetAs[T any](client KRedisClient, key string, path string) (T, error) {
+	text, err := client.JsonGet(key, jsonPaths(path)...)
+	return jsonDecode[T](text, path, err)
+}
+
+// JsonGetAsWithCtx 同 JsonGetAs, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func JsonGetAsWithCtx[T any](ctx context.Context, client KRedisClient, key string, path string) (T, error) {
+	text, err := client.JsonGetWithCtx(ctx, key, jsonPaths(path)...)
+	return jsonDecode[T](text, path, err)
+}
+
+// JsonSetValue 将 value 序列化为 JSON 后写入 key 的 path, path 为空时替换整个文档
+func JsonSetValue[T any](client KRedisClient, key string, path string, value T) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return client.JsonSet(key, jsonRoot(path), string(content))
+}
+
+// JsonSetValueWithCtx 同 JsonSetValue, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func JsonSetValueWithCtx[T any](ctx context.Context, client KRedisClient, key string, path string, value T) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return client.JsonSetWithCtx(ctx, key, jsonRoot(path), string(content))
+}
+
+func jsonPaths(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return []string{path}
+}
+
+// jsonDecode 反序列化 JSON.GET 的结果, JSONPath 没有匹配时结果为空数组
+func jsonDecode[T any](text string, path string, err error) (T, error) {
+	var value T
+	if err != nil {
+		return value, err
+	}
+	if text == "" || (text == "[]" && strings.HasPrefix(path, "$")) {
+		return value, ErrJsonNotFound
+	}
+	err = json.Unmarshal([]byte(text), &value)
+	return value, err
+}
+
+func jsonRoot(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
+
+// jsonArgs 拼接命令参数, values 追加在 prefix 之后
+func jsonArgs(values []string, prefix ...any) []any {
+	args := make([]any, 0, len(prefix)+len(values))
+	args = append(args, prefix...)
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+func jsonStrAppend(ctx context.Context, client redisHd.UniversalClient, key string, path string, value string) ([]int64, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return jsonIntReply(client.Do(ctx, "JSON.STRAPPEND", key, jsonRoot(path), string(content)))
+}
+
+// jsonMGet 非集群模式使用 JSON.MGET, 集群模式下逐个 JSON.GET
+func jsonMGet(ctx context.Context, client redisHd.UniversalClient, path string, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	path = jsonRoot(path)
+
+	if _, ok := client.(*redisHd.ClusterClient); !ok {
+		args := make([]any, 0, len(keys)+2)
+		args = append(args, "JSON.MGET")
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		args = append(args, path)
+		values, err := client.Do(ctx, args...).Slice()
+		if err != nil {
+			return nil, err
+		}
+		return jsonStrings(values)
+	}
+
+	cmds := make([]*redisHd.Cmd, 0, len(keys))
+	_, err := client.Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		for _, key := range keys {
+			cmds = append(cmds, pipe.Do(ctx, "JSON.GET", key, path))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redisHd.Nil) {
+		return nil, err
+	}
+	result := make([]string, 0, len(keys))
+	for _, cmd := range cmds {
+		text, err := cmd.Text()
+		if err != nil && !errors.Is(err, redisHd.Nil) {
+			return nil, err
+		}
+		result = append(result, text)
+	}
+	return result, nil
+}
+
+// jsonIntReply 解析整数或整数数组回复, 数组中的 nil (类型不匹配) 转换为 -1; key 不存在时返回 nil
+func jsonIntReply(cmd *redisHd.Cmd) ([]int64, error) {
+	reply, err := cmd.Result()
+	if errors.Is(err, redisHd.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := reply.(type) {
+	case int64:
+		return []int64{value}, nil
+	case []any:
+		result := make([]int64, 0, len(value))
+		for _, item := range value {
+			switch v := item.(type) {
+			case int64:
+				result = append(result, v)
+			case nil:
+				result = append(result, -1)
+			default:
+				return nil, fmt.Errorf("kredis: unexpected json reply item %T", item)
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("kredis: unexpected json reply %T", reply)
+}
+
+// jsonStringReply 解析字符串或字符串数组回复, 数组中的 nil 转换为空字符串; key 不存在时返回 nil
+func jsonStringReply(cmd *redisHd.Cmd) ([]string, error) {
+	reply, err := cmd.Result()
+	if errors.Is(err, redisHd.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := reply.(type) {
+	case string:
+		return []string{value}, nil
+	case []any:
+		return jsonStrings(value)
+	}
+	return nil, fmt.Errorf("kredis: unexpected json reply %T", reply)
+}
+
+func jsonStrings(values []any) ([]string, error) {
+	result := make([]string, 0, len(values))
+	for _, item := range values {
+		switch v := item.(type) {
+		case string:
+			result = append(result, v)
+		case nil:
+			result = append(result, "")
+		default:
+			return nil, fmt.Errorf("kredis: unexpected json reply item %T", item)
+		}
+	}
+	return result, nil
+}
+
+// jsonNumberReply 解析 JSON.NUMINCRBY 返回的 JSON 文本, 数值或数值数组, 数组中的 null 转换为 NaN
+func jsonNumberReply(cmd *redisHd.Cmd) ([]float64, error) {
+	text, err := cmd.Text()
+	if errors.Is(err, redisHd.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reply any
+	if err := json.Unmarshal([]byte(text), &reply); err != nil {
+		return nil, err
+	}
+	switch value := reply.(type) {
+	case float64:
+		return []float64{value}, nil
+	case []any:
+		result := make([]float64, 0, len(value))
+		for _, item := range value {
+			if v, ok := item.(float64); ok {
+				result = append(result, v)
+			} else {
+				result = append(result, math.NaN())
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("kredis: unexpected json reply %s", text)
+}
+
+///////////////////////////////////////////////////////////////
+
+// JsonArrAppend 向 path 指向的数组末尾追加元素, values 为 JSON 文本, 返回追加后各数组的长度, path 不是数组时为 -1
+func (that *KRedis) JsonArrAppend(key string, path string, values ...string) ([]int64, error) {
+	return that.JsonArrAppendWithCtx(that.ctx.Context(), key, path, values...)
+}
+
+// JsonArrAppendWithCtx 同 JsonArrAppend, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonArrAppendWithCtx(ctx context.Context, key string, path string, values ...string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, jsonArgs(values, "JSON.ARRAPPEND", key, jsonRoot(path))...))
+}
+
+// JsonArrInsert 在 path 指向的数组的 index 位置之前插入元素, 负数表示倒数, 返回插入后各数组的长度
+func (that *KRedis) JsonArrInsert(key string, path string, index int64, values ...string) ([]int64, error) {
+	return that.JsonArrInsertWithCtx(that.ctx.Context(), key, path, index, values...)
+}
+
+// JsonArrInsertWithCtx 同 JsonArrInsert, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonArrInsertWithCtx(ctx context.Context, key string, path string, index int64, values ...string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, jsonArgs(values, "JSON.ARRINSERT", key, jsonRoot(path), index)...))
+}
+
+// JsonArrPop 弹出 path 指向的数组中 index 位置的元素, -1 为最后一个, 返回弹出元素的 JSON 文本, 数组为空或不是数组时为空字符串
+func (that *KRedis) JsonArrPop(key string, path string, index int64) ([]string, error) {
+	return that.JsonArrPopWithCtx(that.ctx.Context(), key, path, index)
+}
+
+// JsonArrPopWithCtx 同 JsonArrPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonArrPopWithCtx(ctx context.Context, key string, path string, index int64) ([]string, error) {
+	return jsonStringReply(that.Client.Do(ctx, "JSON.ARRPOP", key, jsonRoot(path), index))
+}
+
+// JsonArrLen 返回 path 指向的数组的长度, 不是数组时为 -1, key 不存在时返回 nil
+func (that *KRedis) JsonArrLen(key string, path string) ([]int64, error) {
+	return that.JsonArrLenWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonArrLenWithCtx 同 JsonArrLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonArrLenWithCtx(ctx context.Context, key string, path string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, "JSON.ARRLEN", key, jsonRoot(path)))
+}
+
+// JsonNumIncrBy 为 path 指向的数值增加 value, 返回新的值, 不是数值时为 NaN
+func (that *KRedis) JsonNumIncrBy(key string, path string, value float64) ([]float64, error) {
+	return that.JsonNumIncrByWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonNumIncrByWithCtx 同 JsonNumIncrBy, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonNumIncrByWithCtx(ctx context.Context, key string, path string, value float64) ([]float64, error) {
+	return jsonNumberReply(that.Client.Do(ctx, "JSON.NUMINCRBY", key, jsonRoot(path), value))
+}
+
+// JsonStrAppend 向 path 指向的字符串末尾追加 value, value 为普通字符串, 自动编码为 JSON 字符串; 返回追加后各字符串的长度, 不是字符串时为 -1
+func (that *KRedis) JsonStrAppend(key string, path string, value string) ([]int64, error) {
+	return that.JsonStrAppendWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonStrAppendWithCtx 同 JsonStrAppend, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonStrAppendWithCtx(ctx context.Context, key string, path string, value string) ([]int64, error) {
+	return jsonStrAppend(ctx, that.Client, key, path, value)
+}
+
+// JsonMGet 使用一次 JSON.MGET 返回多个 key 中 path 的值(JSON 文本), 与 keys 一一对应, key 或路径不存在时为空字符串
+func (that *KRedis) JsonMGet(path string, keys ...string) ([]string, error) {
+	return that.JsonMGetWithCtx(that.ctx.Context(), path, keys...)
+}
+
+// JsonMGetWithCtx 同 JsonMGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonMGetWithCtx(ctx context.Context, path string, keys ...string) ([]string, error) {
+	return jsonMGet(ctx, that.Client, path, keys)
+}
+
+// JsonToggle 切换 path 指向的布尔值, 返回新的值(1 为 true, 0 为 false), 不是布尔值时为 -1
+func (that *KRedis) JsonToggle(key string, path string) ([]int64, error) {
+	return that.JsonToggleWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonToggleWithCtx 同 JsonToggle, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonToggleWithCtx(ctx context.Context, key string, path string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, "JSON.TOGGLE", key, jsonRoot(path)))
+}
+
+// JsonClear 将 path 指向的数组与对象清空, 数值置为 0, 返回被清空的值的数量
+func (that *KRedis) JsonClear(key string, path string) (int64, error) {
+	return that.JsonClearWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonClearWithCtx 同 JsonClear, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonClearWithCtx(ctx context.Context, key string, path string) (int64, error) {
+	return that.Client.Do(ctx, "JSON.CLEAR", key, jsonRoot(path)).Int64()
+}
+
+// JsonArrAppend 向 path 指向的数组末尾追加元素, values 为 JSON 文本, 返回追加后各数组的长度, path 不是数组时为 -1
+func (that *KRedisCluster) JsonArrAppend(key string, path string, values ...string) ([]int64, error) {
+	return that.JsonArrAppendWithCtx(that.ctx.Context(), key, path, values...)
+}
+
+// JsonArrAppendWithCtx 同 JsonArrAppend, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonArrAppendWithCtx(ctx context.Context, key string, path string, values ...string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, jsonArgs(values, "JSON.ARRAPPEND", key, jsonRoot(path))...))
+}
+
+// JsonArrInsert 在 path 指向的数组的 index 位置之前插入元素, 负数表示倒数, 返回插入后各数组的长度
+func (that *KRedisCluster) JsonArrInsert(key string, path string, index int64, values ...string) ([]int64, error) {
+	return that.JsonArrInsertWithCtx(that.ctx.Context(), key, path, index, values...)
+}
+
+// JsonArrInsertWithCtx 同 JsonArrInsert, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonArrInsertWithCtx(ctx context.Context, key string, path string, index int64, values ...string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, jsonArgs(values, "JSON.ARRINSERT", key, jsonRoot(path), index)...))
+}
+
+// JsonArrPop 弹出 path 指向的数组中 index 位置的元素, -1 为最后一个, 返回弹出元素的 JSON 文本, 数组为空或不是数组时为空字符串
+func (that *KRedisCluster) JsonArrPop(key string, path string, index int64) ([]string, error) {
+	return that.JsonArrPopWithCtx(that.ctx.Context(), key, path, index)
+}
+
+// JsonArrPopWithCtx 同 JsonArrPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonArrPopWithCtx(ctx context.Context, key string, path string, index int64) ([]string, error) {
+	return jsonStringReply(that.Client.Do(ctx, "JSON.ARRPOP", key, jsonRoot(path), index))
+}
+
+// JsonArrLen 返回 path 指向的数组的长度, 不是数组时为 -1, key 不存在时返回 nil
+func (that *KRedisCluster) JsonArrLen(key string, path string) ([]int64, error) {
+	return that.JsonArrLenWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonArrLenWithCtx 同 JsonArrLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonArrLenWithCtx(ctx context.Context, key string, path string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, "JSON.ARRLEN", key, jsonRoot(path)))
+}
+
+// JsonNumIncrBy 为 path 指向的数值增加 value, 返回新的值, 不是数值时为 NaN
+func (that *KRedisCluster) JsonNumIncrBy(key string, path string, value float64) ([]float64, error) {
+	return that.JsonNumIncrByWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonNumIncrByWithCtx 同 JsonNumIncrBy, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonNumIncrByWithCtx(ctx context.Context, key string, path string, value float64) ([]float64, error) {
+	return jsonNumberReply(that.Client.Do(ctx, "JSON.NUMINCRBY", key, jsonRoot(path), value))
+}
+
+// JsonStrAppend 向 path 指向的字符串末尾追加 value, value 为普通字符串, 自动编码为 JSON 字符串; 返回追加后各字符串的长度, 不是字符串时为 -1
+func (that *KRedisCluster) JsonStrAppend(key string, path string, value string) ([]int64, error) {
+	return that.JsonStrAppendWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonStrAppendWithCtx 同 JsonStrAppend, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonStrAppendWithCtx(ctx context.Context, key string, path string, value string) ([]int64, error) {
+	return jsonStrAppend(ctx, that.Client, key, path, value)
+}
+
+// JsonMGet 返回多个 key 中 path 的值(JSON 文本), 与 keys 一一对应, key 或路径不存在时为空字符串.
+// 集群模式下 key 可能位于不同的槽, 使用 pipeline 逐个执行 JSON.GET
+func (that *KRedisCluster) JsonMGet(path string, keys ...string) ([]string, error) {
+	return that.JsonMGetWithCtx(that.ctx.Context(), path, keys...)
+}
+
+// JsonMGetWithCtx 同 JsonMGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonMGetWithCtx(ctx context.Context, path string, keys ...string) ([]string, error) {
+	return jsonMGet(ctx, that.Client, path, keys)
+}
+
+// JsonToggle 切换 path 指向的布尔值, 返回新的值(1 为 true, 0 为 false), 不是布尔值时为 -1
+func (that *KRedisCluster) JsonToggle(key string, path string) ([]int64, error) {
+	return that.JsonToggleWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonToggleWithCtx 同 JsonToggle, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonToggleWithCtx(ctx context.Context, key string, path string) ([]int64, error) {
+	return jsonIntReply(that.Client.Do(ctx, "JSON.TOGGLE", key, jsonRoot(path)))
+}
+
+// JsonClear 将 path 指向的数组与对象清空, 数值置为 0, 返回被清空的值的数量
+func (that *KRedisCluster) JsonClear(key string, path string) (int64, error) {
+	return that.JsonClearWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonClearWithCtx 同 JsonClear, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) JsonClearWithCtx(ctx context.Context, key string, path string) (int64, error) {
+	return that.Client.Do(ctx, "JSON.CLEAR", key, jsonRoot(path)).Int64()
+}
@@ -0,0 +1,398 @@
+package kredis
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// KeyspaceEventType 键空间事件类型, 即 `__keyevent@<db>__:<event>` 中的 event, 也可以使用未列出的事件名
+type KeyspaceEventType string
+
+const (
+	KeyspaceExpired    KeyspaceEventType = "expired" // key 过期被删除
+	KeyspaceEvicted    KeyspaceEventType = "evicted" // key 因 maxmemory 被淘汰
+	KeyspaceNew        KeyspaceEventType = "new"     // 新建 key, 需要 Redis 7.0+
+	KeyspaceSet        KeyspaceEventType = "set"
+	KeyspaceDel        KeyspaceEventType = "del"
+	KeyspaceExpire     KeyspaceEventType = "expire" // 设置过期时间
+	KeyspaceIncrBy     KeyspaceEventType = "incrby"
+	KeyspaceHSet       KeyspaceEventType = "hset"
+	KeyspaceHDel       KeyspaceEventType = "hdel"
+	KeyspaceLPush      KeyspaceEventType = "lpush"
+	KeyspaceRPush      KeyspaceEventType = "rpush"
+	KeyspaceSAdd       KeyspaceEventType = "sadd"
+	KeyspaceZAdd       KeyspaceEventType = "zadd"
+	KeyspaceZIncr      KeyspaceEventType = "zincr"
+	KeyspaceZRem       KeyspaceEventType = "zrem"
+	KeyspaceZPopMin    KeyspaceEventType = "zpopmin"
+	KeyspaceZPopMax    KeyspaceEventType = "zpopmax"
+	KeyspaceXAdd       KeyspaceEventType = "xadd"
+	KeyspaceRename     KeyspaceEventType = "rename_to"   // RENAME 的目标 key
+	KeyspaceRenameFrom KeyspaceEventType = "rename_from" // RENAME 的源 key
+)
+
+// 事件所属的 notify-keyspace-events 类别
+var keyspaceEventClasses = map[KeyspaceEventType]byte{
+	KeyspaceExpired:    'x',
+	KeyspaceEvicted:    'e',
+	KeyspaceNew:        'n',
+	KeyspaceSet:        '$',
+	KeyspaceIncrBy:     '$',
+	KeyspaceDel:        'g',
+	KeyspaceExpire:     'g',
+	KeyspaceHSet:       'h',
+	KeyspaceHDel:       'h',
+	KeyspaceLPush:      'l',
+	KeyspaceRPush:      'l',
+	KeyspaceSAdd:       's',
+	KeyspaceZAdd:       'z',
+	KeyspaceZIncr:      'z',
+	KeyspaceZRem:       'z',
+	KeyspaceZPopMin:    'z',
+	KeyspaceZPopMax:    'z',
+	KeyspaceXAdd:       't',
+	KeyspaceRename:     'g',
+	KeyspaceRenameFrom: 'g',
+
+	"zremrangebyscore": 'z',
+	"zremrangebyrank":  'z',
+	"zremrangebylex":   'z',
+	"zunionstore":      'z',
+	"zinterstore":      'z',
+	"zdiffstore":       'z',
+	"zrangestore":      'z',
+	"restore":          'g',
+	"move_from":        'g',
+	"move_to":          'g',
+	"copy_to":          'g',
+}
+
+// notify-keyspace-events 中 A 代表的类别
+const keyspaceAllClasses = "g$lshzxetd"
+
+// KeyspaceEvent 键空间事件
+type KeyspaceEvent struct {
+	Type KeyspaceEventType
+	Key  string
+	DB   int    // 数据库编号, 集群模式下总是 0
+	Node string // 产生事件的节点地址
+}
+
+// KeyspaceHandler 事件处理函数, 同一节点的事件按顺序调用, 集群模式下不同节点的事件可能并发调用
+type KeyspaceHandler func(event *KeyspaceEvent)
+
+// KeyspaceOptions 键空间事件监听参数, 时间单位均为毫秒
+type KeyspaceOptions struct {
+	Events          []KeyspaceEventType `json:"events"`          // 监听的事件类型, 默认只监听 expired
+	DB              int                 `json:"db"`              // 监听的数据库编号, < 0 时监听所有数据库, 默认 -1
+	IncludeKeys     []string            `json:"includeKeys"`     // 只保留匹配的 key, 为空时不过滤, 匹配规则同 MatchFilter
+	IgnoreKeys      []string            `json:"ignoreKeys"`      // 忽略匹配的 key, 匹配规则同 MatchFilter
+	ConfigureServer bool                `json:"configureServer"` // 启动与重连时通过 CONFIG SET 开启所需的 notify-keyspace-events 标志, 保留已有标志, 默认 true
+	RefreshInterval int64               `json:"refreshInterval"` // 集群模式下检查主节点变化的间隔, 默认30000
+
+	Subscription *SubscriptionOptions `json:"subscription"` // 每个节点订阅的队列与重连参数
+	OnError      func(err error)      `json:"-"`            // 开启通知或获取节点失败时的回调, 可用于记录日志
+}
+
+func NewKeyspaceOptions(events ...KeyspaceEventType) *KeyspaceOptions {
+	if len(events) == 0 {
+		events = []KeyspaceEventType{KeyspaceExpired}
+	}
+	return &KeyspaceOptions{
+		Events:          events,
+		DB:              -1,
+		ConfigureServer: true,
+		RefreshInterval: 30000,
+		Subscription:    NewSubscriptionOptions(),
+	}
+}
+
+func (that *KeyspaceOptions) SetDB(db int) *KeyspaceOptions {
+	that.DB = db
+	return that
+}
+
+// 设置 key 过滤规则, 匹配规则同 MatchFilter
+func (that *KeyspaceOptions) SetKeyFilter(includeKeys []string, ignoreKeys []string) *KeyspaceOptions {
+	that.IncludeKeys = includeKeys
+	that.IgnoreKeys = ignoreKeys
+	return that
+}
+
+// 设置是否自动开启 notify-keyspace-events, 托管的 Redis 禁用 CONFIG 命令时需要关闭并在控制台中配置
+func (that *KeyspaceOptions) SetConfigureServer(configure bool) *KeyspaceOptions {
+	that.ConfigureServer = configure
+	return that
+}
+
+func (that *KeyspaceOptions) SetSubscriptionOptions(opts *SubscriptionOptions) *KeyspaceOptions {
+	that.Subscription = opts
+	return that
+}
+
+func (that *KeyspaceOptions) SetErrorHandler(fn func(err error)) *KeyspaceOptions {
+	that.OnError = fn
+	return that
+}
+
+// KeyspaceNotifyFlags 返回接收 events 所需的 notify-keyspace-events 标志, 如 expired 对应 "Ex"
+func KeyspaceNotifyFlags(events ...KeyspaceEventType) string {
+	classes := make(map[byte]struct{})
+	for _, event := range events {
+		if class, ok := keyspaceEventClasses[event]; ok {
+			classes[class] = struct{}{}
+		} else {
+			classes['A'] = struct{}{}
+		}
+	}
+	flags := make([]byte, 0, len(classes))
+	for class := range classes {
+		flags = append(flags, class)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+	return "E" + string(flags)
+}
+
+// mergeNotifyFlags 在 current 的基础上补充 required 中缺少的标志
+func mergeNotifyFlags(current string, required string) string {
+	merged := current
+	for _, flag := range required {
+		if strings.ContainsRune(merged, flag) || (strings.ContainsRune(merged, 'A') && strings.ContainsRune(keyspaceAllClasses, flag)) {
+			continue
+		}
+		merged += string(flag)
+	}
+	return merged
+}
+
+// KeyspaceListener 键空间事件监听, 订阅 `__keyevent@<db>__:<event>` 频道并转换为 KeyspaceEvent.
+// 键空间通知只在产生事件的节点上发布, 集群模式下订阅所有主节点, 并定期检查主节点的变化.
+// 通知基于发布订阅, 断线期间的事件会丢失, 不应作为唯一的数据来源
+type KeyspaceListener struct {
+	client  KRedisClient
+	opts    *KeyspaceOptions
+	handler KeyspaceHandler
+
+	ctx       *kcontext.ContextNode
+	mu        sync.Mutex
+	nodes     map[string]*Subscription
+	wg        sync.WaitGroup
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewKeyspaceListener 创建监听, 调用 Start 后开始接收; 监听的生命周期绑定到 ctx 下新建的子节点上.
+// opts 为空时使用默认参数, opts 会被复制, 之后修改 opts 不影响已创建的监听
+func NewKeyspaceListener(ctx *kcontext.ContextNode, client KRedisClient, opts *KeyspaceOptions, handler KeyspaceHandler) *KeyspaceListener {
+	options := *NewKeyspaceOptions()
+	if opts != nil {
+		options = *opts
+	}
+	if options.Subscription == nil {
+		options.Subscription = NewSubscriptionOptions()
+	}
+	return &KeyspaceListener{
+		client:  client,
+		opts:    &options,
+		handler: handler,
+		ctx:     ctx.NewChild("kredis_keyspace"),
+		nodes:   make(map[string]*Subscription),
+	}
+}
+
+// Start 开启通知并订阅所有节点, 获取集群节点失败时返回错误; 重复调用无效
+func (that *KeyspaceListener) Start() error {
+	var err error
+	that.startOnce.Do(func() {
+		err = that.refresh()
+		if _, ok := that.client.UniversalClient().(*redisHd.ClusterClient); ok {
+			that.wg.Add(1)
+			go func() {
+				defer that.wg.Done()
+				that.refreshLoop()
+			}()
+		}
+	})
+	return err
+}
+
+// Stop 取消所有订阅, 等待已接收的事件处理完成后返回
+func (that *KeyspaceListener) Stop() {
+	that.stopOnce.Do(func() {
+		that.ctx.Cancel()
+		that.wg.Wait()
+
+		that.mu.Lock()
+		nodes := that.nodes
+		that.nodes = make(map[string]*Subscription)
+		that.mu.Unlock()
+		for _, sub := range nodes {
+			sub.Stop()
+		}
+		that.ctx.Remove()
+	})
+}
+
+// Nodes 返回已订阅的节点地址
+func (that *KeyspaceListener) Nodes() []string {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	nodes := make([]string, 0, len(that.nodes))
+	for addr := range that.nodes {
+		nodes = append(nodes, addr)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func (that *KeyspaceListener) refreshLoop() {
+	ctx := that.ctx.Context()
+	interval := millisecond(max(that.opts.RefreshInterval, 1))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if err := that.refresh(); err != nil && ctx.Err() == nil {
+			that.onError(err)
+		}
+	}
+}
+
+// refresh 订阅新增的主节点, 取消已移除的主节点
+func (that *KeyspaceListener) refresh() error {
+	ctx := that.ctx.Context()
+	masters, err := masterNodes(ctx, that.client.UniversalClient())
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]redisHd.UniversalClient, len(masters))
+	for _, node := range masters {
+		current[nodeAddr(node)] = node
+	}
+
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if ctx.Err() != nil {
+		return nil
+	}
+	for addr, sub := range that.nodes {
+		if _, ok := current[addr]; !ok {
+			sub.Stop()
+			delete(that.nodes, addr)
+		}
+	}
+	for addr, node := range current {
+		if _, ok := that.nodes[addr]; !ok {
+			that.nodes[addr] = that.subscribe(addr, node)
+		}
+	}
+	return nil
+}
+
+// subscribe 开启节点的通知并订阅事件频道, 重连后重新开启通知, 以应对节点重启导致配置丢失
+func (that *KeyspaceListener) subscribe(addr string, node redisHd.UniversalClient) *Subscription {
+	that.configure(node)
+
+	subOpts := *that.opts.Subscription
+	onEvent := subOpts.OnEvent
+	subOpts.OnEvent = func(event SubscriptionEvent) {
+		if event.Kind == SubscriptionResubscribed {
+			that.configure(node)
+		}
+		if onEvent != nil {
+			onEvent(event)
+		}
+	}
+
+	sub := newSubscription(that.ctx.NewChild("kredis_subscription:"+addr), node, &subOpts, func(msg *SubscriptionMessage) {
+		if event := that.parse(addr, msg); event != nil {
+			that.handler(event)
+		}
+	})
+	db := "*"
+	if that.opts.DB >= 0 {
+		db = strconv.Itoa(that.opts.DB)
+	}
+	patterns := make([]string, 0, len(that.opts.Events))
+	for _, event := range that.opts.Events {
+		patterns = append(patterns, "__keyevent@"+db+"__:"+string(event))
+	}
+	if err := sub.PSubscribe(patterns...); err != nil { // 连接不可用时在重连后订阅
+		that.onError(err)
+	}
+	sub.Start()
+	return sub
+}
+
+// configure 在节点上开启所需的 notify-keyspace-events 标志
+func (that *KeyspaceListener) configure(node redisHd.UniversalClient) {
+	if !that.opts.ConfigureServer {
+		return
+	}
+	ctx, cancel := context.WithTimeout(that.ctx.Context(), 5*time.Second)
+	defer cancel()
+
+	values, err := node.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		that.onError(err)
+		return
+	}
+	current := values["notify-keyspace-events"]
+	merged := mergeNotifyFlags(current, KeyspaceNotifyFlags(that.opts.Events...))
+	if merged == current {
+		return
+	}
+	if err := node.ConfigSet(ctx, "notify-keyspace-events", merged).Err(); err != nil {
+		that.onError(err)
+	}
+}
+
+// parse 解析 `__keyevent@<db>__:<event>` 频道的消息, 不满足过滤规则时返回 nil
+func (that *KeyspaceListener) parse(addr string, msg *SubscriptionMessage) *KeyspaceEvent {
+	rest, ok := strings.CutPrefix(msg.Channel, "__keyevent@")
+	if !ok {
+		return nil
+	}
+	dbText, eventType, ok := strings.Cut(rest, "__:")
+	if !ok {
+		return nil
+	}
+	db, err := strconv.Atoi(dbText)
+	if err != nil {
+		return nil
+	}
+
+	key := msg.Payload
+	if len(that.opts.IncludeKeys) > 0 && !MatchFilter(that.opts.IncludeKeys, key) {
+		return nil
+	}
+	if MatchFilter(that.opts.IgnoreKeys, key) {
+		return nil
+	}
+	return &KeyspaceEvent{Type: KeyspaceEventType(eventType), Key: key, DB: db, Node: addr}
+}
+
+func (that *KeyspaceListener) onError(err error) {
+	if that.opts.OnError != nil {
+		that.opts.OnError(err)
+	}
+}
+
+// nodeAddr 返回节点地址, 用于区分集群中的主节点; 哨兵模式下主从切换由订阅的重连处理
+func nodeAddr(node redisHd.UniversalClient) string {
+	if client, ok := node.(*redisHd.Client); ok {
+		return client.Options().Addr
+	}
+	return ""
+}
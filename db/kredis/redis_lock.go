@@ -0,0 +1,492 @@
+package kredis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrLockNotObtained = errors.New("redis lock not obtained")
+	ErrLockNotHeld     = errors.New("redis lock not held")
+)
+
+var (
+	// KEYS[1] 锁, KEYS[2] 隔离令牌计数器; ARGV[1] 锁持有者标识, ARGV[2] 有效期(毫秒)
+	lockAcquireScript = redisHd.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+	// 仅当锁仍由自己持有时删除
+	lockReleaseScript = redisHd.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+	// 仅当锁仍由自己持有时续期
+	lockRenewScript = redisHd.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+)
+
+// LockOptions 分布式锁参数, 时间单位均为毫秒
+type LockOptions struct {
+	TTL           int64   `json:"ttl"`           // 锁的有效期, 默认30000
+	RenewInterval int64   `json:"renewInterval"` // 自动续期周期, 0 表示 TTL/3, 小于0表示不自动续期
+	RetryInterval int64   `json:"retryInterval"` // 阻塞获取锁时的重试间隔, 实际间隔带有随机抖动, 默认100
+	DriftFactor   float64 `json:"driftFactor"`   // Redlock 时钟漂移系数, 有效期扣除 TTL*DriftFactor+2ms, 默认0.01
+}
+
+func NewLockOptions() *LockOptions {
+	return &LockOptions{
+		TTL:           30000,
+		RenewInterval: 0,
+		RetryInterval: 100,
+		DriftFactor:   0.01,
+	}
+}
+
+// 设置锁的有效期, 单位 毫秒
+func (that *LockOptions) SetTTL(ttl int64) *LockOptions {
+	that.TTL = ttl
+	return that
+}
+
+// 设置自动续期周期, 单位 毫秒; 0 表示 TTL/3, 小于0表示不自动续期
+func (that *LockOptions) SetRenewInterval(interval int64) *LockOptions {
+	that.RenewInterval = interval
+	return that
+}
+
+// 设置阻塞获取锁时的重试间隔, 单位 毫秒
+func (that *LockOptions) SetRetryInterval(interval int64) *LockOptions {
+	that.RetryInterval = interval
+	return that
+}
+
+func (that *LockOptions) SetDriftFactor(factor float64) *LockOptions {
+	that.DriftFactor = factor
+	return that
+}
+
+func (that *LockOptions) ttl() time.Duration {
+	if that.TTL <= 0 {
+		return 30 * time.Second
+	}
+	return millisecond(that.TTL)
+}
+
+func (that *LockOptions) renewInterval() time.Duration {
+	if that.RenewInterval < 0 {
+		return 0
+	}
+	if that.RenewInterval == 0 {
+		return that.ttl() / 3
+	}
+	return millisecond(that.RenewInterval)
+}
+
+func (that *LockOptions) retryInterval() time.Duration {
+	if that.RetryInterval <= 0 {
+		return 100 * time.Millisecond
+	}
+	return millisecond(that.RetryInterval)
+}
+
+///////////////////////////////////////////////////////////////
+
+// Locker 分布式锁, 单个实例时为普通的 SET NX 锁, 多个独立实例时为 Redlock, 需要多数实例加锁成功
+type Locker struct {
+	clients []KRedisClient
+	quorum  int
+	opts    *LockOptions
+}
+
+// NewLocker 创建基于单个 Redis(单机, 哨兵或集群)的分布式锁, opts 为 nil 时使用默认参数
+func NewLocker(client KRedisClient, opts *LockOptions) *Locker {
+	return NewRedLocker([]KRedisClient{client}, opts)
+}
+
+// NewRedLocker 创建基于多个相互独立的 Redis 实例的 Redlock, 超过半数的实例加锁成功才算获取到锁.
+// 隔离令牌取加锁成功的实例中的最大值, 只要多数实例的计数器没有丢失即保持单调递增
+func NewRedLocker(clients []KRedisClient, opts *LockOptions) *Locker {
+	if opts == nil {
+		opts = NewLockOptions()
+	}
+	return &Locker{clients: clients, quorum: len(clients)/2 + 1, opts: opts}
+}
+
+// TryLock 尝试获取锁, 锁已被其他持有者占用时立即返回 ErrLockNotObtained.
+// 获取成功后锁的生命周期绑定到 parent 下新建的子节点上, 参见 Lock.Context
+func (that *Locker) TryLock(parent *kcontext.ContextNode, key string) (*Lock, error) {
+	value, err := randomLockValue()
+	if err != nil {
+		return nil, err
+	}
+	return that.tryLock(parent, key, value)
+}
+
+// Lock 阻塞获取锁, 直到成功, 超过 timeout 或 parent 被取消. timeout <= 0 表示一直等待.
+// 访问 Redis 出错时同样在等待期限内重试, 超时后返回的错误包含 ErrLockNotObtained 与最后一次的错误
+func (that *Locker) Lock(parent *kcontext.ContextNode, key string, timeout time.Duration) (*Lock, error) {
+	value, err := randomLockValue()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := parent.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	retry := that.opts.retryInterval()
+	var lastErr error
+	for {
+		lock, err := that.tryLock(parent, key, value)
+		if err == nil {
+			return lock, nil
+		}
+		if !errors.Is(err, ErrLockNotObtained) {
+			lastErr = err
+		}
+
+		// 随机抖动避免多个等待者同时重试
+		wait := retry/2 + time.Duration(mrand.Int63n(int64(retry)))
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil, errors.Join(ErrLockNotObtained, lastErr)
+			}
+			return nil, ErrLockNotObtained
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (that *Locker) tryLock(parent *kcontext.ContextNode, key string, value string) (*Lock, error) {
+	ttl := that.opts.ttl()
+	begin := time.Now()
+
+	token, acquired, err := that.acquire(parent.Context(), key, value, ttl)
+	// Redlock 需要扣除加锁耗时与时钟漂移后仍有剩余的有效期
+	validUntil := begin.Add(that.validity(ttl))
+	if acquired < that.quorum || !time.Now().Before(validUntil) {
+		that.release(key, value)
+		if err != nil && acquired == 0 {
+			return nil, err
+		}
+		return nil, ErrLockNotObtained
+	}
+
+	lock := &Lock{
+		locker:     that,
+		key:        key,
+		value:      value,
+		token:      token,
+		node:       parent.NewChild("kredis_lock:" + key),
+		validUntil: validUntil,
+	}
+	lock.expiry = time.AfterFunc(time.Until(validUntil), lock.expire)
+	if interval := that.opts.renewInterval(); interval > 0 {
+		go lock.renewLoop(interval, ttl)
+	}
+	return lock, nil
+}
+
+// validity 从发出加锁或续期请求的时刻起, 锁可以被认为仍然有效的时长, 扣除了时钟漂移
+func (that *Locker) validity(ttl time.Duration) time.Duration {
+	drift := time.Duration(float64(ttl)*that.opts.DriftFactor) + 2*time.Millisecond
+	return ttl - drift
+}
+
+// acquire 在所有实例上加锁, 返回最大的隔离令牌与加锁成功的实例数量; 只有所有实例都访问失败时才返回错误,
+// 部分实例回复锁已被占用时, 调用方应返回 ErrLockNotObtained 而不是其他实例的连接错误
+func (that *Locker) acquire(ctx context.Context, key string, value string, ttl time.Duration) (int64, int, error) {
+	fenceKey := sameSlotKey(key, "fence")
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		token    int64
+		acquired int
+		replied  int
+		lastErr  error
+	)
+	for _, client := range that.clients {
+		wg.Add(1)
+		go func(client KRedisClient) {
+			defer wg.Done()
+
+			callCtx := ctx
+			if len(that.clients) > 1 { // Redlock 中单个实例不可用时不应阻塞太久
+				var cancel context.CancelFunc
+				callCtx, cancel = context.WithTimeout(ctx, max(ttl/10, 50*time.Millisecond))
+				defer cancel()
+			}
+
+			val, err := lockAcquireScript.Run(callCtx, client.UniversalClient(), []string{key, fenceKey}, value, ttl.Milliseconds()).Int64()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			replied++
+			if val > 0 {
+				acquired++
+				token = max(token, val)
+			}
+		}(client)
+	}
+	wg.Wait()
+	if replied > 0 {
+		return token, acquired, nil
+	}
+	return token, acquired, lastErr
+}
+
+// release 在所有实例上释放锁, 返回释放成功的实例数量
+func (that *Locker) release(key string, value string) int {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		released int
+	)
+	for _, client := range that.clients {
+		wg.Add(1)
+		go func(client KRedisClient) {
+			defer wg.Done()
+			// 持有锁的节点可能已被取消, 释放使用独立的超时
+			ctx, cancel := context.WithTimeout(context.Background(), that.opts.ttl())
+			defer cancel()
+			if val, err := lockReleaseScript.Run(ctx, client.UniversalClient(), []string{key}, value).Int64(); err == nil && val > 0 {
+				mu.Lock()
+				released++
+				mu.Unlock()
+			}
+		}(client)
+	}
+	wg.Wait()
+	return released
+}
+
+// renew 在所有实例上续期, 返回续期成功的实例数量
+func (that *Locker) renew(ctx context.Context, key string, value string, ttl time.Duration) (int, error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		renewed int
+		lastErr error
+	)
+	for _, client := range that.clients {
+		wg.Add(1)
+		go func(client KRedisClient) {
+			defer wg.Done()
+			val, err := lockRenewScript.Run(ctx, client.UniversalClient(), []string{key}, value, ttl.Milliseconds()).Int64()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+			} else if val > 0 {
+				renewed++
+			}
+		}(client)
+	}
+	wg.Wait()
+	if renewed > 0 {
+		lastErr = nil
+	}
+	return renewed, lastErr
+}
+
+///////////////////////////////////////////////////////////////
+
+// Lock 已获取的锁.
+// 锁丢失(续期失败, 被其他持有者覆盖)或释放后, Context 返回的节点会被取消, 依赖该锁的任务应监听该节点退出.
+// 有效期按发出请求的时刻扣除时钟漂移计算, 到期前未能续期时节点在到期时刻立即取消, 早于服务端删除锁的时刻
+type Lock struct {
+	locker *Locker
+	key    string
+	value  string
+	token  int64
+	node   *kcontext.ContextNode
+
+	mu         sync.Mutex
+	released   bool
+	lost       bool
+	validUntil time.Time   // 最近一次加锁或续期成功后的有效期
+	expiry     *time.Timer // 在 validUntil 时刻将锁标记为丢失
+}
+
+// Key 返回锁的键名
+func (that *Lock) Key() string {
+	return that.key
+}
+
+// Token 返回隔离令牌(fencing token), 每次获取锁时单调递增.
+// 写入受保护的资源时应携带该令牌, 资源端拒绝比已见过的令牌更小的写入, 防止锁过期后的旧持有者继续写入
+func (that *Lock) Token() int64 {
+	return that.token
+}
+
+// Context 返回与锁生命周期绑定的上下文节点
+func (that *Lock) Context() *kcontext.ContextNode {
+	return that.node
+}
+
+// Lost 锁是否已经丢失
+func (that *Lock) Lost() bool {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return that.lost
+}
+
+// Refresh 立即续期, 锁已丢失时返回 ErrLockNotHeld
+func (that *Lock) Refresh() error {
+	ttl := that.locker.opts.ttl()
+	ctx, cancel := context.WithTimeout(that.node.Context(), ttl)
+	defer cancel()
+
+	begin := time.Now()
+	renewed, err := that.locker.renew(ctx, that.key, that.value, ttl)
+	if err != nil {
+		return err
+	}
+	if renewed < that.locker.quorum {
+		that.markLost()
+		return ErrLockNotHeld
+	}
+	if !that.extend(begin.Add(that.locker.validity(ttl))) {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Unlock 释放锁并取消 Context 返回的节点, 可以重复调用; 锁在释放前已经丢失时返回 ErrLockNotHeld
+func (that *Lock) Unlock() error {
+	that.mu.Lock()
+	if that.released {
+		that.mu.Unlock()
+		return nil
+	}
+	that.released = true
+	lost := that.lost
+	that.expiry.Stop()
+	that.mu.Unlock()
+
+	that.node.Remove()
+	released := that.locker.release(that.key, that.value)
+	if lost || released < that.locker.quorum {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (that *Lock) markLost() {
+	that.mu.Lock()
+	that.lost = true
+	that.expiry.Stop()
+	that.mu.Unlock()
+	that.node.Cancel()
+}
+
+// extend 续期成功后延长有效期, 锁已经丢失或释放时返回 false
+func (that *Lock) extend(validUntil time.Time) bool {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if that.lost || that.released {
+		return false
+	}
+	if validUntil.After(that.validUntil) {
+		that.validUntil = validUntil
+		that.expiry.Reset(time.Until(validUntil))
+	}
+	return true
+}
+
+// expire 有效期到期时由 expiry 调用; 与续期并发时以最新的有效期为准
+func (that *Lock) expire() {
+	that.mu.Lock()
+	if that.lost || that.released || time.Now().Before(that.validUntil) {
+		that.mu.Unlock()
+		return
+	}
+	that.lost = true
+	that.mu.Unlock()
+	that.node.Cancel()
+}
+
+// renewLoop 周期续期, 锁被其他持有者占用时视为丢失; 网络错误时继续重试, 有效期到期由 expiry 取消节点
+func (that *Lock) renewLoop(interval time.Duration, ttl time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := that.node.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		begin := time.Now()
+		callCtx, cancel := context.WithTimeout(ctx, interval)
+		renewed, err := that.locker.renew(callCtx, that.key, that.value, ttl)
+		cancel()
+
+		if err == nil && renewed >= that.locker.quorum {
+			if !that.extend(begin.Add(that.locker.validity(ttl))) {
+				return
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			that.markLost()
+			return
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////
+
+// randomLockValue 生成锁持有者标识
+func randomLockValue() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// sameSlotKey 返回与 key 位于同一集群槽位的辅助键名.
+// key 已包含有效的 hash tag 时直接追加后缀, 否则将 key 整体作为 hash tag
+func sameSlotKey(key string, suffix string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key + ":" + suffix
+		}
+	}
+	return "{" + key + "}:" + suffix
+}
@@ -0,0 +1,523 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khan-lau/kutils/klogger"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// 默认的耗时直方图上界, 单位 毫秒
+var defaultLatencyBuckets = []int64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// 加载 COMMAND 命令信息失败后的重试间隔
+const commandInfoRetry = time.Minute
+
+// Redis 7 的 COMMAND 只返回容器命令本身, 子命令的 key 位置被 go-redis 丢弃, 这里补充带 key 的子命令
+var subcommandKeyPos = map[string]int{"object": 2, "memory": 2, "xinfo": 2, "xgroup": 2}
+
+// CommandEvent 一次命令或 pipeline 执行完成的事件, 可用于接入链路追踪
+type CommandEvent struct {
+	Name     string        // 命令名, 小写; pipeline 为 "pipeline", 事务为 "multi"
+	Args     []string      // 命令参数, 按 MaxArgs 截断, 开启 RedactKeys 时 key 被替换为摘要
+	Cmds     int           // pipeline 中的命令数量, 单个命令为 1
+	Duration time.Duration // 耗时
+	Err      error         // 执行失败的原因, redis.Nil 不视为失败
+}
+
+// MetricsOptions 命令统计参数, 时间单位均为毫秒
+type MetricsOptions struct {
+	SlowThreshold int64   `json:"slowThreshold"` // 慢命令阈值, 耗时不小于该值的命令写入日志, <= 0 时不记录, 默认100
+	RedactKeys    bool    `json:"redactKeys"`    // 日志与事件中的 key 替换为 "key#<crc32>", 相同的 key 摘要相同, key 的位置来自 COMMAND, 无法确定时替换全部参数, 默认 false
+	MaxArgs       int     `json:"maxArgs"`       // 日志与事件中保留的参数数量(不含命令名), 默认8
+	MaxArgLen     int     `json:"maxArgLen"`     // 单个参数保留的长度, 默认64
+	Buckets       []int64 `json:"buckets"`       // 耗时直方图的上界, 从小到大, 默认 1, 2, 5, ..., 5000
+
+	Logger    *klogger.Logger                                        `json:"-"` // 慢命令日志, 为空时不记录
+	OnStart   func(ctx context.Context, name string) context.Context `json:"-"` // 每个命令或 pipeline 执行前的回调, 返回的 ctx 用于执行命令并传给 OnCommand, 可用于开始链路追踪的 span
+	OnCommand func(ctx context.Context, event *CommandEvent)         `json:"-"` // 每个命令或 pipeline 完成后的回调, 在调用方 goroutine 中执行, 不应阻塞
+}
+
+func NewMetricsOptions() *MetricsOptions {
+	return &MetricsOptions{
+		SlowThreshold: 100,
+		MaxArgs:       8,
+		MaxArgLen:     64,
+		Buckets:       defaultLatencyBuckets,
+	}
+}
+
+// 设置慢命令阈值, 单位 毫秒
+func (that *MetricsOptions) SetSlowLog(threshold int64, logger *klogger.Logger) *MetricsOptions {
+	that.SlowThreshold = threshold
+	that.Logger = logger
+	return that
+}
+
+func (that *MetricsOptions) SetRedactKeys(redact bool) *MetricsOptions {
+	that.RedactKeys = redact
+	return that
+}
+
+// 设置日志中保留的参数数量与单个参数的长度
+func (that *MetricsOptions) SetArgsLimit(maxArgs int, maxArgLen int) *MetricsOptions {
+	that.MaxArgs = maxArgs
+	that.MaxArgLen = maxArgLen
+	return that
+}
+
+// 设置耗时直方图的上界, 单位 毫秒
+func (that *MetricsOptions) SetBuckets(buckets ...int64) *MetricsOptions {
+	that.Buckets = buckets
+	return that
+}
+
+// 设置命令执行前的回调, 例如:
+//
+//	SetStartHandler(func(ctx context.Context, name string) context.Context {
+//		ctx, _ = tracer.Start(ctx, "redis "+name)
+//		return ctx
+//	})
+func (that *MetricsOptions) SetStartHandler(fn func(ctx context.Context, name string) context.Context) *MetricsOptions {
+	that.OnStart = fn
+	return that
+}
+
+func (that *MetricsOptions) SetCommandHandler(fn func(ctx context.Context, event *CommandEvent)) *MetricsOptions {
+	that.OnCommand = fn
+	return that
+}
+
+// CommandStats 单个命令的统计
+type CommandStats struct {
+	Name    string        `json:"name"`
+	Calls   uint64        `json:"calls"`   // 调用次数
+	Errors  uint64        `json:"errors"`  // 失败次数, 不含 redis.Nil
+	Slow    uint64        `json:"slow"`    // 慢命令次数
+	Total   time.Duration `json:"total"`   // 总耗时
+	Max     time.Duration `json:"max"`     // 最大耗时
+	Buckets []uint64      `json:"buckets"` // 与 Bounds 一一对应的累计计数, 即耗时不大于上界的调用次数
+	Bounds  []int64       `json:"bounds"`  // 直方图上界, 单位 毫秒
+}
+
+// Mean 返回平均耗时
+func (that CommandStats) Mean() time.Duration {
+	if that.Calls == 0 {
+		return 0
+	}
+	return that.Total / time.Duration(that.Calls)
+}
+
+// commandStat 单个命令的计数器, 均为原子操作
+type commandStat struct {
+	calls   atomic.Uint64
+	errors  atomic.Uint64
+	slow    atomic.Uint64
+	total   atomic.Int64
+	max     atomic.Int64
+	buckets []atomic.Uint64 // 非累计计数, 最后一个为超过所有上界的调用
+}
+
+// CommandMetrics 命令统计钩子, 记录每个命令的调用次数, 失败次数与耗时直方图, 并将慢命令写入日志.
+// 通过 RedisOptions.AddHook 注册到单机, 哨兵与集群客户端; pipeline 与事务作为一个整体统计.
+//
+// 示例:
+//
+//	metrics := kredis.NewCommandMetrics(kredis.NewMetricsOptions().SetSlowLog(50, logger).SetRedactKeys(true))
+//	client, err := kredis.NewKRedisClient(ctx, kredis.NewRedisOptions(addr).AddHook(metrics))
+//	...
+//	metrics.WritePrometheus(w, "kredis")
+type CommandMetrics struct {
+	opts   *MetricsOptions
+	bounds []time.Duration
+
+	mu    sync.RWMutex
+	stats map[string]*commandStat
+
+	infoMu    sync.Mutex
+	info      atomic.Pointer[map[string]*redisHd.CommandInfo] // COMMAND 返回的命令信息, 用于确定 key 的位置
+	infoRetry time.Time                                       // 加载失败后下次重试的时间
+}
+
+// NewCommandMetrics 创建命令统计钩子, opts 为空时使用默认参数, opts 会被复制, 之后修改 opts 不影响已创建的钩子
+func NewCommandMetrics(opts *MetricsOptions) *CommandMetrics {
+	options := *NewMetricsOptions()
+	if opts != nil {
+		options = *opts
+	}
+	buckets := options.Buckets
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets
+	}
+	buckets = append([]int64(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	options.Buckets = buckets
+
+	bounds := make([]time.Duration, 0, len(buckets))
+	for _, bucket := range buckets {
+		bounds = append(bounds, millisecond(bucket))
+	}
+	return &CommandMetrics{opts: &options, bounds: bounds, stats: make(map[string]*commandStat)}
+}
+
+// DialHook 建立连接的耗时与失败次数记为 "dial"
+func (that *CommandMetrics) DialHook(next redisHd.DialHook) redisHd.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := next(ctx, network, addr)
+		that.record("dial", time.Since(start), err)
+		return conn, err
+	}
+}
+
+func (that *CommandMetrics) ProcessHook(next redisHd.ProcessHook) redisHd.ProcessHook {
+	return func(ctx context.Context, cmd redisHd.Cmder) error {
+		name := cmd.Name()
+		if that.opts.OnStart != nil {
+			ctx = that.opts.OnStart(ctx, name)
+		}
+		start := time.Now()
+		err := next(ctx, cmd)
+		elapsed := time.Since(start)
+
+		slow := that.record(name, elapsed, err)
+		that.report(ctx, slow, &CommandEvent{Name: name, Cmds: 1, Duration: elapsed, Err: commandErr(err)}, cmd, next)
+		return err
+	}
+}
+
+func (that *CommandMetrics) ProcessPipelineHook(next redisHd.ProcessPipelineHook) redisHd.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redisHd.Cmder) error {
+		name := "pipeline"
+		if len(cmds) > 0 && cmds[0].Name() == "multi" {
+			name = "multi"
+		}
+		if that.opts.OnStart != nil {
+			ctx = that.opts.OnStart(ctx, name)
+		}
+		start := time.Now()
+		err := next(ctx, cmds)
+		elapsed := time.Since(start)
+
+		// 只要有一个命令失败, pipeline 即记为失败
+		failure := commandErr(err)
+		for _, cmd := range cmds {
+			if failure != nil {
+				break
+			}
+			failure = commandErr(cmd.Err())
+		}
+		slow := that.record(name, elapsed, failure)
+		var first redisHd.Cmder
+		if len(cmds) > 0 {
+			first = cmds[0]
+		}
+		that.report(ctx, slow, &CommandEvent{Name: name, Cmds: len(cmds), Duration: elapsed, Err: failure}, first, nil)
+		return err
+	}
+}
+
+// Snapshot 返回所有命令的统计, 按命令名排序
+func (that *CommandMetrics) Snapshot() []CommandStats {
+	that.mu.RLock()
+	names := make([]string, 0, len(that.stats))
+	stats := make(map[string]*commandStat, len(that.stats))
+	for name, stat := range that.stats {
+		names = append(names, name)
+		stats[name] = stat
+	}
+	that.mu.RUnlock()
+	sort.Strings(names)
+
+	result := make([]CommandStats, 0, len(names))
+	for _, name := range names {
+		stat := stats[name]
+		item := CommandStats{
+			Name:    name,
+			Calls:   stat.calls.Load(),
+			Errors:  stat.errors.Load(),
+			Slow:    stat.slow.Load(),
+			Total:   time.Duration(stat.total.Load()),
+			Max:     time.Duration(stat.max.Load()),
+			Buckets: make([]uint64, len(that.bounds)),
+			Bounds:  that.opts.Buckets,
+		}
+		cumulative := uint64(0)
+		for i := range that.bounds {
+			cumulative += stat.buckets[i].Load()
+			item.Buckets[i] = cumulative
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// Reset 清空所有统计
+func (that *CommandMetrics) Reset() {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.stats = make(map[string]*commandStat)
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出统计, 指标名以 namespace 为前缀:
+//
+//	<namespace>_command_duration_seconds  耗时直方图, 标签 cmd
+//	<namespace>_command_errors_total      失败次数, 标签 cmd
+//	<namespace>_command_slow_total        慢命令次数, 标签 cmd
+func (that *CommandMetrics) WritePrometheus(w io.Writer, namespace string) error {
+	if namespace == "" {
+		namespace = "kredis"
+	}
+	snapshot := that.Snapshot()
+
+	var sb strings.Builder
+	duration := namespace + "_command_duration_seconds"
+	fmt.Fprintf(&sb, "# HELP %s Redis command latency.\n# TYPE %s histogram\n", duration, duration)
+	for _, stats := range snapshot {
+		for i, bound := range stats.Bounds {
+			fmt.Fprintf(&sb, "%s_bucket{cmd=%q,le=\"%g\"} %d\n", duration, stats.Name, float64(bound)/1000, stats.Buckets[i])
+		}
+		fmt.Fprintf(&sb, "%s_bucket{cmd=%q,le=\"+Inf\"} %d\n", duration, stats.Name, stats.Calls)
+		fmt.Fprintf(&sb, "%s_sum{cmd=%q} %g\n", duration, stats.Name, stats.Total.Seconds())
+		fmt.Fprintf(&sb, "%s_count{cmd=%q} %d\n", duration, stats.Name, stats.Calls)
+	}
+
+	for _, counter := range []struct {
+		name  string
+		help  string
+		value func(CommandStats) uint64
+	}{
+		{namespace + "_command_errors_total", "Redis command errors.", func(s CommandStats) uint64 { return s.Errors }},
+		{namespace + "_command_slow_total", "Redis commands slower than the threshold.", func(s CommandStats) uint64 { return s.Slow }},
+	} {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for _, stats := range snapshot {
+			fmt.Fprintf(&sb, "%s{cmd=%q} %d\n", counter.name, stats.Name, counter.value(stats))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// record 记录一次调用, 返回是否为慢命令
+func (that *CommandMetrics) record(name string, elapsed time.Duration, err error) bool {
+	stat := that.stat(name)
+	stat.calls.Add(1)
+	if commandErr(err) != nil {
+		stat.errors.Add(1)
+	}
+	stat.total.Add(int64(elapsed))
+	for {
+		current := stat.max.Load()
+		if int64(elapsed) <= current || stat.max.CompareAndSwap(current, int64(elapsed)) {
+			break
+		}
+	}
+
+	index := sort.Search(len(that.bounds), func(i int) bool { return elapsed <= that.bounds[i] })
+	stat.buckets[index].Add(1)
+
+	slow := that.opts.SlowThreshold > 0 && elapsed >= millisecond(that.opts.SlowThreshold)
+	if slow {
+		stat.slow.Add(1)
+	}
+	return slow
+}
+
+func (that *CommandMetrics) stat(name string) *commandStat {
+	that.mu.RLock()
+	stat, ok := that.stats[name]
+	that.mu.RUnlock()
+	if ok {
+		return stat
+	}
+
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if stat, ok = that.stats[name]; !ok {
+		stat = &commandStat{buckets: make([]atomic.Uint64, len(that.bounds)+1)}
+		that.stats[name] = stat
+	}
+	return stat
+}
+
+// report 写慢命令日志并调用 OnCommand, 只有需要时才格式化参数; next 不为空时可以通过它加载 COMMAND 命令信息
+func (that *CommandMetrics) report(ctx context.Context, slow bool, event *CommandEvent, cmd redisHd.Cmder, next redisHd.ProcessHook) {
+	logSlow := slow && that.opts.Logger != nil
+	if !logSlow && that.opts.OnCommand == nil {
+		return
+	}
+	if cmd != nil {
+		var info map[string]*redisHd.CommandInfo
+		if that.opts.RedactKeys {
+			info = that.commandInfo(ctx, next)
+		}
+		event.Args = that.formatArgs(cmd, info)
+	}
+
+	if logSlow {
+		if event.Cmds > 1 {
+			that.opts.Logger.Warrn("kredis slow %s (%d cmds, first: %s) took %v", event.Name, event.Cmds, strings.Join(event.Args, " "), event.Duration)
+		} else {
+			that.opts.Logger.Warrn("kredis slow command %s took %v", strings.Join(event.Args, " "), event.Duration)
+		}
+	}
+	if that.opts.OnCommand != nil {
+		that.opts.OnCommand(ctx, event)
+	}
+}
+
+// commandInfo 返回 COMMAND 命令信息, 尚未加载时通过 next 执行 COMMAND, 失败后间隔 commandInfoRetry 再重试
+func (that *CommandMetrics) commandInfo(ctx context.Context, next redisHd.ProcessHook) map[string]*redisHd.CommandInfo {
+	if info := that.info.Load(); info != nil || next == nil {
+		if info == nil {
+			return nil
+		}
+		return *info
+	}
+
+	that.infoMu.Lock()
+	defer that.infoMu.Unlock()
+	if info := that.info.Load(); info != nil {
+		return *info
+	}
+	if time.Now().Before(that.infoRetry) {
+		return nil
+	}
+	that.infoRetry = time.Now().Add(commandInfoRetry)
+	cmd := redisHd.NewCommandsInfoCmd(ctx, "command")
+	if err := next(ctx, cmd); err != nil || len(cmd.Val()) == 0 {
+		return nil
+	}
+	info := cmd.Val()
+	that.info.Store(&info)
+	return info
+}
+
+// formatArgs 格式化命令参数, 第一个元素为命令名; 开启 RedactKeys 时按命令信息替换 key, 无法确定 key 的位置时替换全部参数
+func (that *CommandMetrics) formatArgs(cmd redisHd.Cmder, info map[string]*redisHd.CommandInfo) []string {
+	args := cmd.Args()
+	var keys map[int]bool
+	redactAll := false
+	if that.opts.RedactKeys {
+		var ok bool
+		keys, ok = keyPositions(cmd.Name(), args, info[cmd.Name()])
+		redactAll = !ok
+	}
+	limit := min(len(args), that.opts.MaxArgs+1)
+	result := make([]string, 0, limit+1)
+	for i := 0; i < limit; i++ {
+		arg := fmt.Sprint(args[i])
+		if i > 0 && (redactAll || keys[i]) {
+			arg = fmt.Sprintf("key#%08x", crc32.ChecksumIEEE([]byte(arg)))
+		} else if that.opts.MaxArgLen > 0 && len(arg) > that.opts.MaxArgLen {
+			arg = arg[:that.opts.MaxArgLen] + "..."
+		}
+		result = append(result, arg)
+	}
+	if len(args) > limit {
+		result = append(result, fmt.Sprintf("...(%d more)", len(args)-limit))
+	}
+	return result
+}
+
+// keyPositions 返回参数中 key 的位置(0 为命令名), 命令信息未知或无法解析 key 的位置时返回 false.
+// 固定位置的 key 来自 COMMAND 的 first/last/step, key 位置可变(movablekeys)的命令按参数解析
+func keyPositions(name string, args []any, info *redisHd.CommandInfo) (map[int]bool, bool) {
+	if info == nil {
+		return nil, false
+	}
+	keys := make(map[int]bool)
+	if first := int(info.FirstKeyPos); first > 0 {
+		last, step := int(info.LastKeyPos), max(int(info.StepCount), 1)
+		if last < 0 {
+			last += len(args)
+		}
+		for i := first; i <= last && i < len(args); i += step {
+			keys[i] = true
+		}
+	} else if pos, ok := subcommandKeyPos[name]; ok && pos < len(args) {
+		keys[pos] = true
+	}
+	if !slices.Contains(info.Flags, "movablekeys") {
+		return keys, true
+	}
+
+	switch name {
+	case "eval", "evalsha", "eval_ro", "evalsha_ro", "fcall", "fcall_ro", "zunionstore", "zinterstore", "zdiffstore":
+		return keys, markNumKeys(args, 2, keys)
+	case "zunion", "zinter", "zdiff", "sintercard", "zintercard", "lmpop", "zmpop":
+		return keys, markNumKeys(args, 1, keys)
+	case "blmpop", "bzmpop":
+		return keys, markNumKeys(args, 2, keys)
+	case "xread", "xreadgroup": // STREAMS key [key ...] id [id ...]
+		for i := 1; i < len(args); i++ {
+			if strings.EqualFold(fmt.Sprint(args[i]), "streams") {
+				n := (len(args) - i - 1) / 2
+				for j := i + 1; j <= i+n; j++ {
+					keys[j] = true
+				}
+				return keys, true
+			}
+		}
+		return keys, false
+	case "georadius", "georadiusbymember", "sort": // STORE key, STOREDIST key
+		for i := 2; i+1 < len(args); i++ {
+			if token := strings.ToLower(fmt.Sprint(args[i])); token == "store" || token == "storedist" {
+				keys[i+1] = true
+			}
+		}
+		return keys, true
+	case "migrate": // MIGRATE host port key|"" db timeout [KEYS key [key ...]]
+		if len(args) > 3 && fmt.Sprint(args[3]) != "" {
+			keys[3] = true
+		}
+		for i := 6; i < len(args); i++ {
+			if strings.EqualFold(fmt.Sprint(args[i]), "keys") {
+				for j := i + 1; j < len(args); j++ {
+					keys[j] = true
+				}
+				break
+			}
+		}
+		return keys, true
+	}
+	return keys, false
+}
+
+// markNumKeys 标记 numkeys 参数(位于 at)之后的 key
+func markNumKeys(args []any, at int, keys map[int]bool) bool {
+	if at >= len(args) {
+		return false
+	}
+	n, err := strconv.Atoi(fmt.Sprint(args[at]))
+	if err != nil || n < 0 {
+		return false
+	}
+	for i := at + 1; i <= at+n && i < len(args); i++ {
+		keys[i] = true
+	}
+	return true
+}
+
+// commandErr 过滤 redis.Nil, 它表示 key 不存在而不是执行失败
+func commandErr(err error) error {
+	if errors.Is(err, redisHd.Nil) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,353 @@
+package kredis
+
+import (
+	"context"
+	"encoding/json"
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/container/klists"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// 读取期间的失效按 key 所在的分片判断, 只丢弃同一分片中发起的读取结果
+const nearCacheShards = 64
+
+// NearCacheOptions 近端缓存参数, 时间单位均为毫秒
+type NearCacheOptions struct {
+	MaxEntries int    `json:"maxEntries"` // 本地最多缓存的 key 数量, 超出时淘汰最久未访问的 key, 默认10000
+	TTL        int64  `json:"ttl"`        // 本地缓存的有效期, 也是错过失效通知时数据不一致的最长时间, 默认5000
+	Channel    string `json:"channel"`    // 失效通知频道, 使用同一频道的进程互相通知, 默认 "kredis:near_cache:invalidate"
+
+	OnError func(err error) `json:"-"` // 订阅或解析失效通知失败时的回调, 可用于记录日志
+}
+
+func NewNearCacheOptions() *NearCacheOptions {
+	return &NearCacheOptions{
+		MaxEntries: 10000,
+		TTL:        5000,
+		Channel:    "kredis:near_cache:invalidate",
+	}
+}
+
+func (that *NearCacheOptions) SetMaxEntries(size int) *NearCacheOptions {
+	that.MaxEntries = size
+	return that
+}
+
+// 设置本地缓存有效期, 单位 毫秒
+func (that *NearCacheOptions) SetTTL(ttl int64) *NearCacheOptions {
+	that.TTL = ttl
+	return that
+}
+
+func (that *NearCacheOptions) SetChannel(channel string) *NearCacheOptions {
+	that.Channel = channel
+	return that
+}
+
+func (that *NearCacheOptions) SetErrorHandler(fn func(err error)) *NearCacheOptions {
+	that.OnError = fn
+	return that
+}
+
+// NearCacheStats 近端缓存统计
+type NearCacheStats struct {
+	Hits          uint64 `json:"hits"`          // 本地命中次数
+	Misses        uint64 `json:"misses"`        // 本地未命中(访问 Redis)次数
+	Evictions     uint64 `json:"evictions"`     // 容量淘汰次数
+	Expirations   uint64 `json:"expirations"`   // 过期淘汰次数
+	Invalidations uint64 `json:"invalidations"` // 收到失效通知而删除的次数
+	Size          int    `json:"size"`          // 当前缓存的 key 数量
+}
+
+// HitRate 本地命中率
+func (that NearCacheStats) HitRate() float64 {
+	total := that.Hits + that.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(that.Hits) / float64(total)
+}
+
+type nearEntry struct {
+	key      string
+	value    string
+	expireAt time.Time
+}
+
+// NearCache 位于 KRedis/KRedisCluster 之前的进程内缓存, 用于设备元数据等读多写少的热点 key.
+//
+// 本地缓存为有容量上限的 LRU, 每个 key 在 TTL 后过期; 通过 Set/Del/Invalidate 修改数据时,
+// 会在 Channel 上发布失效通知, 所有进程(包括自己)收到后删除本地副本.
+// 订阅连接断开或重新订阅时清空本地缓存, 避免断开期间错过的失效通知导致读到旧值.
+// 绕过 NearCache 直接写入 Redis 的修改不会发出通知, 只能等待本地缓存过期
+type NearCache struct {
+	client KRedisClient
+	opts   *NearCacheOptions
+	ctx    *kcontext.ContextNode
+
+	mu       sync.Mutex
+	entries  map[string]*klists.KElement[*nearEntry]
+	lru      *klists.KList[*nearEntry] // 前端为最近访问
+	versions [nearCacheShards]uint64   // 分片内发生失效时递增, 用于丢弃失效前发起的读取结果
+
+	flight flightGroup
+	sub    *Subscription
+	once   sync.Once
+
+	hits, misses, evictions, expirations, invalidations atomic.Uint64
+}
+
+// NewNearCache 创建近端缓存, 需要调用 Start 订阅失效通知; 缓存的生命周期绑定到 ctx 下新建的子节点上.
+// opts 为空时使用默认参数, opts 会被复制, 之后修改 opts 不影响已创建的缓存
+func NewNearCache(ctx *kcontext.ContextNode, client KRedisClient, opts *NearCacheOptions) *NearCache {
+	defaults := NewNearCacheOptions()
+	options := *defaults
+	if opts != nil {
+		options = *opts
+	}
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = defaults.MaxEntries
+	}
+	if options.TTL <= 0 {
+		options.TTL = defaults.TTL
+	}
+	if options.Channel == "" {
+		options.Channel = defaults.Channel
+	}
+
+	that := &NearCache{
+		client:  client,
+		opts:    &options,
+		ctx:     ctx.NewChild("kredis_near_cache"),
+		entries: make(map[string]*klists.KElement[*nearEntry]),
+		lru:     klists.New[*nearEntry](),
+	}
+	subOpts := NewSubscriptionOptions().SetEventHandler(that.onEvent)
+	that.sub = newSubscription(that.ctx.NewChild("kredis_subscription"), client.UniversalClient(), subOpts, that.onMessage)
+	return that
+}
+
+// Start 订阅失效通知, 连接不可用时返回错误
+func (that *NearCache) Start() error {
+	if err := that.sub.Subscribe(that.opts.Channel); err != nil {
+		that.sub.Stop()
+		return err
+	}
+	that.sub.Start()
+	return nil
+}
+
+// Stop 取消订阅并清空本地缓存
+func (that *NearCache) Stop() {
+	that.once.Do(func() {
+		that.sub.Stop()
+		that.ctx.Cancel()
+		that.ctx.Remove()
+		that.Purge()
+	})
+}
+
+// Get 读取 key, 本地未命中时从 Redis 读取并缓存, key 不存在时返回 redis.Nil (不缓存)
+func (that *NearCache) Get(key string) (string, error) {
+	return that.GetWithCtx(that.ctx.Context(), key)
+}
+
+// GetWithCtx 同 Get, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *NearCache) GetWithCtx(ctx context.Context, key string) (string, error) {
+	if value, ok := that.lookup(key); ok {
+		that.hits.Add(1)
+		return value, nil
+	}
+	that.misses.Add(1)
+
+	value, err := that.flight.Do(key, func() (any, error) {
+		// 第一个调用方取消时不应使等待同一结果的其他调用方一起失败
+		ctx := context.WithoutCancel(ctx)
+		version := that.currentVersion(key)
+		value, err := that.client.UniversalClient().Get(ctx, key).Result()
+		if err != nil {
+			return "", err
+		}
+		that.store(key, value, version)
+		return value, nil
+	})
+	str, _ := value.(string)
+	return str, err
+}
+
+// Set 写入 Redis 并通知所有进程删除本地副本
+func (that *NearCache) Set(key string, value any, ttl time.Duration) error {
+	return that.SetWithCtx(that.ctx.Context(), key, value, ttl)
+}
+
+// SetWithCtx 同 Set, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *NearCache) SetWithCtx(ctx context.Context, key string, value any, ttl time.Duration) error {
+	that.evict(key)
+	payload, _ := json.Marshal([]string{key})
+	_, err := that.client.UniversalClient().Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		pipe.Set(ctx, key, value, ttl)
+		pipe.Publish(ctx, that.opts.Channel, payload)
+		return nil
+	})
+	return err
+}
+
+// Del 删除 Redis 中的 key 并通知所有进程删除本地副本
+func (that *NearCache) Del(keys ...string) error {
+	return that.DelWithCtx(that.ctx.Context(), keys...)
+}
+
+// DelWithCtx 同 Del, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *NearCache) DelWithCtx(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	that.evict(keys...)
+	payload, _ := json.Marshal(keys)
+	_, err := that.client.UniversalClient().Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		for _, key := range keys { // 集群模式下多个 key 可能位于不同的槽, 逐个删除
+			pipe.Del(ctx, key)
+		}
+		pipe.Publish(ctx, that.opts.Channel, payload)
+		return nil
+	})
+	return err
+}
+
+// Invalidate 只通知所有进程删除本地副本, 用于其他途径修改了 Redis 中数据的场景
+func (that *NearCache) Invalidate(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	that.evict(keys...)
+	payload, _ := json.Marshal(keys)
+	return that.client.UniversalClient().Publish(that.ctx.Context(), that.opts.Channel, payload).Err()
+}
+
+// Purge 清空本地缓存
+func (that *NearCache) Purge() {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for i := range that.versions {
+		that.versions[i]++
+	}
+	that.entries = make(map[string]*klists.KElement[*nearEntry])
+	that.lru.Clear()
+}
+
+// Stats 返回统计信息
+func (that *NearCache) Stats() NearCacheStats {
+	that.mu.Lock()
+	size := len(that.entries)
+	that.mu.Unlock()
+
+	return NearCacheStats{
+		Hits:          that.hits.Load(),
+		Misses:        that.misses.Load(),
+		Evictions:     that.evictions.Load(),
+		Expirations:   that.expirations.Load(),
+		Invalidations: that.invalidations.Load(),
+		Size:          size,
+	}
+}
+
+func (that *NearCache) lookup(key string) (string, bool) {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+
+	elem, ok := that.entries[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(elem.Value.expireAt) {
+		that.lru.Remove(elem)
+		delete(that.entries, key)
+		that.expirations.Add(1)
+		return "", false
+	}
+	that.lru.MoveToFront(elem)
+	return elem.Value.value, true
+}
+
+func (that *NearCache) currentVersion(key string) uint64 {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return that.versions[nearShard(key)]
+}
+
+func nearShard(key string) int {
+	return int(crc32.ChecksumIEEE([]byte(key)) % nearCacheShards)
+}
+
+// store 写入本地缓存, 读取期间 key 所在的分片发生过失效时放弃写入, 避免缓存失效前读到的旧值
+func (that *NearCache) store(key string, value string, version uint64) {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+
+	if version != that.versions[nearShard(key)] {
+		return
+	}
+	expireAt := time.Now().Add(millisecond(that.opts.TTL))
+	if elem, ok := that.entries[key]; ok {
+		elem.Value.value = value
+		elem.Value.expireAt = expireAt
+		that.lru.MoveToFront(elem)
+		return
+	}
+
+	that.entries[key] = that.lru.PushFront(&nearEntry{key: key, value: value, expireAt: expireAt})
+	for len(that.entries) > that.opts.MaxEntries {
+		oldest := that.lru.Back()
+		that.lru.Remove(oldest)
+		delete(that.entries, oldest.Value.key)
+		that.evictions.Add(1)
+	}
+}
+
+// evict 删除本地副本, 返回实际删除的数量
+func (that *NearCache) evict(keys ...string) int {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+
+	count := 0
+	for _, key := range keys {
+		that.versions[nearShard(key)]++
+		if elem, ok := that.entries[key]; ok {
+			that.lru.Remove(elem)
+			delete(that.entries, key)
+			count++
+		}
+	}
+	return count
+}
+
+// onMessage 处理失效通知
+func (that *NearCache) onMessage(msg *SubscriptionMessage) {
+	keys := make([]string, 0, 1)
+	if err := json.Unmarshal([]byte(msg.Payload), &keys); err != nil {
+		if that.opts.OnError != nil {
+			that.opts.OnError(err)
+		}
+		return
+	}
+	that.invalidations.Add(uint64(that.evict(keys...)))
+}
+
+// onEvent 订阅断开期间可能错过失效通知, 断开与重新订阅时都清空本地缓存
+func (that *NearCache) onEvent(event SubscriptionEvent) {
+	switch event.Kind {
+	case SubscriptionDisconnected:
+		if that.opts.OnError != nil {
+			that.opts.OnError(event.Err)
+		}
+		that.Purge()
+	case SubscriptionResubscribed:
+		that.Purge()
+	}
+}
@@ -1,6 +1,14 @@
 package kredis
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/klogger"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
 
 type RedisRecord struct {
 	Key      string
@@ -19,6 +27,255 @@ type RedisMessagePackConstraints interface {
 	// constraints.Integer | constraints.Float
 }
 
+// KRedisClient 单机(含哨兵)与集群客户端的公共接口, KRedis 与 KRedisCluster 均实现了该接口,
+// 业务代码依赖该接口即可同时支持两种部署方式, 参见 NewKRedisClient
 type KRedisClient interface {
-	KRedis | KRedisCluster
+	// UniversalClient 返回底层的 go-redis 客户端, 用于执行未封装的命令
+	UniversalClient() redisHd.UniversalClient
+
+	// 通用
+	Do(args ...any) (any, error)
+	Get(key string) (any, error)
+	Set(key string, value any, duration time.Duration) (bool, error)
+	Exist(key string) (bool, error)
+	Type(key string) (string, error)
+	PTTL(key string) (time.Duration, error)
+	TTL(key string) (time.Duration, error)
+	Expire(key string, expiration time.Duration) bool
+	ExpireAt(key string, tm time.Time) bool
+	Dump(key string) (string, error)
+	Restore(key string, ttl time.Duration, value string) (string, error)
+	RestoreReplace(key string, ttl time.Duration, value string) (string, error)
+	Del(keys ...string) (int64, error)
+	Ping() bool
+	Pipeline() redisHd.Pipeliner
+	ScanMatch(limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error)
+	Scan(limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error)
+
+	// Hash
+	HGet(key string, field string) (any, error)
+	HSet(key string, field string, value any) error
+	HGetAll(key string) (map[string]string, error)
+	HSetAll(key string, fields map[string]any) error
+	HExists(key string, field string) (bool, error)
+	HLen(key string) (int64, error)
+	HKeys(ctx context.Context, key string) ([]string, error)
+	HVals(ctx context.Context, key string) ([]string, error)
+	HSetNX(ctx context.Context, key, field string, value any) (bool, error)
+	HDel(key string, fields ...string) error
+	HMGet(key string, fields ...string) ([]any, error)
+	HMSet(key string, fields map[string]any) error
+
+	// List
+	LPush(key string, values ...any) (int64, error)
+	LPushX(key string, values ...any) (int64, error)
+	RPush(key string, values ...any) (int64, error)
+	RPushX(key string, values ...any) (int64, error)
+	LPop(key string) (string, error)
+	RPop(key string) (string, error)
+	LRange(key string, start int64, stop int64) ([]string, error)
+	LLen(key string) (int64, error)
+	LTrim(key string, start int64, stop int64) error
+	LSet(key string, index int64, value any) error
+	LRem(key string, count int64, value any) (int64, error)
+	LIndex(key string, index int64) (string, error)
+	LInsert(key string, position string, pivot any, value any) (int64, error)
+
+	// Set
+	SAdd(key string, members ...any) (int64, error)
+	SMembers(key string) ([]string, error)
+	SRem(key string, members ...any) (int64, error)
+	SIsMember(key string, member any) (bool, error)
+	SCard(key string) (int64, error)
+	SPop(key string) (string, error)
+	SPopN(key string, count int64) ([]string, error)
+	SUnion(keys ...string) ([]string, error)
+	SUnionStore(destKey string, keys ...string) (int64, error)
+	SInter(keys ...string) ([]string, error)
+	SInterStore(destKey string, keys ...string) (int64, error)
+	SDiff(keys ...string) ([]string, error)
+	SDiffStore(destKey string, keys ...string) (int64, error)
+	SMove(source, destination string, member any) (bool, error)
+	SRandMember(key string) (string, error)
+
+	// RedisJSON
+	JsonGet(key string, paths ...string) (string, error)
+	JsonSet(key string, path string, value string) error
+	JsonMerge(key string, path string, value string) error
+	JsonDel(key string, path string) (int64, error)
+	JsonType(key string, path string) ([]string, error)
+	JsonObjKeys(key string, path string) ([]string, error)
+	JsonObjLen(key string, path string) ([]int64, error)
+	JsonArrAppend(key string, path string, values ...string) ([]int64, error)
+	JsonArrInsert(key string, path string, index int64, values ...string) ([]int64, error)
+	JsonArrPop(key string, path string, index int64) ([]string, error)
+	JsonArrLen(key string, path string) ([]int64, error)
+	JsonNumIncrBy(key string, path string, value float64) ([]float64, error)
+	JsonStrAppend(key string, path string, value string) ([]int64, error)
+	JsonMGet(path string, keys ...string) ([]string, error)
+	JsonToggle(key string, path string) ([]int64, error)
+	JsonClear(key string, path string) (int64, error)
+
+	// 发布订阅, 新代码建议使用 Subscription, 支持运行期增减频道, 连接状态事件与有界队列
+	Publish(topic string, payload any) error
+	PublishArray(messages []*RedisMessage) []error
+	PublishArrayWithCtx(ctx *kcontext.ContextNode, messages []*RedisMessage) []error
+	SyncSubscribeLow(callback func(err error, topic string, payload any), topics ...string)
+	SubscribeLow(callback func(err error, topic string, payload any), topics ...string)
+	SyncPSubscribeLow(callback func(err error, topic string, payload any), topics ...string)
+	PSubscribeLow(callback func(err error, topic string, payload any), topics ...string)
+	SyncSubscribeWithoutTimeout(callback func(err error, topic string, payload any), topics ...string)
+	SubscribeWithoutTimeout(callback func(err error, topic string, payload any), topics ...string)
+	SyncSubscribe(timeout int, callback func(err error, topic string, payload any), topics ...string)
+	Subscribe(timeout int, callback func(err error, topic string, payload any), topics ...string)
+	SyncPSubscribe(timeout int, callback func(err error, topic string, payload any), topics ...string)
+	PSubscribe(timeout int, callback func(err error, topic string, payload any), topics ...string)
+	SyncPSubscribeWithChanSize(timeout int, chanSize int, callback func(err error, topic string, payload any), topics ...string)
+	PSubscribeWithChanSize(timeout int, chanSize int, callback func(err error, topic string, payload any), topics ...string)
+	SyncSubscribeReceive(callback func(err error, topic string, payload any), topics ...string)
+	SubscribeReceive(callback func(err error, topic string, payload any), topics ...string)
+	SyncPSubscribeReceive(callback func(err error, topic string, payload any), topics ...string)
+	PSubscribeReceive(callback func(err error, topic string, payload any), topics ...string)
+	SPublish(channel string, payload any) error
+	SyncSSubscribe(callback func(err error, topic string, payload any), channels ...string)
+	SSubscribe(callback func(err error, topic string, payload any), channels ...string)
+
+	// Stream
+	XAdd(stream string, maxLen int64, values map[string]any) (string, error)
+	XLen(stream string) (int64, error)
+	XGroupCreate(stream string, group string, start string) error
+
+	// Sorted Set
+	ZAdd(key string, members ...redisHd.Z) (int64, error)
+	ZAddFlags(key string, flags ZAddFlag, members ...redisHd.Z) (int64, error)
+	ZIncrBy(key string, increment float64, member string) (float64, error)
+	ZRem(key string, members ...any) (int64, error)
+	ZRemRangeByScore(key string, min string, max string) (int64, error)
+	ZRemRangeByRank(key string, start int64, stop int64) (int64, error)
+	ZScore(key string, member string) (float64, error)
+	ZMScore(key string, members ...string) ([]float64, error)
+	ZRank(key string, member string) (int64, error)
+	ZRevRank(key string, member string) (int64, error)
+	ZCard(key string) (int64, error)
+	ZCount(key string, min string, max string) (int64, error)
+	ZRange(key string, start int64, stop int64) ([]string, error)
+	ZRangeWithScores(key string, start int64, stop int64) ([]redisHd.Z, error)
+	ZRevRange(key string, start int64, stop int64) ([]string, error)
+	ZRangeByScore(key string, min string, max string, offset int64, count int64) ([]string, error)
+	ZRangeByScoreWithScores(key string, min string, max string, offset int64, count int64) ([]redisHd.Z, error)
+	ZRevRangeByScore(key string, min string, max string, offset int64, count int64) ([]string, error)
+	ZRangeByLex(key string, min string, max string, offset int64, count int64) ([]string, error)
+	ZRangeArgs(args redisHd.ZRangeArgs) ([]string, error)
+	ZRangeArgsWithScores(args redisHd.ZRangeArgs) ([]redisHd.Z, error)
+	ZRangeStore(dst string, args redisHd.ZRangeArgs) (int64, error)
+	ZPopMin(key string, count int64) ([]redisHd.Z, error)
+	ZPopMax(key string, count int64) ([]redisHd.Z, error)
+
+	// context 版本, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+	DoWithCtx(ctx context.Context, args ...any) (any, error)
+	GetWithCtx(ctx context.Context, key string) (any, error)
+	SetWithCtx(ctx context.Context, key string, value any, duration time.Duration) (bool, error)
+	ExistWithCtx(ctx context.Context, key string) (bool, error)
+	HGetWithCtx(ctx context.Context, key string, field string) (any, error)
+	HSetWithCtx(ctx context.Context, key string, field string, value any) error
+	HGetAllWithCtx(ctx context.Context, key string) (map[string]string, error)
+	HSetAllWithCtx(ctx context.Context, key string, fields map[string]any) error
+	HExistsWithCtx(ctx context.Context, key string, field string) (bool, error)
+	HLenWithCtx(ctx context.Context, key string) (int64, error)
+	HDelWithCtx(ctx context.Context, key string, fields ...string) error
+	HMGetWithCtx(ctx context.Context, key string, fields ...string) ([]any, error)
+	HMSetWithCtx(ctx context.Context, key string, fields map[string]any) error
+	LPushWithCtx(ctx context.Context, key string, values ...any) (int64, error)
+	LPushXWithCtx(ctx context.Context, key string, values ...any) (int64, error)
+	RPushWithCtx(ctx context.Context, key string, values ...any) (int64, error)
+	RPushXWithCtx(ctx context.Context, key string, values ...any) (int64, error)
+	LPopWithCtx(ctx context.Context, key string) (string, error)
+	RPopWithCtx(ctx context.Context, key string) (string, error)
+	LRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error)
+	LLenWithCtx(ctx context.Context, key string) (int64, error)
+	LTrimWithCtx(ctx context.Context, key string, start int64, stop int64) error
+	LSetWithCtx(ctx context.Context, key string, index int64, value any) error
+	LRemWithCtx(ctx context.Context, key string, count int64, value any) (int64, error)
+	LIndexWithCtx(ctx context.Context, key string, index int64) (string, error)
+	LInsertWithCtx(ctx context.Context, key string, position string, pivot any, value any) (int64, error)
+	SAddWithCtx(ctx context.Context, key string, members ...any) (int64, error)
+	SMembersWithCtx(ctx context.Context, key string) ([]string, error)
+	SRemWithCtx(ctx context.Context, key string, members ...any) (int64, error)
+	SIsMemberWithCtx(ctx context.Context, key string, member any) (bool, error)
+	SCardWithCtx(ctx context.Context, key string) (int64, error)
+	SPopWithCtx(ctx context.Context, key string) (string, error)
+	SPopNWithCtx(ctx context.Context, key string, count int64) ([]string, error)
+	SUnionWithCtx(ctx context.Context, keys ...string) ([]string, error)
+	SUnionStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error)
+	SInterWithCtx(ctx context.Context, keys ...string) ([]string, error)
+	SInterStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error)
+	SDiffWithCtx(ctx context.Context, keys ...string) ([]string, error)
+	SDiffStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error)
+	SMoveWithCtx(ctx context.Context, source, destination string, member any) (bool, error)
+	SRandMemberWithCtx(ctx context.Context, key string) (string, error)
+	TypeWithCtx(ctx context.Context, key string) (string, error)
+	PTTLWithCtx(ctx context.Context, key string) (time.Duration, error)
+	TTLWithCtx(ctx context.Context, key string) (time.Duration, error)
+	ExpireWithCtx(ctx context.Context, key string, expiration time.Duration) bool
+	ExpireAtWithCtx(ctx context.Context, key string, tm time.Time) bool
+	JsonGetWithCtx(ctx context.Context, key string, paths ...string) (string, error)
+	JsonSetWithCtx(ctx context.Context, key string, path string, value string) error
+	JsonMergeWithCtx(ctx context.Context, key string, path string, value string) error
+	JsonDelWithCtx(ctx context.Context, key string, path string) (int64, error)
+	JsonTypeWithCtx(ctx context.Context, key string, path string) ([]string, error)
+	JsonObjKeysWithCtx(ctx context.Context, key string, path string) ([]string, error)
+	JsonObjLenWithCtx(ctx context.Context, key string, path string) ([]int64, error)
+	JsonArrAppendWithCtx(ctx context.Context, key string, path string, values ...string) ([]int64, error)
+	JsonArrInsertWithCtx(ctx context.Context, key string, path string, index int64, values ...string) ([]int64, error)
+	JsonArrPopWithCtx(ctx context.Context, key string, path string, index int64) ([]string, error)
+	JsonArrLenWithCtx(ctx context.Context, key string, path string) ([]int64, error)
+	JsonNumIncrByWithCtx(ctx context.Context, key string, path string, value float64) ([]float64, error)
+	JsonStrAppendWithCtx(ctx context.Context, key string, path string, value string) ([]int64, error)
+	JsonMGetWithCtx(ctx context.Context, path string, keys ...string) ([]string, error)
+	JsonToggleWithCtx(ctx context.Context, key string, path string) ([]int64, error)
+	JsonClearWithCtx(ctx context.Context, key string, path string) (int64, error)
+	DumpWithCtx(ctx context.Context, key string) (string, error)
+	RestoreReplaceWithCtx(ctx context.Context, key string, ttl time.Duration, value string) (string, error)
+	RestoreWithCtx(ctx context.Context, key string, ttl time.Duration, value string) (string, error)
+	DelWithCtx(ctx context.Context, keys ...string) (int64, error)
+	PingWithCtx(ctx context.Context) bool
+	ScanMatchWithCtx(ctx context.Context, limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error)
+	ScanWithCtx(ctx context.Context, limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error)
+	PublishWithCtx(ctx context.Context, topic string, payload any) error
+	SPublishWithCtx(ctx context.Context, channel string, payload any) error
+	XAddWithCtx(ctx context.Context, stream string, maxLen int64, values map[string]any) (string, error)
+	XLenWithCtx(ctx context.Context, stream string) (int64, error)
+	XGroupCreateWithCtx(ctx context.Context, stream string, group string, start string) error
+	ZAddWithCtx(ctx context.Context, key string, members ...redisHd.Z) (int64, error)
+	ZAddFlagsWithCtx(ctx context.Context, key string, flags ZAddFlag, members ...redisHd.Z) (int64, error)
+	ZIncrByWithCtx(ctx context.Context, key string, increment float64, member string) (float64, error)
+	ZRemWithCtx(ctx context.Context, key string, members ...any) (int64, error)
+	ZRemRangeByScoreWithCtx(ctx context.Context, key string, min string, max string) (int64, error)
+	ZRemRangeByRankWithCtx(ctx context.Context, key string, start int64, stop int64) (int64, error)
+	ZScoreWithCtx(ctx context.Context, key string, member string) (float64, error)
+	ZMScoreWithCtx(ctx context.Context, key string, members ...string) ([]float64, error)
+	ZRankWithCtx(ctx context.Context, key string, member string) (int64, error)
+	ZRevRankWithCtx(ctx context.Context, key string, member string) (int64, error)
+	ZCardWithCtx(ctx context.Context, key string) (int64, error)
+	ZCountWithCtx(ctx context.Context, key string, min string, max string) (int64, error)
+	ZRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error)
+	ZRangeWithScoresWithCtx(ctx context.Context, key string, start int64, stop int64) ([]redisHd.Z, error)
+	ZRevRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error)
+	ZRangeByScoreWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error)
+	ZRangeByScoreWithScoresWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]redisHd.Z, error)
+	ZRevRangeByScoreWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error)
+	ZRangeByLexWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error)
+	ZRangeArgsWithCtx(ctx context.Context, args redisHd.ZRangeArgs) ([]string, error)
+	ZRangeArgsWithScoresWithCtx(ctx context.Context, args redisHd.ZRangeArgs) ([]redisHd.Z, error)
+	ZRangeStoreWithCtx(ctx context.Context, dst string, args redisHd.ZRangeArgs) (int64, error)
+	ZPopMinWithCtx(ctx context.Context, key string, count int64) ([]redisHd.Z, error)
+	ZPopMaxWithCtx(ctx context.Context, key string, count int64) ([]redisHd.Z, error)
+
+	// Stop 取消客户端的上下文并关闭连接
+	Stop()
 }
+
+var (
+	_ KRedisClient = (*KRedis)(nil)
+	_ KRedisClient = (*KRedisCluster)(nil)
+)
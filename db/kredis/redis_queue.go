@@ -0,0 +1,499 @@
+package kredis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// 队列的所有 key 使用同一个 hash tag {name}, 集群模式下位于同一个槽, 脚本可以原子地操作多个 key:
+//
+//	{name}:delayed   ZSet, 等待执行的任务, score 为执行时间(毫秒)
+//	{name}:ready     List, 可以执行的任务
+//	{name}:active    ZSet, 执行中的任务, score 为可见性超时的截止时间(毫秒)
+//	{name}:dead      List, 超过重试次数的任务
+//	{name}:jobs      Hash, 任务ID -> 任务内容
+//	{name}:attempts  Hash, 任务ID -> 已执行次数
+//	{name}:unique    Hash, 去重键 -> 任务ID
+//	{name}:owners    Hash, 任务ID -> 去重键, 任务进入死信或完成时用于清理去重键
+var (
+	// ARGV: 任务ID, 任务内容, 执行时间, 当前时间, 去重键; 返回任务ID, 去重键已存在时返回已存在的任务ID
+	queueEnqueueScript = redisHd.NewScript(`
+local id = ARGV[1]
+if ARGV[5] ~= '' then
+	local existing = redis.call('HGET', KEYS[6], ARGV[5])
+	if existing and redis.call('HEXISTS', KEYS[4], existing) == 1 then
+		return existing
+	end
+	redis.call('HSET', KEYS[6], ARGV[5], id)
+	redis.call('HSET', KEYS[8], id, ARGV[5])
+end
+redis.call('HSET', KEYS[4], id, ARGV[2])
+if tonumber(ARGV[3]) <= tonumber(ARGV[4]) then
+	redis.call('RPUSH', KEYS[2], id)
+else
+	redis.call('ZADD', KEYS[1], ARGV[3], id)
+end
+return id
+`)
+
+	// ARGV: 当前时间, 数量上限, 最大重试次数; 将到期的延迟任务与可见性超时的执行中任务移入 ready,
+	// 可见性超时的任务执行次数已超过重试次数时移入死信队列
+	queuePromoteScript = redisHd.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('RPUSH', KEYS[2], id)
+end
+local moved = #ids
+ids = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[3], id)
+	local attempts = tonumber(redis.call('HGET', KEYS[5], id) or '0')
+	if attempts > tonumber(ARGV[3]) then
+		redis.call('RPUSH', KEYS[7], id)
+		local unique = redis.call('HGET', KEYS[8], id)
+		if unique and redis.call('HGET', KEYS[6], unique) == id then
+			redis.call('HDEL', KEYS[6], unique)
+		end
+		redis.call('HDEL', KEYS[8], id)
+	else
+		redis.call('RPUSH', KEYS[2], id)
+	end
+end
+return moved + #ids
+`)
+
+	// ARGV: 可见性超时的截止时间; 返回 {任务ID, 任务内容, 已执行次数}, 没有任务时返回 nil
+	queueReserveScript = redisHd.NewScript(`
+while true do
+	local id = redis.call('LPOP', KEYS[2])
+	if not id then
+		return false
+	end
+	local job = redis.call('HGET', KEYS[4], id)
+	if job then
+		redis.call('ZADD', KEYS[3], ARGV[1], id)
+		local attempts = redis.call('HINCRBY', KEYS[5], id, 1)
+		return {id, job, attempts}
+	end
+end
+`)
+
+	// ARGV: 任务ID, 去重键; 删除完成的任务
+	queueAckScript = redisHd.NewScript(`
+local removed = redis.call('ZREM', KEYS[3], ARGV[1])
+redis.call('HDEL', KEYS[4], ARGV[1])
+redis.call('HDEL', KEYS[5], ARGV[1])
+redis.call('HDEL', KEYS[8], ARGV[1])
+if ARGV[2] ~= '' and redis.call('HGET', KEYS[6], ARGV[2]) == ARGV[1] then
+	redis.call('HDEL', KEYS[6], ARGV[2])
+end
+return removed
+`)
+
+	// ARGV: 任务ID; worker 停止时把执行中的任务放回 ready 队首, 本次执行不计入执行次数
+	queueReleaseScript = redisHd.NewScript(`
+if redis.call('ZREM', KEYS[3], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HINCRBY', KEYS[5], ARGV[1], -1)
+redis.call('LPUSH', KEYS[2], ARGV[1])
+return 1
+`)
+
+	// ARGV: 任务ID, 任务内容, 下次执行时间, 是否进入死信, 去重键; 任务已因可见性超时被重新投递时返回 0
+	queueRetryScript = redisHd.NewScript(`
+if redis.call('ZREM', KEYS[3], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[4], ARGV[1], ARGV[2])
+if ARGV[4] == '1' then
+	redis.call('RPUSH', KEYS[7], ARGV[1])
+	redis.call('HDEL', KEYS[8], ARGV[1])
+	if ARGV[5] ~= '' and redis.call('HGET', KEYS[6], ARGV[5]) == ARGV[1] then
+		redis.call('HDEL', KEYS[6], ARGV[5])
+	end
+else
+	redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+end
+return 1
+`)
+)
+
+// Job 队列中的任务
+type Job struct {
+	ID        string `json:"id"`
+	Payload   string `json:"payload"`             // 任务内容, 通常为 JSON
+	UniqueKey string `json:"uniqueKey,omitempty"` // 去重键, 相同去重键的任务在完成或进入死信前只保留一个
+	CreatedAt int64  `json:"createdAt"`           // 创建时间, unix 毫秒
+	RunAt     int64  `json:"runAt"`               // 计划执行时间, unix 毫秒
+	LastError string `json:"lastError,omitempty"` // 最近一次失败的原因
+	Attempts  int64  `json:"-"`                   // 包括本次在内的执行次数
+}
+
+// JobHandler 任务处理函数, 返回 nil 时任务完成, 否则按退避时间重试, 超过重试次数后进入死信队列.
+// ctx 在 worker 停止时被取消, 此时返回的错误不计入重试次数, 任务放回 ready 队首等待下次执行
+type JobHandler func(ctx context.Context, job *Job) error
+
+// QueueStats 队列各状态的任务数量
+type QueueStats struct {
+	Delayed int64 `json:"delayed"`
+	Ready   int64 `json:"ready"`
+	Active  int64 `json:"active"`
+	Dead    int64 `json:"dead"`
+}
+
+// QueueOptions 队列参数, 时间单位均为毫秒
+type QueueOptions struct {
+	Visibility   int64 `json:"visibility"`   // 可见性超时, worker 崩溃后任务在该时间后被重新投递, 执行期间自动续期, 默认30000
+	MaxRetries   int64 `json:"maxRetries"`   // 失败后的最大重试次数, 可见性超时后的重新投递同样计入, 默认3
+	Backoff      int64 `json:"backoff"`      // 第一次重试的等待时间, 之后每次翻倍, 默认1000
+	MaxBackoff   int64 `json:"maxBackoff"`   // 重试等待时间的上限, 默认60000
+	PollInterval int64 `json:"pollInterval"` // 没有任务时的轮询间隔, 默认500
+	Concurrency  int   `json:"concurrency"`  // worker 并发数, 默认4
+	PromoteBatch int64 `json:"promoteBatch"` // 每次移入 ready 的最大任务数, 默认100
+
+	OnError func(err error) `json:"-"` // 访问 Redis 或处理任务失败时的回调, 可用于记录日志
+}
+
+func NewQueueOptions() *QueueOptions {
+	return &QueueOptions{
+		Visibility:   30000,
+		MaxRetries:   3,
+		Backoff:      1000,
+		MaxBackoff:   60000,
+		PollInterval: 500,
+		Concurrency:  4,
+		PromoteBatch: 100,
+	}
+}
+
+// 设置可见性超时, 单位 毫秒
+func (that *QueueOptions) SetVisibility(visibility int64) *QueueOptions {
+	that.Visibility = visibility
+	return that
+}
+
+// 设置重试次数与退避时间, 单位 毫秒
+func (that *QueueOptions) SetRetry(maxRetries int64, backoff int64, maxBackoff int64) *QueueOptions {
+	that.MaxRetries = maxRetries
+	that.Backoff = backoff
+	that.MaxBackoff = maxBackoff
+	return that
+}
+
+// 设置轮询间隔, 单位 毫秒
+func (that *QueueOptions) SetPollInterval(interval int64) *QueueOptions {
+	that.PollInterval = interval
+	return that
+}
+
+func (that *QueueOptions) SetConcurrency(concurrency int) *QueueOptions {
+	that.Concurrency = concurrency
+	return that
+}
+
+func (that *QueueOptions) SetErrorHandler(fn func(err error)) *QueueOptions {
+	that.OnError = fn
+	return that
+}
+
+// Queue 基于 Redis 的可靠延迟任务队列, 任务至少执行一次, 处理函数需要幂等.
+// 执行时间使用各实例的本地时钟, 实例之间的时钟偏差会体现为任务执行时间的偏差
+type Queue struct {
+	client KRedisClient
+	name   string
+	opts   *QueueOptions
+	keys   []string // delayed, ready, active, jobs, attempts, unique, dead, owners
+}
+
+// NewQueue 创建队列, opts 为空时使用默认参数, opts 会被复制, 之后修改 opts 不影响已创建的队列
+func NewQueue(client KRedisClient, name string, opts *QueueOptions) *Queue {
+	options := *NewQueueOptions()
+	if opts != nil {
+		options = *opts
+	}
+	keys := make([]string, 0, 8)
+	for _, suffix := range []string{"delayed", "ready", "active", "jobs", "attempts", "unique", "dead", "owners"} {
+		keys = append(keys, "{"+name+"}:"+suffix)
+	}
+	return &Queue{client: client, name: name, opts: &options, keys: keys}
+}
+
+func (that *Queue) Name() string { return that.name }
+
+// Enqueue 添加任务, delay 后执行; uniqueKey 不为空时, 相同 uniqueKey 的任务未完成前重复添加直接返回已有任务的ID
+func (that *Queue) Enqueue(ctx context.Context, payload string, delay time.Duration, uniqueKey string) (string, error) {
+	return that.EnqueueAt(ctx, payload, time.Now().Add(delay), uniqueKey)
+}
+
+// EnqueueAt 添加在 runAt 执行的任务, 已过期的时间立即执行
+func (that *Queue) EnqueueAt(ctx context.Context, payload string, runAt time.Time, uniqueKey string) (string, error) {
+	id, err := randomLockValue()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UnixMilli()
+	job := &Job{ID: id, Payload: payload, UniqueKey: uniqueKey, CreatedAt: now, RunAt: runAt.UnixMilli()}
+	content, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	return queueEnqueueScript.Run(ctx, that.client.UniversalClient(), that.keys, id, content, job.RunAt, now, uniqueKey).Text()
+}
+
+// Stats 返回各状态的任务数量
+func (that *Queue) Stats(ctx context.Context) (*QueueStats, error) {
+	client := that.client.UniversalClient()
+	var delayed, ready, active, dead *redisHd.IntCmd
+	_, err := client.Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		delayed = pipe.ZCard(ctx, that.keys[0])
+		ready = pipe.LLen(ctx, that.keys[1])
+		active = pipe.ZCard(ctx, that.keys[2])
+		dead = pipe.LLen(ctx, that.keys[6])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &QueueStats{Delayed: delayed.Val(), Ready: ready.Val(), Active: active.Val(), Dead: dead.Val()}, nil
+}
+
+// DeadJobs 返回死信队列中最早的 limit 个任务
+func (that *Queue) DeadJobs(ctx context.Context, limit int64) ([]*Job, error) {
+	client := that.client.UniversalClient()
+	ids, err := client.LRange(ctx, that.keys[6], 0, limit-1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	contents, err := client.HMGet(ctx, that.keys[3], ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(ids))
+	for _, content := range contents {
+		if s, ok := content.(string); ok {
+			job := &Job{}
+			if err := json.Unmarshal([]byte(s), job); err == nil {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// promote 将到期的任务移入 ready, 可见性超时且超过重试次数的任务移入死信队列
+func (that *Queue) promote(ctx context.Context) error {
+	return queuePromoteScript.Run(ctx, that.client.UniversalClient(), that.keys, time.Now().UnixMilli(), max(that.opts.PromoteBatch, 1), that.opts.MaxRetries).Err()
+}
+
+// reserve 取出一个任务并设置可见性超时, 没有任务时返回 nil
+func (that *Queue) reserve(ctx context.Context) (*Job, error) {
+	deadline := time.Now().UnixMilli() + that.opts.Visibility
+	values, err := queueReserveScript.Run(ctx, that.client.UniversalClient(), that.keys, deadline).Slice()
+	if errors.Is(err, redisHd.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("kredis: unexpected reserve reply %v", values)
+	}
+
+	content, _ := values[1].(string)
+	job := &Job{}
+	if err := json.Unmarshal([]byte(content), job); err != nil {
+		return nil, err
+	}
+	job.Attempts, _ = values[2].(int64)
+	return job, nil
+}
+
+// extend 延长执行中任务的可见性超时, 任务已被重新投递时不做任何修改
+func (that *Queue) extend(ctx context.Context, job *Job) error {
+	deadline := time.Now().UnixMilli() + that.opts.Visibility
+	return that.client.UniversalClient().ZAddXX(ctx, that.keys[2], redisHd.Z{Score: float64(deadline), Member: job.ID}).Err()
+}
+
+func (that *Queue) ack(ctx context.Context, job *Job) error {
+	return queueAckScript.Run(ctx, that.client.UniversalClient(), that.keys, job.ID, job.UniqueKey).Err()
+}
+
+// release 把执行中的任务放回 ready 队首, 不计入执行次数
+func (that *Queue) release(ctx context.Context, job *Job) error {
+	return queueReleaseScript.Run(ctx, that.client.UniversalClient(), that.keys, job.ID).Err()
+}
+
+// retry 按退避时间重新调度失败的任务, 超过重试次数时移入死信队列, 返回是否进入死信
+func (that *Queue) retry(ctx context.Context, job *Job, cause error) (bool, error) {
+	dead := job.Attempts > that.opts.MaxRetries
+	backoff := that.opts.Backoff
+	for i := int64(1); i < job.Attempts && backoff < that.opts.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, max(that.opts.MaxBackoff, that.opts.Backoff))
+
+	job.LastError = cause.Error()
+	job.RunAt = time.Now().UnixMilli() + backoff
+	content, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+	deadFlag := "0"
+	if dead {
+		deadFlag = "1"
+	}
+	err = queueRetryScript.Run(ctx, that.client.UniversalClient(), that.keys, job.ID, content, job.RunAt, deadFlag, job.UniqueKey).Err()
+	return dead, err
+}
+
+///////////////////////////////////////////////////////////////
+
+// QueueWorker 以 Concurrency 个 goroutine 并发处理队列中的任务, 生命周期绑定到 ContextNode
+type QueueWorker struct {
+	queue   *Queue
+	handler JobHandler
+	ctx     *kcontext.ContextNode
+	wg      sync.WaitGroup
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewQueueWorker 创建 worker, worker 的生命周期绑定到 ctx 下新建的子节点上
+func NewQueueWorker(ctx *kcontext.ContextNode, queue *Queue, handler JobHandler) *QueueWorker {
+	return &QueueWorker{
+		queue:   queue,
+		handler: handler,
+		ctx:     ctx.NewChild("kredis_queue_worker:" + queue.name),
+	}
+}
+
+// Start 启动调度与处理 goroutine, 重复调用无效
+func (that *QueueWorker) Start() {
+	that.startOnce.Do(func() {
+		that.wg.Add(1)
+		go func() {
+			defer that.wg.Done()
+			that.promoteLoop()
+		}()
+
+		for i := 0; i < max(that.queue.opts.Concurrency, 1); i++ {
+			that.wg.Add(1)
+			go func() {
+				defer that.wg.Done()
+				that.workLoop()
+			}()
+		}
+	})
+}
+
+// Stop 停止取出新任务, 取消正在执行的任务的 ctx 并等待其返回
+func (that *QueueWorker) Stop() {
+	that.stopOnce.Do(func() {
+		that.ctx.Cancel()
+		that.wg.Wait()
+		that.ctx.Remove()
+	})
+}
+
+func (that *QueueWorker) promoteLoop() {
+	ctx := that.ctx.Context()
+	interval := millisecond(max(that.queue.opts.PollInterval, 1))
+	for ctx.Err() == nil {
+		if err := that.queue.promote(ctx); err != nil && ctx.Err() == nil {
+			that.onError(err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (that *QueueWorker) workLoop() {
+	ctx := that.ctx.Context()
+	interval := millisecond(max(that.queue.opts.PollInterval, 1))
+	for ctx.Err() == nil {
+		job, err := that.queue.reserve(ctx)
+		if err != nil && ctx.Err() == nil {
+			that.onError(err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+			continue
+		}
+		that.process(ctx, job)
+	}
+}
+
+func (that *QueueWorker) process(ctx context.Context, job *Job) {
+	// 执行期间定期续期, 避免执行时间较长的任务被重复投递
+	renewDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(millisecond(max(that.queue.opts.Visibility/3, 1)))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewDone:
+				return
+			case <-ticker.C:
+				if err := that.queue.extend(ctx, job); err != nil && ctx.Err() == nil {
+					that.onError(err)
+				}
+			}
+		}
+	}()
+
+	err := that.safeHandle(ctx, job)
+	close(renewDone)
+
+	// 停止过程中也要完成确认或重试, 否则任务要等可见性超时后才会被重新投递
+	finishCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		if err := that.queue.ack(finishCtx, job); err != nil {
+			that.onError(err)
+		}
+		return
+	}
+	if ctx.Err() != nil { // 因停止而中断的执行不算失败, 不计入重试次数也不退避
+		if err := that.queue.release(finishCtx, job); err != nil {
+			that.onError(err)
+		}
+		return
+	}
+
+	that.onError(fmt.Errorf("kredis: job %s attempt %d failed: %w", job.ID, job.Attempts, err))
+	if _, err := that.queue.retry(finishCtx, job, err); err != nil {
+		that.onError(err)
+	}
+}
+
+// safeHandle 调用处理函数, panic 视为处理失败
+func (that *QueueWorker) safeHandle(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("kredis: job handler panic: %v", r)
+		}
+	}()
+	return that.handler(ctx, job)
+}
+
+func (that *QueueWorker) onError(err error) {
+	if that.queue.opts.OnError != nil {
+		that.queue.opts.OnError(err)
+	}
+}
@@ -0,0 +1,297 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// 所有脚本均为 KEYS[1] 限流键; ARGV[1] 上限, ARGV[2] 窗口(毫秒), ARGV[3] 本次请求数量.
+// 返回 {是否允许, 剩余数量, 重试等待(毫秒)}. 时间取自 Redis 服务端, 不受各实例时钟偏差影响
+var (
+	// 固定窗口: 窗口从第一次请求开始计算, 到期后计数清零
+	rateFixedWindowScript = redisHd.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current + n > limit then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl < 0 then ttl = window end
+	return {0, limit - current, ttl}
+end
+current = redis.call('INCRBY', KEYS[1], n)
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], window)
+end
+return {1, limit - current, 0}
+`)
+
+	// 滑动窗口日志: ZSet 记录窗口内每次请求的时间; ARGV[4] 为本次请求的唯一标识
+	rateSlidingLogScript = redisHd.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count + n > limit then
+	local retry = window
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	if oldest[2] then retry = tonumber(oldest[2]) + window - now end
+	return {0, limit - count, retry}
+end
+for i = 1, n do
+	redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[1], window)
+return {1, limit - count - n, 0}
+`)
+
+	// 令牌桶: 容量为上限, 每个窗口匀速补充上限数量的令牌, 允许突发
+	rateTokenBucketScript = redisHd.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local rate = capacity / window
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+else
+	retry = math.ceil((n - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {allowed, math.floor(tokens), retry}
+`)
+)
+
+// RateLimitAlgorithm 限流算法
+type RateLimitAlgorithm int
+
+const (
+	RateFixedWindow RateLimitAlgorithm = iota // 固定窗口, 开销最小, 窗口边界处可能出现两倍突发
+	RateSlidingLog                            // 滑动窗口日志, 最精确, 内存占用与上限成正比
+	RateTokenBucket                           // 令牌桶, 平滑限流并允许不超过上限的突发
+)
+
+func (that RateLimitAlgorithm) String() string {
+	switch that {
+	case RateFixedWindow:
+		return "fixed_window"
+	case RateSlidingLog:
+		return "sliding_log"
+	case RateTokenBucket:
+		return "token_bucket"
+	}
+	return "unknown"
+}
+
+// RateFallbackPolicy Redis 不可用时的处理方式
+type RateFallbackPolicy int
+
+const (
+	RateFallbackLocal RateFallbackPolicy = iota // 使用进程内令牌桶限流, 每个实例独立计数
+	RateFallbackAllow                           // 全部放行
+	RateFallbackDeny                            // 全部拒绝
+)
+
+// RateLimitResult 限流结果
+type RateLimitResult struct {
+	Allowed    bool          // 是否允许
+	Remaining  int64         // 剩余可用数量
+	RetryAfter time.Duration // 被拒绝时距离下次可能允许的等待时间
+	Fallback   bool          // Redis 不可用, 结果由 Fallback 策略给出
+}
+
+// RateLimitOptions 限流参数, 时间单位均为毫秒
+type RateLimitOptions struct {
+	Algorithm  RateLimitAlgorithm `json:"algorithm"`  // 限流算法, 默认令牌桶
+	Limit      int64              `json:"limit"`      // 每个窗口允许的请求数量, 令牌桶时为桶容量
+	Window     int64              `json:"window"`     // 窗口长度, 默认1000
+	Prefix     string             `json:"prefix"`     // key 前缀, 默认 "ratelimit:"
+	Fallback   RateFallbackPolicy `json:"fallback"`   // Redis 不可用时的处理方式, 默认进程内限流
+	LocalLimit int64              `json:"localLimit"` // 进程内限流的上限, <= 0 时与 Limit 相同; 多实例部署时可设置为 Limit / 实例数
+
+	OnError func(err error) `json:"-"` // 访问 Redis 失败时的回调, 可用于记录日志
+}
+
+func NewRateLimitOptions(algorithm RateLimitAlgorithm, limit int64, window int64) *RateLimitOptions {
+	return &RateLimitOptions{
+		Algorithm: algorithm,
+		Limit:     limit,
+		Window:    window,
+		Prefix:    "ratelimit:",
+		Fallback:  RateFallbackLocal,
+	}
+}
+
+func (that *RateLimitOptions) SetPrefix(prefix string) *RateLimitOptions {
+	that.Prefix = prefix
+	return that
+}
+
+func (that *RateLimitOptions) SetFallback(policy RateFallbackPolicy, localLimit int64) *RateLimitOptions {
+	that.Fallback = policy
+	that.LocalLimit = localLimit
+	return that
+}
+
+func (that *RateLimitOptions) SetErrorHandler(fn func(err error)) *RateLimitOptions {
+	that.OnError = fn
+	return that
+}
+
+// RateLimiter 基于 Lua 脚本的分布式限流器, 同一个 key 在所有实例之间共享配额.
+// 每个 key 只对应一个 Redis 键, 键名带 hash tag, 集群模式下同样适用
+type RateLimiter struct {
+	client KRedisClient
+	opts   *RateLimitOptions
+
+	mu    sync.Mutex
+	local map[string]*localBucket
+}
+
+// NewRateLimiter 创建限流器, opts 会被复制, 之后修改 opts 不影响已创建的限流器; opts 为 nil 或 Limit <= 0 时返回错误
+func NewRateLimiter(client KRedisClient, opts *RateLimitOptions) (*RateLimiter, error) {
+	if opts == nil {
+		return nil, errors.New("kredis: rate limit options required")
+	}
+	if opts.Limit <= 0 {
+		return nil, fmt.Errorf("kredis: invalid rate limit %d", opts.Limit)
+	}
+	options := *opts
+	if options.Window <= 0 {
+		options.Window = 1000
+	}
+	return &RateLimiter{client: client, opts: &options, local: make(map[string]*localBucket)}, nil
+}
+
+// Allow 检查 key (如租户ID) 的一次请求是否允许
+func (that *RateLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	return that.AllowN(ctx, key, 1)
+}
+
+// AllowN 检查 key 的 n 次请求是否允许, 被拒绝时不消耗配额.
+// Redis 不可用时按 Fallback 策略返回结果, 错误通过 OnError 报告, 不作为返回值
+func (that *RateLimiter) AllowN(ctx context.Context, key string, n int64) (*RateLimitResult, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("kredis: invalid rate limit request count %d", n)
+	}
+
+	var script *redisHd.Script
+	args := []any{that.opts.Limit, that.opts.Window, n}
+	switch that.opts.Algorithm {
+	case RateFixedWindow:
+		script = rateFixedWindowScript
+	case RateSlidingLog:
+		script = rateSlidingLogScript
+		id, err := randomLockValue()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, id)
+	case RateTokenBucket:
+		script = rateTokenBucketScript
+	default:
+		return nil, fmt.Errorf("kredis: unknown rate limit algorithm %d", that.opts.Algorithm)
+	}
+
+	redisKey := sameSlotKey(that.opts.Prefix+key, that.opts.Algorithm.String())
+	values, err := script.Run(ctx, that.client.UniversalClient(), []string{redisKey}, args...).Int64Slice()
+	if err != nil || len(values) != 3 {
+		if err == nil {
+			err = fmt.Errorf("kredis: unexpected rate limit reply %v", values)
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if that.opts.OnError != nil {
+			that.opts.OnError(err)
+		}
+		return that.fallback(key, n), nil
+	}
+
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  max(values[1], 0),
+		RetryAfter: time.Duration(max(values[2], 0)) * time.Millisecond,
+	}, nil
+}
+
+func (that *RateLimiter) fallback(key string, n int64) *RateLimitResult {
+	switch that.opts.Fallback {
+	case RateFallbackAllow:
+		return &RateLimitResult{Allowed: true, Remaining: that.opts.Limit, Fallback: true}
+	case RateFallbackDeny:
+		return &RateLimitResult{Allowed: false, RetryAfter: millisecond(that.opts.Window), Fallback: true}
+	}
+
+	limit := that.opts.LocalLimit
+	if limit <= 0 {
+		limit = that.opts.Limit
+	}
+
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	now := time.Now()
+	bucket, ok := that.local[key]
+	if !ok {
+		if len(that.local) >= localBucketMaxKeys {
+			that.pruneLocal(now)
+		}
+		bucket = &localBucket{tokens: float64(limit), updated: now}
+		that.local[key] = bucket
+	}
+	result := bucket.take(now, float64(limit), millisecond(that.opts.Window), float64(n))
+	result.Fallback = true
+	return result
+}
+
+// pruneLocal 删除已经补满的令牌桶, 它们与新建的桶等价
+func (that *RateLimiter) pruneLocal(now time.Time) {
+	window := millisecond(that.opts.Window)
+	for key, bucket := range that.local {
+		if now.Sub(bucket.updated) >= window {
+			delete(that.local, key)
+		}
+	}
+}
+
+const localBucketMaxKeys = 10000 // 进程内限流最多保存的 key 数量, 超出时清理已补满的令牌桶
+
+// localBucket 进程内令牌桶
+type localBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func (that *localBucket) take(now time.Time, capacity float64, window time.Duration, n float64) *RateLimitResult {
+	rate := capacity / float64(window) // 每纳秒补充的令牌数
+	that.tokens = math.Min(capacity, that.tokens+float64(now.Sub(that.updated))*rate)
+	that.updated = now
+
+	if that.tokens >= n {
+		that.tokens -= n
+		return &RateLimitResult{Allowed: true, Remaining: int64(that.tokens)}
+	}
+	return &RateLimitResult{Remaining: int64(that.tokens), RetryAfter: time.Duration(math.Ceil((n - that.tokens) / rate))}
+}
@@ -0,0 +1,182 @@
+package kredis
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// ScanOptions 流式扫描参数, 过滤均在服务端完成
+type ScanOptions struct {
+	Match     string `json:"match"`     // SCAN MATCH 模式, 默认 "*"
+	Type      string `json:"type"`      // SCAN TYPE 数据类型, 如 string, hash, 为空时不过滤, 需要 Redis 6.0+
+	Count     int64  `json:"count"`     // 每次 SCAN 的数量提示, 默认1000
+	BatchSize int    `json:"batchSize"` // ScanRecords 获取数据与 DeleteMatch 删除时每个 pipeline 的 key 数量, 默认500
+}
+
+func NewScanOptions(match string) *ScanOptions {
+	if match == "" {
+		match = "*"
+	}
+	return &ScanOptions{
+		Match:     match,
+		Count:     1000,
+		BatchSize: 500,
+	}
+}
+
+func (that *ScanOptions) SetType(dataType string) *ScanOptions {
+	that.Type = dataType
+	return that
+}
+
+func (that *ScanOptions) SetCount(count int64) *ScanOptions {
+	that.Count = count
+	return that
+}
+
+func (that *ScanOptions) SetBatchSize(size int) *ScanOptions {
+	that.BatchSize = size
+	return that
+}
+
+// ScanKeys 流式遍历匹配的 key, 集群模式下依次遍历所有主节点. 出错时产出一次错误后结束.
+// 与 Scan 不同, 不会把所有 key 放入内存; SCAN 的语义保证遍历期间一直存在的 key 至少被返回一次, 但可能重复
+//
+// 示例:
+//
+//	for key, err := range kredis.ScanKeys(ctx, client, kredis.NewScanOptions("device:*").SetType("hash")) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func ScanKeys(ctx context.Context, client KRedisClient, opts *ScanOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		scanBatches(ctx, client.UniversalClient(), opts, func(_ redisHd.UniversalClient, keys []string, err error) bool {
+			if err != nil {
+				yield("", err)
+				return false
+			}
+			for _, key := range keys {
+				if !yield(key, nil) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+// ScanRecords 流式遍历匹配的 key 并通过 pipeline 批量获取类型, PTTL 与 DUMP 数据, 扫描后被删除的 key 被忽略
+func ScanRecords(ctx context.Context, client KRedisClient, opts *ScanOptions) iter.Seq2[*RedisRecord, error] {
+	return func(yield func(*RedisRecord, error) bool) {
+		scanBatches(ctx, client.UniversalClient(), opts, func(node redisHd.UniversalClient, keys []string, err error) bool {
+			if err == nil {
+				var records []*RedisRecord
+				if records, _, err = dumpBatch(ctx, node, keys, nil); err == nil {
+					for _, record := range records {
+						if !yield(record, nil) {
+							return false
+						}
+					}
+					return true
+				}
+			}
+			yield(nil, err)
+			return false
+		})
+	}
+}
+
+// DeleteMatch 分批删除匹配的 key, 使用 UNLINK 在后台释放内存, 返回删除的数量
+func DeleteMatch(ctx context.Context, client KRedisClient, opts *ScanOptions) (int64, error) {
+	deleted := int64(0)
+	var lastErr error
+	scanBatches(ctx, client.UniversalClient(), opts, func(node redisHd.UniversalClient, keys []string, err error) bool {
+		if err != nil {
+			lastErr = err
+			return false
+		}
+
+		// 集群模式下同一节点上的 key 也可能位于不同的槽, 逐个 UNLINK
+		cmds := make([]*redisHd.IntCmd, 0, len(keys))
+		_, err = node.Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+			for _, key := range keys {
+				cmds = append(cmds, pipe.Unlink(ctx, key))
+			}
+			return nil
+		})
+		for _, cmd := range cmds {
+			deleted += cmd.Val()
+		}
+		if err != nil {
+			lastErr = err
+			return false
+		}
+		return true
+	})
+	return deleted, lastErr
+}
+
+// scanBatches 扫描所有主节点, 每凑满 BatchSize 个 key 调用一次 fn, fn 返回 false 时停止; 出错时以 err 调用 fn 后停止
+func scanBatches(ctx context.Context, client redisHd.UniversalClient, opts *ScanOptions, fn func(node redisHd.UniversalClient, keys []string, err error) bool) {
+	nodes, err := masterNodes(ctx, client)
+	if err != nil {
+		fn(nil, nil, err)
+		return
+	}
+
+	batchSize := max(opts.BatchSize, 1)
+	for _, node := range nodes {
+		batch := make([]string, 0, batchSize)
+		var cursor uint64
+		for {
+			var keys []string
+			var err error
+			if opts.Type != "" {
+				keys, cursor, err = node.ScanType(ctx, cursor, opts.Match, max(opts.Count, 1), opts.Type).Result()
+			} else {
+				keys, cursor, err = node.Scan(ctx, cursor, opts.Match, max(opts.Count, 1)).Result()
+			}
+			if err != nil {
+				fn(node, nil, err)
+				return
+			}
+
+			batch = append(batch, keys...)
+			for len(batch) >= batchSize {
+				if !fn(node, batch[:batchSize], nil) {
+					return
+				}
+				batch = append(batch[:0], batch[batchSize:]...)
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+		if len(batch) > 0 && !fn(node, batch, nil) {
+			return
+		}
+	}
+}
+
+// masterNodes 返回需要扫描的节点, 集群模式下为所有主节点, 否则为客户端本身
+func masterNodes(ctx context.Context, client redisHd.UniversalClient) ([]redisHd.UniversalClient, error) {
+	cluster, ok := client.(*redisHd.ClusterClient)
+	if !ok {
+		return []redisHd.UniversalClient{client}, nil
+	}
+
+	var mu sync.Mutex
+	nodes := make([]redisHd.UniversalClient, 0, 8)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redisHd.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes = append(nodes, node)
+		return nil
+	})
+	return nodes, err
+}
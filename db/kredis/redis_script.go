@@ -0,0 +1,267 @@
+package kredis
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+var ErrScriptNotFound = errors.New("kredis: script not registered")
+
+// Script Lua 脚本, 通过 EVALSHA 执行, 服务端没有缓存时(NOSCRIPT)自动退回 EVAL, EVAL 同时会把脚本缓存到该节点.
+// 集群模式下按 KEYS 所在的槽路由, 脚本中访问的所有 key 都必须通过 KEYS 传入且位于同一个槽
+type Script struct {
+	name string
+	src  string
+	hash string
+}
+
+// NewScript 创建脚本, name 仅用于注册与日志
+func NewScript(name string, src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{name: name, src: src, hash: hex.EncodeToString(sum[:])}
+}
+
+func (that *Script) Name() string   { return that.name }
+func (that *Script) Source() string { return that.src }
+
+// Hash 返回脚本的 SHA1, 即 EVALSHA 使用的标识
+func (that *Script) Hash() string { return that.hash }
+
+// Load 将脚本缓存到服务端, 集群模式下缓存到所有节点
+func (that *Script) Load(ctx context.Context, client KRedisClient) error {
+	return client.UniversalClient().ScriptLoad(ctx, that.src).Err()
+}
+
+// Run 执行脚本
+func (that *Script) Run(ctx context.Context, client KRedisClient, keys []string, args ...any) *redisHd.Cmd {
+	universal := client.UniversalClient()
+	cmd := universal.EvalSha(ctx, that.hash, keys, args...)
+	if isNoScript(cmd.Err()) {
+		return universal.Eval(ctx, that.src, keys, args...)
+	}
+	return cmd
+}
+
+// RunRO 以只读方式执行脚本(EVALSHA_RO), 可以路由到从节点, 需要 Redis 7.0+
+func (that *Script) RunRO(ctx context.Context, client KRedisClient, keys []string, args ...any) *redisHd.Cmd {
+	universal := client.UniversalClient()
+	cmd := universal.EvalShaRO(ctx, that.hash, keys, args...)
+	if isNoScript(cmd.Err()) {
+		return universal.EvalRO(ctx, that.src, keys, args...)
+	}
+	return cmd
+}
+
+func isNoScript(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
+
+///////////////////////////////////////////////////////////////
+
+// FunctionLibrary Redis 7 函数库, 代码首行形如 `#!lua name=mylib`
+type FunctionLibrary struct {
+	Name string
+	Code string
+}
+
+// NewFunctionLibrary 解析代码首行中的库名
+func NewFunctionLibrary(code string) (*FunctionLibrary, error) {
+	name := parseLibraryName(code)
+	if name == "" {
+		return nil, fmt.Errorf("kredis: function library must start with '#!lua name=<library>'")
+	}
+	return &FunctionLibrary{Name: name, Code: code}, nil
+}
+
+// Load 通过 FUNCTION LOAD REPLACE 加载函数库, 集群模式下加载到所有主节点(从节点通过复制获得)
+func (that *FunctionLibrary) Load(ctx context.Context, client KRedisClient) error {
+	nodes, err := masterNodes(ctx, client.UniversalClient())
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if err := node.FunctionLoadReplace(ctx, that.Code).Err(); err != nil {
+			return fmt.Errorf("kredis: load function library %s: %w", that.Name, err)
+		}
+	}
+	return nil
+}
+
+// FCall 调用函数库中的函数, 需要 Redis 7.0+
+func FCall(ctx context.Context, client KRedisClient, function string, keys []string, args ...any) *redisHd.Cmd {
+	return client.UniversalClient().FCall(ctx, function, keys, args...)
+}
+
+// FCallRO 以只读方式调用函数, 函数需要声明 no-writes 标志
+func FCallRO(ctx context.Context, client KRedisClient, function string, keys []string, args ...any) *redisHd.Cmd {
+	return client.UniversalClient().FCallRO(ctx, function, keys, args...)
+}
+
+// parseLibraryName 从 `#!lua name=mylib` 中取出库名
+func parseLibraryName(code string) string {
+	line, _, _ := strings.Cut(code, "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	for _, field := range strings.Fields(line[2:]) {
+		if name, ok := strings.CutPrefix(field, "name="); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+///////////////////////////////////////////////////////////////
+
+// ScriptRegistry 脚本与函数库的注册表, 通常在启动时从 embed.FS 加载, 连接建立后调用 LoadAll 预先缓存.
+//
+// 示例:
+//
+//	//go:embed lua/*.lua
+//	var luaFS embed.FS
+//
+//	registry := kredis.NewScriptRegistry()
+//	if err := registry.RegisterFS(luaFS, "lua/*.lua"); err != nil {
+//		return err
+//	}
+//	registry.LoadAll(ctx, client)
+//	result, err := registry.Run(ctx, client, "incr_if_below", []string{"counter"}, 100).Int64()
+type ScriptRegistry struct {
+	mu        sync.RWMutex
+	scripts   map[string]*Script
+	libraries map[string]*FunctionLibrary
+}
+
+func NewScriptRegistry() *ScriptRegistry {
+	return &ScriptRegistry{
+		scripts:   make(map[string]*Script),
+		libraries: make(map[string]*FunctionLibrary),
+	}
+}
+
+// Register 注册脚本, 同名脚本被替换
+func (that *ScriptRegistry) Register(name string, src string) *Script {
+	script := NewScript(name, src)
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.scripts[name] = script
+	return script
+}
+
+// RegisterLibrary 注册 Redis 7 函数库, 同名函数库被替换
+func (that *ScriptRegistry) RegisterLibrary(code string) (*FunctionLibrary, error) {
+	library, err := NewFunctionLibrary(code)
+	if err != nil {
+		return nil, err
+	}
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.libraries[library.Name] = library
+	return library, nil
+}
+
+// RegisterFS 注册 fsys 中匹配 pattern 的文件.
+// 首行为 `#!lua name=...` 的文件作为函数库注册, 其余文件作为脚本注册, 脚本名为去掉扩展名的文件名
+func (that *ScriptRegistry) RegisterFS(fsys fs.FS, pattern string) error {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		code := string(content)
+		if strings.HasPrefix(code, "#!") {
+			if _, err := that.RegisterLibrary(code); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			continue
+		}
+		name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+		that.Register(name, code)
+	}
+	return nil
+}
+
+// Get 返回已注册的脚本, 不存在时返回 nil
+func (that *ScriptRegistry) Get(name string) *Script {
+	that.mu.RLock()
+	defer that.mu.RUnlock()
+	return that.scripts[name]
+}
+
+// Names 返回已注册的脚本名
+func (that *ScriptRegistry) Names() []string {
+	that.mu.RLock()
+	defer that.mu.RUnlock()
+	names := make([]string, 0, len(that.scripts))
+	for name := range that.scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Libraries 返回已注册的函数库名
+func (that *ScriptRegistry) Libraries() []string {
+	that.mu.RLock()
+	defer that.mu.RUnlock()
+	names := make([]string, 0, len(that.libraries))
+	for name := range that.libraries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadAll 将所有脚本缓存到服务端, 并加载所有函数库; 集群模式下作用于所有节点.
+// 脚本未预先加载时 Run 也能正常执行, 预先加载只是避免第一次调用时多一次往返
+func (that *ScriptRegistry) LoadAll(ctx context.Context, client KRedisClient) error {
+	that.mu.RLock()
+	scripts := make([]*Script, 0, len(that.scripts))
+	for _, script := range that.scripts {
+		scripts = append(scripts, script)
+	}
+	libraries := make([]*FunctionLibrary, 0, len(that.libraries))
+	for _, library := range that.libraries {
+		libraries = append(libraries, library)
+	}
+	that.mu.RUnlock()
+
+	errs := make([]error, 0)
+	for _, script := range scripts {
+		if err := script.Load(ctx, client); err != nil {
+			errs = append(errs, fmt.Errorf("kredis: load script %s: %w", script.name, err))
+		}
+	}
+	for _, library := range libraries {
+		if err := library.Load(ctx, client); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run 执行已注册的脚本, 脚本不存在时返回 ErrScriptNotFound
+func (that *ScriptRegistry) Run(ctx context.Context, client KRedisClient, name string, keys []string, args ...any) *redisHd.Cmd {
+	script := that.Get(name)
+	if script == nil {
+		cmd := redisHd.NewCmd(ctx)
+		cmd.SetErr(fmt.Errorf("%w: %s", ErrScriptNotFound, name))
+		return cmd
+	}
+	return script.Run(ctx, client, keys, args...)
+}
@@ -0,0 +1,367 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+const (
+	shardedHealthCheck         = 30 * time.Second // 没有消息时发送 PING 的间隔
+	shardedResubscribeInterval = time.Second      // 断开或槽迁移后重新订阅的间隔
+	clusterSlots               = 16384
+)
+
+// SPublish 向分片频道发布消息(SPUBLISH), 集群模式下只发送到频道所在槽的主节点, 需要 Redis 7.0+
+func (that *KRedis) SPublish(channel string, payload any) error {
+	return that.SPublishWithCtx(that.ctx.Context(), channel, payload)
+}
+
+// SPublishWithCtx 同 SPublish, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SPublishWithCtx(ctx context.Context, channel string, payload any) error {
+	return that.Client.SPublish(ctx, channel, payload).Err()
+}
+
+// SyncSSubscribe 订阅分片频道(SSUBSCRIBE), 阻塞直到客户端停止, 参见 shardedSubscribe
+func (that *KRedis) SyncSSubscribe(callback func(err error, topic string, payload any), channels ...string) {
+	shardedSubscribe(that.ctx.Context(), that.Client, callback, channels)
+}
+
+// SSubscribe 异步版本 SyncSSubscribe
+func (that *KRedis) SSubscribe(callback func(err error, topic string, payload any), channels ...string) {
+	go that.SyncSSubscribe(callback, channels...)
+}
+
+// SPublish 向分片频道发布消息(SPUBLISH), 只发送到频道所在槽的主节点, 不会像 PUBLISH 一样广播到整个集群, 需要 Redis 7.0+
+func (that *KRedisCluster) SPublish(channel string, payload any) error {
+	return that.SPublishWithCtx(that.ctx.Context(), channel, payload)
+}
+
+// SPublishWithCtx 同 SPublish, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) SPublishWithCtx(ctx context.Context, channel string, payload any) error {
+	return that.Client.SPublish(ctx, channel, payload).Err()
+}
+
+// SyncSSubscribe 订阅分片频道(SSUBSCRIBE), 阻塞直到客户端停止, 参见 shardedSubscribe
+func (that *KRedisCluster) SyncSSubscribe(callback func(err error, topic string, payload any), channels ...string) {
+	shardedSubscribe(that.ctx.Context(), that.Client, callback, channels)
+}
+
+// SSubscribe 异步版本 SyncSSubscribe
+func (that *KRedisCluster) SSubscribe(callback func(err error, topic string, payload any), channels ...string) {
+	go that.SyncSSubscribe(callback, channels...)
+}
+
+// shardedSubscribe 按主节点分组订阅分片频道, 每个主节点只使用一个连接, 同一连接上按槽分别发送 SSUBSCRIBE.
+//   - 收到消息时调用 callback(nil, channel, payload), 不同节点的消息可能并发调用 callback
+//   - 槽迁移(服务端发送 sunsubscribe 或返回 MOVED)时调用 callback(err, "", nil), 只把受影响的频道按新的槽分布重新分组订阅
+//   - 连接断开时调用 callback(err, "", nil), 该节点的频道在间隔后重新分组订阅, 集群模式下先刷新槽分布
+//   - ctx 取消时所有订阅退出, 最后调用一次 callback(ErrUnSubscribe, "", nil)
+func shardedSubscribe(ctx context.Context, client redisHd.UniversalClient, callback func(err error, topic string, payload any), channels []string) {
+	s := &shardedSubscriber{
+		ctx:      ctx,
+		client:   client,
+		callback: callback,
+		nodes:    make(map[string]*shardNode),
+		migrated: make(chan []string),
+	}
+
+	var retry <-chan time.Time
+	pending := s.assign(channels)
+	if len(pending) > 0 {
+		retry = time.After(shardedResubscribeInterval)
+	}
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case moved := <-s.migrated:
+			if len(pending) == 0 { // 等待一个间隔, 合并同一次迁移或故障转移中的其他频道
+				retry = time.After(shardedResubscribeInterval)
+			}
+			pending = append(pending, moved...)
+		case <-retry:
+			retry = nil
+			if cluster, ok := client.(*redisHd.ClusterClient); ok { // MOVED 或槽迁移后使用新的槽分布
+				cluster.ReloadState(ctx)
+			}
+			if pending = s.assign(pending); len(pending) > 0 {
+				retry = time.After(shardedResubscribeInterval)
+			}
+		}
+	}
+	s.wg.Wait()
+	callback(ErrUnSubscribe, "", nil)
+}
+
+var errSlotMigrated = errors.New("kredis: shard channel slot migrated")
+
+// shardedSubscriber 分片订阅的协调者, 维护主节点地址到订阅连接的映射
+type shardedSubscriber struct {
+	ctx      context.Context
+	client   redisHd.UniversalClient
+	callback func(err error, topic string, payload any)
+
+	mu       sync.Mutex
+	nodes    map[string]*shardNode
+	migrated chan []string // 需要重新分组的频道
+	wg       sync.WaitGroup
+}
+
+// shardNode 一个主节点上的订阅连接
+type shardNode struct {
+	mu       sync.Mutex
+	pubsub   *redisHd.PubSub
+	channels map[string]struct{}
+	closed   bool
+}
+
+// assign 按主节点分组订阅频道, 已有该节点的连接时追加订阅, 返回无法确定所在节点的频道
+func (that *shardedSubscriber) assign(channels []string) []string {
+	groups, failed, err := that.groupByNode(channels)
+	if err != nil {
+		that.callback(err, "", nil)
+	}
+
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for addr, group := range groups {
+		if node := that.nodes[addr]; node != nil && node.add(that.ctx, that.client, group) {
+			continue
+		}
+		node := &shardNode{channels: make(map[string]struct{}, len(group))}
+		for _, channel := range group {
+			node.channels[channel] = struct{}{}
+		}
+		that.nodes[addr] = node
+		that.wg.Add(1)
+		go that.serve(addr, node)
+	}
+	return failed
+}
+
+// groupByNode 按频道所在槽的主节点地址分组, 非集群模式只有一个分组
+func (that *shardedSubscriber) groupByNode(channels []string) (map[string][]string, []string, error) {
+	cluster, ok := that.client.(*redisHd.ClusterClient)
+	if !ok {
+		return map[string][]string{"": channels}, nil, nil
+	}
+
+	var (
+		groups  = make(map[string][]string)
+		failed  []string
+		lastErr error
+	)
+	for _, channel := range channels {
+		master, err := cluster.MasterForKey(that.ctx, channel)
+		if err != nil {
+			failed, lastErr = append(failed, channel), err
+			continue
+		}
+		addr := master.Options().Addr
+		groups[addr] = append(groups[addr], channel)
+	}
+	return groups, failed, lastErr
+}
+
+// serve 订阅节点上的频道并接收消息, 连接出错时把该节点的全部频道交给协调者重新分组
+func (that *shardedSubscriber) serve(addr string, node *shardNode) {
+	defer that.wg.Done()
+
+	node.mu.Lock()
+	node.pubsub = that.client.SSubscribe(that.ctx)
+	err := node.subscribe(that.ctx, that.client, setKeys(node.channels))
+	node.mu.Unlock()
+	stop := context.AfterFunc(that.ctx, func() { node.pubsub.Close() }) // 使阻塞中的接收立即返回
+
+	if err == nil {
+		err = that.receive(node)
+	}
+	stop()
+	node.pubsub.Close()
+
+	that.mu.Lock()
+	if that.nodes[addr] == node {
+		delete(that.nodes, addr)
+	}
+	that.mu.Unlock()
+	channels := node.close()
+	if that.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		that.callback(err, "", nil)
+	}
+	that.requeue(channels)
+}
+
+// receive 接收消息直到连接出错或节点上已没有订阅的频道
+func (that *shardedSubscriber) receive(node *shardNode) error {
+	awaitingPong := false
+	for {
+		msg, err := node.pubsub.ReceiveTimeout(that.ctx, shardedHealthCheck)
+		if err != nil {
+			var netErr net.Error
+			if that.ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+				if awaitingPong {
+					return ErrPingTimeout
+				}
+				awaitingPong = true
+				if err = node.pubsub.Ping(that.ctx); err == nil {
+					continue
+				}
+			}
+			if slot, ok := movedSlot(err); ok { // 某个槽的 SSUBSCRIBE 被重定向, 只重新分组该槽的频道
+				that.callback(err, "", nil)
+				if that.requeue(node.removeSlot(slot)); node.empty() {
+					return nil
+				}
+				continue
+			}
+			return err
+		}
+
+		awaitingPong = false
+		switch m := msg.(type) {
+		case *redisHd.Message:
+			that.callback(nil, m.Channel, m.Payload)
+		case *redisHd.Subscription:
+			// 不会主动取消订阅, 收到 sunsubscribe 说明频道所在的槽已迁移到其他节点
+			if m.Kind == "sunsubscribe" {
+				that.callback(errSlotMigrated, "", nil)
+				if that.requeue(node.remove(m.Channel)); node.empty() {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// requeue 把频道交给协调者重新分组订阅
+func (that *shardedSubscriber) requeue(channels []string) {
+	if len(channels) == 0 {
+		return
+	}
+	select {
+	case that.migrated <- channels:
+	case <-that.ctx.Done():
+	}
+}
+
+// add 在已有连接上追加订阅, 连接已关闭时返回 false
+func (that *shardNode) add(ctx context.Context, client redisHd.UniversalClient, channels []string) bool {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if that.closed {
+		return false
+	}
+	for _, channel := range channels {
+		that.channels[channel] = struct{}{}
+	}
+	if that.pubsub != nil { // 写入失败时连接随之断开, 频道会在 serve 退出时重新分组
+		_ = that.subscribe(ctx, client, channels)
+	}
+	return true
+}
+
+// subscribe 按槽分别发送 SSUBSCRIBE, 集群不允许一条 SSUBSCRIBE 包含不同槽的频道
+func (that *shardNode) subscribe(ctx context.Context, client redisHd.UniversalClient, channels []string) error {
+	if _, ok := client.(*redisHd.ClusterClient); !ok {
+		return that.pubsub.SSubscribe(ctx, channels...)
+	}
+	slots := make(map[int][]string)
+	for _, channel := range channels {
+		slot := KeySlot(channel)
+		slots[slot] = append(slots[slot], channel)
+	}
+	for _, group := range slots {
+		if err := that.pubsub.SSubscribe(ctx, group...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove 移除已迁移的频道
+func (that *shardNode) remove(channel string) []string {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if _, ok := that.channels[channel]; !ok {
+		return nil
+	}
+	delete(that.channels, channel)
+	return []string{channel}
+}
+
+// removeSlot 移除指定槽的频道
+func (that *shardNode) removeSlot(slot int) []string {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	var removed []string
+	for channel := range that.channels {
+		if KeySlot(channel) == slot {
+			removed = append(removed, channel)
+			delete(that.channels, channel)
+		}
+	}
+	return removed
+}
+
+func (that *shardNode) empty() bool {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return len(that.channels) == 0
+}
+
+// close 标记连接已关闭, 之后的 add 会创建新的连接, 返回仍在该节点上的频道
+func (that *shardNode) close() []string {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.closed = true
+	return setKeys(that.channels)
+}
+
+// movedSlot 解析 "MOVED <slot> <addr>" 错误中的槽
+func movedSlot(err error) (int, bool) {
+	var redisErr redisHd.Error
+	if !errors.As(err, &redisErr) {
+		return 0, false
+	}
+	fields := strings.Fields(redisErr.Error())
+	if len(fields) != 3 || fields[0] != "MOVED" {
+		return 0, false
+	}
+	slot, err := strconv.Atoi(fields[1])
+	return slot, err == nil
+}
+
+// KeySlot 计算 key 所在的集群槽, 与 CLUSTER KEYSLOT 相同: 存在非空 hash tag 时只计算 hash tag
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % clusterSlots
+}
+
+// crc16 CRC16-CCITT (XMODEM), Redis 集群使用的槽位校验算法
+func crc16(s string) uint16 {
+	crc := uint16(0)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
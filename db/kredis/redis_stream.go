@@ -0,0 +1,376 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// StreamMessage Stream 中的一条消息
+type StreamMessage struct {
+	Stream string         // 所属 Stream
+	ID     string         // 消息ID, 如 1700000000000-0
+	Values map[string]any // 消息内容
+}
+
+// StreamHandler 处理一条消息, 返回 nil 时消息被确认(XACK), 否则保留在待处理列表中, 超时后被重新认领
+type StreamHandler func(msg *StreamMessage) error
+
+// XAdd 向 Stream 追加消息, maxLen > 0 时按近似方式(MAXLEN ~)裁剪 Stream 长度, 返回消息ID
+func (that *KRedis) XAdd(stream string, maxLen int64, values map[string]any) (string, error) {
+	return that.XAddWithCtx(that.ctx.Context(), stream, maxLen, values)
+}
+
+// XAddWithCtx 同 XAdd, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) XAddWithCtx(ctx context.Context, stream string, maxLen int64, values map[string]any) (string, error) {
+	return streamAdd(ctx, that.Client, stream, maxLen, values)
+}
+
+// XLen 返回 Stream 中的消息数量
+func (that *KRedis) XLen(stream string) (int64, error) {
+	return that.XLenWithCtx(that.ctx.Context(), stream)
+}
+
+// XLenWithCtx 同 XLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) XLenWithCtx(ctx context.Context, stream string) (int64, error) {
+	return that.Client.XLen(ctx, stream).Result()
+}
+
+// XGroupCreate 创建消费组, Stream 不存在时自动创建, 消费组已存在时不返回错误.
+// start 为消费组的起始位置, "0" 表示从头消费, "$" 表示只消费之后的新消息
+func (that *KRedis) XGroupCreate(stream string, group string, start string) error {
+	return that.XGroupCreateWithCtx(that.ctx.Context(), stream, group, start)
+}
+
+// XGroupCreateWithCtx 同 XGroupCreate, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) XGroupCreateWithCtx(ctx context.Context, stream string, group string, start string) error {
+	return streamGroupCreate(ctx, that.Client, stream, group, start)
+}
+
+// XAdd 向 Stream 追加消息, maxLen > 0 时按近似方式(MAXLEN ~)裁剪 Stream 长度, 返回消息ID
+func (that *KRedisCluster) XAdd(stream string, maxLen int64, values map[string]any) (string, error) {
+	return that.XAddWithCtx(that.ctx.Context(), stream, maxLen, values)
+}
+
+// XAddWithCtx 同 XAdd, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) XAddWithCtx(ctx context.Context, stream string, maxLen int64, values map[string]any) (string, error) {
+	return streamAdd(ctx, that.Client, stream, maxLen, values)
+}
+
+// XLen 返回 Stream 中的消息数量
+func (that *KRedisCluster) XLen(stream string) (int64, error) {
+	return that.XLenWithCtx(that.ctx.Context(), stream)
+}
+
+// XLenWithCtx 同 XLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) XLenWithCtx(ctx context.Context, stream string) (int64, error) {
+	return that.Client.XLen(ctx, stream).Result()
+}
+
+// XGroupCreate 创建消费组, Stream 不存在时自动创建, 消费组已存在时不返回错误.
+// start 为消费组的起始位置, "0" 表示从头消费, "$" 表示只消费之后的新消息
+func (that *KRedisCluster) XGroupCreate(stream string, group string, start string) error {
+	return that.XGroupCreateWithCtx(that.ctx.Context(), stream, group, start)
+}
+
+// XGroupCreateWithCtx 同 XGroupCreate, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) XGroupCreateWithCtx(ctx context.Context, stream string, group string, start string) error {
+	return streamGroupCreate(ctx, that.Client, stream, group, start)
+}
+
+func streamAdd(ctx context.Context, client redisHd.UniversalClient, stream string, maxLen int64, values map[string]any) (string, error) {
+	args := &redisHd.XAddArgs{Stream: stream, Values: values}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true // 近似裁剪性能远高于精确裁剪
+	}
+	return client.XAdd(ctx, args).Result()
+}
+
+func streamGroupCreate(ctx context.Context, client redisHd.UniversalClient, stream string, group string, start string) error {
+	if start == "" {
+		start = "0"
+	}
+	err := client.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+///////////////////////////////////////////////////////////////
+
+// StreamWorkerOptions 消费组 worker 参数, 时间单位均为毫秒
+type StreamWorkerOptions struct {
+	Group         string `json:"group"`         // 消费组名称
+	Consumer      string `json:"consumer"`      // 消费者名称, 同一消费组内唯一, 通常使用主机名
+	StartID       string `json:"startId"`       // 消费组不存在时的起始位置, 默认 "0" 从头消费
+	BatchSize     int64  `json:"batchSize"`     // 每次读取的最大消息数量, 默认10
+	Block         int64  `json:"block"`         // 没有新消息时的阻塞等待时间, 默认2000, 也是 Stop 的最长等待时间
+	MinIdle       int64  `json:"minIdle"`       // 待处理消息超过该时间未确认时被重新认领, 默认60000
+	ClaimInterval int64  `json:"claimInterval"` // 重新认领的检查周期, 默认30000
+	RetryInterval int64  `json:"retryInterval"` // 读取失败后的重试间隔, 默认1000
+
+	OnError func(err error) `json:"-"` // 读取, 确认或处理消息失败时的回调, 可用于记录日志
+}
+
+func NewStreamWorkerOptions(group string, consumer string) *StreamWorkerOptions {
+	return &StreamWorkerOptions{
+		Group:         group,
+		Consumer:      consumer,
+		StartID:       "0",
+		BatchSize:     10,
+		Block:         2000,
+		MinIdle:       60000,
+		ClaimInterval: 30000,
+		RetryInterval: 1000,
+	}
+}
+
+// 设置消费组不存在时的起始位置, "0" 表示从头消费, "$" 表示只消费之后的新消息
+func (that *StreamWorkerOptions) SetStartID(id string) *StreamWorkerOptions {
+	that.StartID = id
+	return that
+}
+
+func (that *StreamWorkerOptions) SetBatchSize(size int64) *StreamWorkerOptions {
+	that.BatchSize = size
+	return that
+}
+
+// 设置阻塞等待时间, 单位 毫秒
+func (that *StreamWorkerOptions) SetBlock(block int64) *StreamWorkerOptions {
+	that.Block = block
+	return that
+}
+
+// 设置重新认领的最小空闲时间与检查周期, 单位 毫秒
+func (that *StreamWorkerOptions) SetClaim(minIdle int64, interval int64) *StreamWorkerOptions {
+	that.MinIdle = minIdle
+	that.ClaimInterval = interval
+	return that
+}
+
+// 设置读取失败后的重试间隔, 单位 毫秒
+func (that *StreamWorkerOptions) SetRetryInterval(interval int64) *StreamWorkerOptions {
+	that.RetryInterval = interval
+	return that
+}
+
+func (that *StreamWorkerOptions) SetErrorHandler(fn func(err error)) *StreamWorkerOptions {
+	that.OnError = fn
+	return that
+}
+
+// StreamWorker 消费组 worker.
+// 启动时先处理本消费者崩溃前未确认的消息, 之后通过 XREADGROUP 读取新消息, 处理成功后 XACK;
+// 同时周期性地通过 XAUTOCLAIM 认领其他消费者长时间未确认的消息, 保证消费者下线时消息不丢失
+type StreamWorker struct {
+	client  KRedisClient
+	stream  string
+	opts    *StreamWorkerOptions
+	handler StreamHandler
+
+	ctx  *kcontext.ContextNode
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewStreamWorker 创建消费组 worker, worker 的生命周期绑定到 ctx 下新建的子节点上.
+// opts 会被复制, 之后修改 opts 不影响已创建的 worker; opts 为空时使用默认参数, 未设置的消费组名称使用 stream, 消费者名称使用主机名
+func NewStreamWorker(ctx *kcontext.ContextNode, client KRedisClient, stream string, opts *StreamWorkerOptions, handler StreamHandler) *StreamWorker {
+	options := *NewStreamWorkerOptions("", "")
+	if opts != nil {
+		options = *opts
+	}
+	if options.Group == "" {
+		options.Group = stream
+	}
+	if options.Consumer == "" {
+		options.Consumer = streamConsumerName()
+	}
+	return &StreamWorker{
+		client:  client,
+		stream:  stream,
+		opts:    &options,
+		handler: handler,
+		ctx:     ctx.NewChild("kredis_stream_worker:" + stream),
+	}
+}
+
+// streamConsumerName 默认的消费者名称, 即主机名
+func streamConsumerName() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "kredis"
+}
+
+// Start 创建消费组并在后台开始消费
+func (that *StreamWorker) Start() error {
+	if err := streamGroupCreate(that.ctx.Context(), that.client.UniversalClient(), that.stream, that.opts.Group, that.opts.StartID); err != nil {
+		return err
+	}
+
+	that.wg.Add(1)
+	go func() {
+		defer that.wg.Done()
+		that.run()
+	}()
+	return nil
+}
+
+// Stop 停止消费并等待正在处理的消息完成, 最长等待一个阻塞周期
+func (that *StreamWorker) Stop() {
+	that.once.Do(func() {
+		that.ctx.Cancel()
+		that.wg.Wait()
+		that.ctx.Remove()
+	})
+}
+
+func (that *StreamWorker) run() {
+	ctx := that.ctx.Context()
+	client := that.client.UniversalClient()
+
+	// 从 "0" 开始分批读取本消费者已投递但未确认的消息, 读完后切换为 ">" 读取新消息
+	readID := "0"
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= millisecond(max(that.opts.ClaimInterval, 1)) {
+			that.claim(ctx, client)
+			lastClaim = time.Now()
+		}
+
+		streams, err := client.XReadGroup(ctx, &redisHd.XReadGroupArgs{
+			Group:    that.opts.Group,
+			Consumer: that.opts.Consumer,
+			Streams:  []string{that.stream, readID},
+			Count:    max(that.opts.BatchSize, 1),
+			Block:    millisecond(max(that.opts.Block, 1)),
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redisHd.Nil) {
+				continue // 阻塞超时, 没有新消息
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			that.onError(err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") { // Stream 或消费组被删除后重建
+				streamGroupCreate(ctx, client, that.stream, that.opts.Group, that.opts.StartID)
+			}
+			that.sleep(millisecond(that.opts.RetryInterval))
+			continue
+		}
+
+		count, failed := 0, 0
+		for _, stream := range streams {
+			count += len(stream.Messages)
+			failed += that.handle(ctx, client, stream.Messages)
+			if readID != ">" && len(stream.Messages) > 0 {
+				readID = stream.Messages[len(stream.Messages)-1].ID // 下一批从本批最后一条之后读取
+			}
+		}
+		if readID != ">" && count == 0 {
+			readID = ">" // 待处理消息已经读完
+		}
+		if failed > 0 { // 处理失败时退避, 避免依赖故障时持续空转
+			that.sleep(millisecond(that.opts.RetryInterval))
+		}
+	}
+}
+
+// claim 认领空闲时间超过 MinIdle 的待处理消息并处理
+func (that *StreamWorker) claim(ctx context.Context, client redisHd.UniversalClient) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		messages, next, err := client.XAutoClaim(ctx, &redisHd.XAutoClaimArgs{
+			Stream:   that.stream,
+			Group:    that.opts.Group,
+			Consumer: that.opts.Consumer,
+			MinIdle:  millisecond(that.opts.MinIdle),
+			Start:    start,
+			Count:    max(that.opts.BatchSize, 1),
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, redisHd.Nil) {
+				that.onError(err)
+			}
+			return
+		}
+
+		if that.handle(ctx, client, messages) > 0 {
+			that.sleep(millisecond(that.opts.RetryInterval))
+		}
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// handle 处理并确认消息, 返回处理失败的消息数量
+func (that *StreamWorker) handle(ctx context.Context, client redisHd.UniversalClient, messages []redisHd.XMessage) int {
+	failed := 0
+	for _, message := range messages {
+		if message.Values != nil { // 读取待处理消息时, 已从 Stream 中删除的消息只有 ID, 直接确认
+			msg := &StreamMessage{Stream: that.stream, ID: message.ID, Values: message.Values}
+			if err := that.safeHandle(msg); err != nil {
+				that.onError(err)
+				failed++
+				continue // 不确认, 超过 MinIdle 后被重新认领
+			}
+		}
+
+		// 停止过程中也要完成确认, 避免已处理的消息被重复投递
+		ackCtx := context.WithoutCancel(ctx)
+		if err := client.XAck(ackCtx, that.stream, that.opts.Group, message.ID).Err(); err != nil {
+			that.onError(err)
+		}
+	}
+	return failed
+}
+
+// safeHandle 调用处理函数, 处理函数 panic 时视为处理失败
+func (that *StreamWorker) safeHandle(msg *StreamMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &StreamHandlerPanic{ID: msg.ID, Value: r}
+		}
+	}()
+	return that.handler(msg)
+}
+
+func (that *StreamWorker) onError(err error) {
+	if that.opts.OnError != nil {
+		that.opts.OnError(err)
+	}
+}
+
+func (that *StreamWorker) sleep(d time.Duration) {
+	if d <= 0 {
+		d = time.Second
+	}
+	select {
+	case <-that.ctx.Context().Done():
+	case <-time.After(d):
+	}
+}
+
+// StreamHandlerPanic 处理函数 panic 时传给 OnError 的错误
+type StreamHandlerPanic struct {
+	ID    string // 消息ID
+	Value any    // panic 的值
+}
+
+func (that *StreamHandlerPanic) Error() string {
+	return "stream handler panic on message " + that.ID
+}
@@ -0,0 +1,376 @@
+package kredis
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+var ErrPingTimeout = errors.New("kredis: pubsub ping timeout")
+
+// SubscriptionEventKind 订阅连接状态事件类型
+type SubscriptionEventKind int
+
+const (
+	SubscriptionConnected    SubscriptionEventKind = iota // 首次连接成功
+	SubscriptionDisconnected                              // 连接断开, 之后会自动重连
+	SubscriptionResubscribed                              // 重连成功, 已重新订阅所有频道与模式
+)
+
+func (that SubscriptionEventKind) String() string {
+	switch that {
+	case SubscriptionConnected:
+		return "connected"
+	case SubscriptionDisconnected:
+		return "disconnected"
+	case SubscriptionResubscribed:
+		return "resubscribed"
+	}
+	return "unknown"
+}
+
+// SubscriptionEvent 订阅连接状态事件
+type SubscriptionEvent struct {
+	Kind SubscriptionEventKind
+	Err  error // 断开的原因, 仅 SubscriptionDisconnected 时有值
+}
+
+// QueuePolicy 消息队列满时的处理方式
+type QueuePolicy int
+
+const (
+	QueueBlock      QueuePolicy = iota // 阻塞接收, 消息不丢失, 但处理过慢时 Redis 可能因输出缓冲区超限断开连接
+	QueueDropNewest                    // 丢弃新到达的消息
+	QueueDropOldest                    // 丢弃队列中最早的消息
+)
+
+// SubscriptionMessage 订阅收到的消息
+type SubscriptionMessage struct {
+	Channel string // 消息所在的频道
+	Pattern string // 通过模式订阅收到时为匹配的模式, 否则为空
+	Payload string
+}
+
+// SubscriptionHandler 消息处理函数, 同一个 Subscription 的消息按接收顺序在同一个 goroutine 中依次调用
+type SubscriptionHandler func(msg *SubscriptionMessage)
+
+// SubscriptionOptions 订阅参数, 时间单位均为毫秒
+type SubscriptionOptions struct {
+	QueueSize           int         `json:"queueSize"`           // 接收与处理之间的队列长度, 默认1000
+	Policy              QueuePolicy `json:"policy"`              // 队列满时的处理方式, 默认 QueueBlock
+	HealthCheckInterval int64       `json:"healthCheckInterval"` // 没有消息时发送 PING 的间隔, 连续两个周期没有响应视为断开, 默认10000
+	ReconnectInterval   int64       `json:"reconnectInterval"`   // 断开后的重连间隔, 默认1000
+
+	OnEvent func(event SubscriptionEvent) `json:"-"` // 连接状态变化时的回调, 在接收 goroutine 中调用, 不应阻塞
+}
+
+func NewSubscriptionOptions() *SubscriptionOptions {
+	return &SubscriptionOptions{
+		QueueSize:           1000,
+		Policy:              QueueBlock,
+		HealthCheckInterval: 10000,
+		ReconnectInterval:   1000,
+	}
+}
+
+func (that *SubscriptionOptions) SetQueue(size int, policy QueuePolicy) *SubscriptionOptions {
+	that.QueueSize = size
+	that.Policy = policy
+	return that
+}
+
+// 设置健康检查与重连间隔, 单位 毫秒
+func (that *SubscriptionOptions) SetIntervals(healthCheck int64, reconnect int64) *SubscriptionOptions {
+	that.HealthCheckInterval = healthCheck
+	that.ReconnectInterval = reconnect
+	return that
+}
+
+func (that *SubscriptionOptions) SetEventHandler(fn func(event SubscriptionEvent)) *SubscriptionOptions {
+	that.OnEvent = fn
+	return that
+}
+
+// Subscription 统一的订阅对象, 替代 Subscribe*/PSubscribe* 系列函数.
+//   - 运行期间可以随时增加或取消频道与模式
+//   - 连接状态通过 OnEvent 显式通知, 断开后自动重连并重新订阅
+//   - 接收与处理之间为有界队列, 消息按接收顺序在单个 goroutine 中处理
+//   - 生命周期绑定到 ContextNode, 父节点取消或调用 Stop 时处理完队列中的消息后退出
+type Subscription struct {
+	client  redisHd.UniversalClient
+	opts    *SubscriptionOptions
+	handler SubscriptionHandler
+
+	ctx      *kcontext.ContextNode
+	mu       sync.Mutex
+	pubsub   *redisHd.PubSub
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	queue     chan *SubscriptionMessage
+	dropped   atomic.Uint64
+	wg        sync.WaitGroup
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewSubscription 创建订阅, 调用 Start 后开始接收; 订阅的生命周期绑定到 ctx 下新建的子节点上
+func NewSubscription(ctx *kcontext.ContextNode, client KRedisClient, opts *SubscriptionOptions, handler SubscriptionHandler) *Subscription {
+	return newSubscription(ctx.NewChild("kredis_subscription"), client.UniversalClient(), opts, handler)
+}
+
+func newSubscription(subCtx *kcontext.ContextNode, client redisHd.UniversalClient, opts *SubscriptionOptions, handler SubscriptionHandler) *Subscription {
+	return &Subscription{
+		client:   client,
+		opts:     opts,
+		handler:  handler,
+		ctx:      subCtx,
+		pubsub:   client.Subscribe(subCtx.Context()),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		queue:    make(chan *SubscriptionMessage, max(opts.QueueSize, 1)),
+	}
+}
+
+// Start 开始接收与处理消息, 重复调用无效
+func (that *Subscription) Start() {
+	that.startOnce.Do(func() {
+		that.wg.Add(3)
+		go func() {
+			defer that.wg.Done()
+			that.receive()
+		}()
+		go func() {
+			defer that.wg.Done()
+			for msg := range that.queue {
+				that.handler(msg)
+			}
+		}()
+		go func() { // 父节点取消时关闭连接, 使阻塞中的接收立即返回
+			defer that.wg.Done()
+			<-that.ctx.Context().Done()
+			that.mu.Lock()
+			that.pubsub.Close()
+			that.mu.Unlock()
+		}()
+	})
+}
+
+// Stop 取消订阅, 等待队列中的消息处理完成后返回
+func (that *Subscription) Stop() {
+	that.stopOnce.Do(func() {
+		that.ctx.Cancel()
+		that.startOnce.Do(func() { // 未启动时直接释放资源
+			that.pubsub.Close()
+			close(that.queue)
+		})
+		that.wg.Wait()
+		that.ctx.Remove()
+	})
+}
+
+// Subscribe 增加订阅的频道, 连接不可用时返回错误, 但频道仍被记录并在重连后订阅
+func (that *Subscription) Subscribe(channels ...string) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for _, channel := range channels {
+		that.channels[channel] = struct{}{}
+	}
+	return that.pubsub.Subscribe(that.ctx.Context(), channels...)
+}
+
+// Unsubscribe 取消订阅的频道
+func (that *Subscription) Unsubscribe(channels ...string) error {
+	if len(channels) == 0 { // go-redis 在参数为空时取消所有频道
+		return nil
+	}
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for _, channel := range channels {
+		delete(that.channels, channel)
+	}
+	return that.pubsub.Unsubscribe(that.ctx.Context(), channels...)
+}
+
+// PSubscribe 增加订阅的模式, 如 "device:*"
+func (that *Subscription) PSubscribe(patterns ...string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for _, pattern := range patterns {
+		that.patterns[pattern] = struct{}{}
+	}
+	return that.pubsub.PSubscribe(that.ctx.Context(), patterns...)
+}
+
+// PUnsubscribe 取消订阅的模式
+func (that *Subscription) PUnsubscribe(patterns ...string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for _, pattern := range patterns {
+		delete(that.patterns, pattern)
+	}
+	return that.pubsub.PUnsubscribe(that.ctx.Context(), patterns...)
+}
+
+// Channels 返回当前订阅的频道
+func (that *Subscription) Channels() []string {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return setKeys(that.channels)
+}
+
+// Patterns 返回当前订阅的模式
+func (that *Subscription) Patterns() []string {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return setKeys(that.patterns)
+}
+
+// Dropped 返回因队列已满而丢弃的消息数量
+func (that *Subscription) Dropped() uint64 {
+	return that.dropped.Load()
+}
+
+func (that *Subscription) receive() {
+	defer close(that.queue)
+
+	ctx := that.ctx.Context()
+	healthCheck := millisecond(max(that.opts.HealthCheckInterval, 1))
+	connected, everConnected, awaitingPong := false, false, false
+
+	for ctx.Err() == nil {
+		that.mu.Lock()
+		pubsub := that.pubsub
+		that.mu.Unlock()
+
+		msg, err := pubsub.ReceiveTimeout(ctx, healthCheck)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if !awaitingPong {
+					awaitingPong = true
+					if err = pubsub.Ping(ctx); err == nil {
+						continue
+					}
+				} else {
+					err = ErrPingTimeout
+				}
+			}
+
+			if connected {
+				connected = false
+				that.emit(SubscriptionEvent{Kind: SubscriptionDisconnected, Err: err})
+			}
+			awaitingPong = false
+			that.sleep(millisecond(that.opts.ReconnectInterval))
+			that.reconnect()
+			continue
+		}
+
+		awaitingPong = false
+		if !connected { // 连接后收到的第一个回复(订阅确认或 PONG)说明连接可用
+			connected = true
+			if everConnected {
+				that.emit(SubscriptionEvent{Kind: SubscriptionResubscribed})
+			} else {
+				that.emit(SubscriptionEvent{Kind: SubscriptionConnected})
+			}
+			everConnected = true
+		}
+
+		if m, ok := msg.(*redisHd.Message); ok {
+			that.enqueue(&SubscriptionMessage{Channel: m.Channel, Pattern: m.Pattern, Payload: m.Payload})
+		}
+	}
+}
+
+// reconnect 关闭当前连接, 使用新的连接重新订阅所有频道与模式
+func (that *Subscription) reconnect() {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if that.ctx.Context().Err() != nil {
+		return
+	}
+
+	ctx := that.ctx.Context()
+	that.pubsub.Close()
+	that.pubsub = that.client.Subscribe(ctx)
+	// 订阅失败时连接在下次接收时重建, 重建时 go-redis 会重新发送所有订阅
+	if channels := setKeys(that.channels); len(channels) > 0 {
+		that.pubsub.Subscribe(ctx, channels...)
+	}
+	if patterns := setKeys(that.patterns); len(patterns) > 0 {
+		that.pubsub.PSubscribe(ctx, patterns...)
+	}
+}
+
+func (that *Subscription) enqueue(msg *SubscriptionMessage) {
+	switch that.opts.Policy {
+	case QueueDropNewest:
+		select {
+		case that.queue <- msg:
+		default:
+			that.dropped.Add(1)
+		}
+	case QueueDropOldest:
+		for {
+			select {
+			case that.queue <- msg:
+				return
+			default:
+			}
+			select {
+			case <-that.queue:
+				that.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case that.queue <- msg:
+		case <-that.ctx.Context().Done():
+		}
+	}
+}
+
+func (that *Subscription) emit(event SubscriptionEvent) {
+	if that.opts.OnEvent != nil {
+		that.opts.OnEvent(event)
+	}
+}
+
+func (that *Subscription) sleep(d time.Duration) {
+	if d <= 0 {
+		d = time.Second
+	}
+	select {
+	case <-that.ctx.Context().Done():
+	case <-time.After(d):
+	}
+}
+
+func setKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return keys
+}
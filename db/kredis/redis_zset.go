@@ -0,0 +1,509 @@
+package kredis
+
+import (
+	"context"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// ZAddFlag ZADD 的条件标志, 可以按位组合, 如 ZAddXX | ZAddGT | ZAddCH
+type ZAddFlag int
+
+const (
+	ZAddNX ZAddFlag = 1 << iota // 只添加新成员, 不更新已存在的成员
+	ZAddXX                      // 只更新已存在的成员, 不添加新成员
+	ZAddGT                      // 只在新分数大于当前分数时更新, 新成员总是添加
+	ZAddLT                      // 只在新分数小于当前分数时更新, 新成员总是添加
+	ZAddCH                      // 返回值包含分数发生变化的成员, 而不仅是新增的成员
+)
+
+func zsetAdd(ctx context.Context, client redisHd.UniversalClient, key string, flags ZAddFlag, members []redisHd.Z) (int64, error) {
+	return client.ZAddArgs(ctx, key, redisHd.ZAddArgs{
+		NX:      flags&ZAddNX != 0,
+		XX:      flags&ZAddXX != 0,
+		GT:      flags&ZAddGT != 0,
+		LT:      flags&ZAddLT != 0,
+		Ch:      flags&ZAddCH != 0,
+		Members: members,
+	}).Result()
+}
+
+// ZAdd 添加成员或更新已存在成员的分数, 返回新增成员数量
+func (that *KRedis) ZAdd(key string, members ...redisHd.Z) (int64, error) {
+	return that.ZAddWithCtx(that.ctx.Context(), key, members...)
+}
+
+// ZAddWithCtx 同 ZAdd, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZAddWithCtx(ctx context.Context, key string, members ...redisHd.Z) (int64, error) {
+	return that.Client.ZAdd(ctx, key, members...).Result()
+}
+
+// ZAddFlags 按 flags 指定的 NX/XX/GT/LT/CH 条件执行 ZADD, 设置 ZAddCH 时返回新增与分数变化的成员数量
+func (that *KRedis) ZAddFlags(key string, flags ZAddFlag, members ...redisHd.Z) (int64, error) {
+	return that.ZAddFlagsWithCtx(that.ctx.Context(), key, flags, members...)
+}
+
+// ZAddFlagsWithCtx 同 ZAddFlags, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZAddFlagsWithCtx(ctx context.Context, key string, flags ZAddFlag, members ...redisHd.Z) (int64, error) {
+	return zsetAdd(ctx, that.Client, key, flags, members)
+}
+
+// ZIncrBy 为成员的分数增加 increment, 返回新的分数
+func (that *KRedis) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return that.ZIncrByWithCtx(that.ctx.Context(), key, increment, member)
+}
+
+// ZIncrByWithCtx 同 ZIncrBy, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZIncrByWithCtx(ctx context.Context, key string, increment float64, member string) (float64, error) {
+	return that.Client.ZIncrBy(ctx, key, increment, member).Result()
+}
+
+// ZRem 删除成员, 返回实际删除的数量
+func (that *KRedis) ZRem(key string, members ...any) (int64, error) {
+	return that.ZRemWithCtx(that.ctx.Context(), key, members...)
+}
+
+// ZRemWithCtx 同 ZRem, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRemWithCtx(ctx context.Context, key string, members ...any) (int64, error) {
+	return that.Client.ZRem(ctx, key, members...).Result()
+}
+
+// ZRemRangeByScore 删除分数在 [min, max] 区间内的成员, min/max 支持 `(` 开区间与 -inf/+inf
+func (that *KRedis) ZRemRangeByScore(key string, min string, max string) (int64, error) {
+	return that.ZRemRangeByScoreWithCtx(that.ctx.Context(), key, min, max)
+}
+
+// ZRemRangeByScoreWithCtx 同 ZRemRangeByScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRemRangeByScoreWithCtx(ctx context.Context, key string, min string, max string) (int64, error) {
+	return that.Client.ZRemRangeByScore(ctx, key, min, max).Result()
+}
+
+// ZRemRangeByRank 删除排名在 [start, stop] 区间内的成员
+func (that *KRedis) ZRemRangeByRank(key string, start int64, stop int64) (int64, error) {
+	return that.ZRemRangeByRankWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRemRangeByRankWithCtx 同 ZRemRangeByRank, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRemRangeByRankWithCtx(ctx context.Context, key string, start int64, stop int64) (int64, error) {
+	return that.Client.ZRemRangeByRank(ctx, key, start, stop).Result()
+}
+
+// ZScore 返回成员的分数, 成员不存在时返回 redis.Nil
+func (that *KRedis) ZScore(key string, member string) (float64, error) {
+	return that.ZScoreWithCtx(that.ctx.Context(), key, member)
+}
+
+// ZScoreWithCtx 同 ZScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZScoreWithCtx(ctx context.Context, key string, member string) (float64, error) {
+	return that.Client.ZScore(ctx, key, member).Result()
+}
+
+// ZMScore 批量返回成员的分数, 不存在的成员分数为 0
+func (that *KRedis) ZMScore(key string, members ...string) ([]float64, error) {
+	return that.ZMScoreWithCtx(that.ctx.Context(), key, members...)
+}
+
+// ZMScoreWithCtx 同 ZMScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZMScoreWithCtx(ctx context.Context, key string, members ...string) ([]float64, error) {
+	return that.Client.ZMScore(ctx, key, members...).Result()
+}
+
+// ZRank 返回成员按分数从小到大的排名(从0开始), 成员不存在时返回 redis.Nil
+func (that *KRedis) ZRank(key string, member string) (int64, error) {
+	return that.ZRankWithCtx(that.ctx.Context(), key, member)
+}
+
+// ZRankWithCtx 同 ZRank, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRankWithCtx(ctx context.Context, key string, member string) (int64, error) {
+	return that.Client.ZRank(ctx, key, member).Result()
+}
+
+// ZRevRank 返回成员按分数从大到小的排名(从0开始), 成员不存在时返回 redis.Nil
+func (that *KRedis) ZRevRank(key string, member string) (int64, error) {
+	return that.ZRevRankWithCtx(that.ctx.Context(), key, member)
+}
+
+// ZRevRankWithCtx 同 ZRevRank, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRevRankWithCtx(ctx context.Context, key string, member string) (int64, error) {
+	return that.Client.ZRevRank(ctx, key, member).Result()
+}
+
+// ZCard 返回成员数量
+func (that *KRedis) ZCard(key string) (int64, error) {
+	return that.ZCardWithCtx(that.ctx.Context(), key)
+}
+
+// ZCardWithCtx 同 ZCard, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZCardWithCtx(ctx context.Context, key string) (int64, error) {
+	return that.Client.ZCard(ctx, key).Result()
+}
+
+// ZCount 返回分数在 [min, max] 区间内的成员数量
+func (that *KRedis) ZCount(key string, min string, max string) (int64, error) {
+	return that.ZCountWithCtx(that.ctx.Context(), key, min, max)
+}
+
+// ZCountWithCtx 同 ZCount, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZCountWithCtx(ctx context.Context, key string, min string, max string) (int64, error) {
+	return that.Client.ZCount(ctx, key, min, max).Result()
+}
+
+// ZRange 按排名返回 [start, stop] 区间内的成员, 分数从小到大, 负数表示倒数
+func (that *KRedis) ZRange(key string, start int64, stop int64) ([]string, error) {
+	return that.ZRangeWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRangeWithCtx 同 ZRange, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return that.Client.ZRange(ctx, key, start, stop).Result()
+}
+
+// ZRangeWithScores 同 ZRange, 同时返回分数
+func (that *KRedis) ZRangeWithScores(key string, start int64, stop int64) ([]redisHd.Z, error) {
+	return that.ZRangeWithScoresWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRangeWithScoresWithCtx 同 ZRangeWithScores, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeWithScoresWithCtx(ctx context.Context, key string, start int64, stop int64) ([]redisHd.Z, error) {
+	return that.Client.ZRangeWithScores(ctx, key, start, stop).Result()
+}
+
+// ZRevRange 按排名返回 [start, stop] 区间内的成员, 分数从大到小
+func (that *KRedis) ZRevRange(key string, start int64, stop int64) ([]string, error) {
+	return that.ZRevRangeWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRevRangeWithCtx 同 ZRevRange, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRevRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return that.Client.ZRevRange(ctx, key, start, stop).Result()
+}
+
+// ZRangeByScore 返回分数在 [min, max] 区间内的成员, 分数从小到大; offset, count 均为 0 时不分页
+func (that *KRedis) ZRangeByScore(key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.ZRangeByScoreWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRangeByScoreWithCtx 同 ZRangeByScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeByScoreWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByScore: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRangeByScoreWithScores 同 ZRangeByScore, 同时返回分数
+func (that *KRedis) ZRangeByScoreWithScores(key string, min string, max string, offset int64, count int64) ([]redisHd.Z, error) {
+	return that.ZRangeByScoreWithScoresWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRangeByScoreWithScoresWithCtx 同 ZRangeByScoreWithScores, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeByScoreWithScoresWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]redisHd.Z, error) {
+	return that.Client.ZRangeArgsWithScores(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByScore: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRevRangeByScore 返回分数在 [min, max] 区间内的成员, 分数从大到小; offset, count 均为 0 时不分页
+func (that *KRedis) ZRevRangeByScore(key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.ZRevRangeByScoreWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRevRangeByScoreWithCtx 同 ZRevRangeByScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRevRangeByScoreWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByScore: true, Rev: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRangeByLex 返回字典序在 [min, max] 区间内的成员, 要求所有成员分数相同; min/max 形如 `[a`, `(a`, `-`, `+`
+func (that *KRedis) ZRangeByLex(key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.ZRangeByLexWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRangeByLexWithCtx 同 ZRangeByLex, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeByLexWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByLex: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRangeArgs 按 args 执行 ZRANGE, 支持按排名/分数/字典序, 倒序与分页的任意组合
+func (that *KRedis) ZRangeArgs(args redisHd.ZRangeArgs) ([]string, error) {
+	return that.ZRangeArgsWithCtx(that.ctx.Context(), args)
+}
+
+// ZRangeArgsWithCtx 同 ZRangeArgs, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeArgsWithCtx(ctx context.Context, args redisHd.ZRangeArgs) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, args).Result()
+}
+
+// ZRangeArgsWithScores 同 ZRangeArgs, 同时返回分数
+func (that *KRedis) ZRangeArgsWithScores(args redisHd.ZRangeArgs) ([]redisHd.Z, error) {
+	return that.ZRangeArgsWithScoresWithCtx(that.ctx.Context(), args)
+}
+
+// ZRangeArgsWithScoresWithCtx 同 ZRangeArgsWithScores, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeArgsWithScoresWithCtx(ctx context.Context, args redisHd.ZRangeArgs) ([]redisHd.Z, error) {
+	return that.Client.ZRangeArgsWithScores(ctx, args).Result()
+}
+
+// ZRangeStore 将 ZRANGE 的结果保存到 dst, 返回保存的成员数量; 集群模式下 dst 与 args.Key 需要位于同一个槽
+func (that *KRedis) ZRangeStore(dst string, args redisHd.ZRangeArgs) (int64, error) {
+	return that.ZRangeStoreWithCtx(that.ctx.Context(), dst, args)
+}
+
+// ZRangeStoreWithCtx 同 ZRangeStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZRangeStoreWithCtx(ctx context.Context, dst string, args redisHd.ZRangeArgs) (int64, error) {
+	return that.Client.ZRangeStore(ctx, dst, args).Result()
+}
+
+// ZPopMin 弹出分数最小的 count 个成员
+func (that *KRedis) ZPopMin(key string, count int64) ([]redisHd.Z, error) {
+	return that.ZPopMinWithCtx(that.ctx.Context(), key, count)
+}
+
+// ZPopMinWithCtx 同 ZPopMin, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZPopMinWithCtx(ctx context.Context, key string, count int64) ([]redisHd.Z, error) {
+	return that.Client.ZPopMin(ctx, key, count).Result()
+}
+
+// ZPopMax 弹出分数最大的 count 个成员
+func (that *KRedis) ZPopMax(key string, count int64) ([]redisHd.Z, error) {
+	return that.ZPopMaxWithCtx(that.ctx.Context(), key, count)
+}
+
+// ZPopMaxWithCtx 同 ZPopMax, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ZPopMaxWithCtx(ctx context.Context, key string, count int64) ([]redisHd.Z, error) {
+	return that.Client.ZPopMax(ctx, key, count).Result()
+}
+
+// ZAdd 添加成员或更新已存在成员的分数, 返回新增成员数量
+func (that *KRedisCluster) ZAdd(key string, members ...redisHd.Z) (int64, error) {
+	return that.ZAddWithCtx(that.ctx.Context(), key, members...)
+}
+
+// ZAddWithCtx 同 ZAdd, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZAddWithCtx(ctx context.Context, key string, members ...redisHd.Z) (int64, error) {
+	return that.Client.ZAdd(ctx, key, members...).Result()
+}
+
+// ZAddFlags 按 flags 指定的 NX/XX/GT/LT/CH 条件执行 ZADD, 设置 ZAddCH 时返回新增与分数变化的成员数量
+func (that *KRedisCluster) ZAddFlags(key string, flags ZAddFlag, members ...redisHd.Z) (int64, error) {
+	return that.ZAddFlagsWithCtx(that.ctx.Context(), key, flags, members...)
+}
+
+// ZAddFlagsWithCtx 同 ZAddFlags, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZAddFlagsWithCtx(ctx context.Context, key string, flags ZAddFlag, members ...redisHd.Z) (int64, error) {
+	return zsetAdd(ctx, that.Client, key, flags, members)
+}
+
+// ZIncrBy 为成员的分数增加 increment, 返回新的分数
+func (that *KRedisCluster) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return that.ZIncrByWithCtx(that.ctx.Context(), key, increment, member)
+}
+
+// ZIncrByWithCtx 同 ZIncrBy, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZIncrByWithCtx(ctx context.Context, key string, increment float64, member string) (float64, error) {
+	return that.Client.ZIncrBy(ctx, key, increment, member).Result()
+}
+
+// ZRem 删除成员, 返回实际删除的数量
+func (that *KRedisCluster) ZRem(key string, members ...any) (int64, error) {
+	return that.ZRemWithCtx(that.ctx.Context(), key, members...)
+}
+
+// ZRemWithCtx 同 ZRem, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRemWithCtx(ctx context.Context, key string, members ...any) (int64, error) {
+	return that.Client.ZRem(ctx, key, members...).Result()
+}
+
+// ZRemRangeByScore 删除分数在 [min, max] 区间内的成员, min/max 支持 `(` 开区间与 -inf/+inf
+func (that *KRedisCluster) ZRemRangeByScore(key string, min string, max string) (int64, error) {
+	return that.ZRemRangeByScoreWithCtx(that.ctx.Context(), key, min, max)
+}
+
+// ZRemRangeByScoreWithCtx 同 ZRemRangeByScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRemRangeByScoreWithCtx(ctx context.Context, key string, min string, max string) (int64, error) {
+	return that.Client.ZRemRangeByScore(ctx, key, min, max).Result()
+}
+
+// ZRemRangeByRank 删除排名在 [start, stop] 区间内的成员
+func (that *KRedisCluster) ZRemRangeByRank(key string, start int64, stop int64) (int64, error) {
+	return that.ZRemRangeByRankWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRemRangeByRankWithCtx 同 ZRemRangeByRank, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRemRangeByRankWithCtx(ctx context.Context, key string, start int64, stop int64) (int64, error) {
+	return that.Client.ZRemRangeByRank(ctx, key, start, stop).Result()
+}
+
+// ZScore 返回成员的分数, 成员不存在时返回 redis.Nil
+func (that *KRedisCluster) ZScore(key string, member string) (float64, error) {
+	return that.ZScoreWithCtx(that.ctx.Context(), key, member)
+}
+
+// ZScoreWithCtx 同 ZScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZScoreWithCtx(ctx context.Context, key string, member string) (float64, error) {
+	return that.Client.ZScore(ctx, key, member).Result()
+}
+
+// ZMScore 批量返回成员的分数, 不存在的成员分数为 0
+func (that *KRedisCluster) ZMScore(key string, members ...string) ([]float64, error) {
+	return that.ZMScoreWithCtx(that.ctx.Context(), key, members...)
+}
+
+// ZMScoreWithCtx 同 ZMScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZMScoreWithCtx(ctx context.Context, key string, members ...string) ([]float64, error) {
+	return that.Client.ZMScore(ctx, key, members...).Result()
+}
+
+// ZRank 返回成员按分数从小到大的排名(从0开始), 成员不存在时返回 redis.Nil
+func (that *KRedisCluster) ZRank(key string, member string) (int64, error) {
+	return that.ZRankWithCtx(that.ctx.Context(), key, member)
+}
+
+// ZRankWithCtx 同 ZRank, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRankWithCtx(ctx context.Context, key string, member string) (int64, error) {
+	return that.Client.ZRank(ctx, key, member).Result()
+}
+
+// ZRevRank 返回成员按分数从大到小的排名(从0开始), 成员不存在时返回 redis.Nil
+func (that *KRedisCluster) ZRevRank(key string, member string) (int64, error) {
+	return that.ZRevRankWithCtx(that.ctx.Context(), key, member)
+}
+
+// ZRevRankWithCtx 同 ZRevRank, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRevRankWithCtx(ctx context.Context, key string, member string) (int64, error) {
+	return that.Client.ZRevRank(ctx, key, member).Result()
+}
+
+// ZCard 返回成员数量
+func (that *KRedisCluster) ZCard(key string) (int64, error) {
+	return that.ZCardWithCtx(that.ctx.Context(), key)
+}
+
+// ZCardWithCtx 同 ZCard, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZCardWithCtx(ctx context.Context, key string) (int64, error) {
+	return that.Client.ZCard(ctx, key).Result()
+}
+
+// ZCount 返回分数在 [min, max] 区间内的成员数量
+func (that *KRedisCluster) ZCount(key string, min string, max string) (int64, error) {
+	return that.ZCountWithCtx(that.ctx.Context(), key, min, max)
+}
+
+// ZCountWithCtx 同 ZCount, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZCountWithCtx(ctx context.Context, key string, min string, max string) (int64, error) {
+	return that.Client.ZCount(ctx, key, min, max).Result()
+}
+
+// ZRange 按排名返回 [start, stop] 区间内的成员, 分数从小到大, 负数表示倒数
+func (that *KRedisCluster) ZRange(key string, start int64, stop int64) ([]string, error) {
+	return that.ZRangeWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRangeWithCtx 同 ZRange, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return that.Client.ZRange(ctx, key, start, stop).Result()
+}
+
+// ZRangeWithScores 同 ZRange, 同时返回分数
+func (that *KRedisCluster) ZRangeWithScores(key string, start int64, stop int64) ([]redisHd.Z, error) {
+	return that.ZRangeWithScoresWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRangeWithScoresWithCtx 同 ZRangeWithScores, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeWithScoresWithCtx(ctx context.Context, key string, start int64, stop int64) ([]redisHd.Z, error) {
+	return that.Client.ZRangeWithScores(ctx, key, start, stop).Result()
+}
+
+// ZRevRange 按排名返回 [start, stop] 区间内的成员, 分数从大到小
+func (that *KRedisCluster) ZRevRange(key string, start int64, stop int64) ([]string, error) {
+	return that.ZRevRangeWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// ZRevRangeWithCtx 同 ZRevRange, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRevRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return that.Client.ZRevRange(ctx, key, start, stop).Result()
+}
+
+// ZRangeByScore 返回分数在 [min, max] 区间内的成员, 分数从小到大; offset, count 均为 0 时不分页
+func (that *KRedisCluster) ZRangeByScore(key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.ZRangeByScoreWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRangeByScoreWithCtx 同 ZRangeByScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeByScoreWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByScore: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRangeByScoreWithScores 同 ZRangeByScore, 同时返回分数
+func (that *KRedisCluster) ZRangeByScoreWithScores(key string, min string, max string, offset int64, count int64) ([]redisHd.Z, error) {
+	return that.ZRangeByScoreWithScoresWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRangeByScoreWithScoresWithCtx 同 ZRangeByScoreWithScores, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeByScoreWithScoresWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]redisHd.Z, error) {
+	return that.Client.ZRangeArgsWithScores(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByScore: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRevRangeByScore 返回分数在 [min, max] 区间内的成员, 分数从大到小; offset, count 均为 0 时不分页
+func (that *KRedisCluster) ZRevRangeByScore(key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.ZRevRangeByScoreWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRevRangeByScoreWithCtx 同 ZRevRangeByScore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRevRangeByScoreWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByScore: true, Rev: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRangeByLex 返回字典序在 [min, max] 区间内的成员, 要求所有成员分数相同; min/max 形如 `[a`, `(a`, `-`, `+`
+func (that *KRedisCluster) ZRangeByLex(key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.ZRangeByLexWithCtx(that.ctx.Context(), key, min, max, offset, count)
+}
+
+// ZRangeByLexWithCtx 同 ZRangeByLex, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeByLexWithCtx(ctx context.Context, key string, min string, max string, offset int64, count int64) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, redisHd.ZRangeArgs{Key: key, Start: min, Stop: max, ByLex: true, Offset: offset, Count: count}).Result()
+}
+
+// ZRangeArgs 按 args 执行 ZRANGE, 支持按排名/分数/字典序, 倒序与分页的任意组合
+func (that *KRedisCluster) ZRangeArgs(args redisHd.ZRangeArgs) ([]string, error) {
+	return that.ZRangeArgsWithCtx(that.ctx.Context(), args)
+}
+
+// ZRangeArgsWithCtx 同 ZRangeArgs, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeArgsWithCtx(ctx context.Context, args redisHd.ZRangeArgs) ([]string, error) {
+	return that.Client.ZRangeArgs(ctx, args).Result()
+}
+
+// ZRangeArgsWithScores 同 ZRangeArgs, 同时返回分数
+func (that *KRedisCluster) ZRangeArgsWithScores(args redisHd.ZRangeArgs) ([]redisHd.Z, error) {
+	return that.ZRangeArgsWithScoresWithCtx(that.ctx.Context(), args)
+}
+
+// ZRangeArgsWithScoresWithCtx 同 ZRangeArgsWithScores, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeArgsWithScoresWithCtx(ctx context.Context, args redisHd.ZRangeArgs) ([]redisHd.Z, error) {
+	return that.Client.ZRangeArgsWithScores(ctx, args).Result()
+}
+
+// ZRangeStore 将 ZRANGE 的结果保存到 dst, 返回保存的成员数量; 集群模式下 dst 与 args.Key 需要位于同一个槽
+func (that *KRedisCluster) ZRangeStore(dst string, args redisHd.ZRangeArgs) (int64, error) {
+	return that.ZRangeStoreWithCtx(that.ctx.Context(), dst, args)
+}
+
+// ZRangeStoreWithCtx 同 ZRangeStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZRangeStoreWithCtx(ctx context.Context, dst string, args redisHd.ZRangeArgs) (int64, error) {
+	return that.Client.ZRangeStore(ctx, dst, args).Result()
+}
+
+// ZPopMin 弹出分数最小的 count 个成员
+func (that *KRedisCluster) ZPopMin(key string, count int64) ([]redisHd.Z, error) {
+	return that.ZPopMinWithCtx(that.ctx.Context(), key, count)
+}
+
+// ZPopMinWithCtx 同 ZPopMin, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZPopMinWithCtx(ctx context.Context, key string, count int64) ([]redisHd.Z, error) {
+	return that.Client.ZPopMin(ctx, key, count).Result()
+}
+
+// ZPopMax 弹出分数最大的 count 个成员
+func (that *KRedisCluster) ZPopMax(key string, count int64) ([]redisHd.Z, error) {
+	return that.ZPopMaxWithCtx(that.ctx.Context(), key, count)
+}
+
+// ZPopMaxWithCtx 同 ZPopMax, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedisCluster) ZPopMaxWithCtx(ctx context.Context, key string, count int64) ([]redisHd.Z, error) {
+	return that.Client.ZPopMax(ctx, key, count).Result()
+}
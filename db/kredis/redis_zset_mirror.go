@@ -0,0 +1,341 @@
+package kredis
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/container/kzset"
+
+	redisHd "github.com/redis/go-redis/v9"
+)
+
+// ZSetMirrorOptions ZSet 本地镜像参数, 时间单位均为毫秒
+type ZSetMirrorOptions struct {
+	Channel        string `json:"channel"`        // 变更通知频道, 默认为 key + ":changes"
+	ResyncInterval int64  `json:"resyncInterval"` // 全量对账周期, 用于修正绕过镜像的写入与丢失的通知, 默认60000, <= 0 时关闭
+	ScanCount      int64  `json:"scanCount"`      // 全量加载时每批 ZSCAN 的数量, 默认1000
+	WatchKeyspace  bool   `json:"watchKeyspace"`  // 监听 key 的键空间事件, 绕过镜像的写入(ZINCRBY, ZPOPMIN, 过期等)发生后立即全量加载, 默认 false
+
+	OnError func(err error) `json:"-"` // 加载, 对账或解析通知失败时的回调, 可用于记录日志
+}
+
+func NewZSetMirrorOptions() *ZSetMirrorOptions {
+	return &ZSetMirrorOptions{
+		ResyncInterval: 60000,
+		ScanCount:      1000,
+	}
+}
+
+func (that *ZSetMirrorOptions) SetChannel(channel string) *ZSetMirrorOptions {
+	that.Channel = channel
+	return that
+}
+
+// 设置全量对账周期, 单位 毫秒
+func (that *ZSetMirrorOptions) SetResyncInterval(interval int64) *ZSetMirrorOptions {
+	that.ResyncInterval = interval
+	return that
+}
+
+func (that *ZSetMirrorOptions) SetScanCount(count int64) *ZSetMirrorOptions {
+	that.ScanCount = count
+	return that
+}
+
+// 设置是否监听键空间事件, 开启后每次写入(包括通过镜像的写入)都会触发一次全量加载, 适用于 ZSet 不大且存在外部写入的场景
+func (that *ZSetMirrorOptions) SetWatchKeyspace(watch bool) *ZSetMirrorOptions {
+	that.WatchKeyspace = watch
+	return that
+}
+
+func (that *ZSetMirrorOptions) SetErrorHandler(fn func(err error)) *ZSetMirrorOptions {
+	that.OnError = fn
+	return that
+}
+
+// zsetChange 变更通知的消息体
+type zsetChange struct {
+	Op      string   `json:"op"` // add, rem
+	Members []string `json:"members"`
+	Scores  []int64  `json:"scores,omitempty"`
+}
+
+// ZSetMirror 将 Redis ZSet 同步到本地的 kzset.GoZSet, 读操作直接访问本地副本, 不产生网络请求.
+//
+// 同步方式:
+//   - Start 时先订阅变更频道, 再通过 ZSCAN 全量加载, 保证加载期间的变更不会丢失
+//   - 通过 Add/Remove 写入时, 在同一个 pipeline 中修改 ZSet 并发布变更通知, 所有进程的镜像增量更新
+//   - 周期性全量对账, 修正绕过镜像直接写入 Redis 的变更与连接中断期间丢失的通知
+//
+// 注意: 绕过镜像直接写入 Redis 的变更(ZINCRBY, ZPOPMIN, ZREM, 过期等)不会发布变更通知,
+// 默认情况下本地副本最长在 ResyncInterval 内是旧的; 需要及时反映外部写入时开启 WatchKeyspace,
+// 通过键空间事件触发全量加载(需要 notify-keyspace-events, 参见 KeyspaceListener).
+//
+// GoZSet 的分数为 int64, Redis 中的浮点分数加载时截断为整数. GoZSet 由镜像独占, 不应再被其他代码修改
+type ZSetMirror struct {
+	client KRedisClient
+	key    string
+	zset   *kzset.GoZSet
+	opts   *ZSetMirrorOptions
+
+	ctx      *kcontext.ContextNode
+	pubsub   *redisHd.PubSub
+	keyspace *KeyspaceListener
+	dirty    chan struct{} // 收到键空间事件, 需要全量加载
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+// 会修改 ZSet 内容的键空间事件
+var zsetMirrorEvents = []KeyspaceEventType{
+	KeyspaceZAdd, KeyspaceZIncr, KeyspaceZRem, KeyspaceZPopMin, KeyspaceZPopMax,
+	"zremrangebyscore", "zremrangebyrank", "zremrangebylex", "zunionstore", "zinterstore", "zdiffstore", "zrangestore",
+	KeyspaceDel, KeyspaceExpired, KeyspaceEvicted, KeyspaceRename, KeyspaceRenameFrom, "restore", "move_from", "move_to", "copy_to",
+}
+
+// NewZSetMirror 创建 key 的本地镜像, zset 为 nil 时创建新的 GoZSet; 镜像的生命周期绑定到 ctx 下新建的子节点上.
+// opts 为空时使用默认参数, opts 会被复制, 之后修改 opts 不影响已创建的镜像
+func NewZSetMirror(ctx *kcontext.ContextNode, client KRedisClient, key string, zset *kzset.GoZSet, opts *ZSetMirrorOptions) *ZSetMirror {
+	if zset == nil {
+		zset = kzset.NewGoZSet()
+	}
+	options := *NewZSetMirrorOptions()
+	if opts != nil {
+		options = *opts
+	}
+	if options.Channel == "" {
+		options.Channel = key + ":changes"
+	}
+	if options.ScanCount <= 0 {
+		options.ScanCount = 1000
+	}
+	return &ZSetMirror{
+		client: client,
+		key:    key,
+		zset:   zset,
+		opts:   &options,
+		ctx:    ctx.NewChild("kredis_zset_mirror:" + key),
+		dirty:  make(chan struct{}, 1),
+	}
+}
+
+// ZSet 返回本地副本
+func (that *ZSetMirror) ZSet() *kzset.GoZSet {
+	return that.zset
+}
+
+// Start 订阅变更频道并全量加载, 成功后在后台保持同步
+func (that *ZSetMirror) Start() error {
+	ctx := that.ctx.Context()
+	pubsub := that.client.UniversalClient().Subscribe(ctx, that.opts.Channel)
+	if _, err := pubsub.Receive(ctx); err != nil { // 等待订阅确认
+		pubsub.Close()
+		return err
+	}
+	if that.opts.WatchKeyspace { // 先监听事件再加载, 加载期间的外部写入会再触发一次加载
+		keyspaceOpts := NewKeyspaceOptions(zsetMirrorEvents...).SetKeyFilter([]string{that.key}, nil).SetErrorHandler(that.onError)
+		that.keyspace = NewKeyspaceListener(that.ctx, that.client, keyspaceOpts, func(event *KeyspaceEvent) {
+			select {
+			case that.dirty <- struct{}{}:
+			default:
+			}
+		})
+		if err := that.keyspace.Start(); err != nil {
+			that.keyspace.Stop()
+			pubsub.Close()
+			return err
+		}
+	}
+	if err := that.Load(); err != nil {
+		if that.keyspace != nil {
+			that.keyspace.Stop()
+		}
+		pubsub.Close()
+		return err
+	}
+	that.pubsub = pubsub
+
+	that.wg.Add(1)
+	go func() {
+		defer that.wg.Done()
+		that.run()
+	}()
+	return nil
+}
+
+// Stop 取消订阅并停止同步, 本地副本保留最后的状态
+func (that *ZSetMirror) Stop() {
+	that.once.Do(func() {
+		that.ctx.Cancel()
+		if that.pubsub != nil {
+			that.pubsub.Close()
+		}
+		if that.keyspace != nil {
+			that.keyspace.Stop()
+		}
+		that.wg.Wait()
+		that.ctx.Remove()
+	})
+}
+
+// Load 通过 ZSCAN 全量加载 Redis 中的数据, 并删除本地存在而 Redis 中不存在的成员
+func (that *ZSetMirror) Load() error {
+	ctx := that.ctx.Context()
+	client := that.client.UniversalClient()
+
+	remote := make(map[string]int64)
+	var cursor uint64
+	for {
+		keys, next, err := client.ZScan(ctx, that.key, cursor, "", max(that.opts.ScanCount, 1)).Result()
+		if err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(keys); i += 2 { // member, score 交替出现
+			score, err := parseZScore(keys[i+1])
+			if err != nil {
+				return err
+			}
+			remote[keys[i]] = score
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for _, member := range that.zset.ZRangeByScore(math.MaxInt64) {
+		if _, ok := remote[member]; !ok {
+			that.zset.ZRem(member)
+		}
+	}
+	for member, score := range remote {
+		that.zset.ZAdd(member, score)
+	}
+	return nil
+}
+
+// Add 添加或更新成员, 写入 Redis 并通知所有镜像
+func (that *ZSetMirror) Add(member string, score int64) error {
+	return that.AddWithCtx(that.ctx.Context(), member, score)
+}
+
+// AddWithCtx 同 Add, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *ZSetMirror) AddWithCtx(ctx context.Context, member string, score int64) error {
+	payload, _ := json.Marshal(&zsetChange{Op: "add", Members: []string{member}, Scores: []int64{score}})
+	_, err := that.client.UniversalClient().Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		pipe.ZAdd(ctx, that.key, redisHd.Z{Score: float64(score), Member: member})
+		pipe.Publish(ctx, that.opts.Channel, payload)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	that.zset.ZAdd(member, score) // 不等待通知, 保证本进程写后立即可读
+	return nil
+}
+
+// Remove 删除成员, 写入 Redis 并通知所有镜像
+func (that *ZSetMirror) Remove(members ...string) error {
+	return that.RemoveWithCtx(that.ctx.Context(), members...)
+}
+
+// RemoveWithCtx 同 Remove, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *ZSetMirror) RemoveWithCtx(ctx context.Context, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	values := make([]any, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+
+	payload, _ := json.Marshal(&zsetChange{Op: "rem", Members: members})
+	_, err := that.client.UniversalClient().Pipelined(ctx, func(pipe redisHd.Pipeliner) error {
+		pipe.ZRem(ctx, that.key, values...)
+		pipe.Publish(ctx, that.opts.Channel, payload)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		that.zset.ZRem(member)
+	}
+	return nil
+}
+
+func (that *ZSetMirror) run() {
+	var resync <-chan time.Time
+	if that.opts.ResyncInterval > 0 {
+		ticker := time.NewTicker(millisecond(that.opts.ResyncInterval))
+		defer ticker.Stop()
+		resync = ticker.C
+	}
+
+	ch := that.pubsub.Channel()
+	for {
+		select {
+		case <-that.ctx.Context().Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			that.apply(msg.Payload)
+			continue
+		case <-resync:
+		case <-that.dirty:
+		}
+		if err := that.Load(); err != nil && that.ctx.Context().Err() == nil {
+			that.onError(err)
+		}
+	}
+}
+
+// apply 应用一条变更通知
+func (that *ZSetMirror) apply(payload string) {
+	change := &zsetChange{}
+	if err := json.Unmarshal([]byte(payload), change); err != nil {
+		that.onError(err)
+		return
+	}
+
+	switch change.Op {
+	case "add":
+		for i, member := range change.Members {
+			if i < len(change.Scores) {
+				that.zset.ZAdd(member, change.Scores[i])
+			}
+		}
+	case "rem":
+		for _, member := range change.Members {
+			that.zset.ZRem(member)
+		}
+	}
+}
+
+func (that *ZSetMirror) onError(err error) {
+	if that.opts.OnError != nil {
+		that.opts.OnError(err)
+	}
+}
+
+// parseZScore 解析 ZSCAN 返回的分数, 浮点分数截断为整数, inf/-inf 转换为 int64 的最大/最小值
+func parseZScore(s string) (int64, error) {
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case score >= math.MaxInt64:
+		return math.MaxInt64, nil
+	case score <= math.MinInt64:
+		return math.MinInt64, nil
+	}
+	return int64(score), nil
+}
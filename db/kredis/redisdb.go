@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -32,29 +33,35 @@ func redisOnConnect(ctx context.Context, cn *redisHd.Conn) error {
 	return nil
 }
 
+// NewKRedis 创建单机客户端, 使用 NewRedisOptions 的默认参数; 需要 TLS 或调整连接池时使用 NewKRedisWithOptions
 func NewKRedis(ctx *kcontext.ContextNode, addr string, user string, password string, dbNum int) *KRedis {
-	client := redisHd.NewClient(&redisHd.Options{
-		Addr:            addr,
-		Username:        user,     // redis 6.0以上版本
-		Password:        password, // 没有密码, 默认值
-		DB:              dbNum,    // 默认DB 0
-		MaxRetries:      3,        // 自动重连3次, 失败后报错
-		DialTimeout:     10 * time.Second,
-		ReadTimeout:     30 * time.Second,
-		WriteTimeout:    30 * time.Second,
-		PoolSize:        10,
-		PoolTimeout:     30 * time.Second,
-		ConnMaxIdleTime: 30 * time.Second, // 链路最大空闲时间
-		OnConnect:       redisOnConnect,
-	})
+	opts := NewRedisOptions(addr).SetAuth(user, password).SetDB(dbNum).SetOnConnect(redisOnConnect)
+	client, err := NewKRedisWithOptions(ctx, opts)
+	if err != nil { // 只有加载 TLS 证书文件会失败, 这里没有配置, 正常不会出现
+		log.Printf("kredis: NewKRedis %v failed: %v", addr, err)
+	}
+	return client
+}
 
+// newKRedis 包装已创建的单机或哨兵客户端
+func newKRedis(ctx *kcontext.ContextNode, client *redisHd.Client) *KRedis {
 	subCtx := ctx.NewChild("kredis_client")
 	return &KRedis{Client: client, ctx: subCtx}
 }
 
+// UniversalClient 返回底层的 go-redis 客户端
+func (that *KRedis) UniversalClient() redisHd.UniversalClient {
+	return that.Client
+}
+
 // 执行指令
 func (mr *KRedis) Do(args ...any) (any, error) {
-	val, err := mr.Client.Do(mr.ctx.Context(), args...).Result()
+	return mr.DoWithCtx(mr.ctx.Context(), args...)
+}
+
+// DoWithCtx 同 Do, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) DoWithCtx(ctx context.Context, args ...any) (any, error) {
+	val, err := mr.Client.Do(ctx, args...).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -65,7 +72,12 @@ func (mr *KRedis) Do(args ...any) (any, error) {
 
 // 获取一个key的值
 func (mr *KRedis) Get(key string) (any, error) {
-	val, err := mr.Client.Do(mr.ctx.Context(), "GET", key).Result()
+	return mr.GetWithCtx(mr.ctx.Context(), key)
+}
+
+// GetWithCtx 同 Get, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) GetWithCtx(ctx context.Context, key string) (any, error) {
+	val, err := mr.Client.Do(ctx, "GET", key).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -76,7 +88,12 @@ func (mr *KRedis) Get(key string) (any, error) {
 
 // 设置某个key的值, 并指定ttl
 func (mr *KRedis) Set(key string, value any, duration time.Duration) (bool, error) {
-	err := mr.Client.Set(mr.ctx.Context(), key, value, duration).Err()
+	return mr.SetWithCtx(mr.ctx.Context(), key, value, duration)
+}
+
+// SetWithCtx 同 Set, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) SetWithCtx(ctx context.Context, key string, value any, duration time.Duration) (bool, error) {
+	err := mr.Client.Set(ctx, key, value, duration).Err()
 	if err != nil {
 		return false, err
 	}
@@ -85,7 +102,12 @@ func (mr *KRedis) Set(key string, value any, duration time.Duration) (bool, erro
 
 // 判断某个key是否存在
 func (mr *KRedis) Exist(key string) (bool, error) {
-	_, err := mr.Client.Get(mr.ctx.Context(), key).Result()
+	return mr.ExistWithCtx(mr.ctx.Context(), key)
+}
+
+// ExistWithCtx 同 Exist, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) ExistWithCtx(ctx context.Context, key string) (bool, error) {
+	_, err := mr.Client.Get(ctx, key).Result()
 	if err == redisHd.Nil {
 		return false, nil
 	} else if err != nil {
@@ -96,7 +118,12 @@ func (mr *KRedis) Exist(key string) (bool, error) {
 
 // 获取一个key的hash字段的值
 func (that *KRedis) HGet(key string, field string) (any, error) {
-	val, err := that.Client.HGet(that.ctx.Context(), key, field).Result()
+	return that.HGetWithCtx(that.ctx.Context(), key, field)
+}
+
+// HGetWithCtx 同 HGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HGetWithCtx(ctx context.Context, key string, field string) (any, error) {
+	val, err := that.Client.HGet(ctx, key, field).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -107,7 +134,12 @@ func (that *KRedis) HGet(key string, field string) (any, error) {
 
 // 设置一个key的hash字段的值
 func (that *KRedis) HSet(key string, field string, value any) error {
-	err := that.Client.HSet(that.ctx.Context(), key, field, value).Err()
+	return that.HSetWithCtx(that.ctx.Context(), key, field, value)
+}
+
+// HSetWithCtx 同 HSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HSetWithCtx(ctx context.Context, key string, field string, value any) error {
+	err := that.Client.HSet(ctx, key, field, value).Err()
 	if err != nil {
 		return err
 	}
@@ -116,7 +148,12 @@ func (that *KRedis) HSet(key string, field string, value any) error {
 
 // 获取一个key的hash字段的值列表
 func (that *KRedis) HGetAll(key string) (map[string]string, error) {
-	valMap, err := that.Client.HGetAll(that.ctx.Context(), key).Result()
+	return that.HGetAllWithCtx(that.ctx.Context(), key)
+}
+
+// HGetAllWithCtx 同 HGetAll, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HGetAllWithCtx(ctx context.Context, key string) (map[string]string, error) {
+	valMap, err := that.Client.HGetAll(ctx, key).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -127,7 +164,12 @@ func (that *KRedis) HGetAll(key string) (map[string]string, error) {
 
 // 设置一个key的hash字段的值列表
 func (that *KRedis) HSetAll(key string, fields map[string]any) error {
-	err := that.Client.HSet(that.ctx.Context(), key, fields).Err()
+	return that.HSetAllWithCtx(that.ctx.Context(), key, fields)
+}
+
+// HSetAllWithCtx 同 HSetAll, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HSetAllWithCtx(ctx context.Context, key string, fields map[string]any) error {
+	err := that.Client.HSet(ctx, key, fields).Err()
 	if err != nil {
 		return err
 	}
@@ -136,7 +178,12 @@ func (that *KRedis) HSetAll(key string, fields map[string]any) error {
 
 // 判断一个key的hash字段是否存在
 func (that *KRedis) HExists(key string, field string) (bool, error) {
-	isExists, err := that.Client.HExists(that.ctx.Context(), key, field).Result()
+	return that.HExistsWithCtx(that.ctx.Context(), key, field)
+}
+
+// HExistsWithCtx 同 HExists, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HExistsWithCtx(ctx context.Context, key string, field string) (bool, error) {
+	isExists, err := that.Client.HExists(ctx, key, field).Result()
 	if nil != err {
 		return false, err
 	}
@@ -145,7 +192,12 @@ func (that *KRedis) HExists(key string, field string) (bool, error) {
 
 // 获取一个key的hash字段的数量
 func (that *KRedis) HLen(key string) (int64, error) {
-	val, err := that.Client.HLen(that.ctx.Context(), key).Result()
+	return that.HLenWithCtx(that.ctx.Context(), key)
+}
+
+// HLenWithCtx 同 HLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HLenWithCtx(ctx context.Context, key string) (int64, error) {
+	val, err := that.Client.HLen(ctx, key).Result()
 	if nil != err {
 		return 0, err
 	}
@@ -181,7 +233,12 @@ func (that *KRedis) HSetNX(ctx context.Context, key, field string, value any) (b
 
 // 删除一个key的hash字段的值列表
 func (that *KRedis) HDel(key string, fields ...string) error {
-	_, err := that.Client.HDel(that.ctx.Context(), key, fields...).Result()
+	return that.HDelWithCtx(that.ctx.Context(), key, fields...)
+}
+
+// HDelWithCtx 同 HDel, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HDelWithCtx(ctx context.Context, key string, fields ...string) error {
+	_, err := that.Client.HDel(ctx, key, fields...).Result()
 	if nil != err {
 		return err
 	}
@@ -190,7 +247,12 @@ func (that *KRedis) HDel(key string, fields ...string) error {
 
 // 获取一个key的hash字段的值列表
 func (that *KRedis) HMGet(key string, fields ...string) ([]any, error) {
-	valMap, err := that.Client.HMGet(that.ctx.Context(), key, fields...).Result()
+	return that.HMGetWithCtx(that.ctx.Context(), key, fields...)
+}
+
+// HMGetWithCtx 同 HMGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HMGetWithCtx(ctx context.Context, key string, fields ...string) ([]any, error) {
+	valMap, err := that.Client.HMGet(ctx, key, fields...).Result()
 	if err == redisHd.Nil {
 		return nil, nil
 	} else if nil != err {
@@ -201,7 +263,12 @@ func (that *KRedis) HMGet(key string, fields ...string) ([]any, error) {
 
 // 设置一个key的hash字段的值列表, 如果不存在则创建
 func (that *KRedis) HMSet(key string, fields map[string]any) error {
-	err := that.Client.HMSet(that.ctx.Context(), key, fields).Err()
+	return that.HMSetWithCtx(that.ctx.Context(), key, fields)
+}
+
+// HMSetWithCtx 同 HMSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) HMSetWithCtx(ctx context.Context, key string, fields map[string]any) error {
+	err := that.Client.HMSet(ctx, key, fields).Err()
 	if nil != err {
 		return err
 	}
@@ -210,130 +277,275 @@ func (that *KRedis) HMSet(key string, fields map[string]any) error {
 
 // 从列表左边插入数据
 func (that *KRedis) LPush(key string, values ...any) (int64, error) {
-	return that.Client.LPush(that.ctx.Context(), key, values...).Result()
+	return that.LPushWithCtx(that.ctx.Context(), key, values...)
+}
+
+// LPushWithCtx 同 LPush, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LPushWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.LPush(ctx, key, values...).Result()
 }
 
 // 从列表左边插入数据, 如果不存在则不插入数据
 func (that *KRedis) LPushX(key string, values ...any) (int64, error) {
-	return that.Client.LPushX(that.ctx.Context(), key, values...).Result()
+	return that.LPushXWithCtx(that.ctx.Context(), key, values...)
+}
+
+// LPushXWithCtx 同 LPushX, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LPushXWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.LPushX(ctx, key, values...).Result()
 }
 
 // 从列表右边插入数据
 func (that *KRedis) RPush(key string, values ...any) (int64, error) {
-	return that.Client.RPush(that.ctx.Context(), key, values...).Result()
+	return that.RPushWithCtx(that.ctx.Context(), key, values...)
+}
+
+// RPushWithCtx 同 RPush, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) RPushWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.RPush(ctx, key, values...).Result()
 }
 
 // 从列表右边插入数据, 如果不存在则不插入数据
 func (that *KRedis) RPushX(key string, values ...any) (int64, error) {
-	return that.Client.RPushX(that.ctx.Context(), key, values...).Result()
+	return that.RPushXWithCtx(that.ctx.Context(), key, values...)
+}
+
+// RPushXWithCtx 同 RPushX, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) RPushXWithCtx(ctx context.Context, key string, values ...any) (int64, error) {
+	return that.Client.RPushX(ctx, key, values...).Result()
 }
 
 // 从列表左边弹出数据
 func (that *KRedis) LPop(key string) (string, error) {
-	return that.Client.LPop(that.ctx.Context(), key).Result()
+	return that.LPopWithCtx(that.ctx.Context(), key)
+}
+
+// LPopWithCtx 同 LPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LPopWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.LPop(ctx, key).Result()
 }
 
 // 从列表右边弹出数据
 func (that *KRedis) RPop(key string) (string, error) {
-	return that.Client.RPop(that.ctx.Context(), key).Result()
+	return that.RPopWithCtx(that.ctx.Context(), key)
+}
+
+// RPopWithCtx 同 RPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) RPopWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.RPop(ctx, key).Result()
 }
 
 // 返回列表的一个范围内的数据, 也可以返回全部数据
 func (that *KRedis) LRange(key string, start int64, stop int64) ([]string, error) {
-	return that.Client.LRange(that.ctx.Context(), key, start, stop).Result()
+	return that.LRangeWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// LRangeWithCtx 同 LRange, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LRangeWithCtx(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return that.Client.LRange(ctx, key, start, stop).Result()
 }
 
 // 返回列表的大小
 func (that *KRedis) LLen(key string) (int64, error) {
-	return that.Client.LLen(that.ctx.Context(), key).Result()
+	return that.LLenWithCtx(that.ctx.Context(), key)
+}
+
+// LLenWithCtx 同 LLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LLenWithCtx(ctx context.Context, key string) (int64, error) {
+	return that.Client.LLen(ctx, key).Result()
 }
 
 func (that *KRedis) LTrim(key string, start int64, stop int64) error {
-	return that.Client.LTrim(that.ctx.Context(), key, start, stop).Err()
+	return that.LTrimWithCtx(that.ctx.Context(), key, start, stop)
+}
+
+// LTrimWithCtx 同 LTrim, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LTrimWithCtx(ctx context.Context, key string, start int64, stop int64) error {
+	return that.Client.LTrim(ctx, key, start, stop).Err()
 }
 
 func (that *KRedis) LSet(key string, index int64, value any) error {
-	return that.Client.LSet(that.ctx.Context(), key, index, value).Err()
+	return that.LSetWithCtx(that.ctx.Context(), key, index, value)
+}
+
+// LSetWithCtx 同 LSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LSetWithCtx(ctx context.Context, key string, index int64, value any) error {
+	return that.Client.LSet(ctx, key, index, value).Err()
 }
 
 // 删除列表中的数据
 func (that *KRedis) LRem(key string, count int64, value any) (int64, error) {
-	return that.Client.LRem(that.ctx.Context(), key, count, value).Result()
+	return that.LRemWithCtx(that.ctx.Context(), key, count, value)
+}
+
+// LRemWithCtx 同 LRem, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LRemWithCtx(ctx context.Context, key string, count int64, value any) (int64, error) {
+	return that.Client.LRem(ctx, key, count, value).Result()
 }
 
 // 根据索引坐标, 查询列表中的数据
 func (that *KRedis) LIndex(key string, index int64) (string, error) {
-	return that.Client.LIndex(that.ctx.Context(), key, index).Result()
+	return that.LIndexWithCtx(that.ctx.Context(), key, index)
+}
+
+// LIndexWithCtx 同 LIndex, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LIndexWithCtx(ctx context.Context, key string, index int64) (string, error) {
+	return that.Client.LIndex(ctx, key, index).Result()
 }
 
 // 在指定位置插入数据, 在头部插入用"before", 尾部插入用"after"
 func (that *KRedis) LInsert(key string, position string, pivot any, value any) (int64, error) {
-	return that.Client.LInsert(that.ctx.Context(), key, position, pivot, value).Result()
+	return that.LInsertWithCtx(that.ctx.Context(), key, position, pivot, value)
+}
+
+// LInsertWithCtx 同 LInsert, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) LInsertWithCtx(ctx context.Context, key string, position string, pivot any, value any) (int64, error) {
+	return that.Client.LInsert(ctx, key, position, pivot, value).Result()
 }
 
 func (that *KRedis) SAdd(key string, members ...any) (int64, error) {
-	return that.Client.SAdd(that.ctx.Context(), key, members...).Result()
+	return that.SAddWithCtx(that.ctx.Context(), key, members...)
+}
+
+// SAddWithCtx 同 SAdd, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SAddWithCtx(ctx context.Context, key string, members ...any) (int64, error) {
+	return that.Client.SAdd(ctx, key, members...).Result()
 }
 
 func (that *KRedis) SMembers(key string) ([]string, error) {
-	return that.Client.SMembers(that.ctx.Context(), key).Result()
+	return that.SMembersWithCtx(that.ctx.Context(), key)
+}
+
+// SMembersWithCtx 同 SMembers, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SMembersWithCtx(ctx context.Context, key string) ([]string, error) {
+	return that.Client.SMembers(ctx, key).Result()
 }
 
 func (that *KRedis) SRem(key string, members ...any) (int64, error) {
-	return that.Client.SRem(that.ctx.Context(), key, members...).Result()
+	return that.SRemWithCtx(that.ctx.Context(), key, members...)
+}
+
+// SRemWithCtx 同 SRem, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SRemWithCtx(ctx context.Context, key string, members ...any) (int64, error) {
+	return that.Client.SRem(ctx, key, members...).Result()
 }
 
 func (that *KRedis) SIsMember(key string, member any) (bool, error) {
-	return that.Client.SIsMember(that.ctx.Context(), key, member).Result()
+	return that.SIsMemberWithCtx(that.ctx.Context(), key, member)
+}
+
+// SIsMemberWithCtx 同 SIsMember, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SIsMemberWithCtx(ctx context.Context, key string, member any) (bool, error) {
+	return that.Client.SIsMember(ctx, key, member).Result()
 }
 
 func (that *KRedis) SCard(key string) (int64, error) {
-	return that.Client.SCard(that.ctx.Context(), key).Result()
+	return that.SCardWithCtx(that.ctx.Context(), key)
+}
+
+// SCardWithCtx 同 SCard, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SCardWithCtx(ctx context.Context, key string) (int64, error) {
+	return that.Client.SCard(ctx, key).Result()
 }
 
 func (that *KRedis) SPop(key string) (string, error) {
-	return that.Client.SPop(that.ctx.Context(), key).Result()
+	return that.SPopWithCtx(that.ctx.Context(), key)
+}
+
+// SPopWithCtx 同 SPop, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SPopWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.SPop(ctx, key).Result()
 }
 
 func (that *KRedis) SPopN(key string, count int64) ([]string, error) {
-	return that.Client.SPopN(that.ctx.Context(), key, count).Result()
+	return that.SPopNWithCtx(that.ctx.Context(), key, count)
+}
+
+// SPopNWithCtx 同 SPopN, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SPopNWithCtx(ctx context.Context, key string, count int64) ([]string, error) {
+	return that.Client.SPopN(ctx, key, count).Result()
 }
 
 func (that *KRedis) SUnion(keys ...string) ([]string, error) {
-	return that.Client.SUnion(that.ctx.Context(), keys...).Result()
+	return that.SUnionWithCtx(that.ctx.Context(), keys...)
+}
+
+// SUnionWithCtx 同 SUnion, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SUnionWithCtx(ctx context.Context, keys ...string) ([]string, error) {
+	return that.Client.SUnion(ctx, keys...).Result()
 }
 
 func (that *KRedis) SUnionStore(destKey string, keys ...string) (int64, error) {
-	return that.Client.SUnionStore(that.ctx.Context(), destKey, keys...).Result()
+	return that.SUnionStoreWithCtx(that.ctx.Context(), destKey, keys...)
+}
+
+// SUnionStoreWithCtx 同 SUnionStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SUnionStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error) {
+	return that.Client.SUnionStore(ctx, destKey, keys...).Result()
 }
 
 func (that *KRedis) SInter(keys ...string) ([]string, error) {
-	return that.Client.SInter(that.ctx.Context(), keys...).Result()
+	return that.SInterWithCtx(that.ctx.Context(), keys...)
+}
+
+// SInterWithCtx 同 SInter, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SInterWithCtx(ctx context.Context, keys ...string) ([]string, error) {
+	return that.Client.SInter(ctx, keys...).Result()
 }
 
 func (that *KRedis) SInterStore(destKey string, keys ...string) (int64, error) {
-	return that.Client.SInterStore(that.ctx.Context(), destKey, keys...).Result()
+	return that.SInterStoreWithCtx(that.ctx.Context(), destKey, keys...)
+}
+
+// SInterStoreWithCtx 同 SInterStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SInterStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error) {
+	return that.Client.SInterStore(ctx, destKey, keys...).Result()
 }
 
 func (that *KRedis) SDiff(keys ...string) ([]string, error) {
-	return that.Client.SDiff(that.ctx.Context(), keys...).Result()
+	return that.SDiffWithCtx(that.ctx.Context(), keys...)
+}
+
+// SDiffWithCtx 同 SDiff, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SDiffWithCtx(ctx context.Context, keys ...string) ([]string, error) {
+	return that.Client.SDiff(ctx, keys...).Result()
 }
 
 func (that *KRedis) SDiffStore(destKey string, keys ...string) (int64, error) {
-	return that.Client.SDiffStore(that.ctx.Context(), destKey, keys...).Result()
+	return that.SDiffStoreWithCtx(that.ctx.Context(), destKey, keys...)
+}
+
+// SDiffStoreWithCtx 同 SDiffStore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SDiffStoreWithCtx(ctx context.Context, destKey string, keys ...string) (int64, error) {
+	return that.Client.SDiffStore(ctx, destKey, keys...).Result()
 }
 
 func (that *KRedis) SMove(source, destination string, member any) (bool, error) {
-	return that.Client.SMove(that.ctx.Context(), source, destination, member).Result()
+	return that.SMoveWithCtx(that.ctx.Context(), source, destination, member)
+}
+
+// SMoveWithCtx 同 SMove, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SMoveWithCtx(ctx context.Context, source, destination string, member any) (bool, error) {
+	return that.Client.SMove(ctx, source, destination, member).Result()
 }
 
 func (that *KRedis) SRandMember(key string) (string, error) {
-	return that.Client.SRandMember(that.ctx.Context(), key).Result()
+	return that.SRandMemberWithCtx(that.ctx.Context(), key)
+}
+
+// SRandMemberWithCtx 同 SRandMember, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) SRandMemberWithCtx(ctx context.Context, key string) (string, error) {
+	return that.Client.SRandMember(ctx, key).Result()
 }
 
 // 获取一个key的数据类型, 数据类型全小写
 func (mr *KRedis) Type(key string) (string, error) {
-	dataType, err := mr.Client.Type(mr.ctx.Context(), key).Result()
+	return mr.TypeWithCtx(mr.ctx.Context(), key)
+}
+
+// TypeWithCtx 同 Type, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) TypeWithCtx(ctx context.Context, key string) (string, error) {
+	dataType, err := mr.Client.Type(ctx, key).Result()
 	if nil != err {
 		return "", err
 	}
@@ -342,20 +554,40 @@ func (mr *KRedis) Type(key string) (string, error) {
 
 // 返回一个Key的过期时间, 单位为毫秒
 func (mr *KRedis) PTTL(key string) (time.Duration, error) {
-	return mr.Client.PTTL(mr.ctx.Context(), key).Result()
+	return mr.PTTLWithCtx(mr.ctx.Context(), key)
+}
+
+// PTTLWithCtx 同 PTTL, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) PTTLWithCtx(ctx context.Context, key string) (time.Duration, error) {
+	return mr.Client.PTTL(ctx, key).Result()
 }
 
 // 返回一个Key的过期时间, 单位为秒
 func (mr *KRedis) TTL(key string) (time.Duration, error) {
-	return mr.Client.TTL(mr.ctx.Context(), key).Result()
+	return mr.TTLWithCtx(mr.ctx.Context(), key)
+}
+
+// TTLWithCtx 同 TTL, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) TTLWithCtx(ctx context.Context, key string) (time.Duration, error) {
+	return mr.Client.TTL(ctx, key).Result()
 }
 
 func (that *KRedis) Expire(key string, expiration time.Duration) bool {
-	return that.Client.Expire(that.ctx.Context(), key, expiration).Val()
+	return that.ExpireWithCtx(that.ctx.Context(), key, expiration)
+}
+
+// ExpireWithCtx 同 Expire, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ExpireWithCtx(ctx context.Context, key string, expiration time.Duration) bool {
+	return that.Client.Expire(ctx, key, expiration).Val()
 }
 
 func (that *KRedis) ExpireAt(key string, tm time.Time) bool {
-	return that.Client.ExpireAt(that.ctx.Context(), key, tm).Val()
+	return that.ExpireAtWithCtx(that.ctx.Context(), key, tm)
+}
+
+// ExpireAtWithCtx 同 ExpireAt, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) ExpireAtWithCtx(ctx context.Context, key string, tm time.Time) bool {
+	return that.Client.ExpireAt(ctx, key, tm).Val()
 }
 
 // JsonGet 封装了 Redis JSON.GET 命令, 并直接返回原始的 JSON 字符串.
@@ -364,13 +596,18 @@ func (that *KRedis) ExpireAt(key string, tm time.Time) bool {
 // paths: 可选的 JSON Path 参数.如果没有提供, 则获取整个 JSON 文档.
 // 返回值：JSON 字符串.如果键或路径不存在, 或结果为空, 则返回空字符串和 nil 错误.
 func (that *KRedis) JsonGet(key string, paths ...string) (string, error) {
+	return that.JsonGetWithCtx(that.ctx.Context(), key, paths...)
+}
+
+// JsonGetWithCtx 同 JsonGet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonGetWithCtx(ctx context.Context, key string, paths ...string) (string, error) {
 	args := make([]any, 0, 2+len(paths))
 	args = append(args, "JSON.GET", key)
 	for _, path := range paths {
 		args = append(args, path)
 	}
 	// 执行 Redis 命令
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 	// 检查 Redis 命令执行是否出错
 	if err := cmd.Err(); err != nil {
 		// 如果错误是 redis.Nil (表示键或路径不存在), 则返回空字符串和 nil 错误
@@ -398,9 +635,14 @@ func (that *KRedis) JsonGet(key string, paths ...string) (string, error) {
 // value: 要存储的 Go 值, 将被序列化为 JSON.
 // 返回值：如果操作成功则返回 nil, 否则返回错误.
 func (that *KRedis) JsonSet(key string, path string, value string) error {
+	return that.JsonSetWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonSetWithCtx 同 JsonSet, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonSetWithCtx(ctx context.Context, key string, path string, value string) error {
 	// 构建 Redis 命令参数：JSON.SET key path jsonValueString
 	// `string(jsonValue)` 将字节切片转换为字符串, go-redis 可以接受
-	cmd := that.Client.Do(that.ctx.Context(), "JSON.SET", key, path, value)
+	cmd := that.Client.Do(ctx, "JSON.SET", key, path, value)
 
 	// 检查 Redis 命令执行是否出错
 	if err := cmd.Err(); err != nil {
@@ -417,7 +659,12 @@ func (that *KRedis) JsonSet(key string, path string, value string) error {
 // value: 要存储的 Go 值, 将被序列化为 JSON 并与现有值合并.
 // 返回值：如果操作成功则返回 nil, 否则返回错误.
 func (that *KRedis) JsonMerge(key string, path string, value string) error {
-	cmd := that.Client.Do(that.ctx.Context(), "JSON.MERGE", key, path, value)
+	return that.JsonMergeWithCtx(that.ctx.Context(), key, path, value)
+}
+
+// JsonMergeWithCtx 同 JsonMerge, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonMergeWithCtx(ctx context.Context, key string, path string, value string) error {
+	cmd := that.Client.Do(ctx, "JSON.MERGE", key, path, value)
 	if err := cmd.Err(); err != nil {
 		return err
 	}
@@ -429,6 +676,11 @@ func (that *KRedis) JsonMerge(key string, path string, value string) error {
 // path: 可选的 JSON Path.如果为空字符串, 则删除整个 JSON 文档.
 // 返回值：被删除的 JSON 值数量.如果键或路径不存在, 通常返回 0.
 func (that *KRedis) JsonDel(key string, path string) (int64, error) {
+	return that.JsonDelWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonDelWithCtx 同 JsonDel, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonDelWithCtx(ctx context.Context, key string, path string) (int64, error) {
 	// 构建 Redis 命令参数
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.DEL", key)
@@ -436,7 +688,7 @@ func (that *KRedis) JsonDel(key string, path string) (int64, error) {
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	// 检查 Redis 命令执行是否出错
 	if err := cmd.Err(); err != nil {
@@ -458,13 +710,18 @@ func (that *KRedis) JsonDel(key string, path string) (int64, error) {
 // path: 可选的 JSON Path.如果为空字符串, 则返回根路径的类型; 不支持同时指定多个路径.
 // 返回值：一个包含 JSON 值类型的字符串切片.如果键或路径不存在, 则返回 nil 切片和 nil 错误.
 func (that *KRedis) JsonType(key string, path string) ([]string, error) {
+	return that.JsonTypeWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonTypeWithCtx 同 JsonType, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonTypeWithCtx(ctx context.Context, key string, path string) ([]string, error) {
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.TYPE", key)
 	if path != "" { // 如果 path 不为空, 则添加到参数中
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	if err := cmd.Err(); err != nil {
 		if err == redisHd.Nil {
@@ -509,13 +766,18 @@ func (that *KRedis) JsonType(key string, path string) ([]string, error) {
 // path: 可选的 JSON Path.如果为空字符串, 则返回根对象的键.
 // 返回值：一个包含对象键的字符串切片.如果键、路径不存在或路径对应的不是对象, 则返回 nil 切片和 nil 错误.
 func (that *KRedis) JsonObjKeys(key string, path string) ([]string, error) {
+	return that.JsonObjKeysWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonObjKeysWithCtx 同 JsonObjKeys, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonObjKeysWithCtx(ctx context.Context, key string, path string) ([]string, error) {
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.OBJKEYS", key)
 	if path != "" { // 如果 path 不为空, 则添加到参数中
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	if err := cmd.Err(); err != nil {
 		if err == redisHd.Nil {
@@ -551,13 +813,18 @@ func (that *KRedis) JsonObjKeys(key string, path string) ([]string, error) {
 // path: 可选的 JSON Path.如果为空字符串, 则返回根对象的键.
 // 返回值：一个包含对象键的字符串切片.如果键、路径不存在或路径对应的不是对象, 则返回 nil 切片和 nil 错误.
 func (that *KRedis) JsonObjLen(key string, path string) ([]int64, error) {
+	return that.JsonObjLenWithCtx(that.ctx.Context(), key, path)
+}
+
+// JsonObjLenWithCtx 同 JsonObjLen, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (that *KRedis) JsonObjLenWithCtx(ctx context.Context, key string, path string) ([]int64, error) {
 	args := make([]any, 0, 3)
 	args = append(args, "JSON.OBJLEN", key)
 	if path != "" { // 如果 path 不为空, 则添加到参数中
 		args = append(args, path)
 	}
 
-	cmd := that.Client.Do(that.ctx.Context(), args...)
+	cmd := that.Client.Do(ctx, args...)
 
 	if err := cmd.Err(); err != nil {
 		if err == redisHd.Nil {
@@ -596,29 +863,60 @@ func (mr *KRedis) Pipeline() redisHd.Pipeliner {
 }
 
 func (mr *KRedis) Dump(key string) (string, error) {
-	return mr.Client.Dump(mr.ctx.Context(), key).Result()
+	return mr.DumpWithCtx(mr.ctx.Context(), key)
+}
+
+// DumpWithCtx 同 Dump, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) DumpWithCtx(ctx context.Context, key string) (string, error) {
+	return mr.Client.Dump(ctx, key).Result()
 }
 
 func (mr *KRedis) RestoreReplace(key string, ttl time.Duration, value string) (string, error) {
-	return mr.Client.RestoreReplace(mr.ctx.Context(), key, ttl, value).Result()
+	return mr.RestoreReplaceWithCtx(mr.ctx.Context(), key, ttl, value)
+}
+
+// RestoreReplaceWithCtx 同 RestoreReplace, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) RestoreReplaceWithCtx(ctx context.Context, key string, ttl time.Duration, value string) (string, error) {
+	return mr.Client.RestoreReplace(ctx, key, ttl, value).Result()
 }
 
 func (mr *KRedis) Restore(key string, ttl time.Duration, value string) (string, error) {
-	return mr.Client.Restore(mr.ctx.Context(), key, ttl, value).Result()
+	return mr.RestoreWithCtx(mr.ctx.Context(), key, ttl, value)
+}
+
+// RestoreWithCtx 同 Restore, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) RestoreWithCtx(ctx context.Context, key string, ttl time.Duration, value string) (string, error) {
+	return mr.Client.Restore(ctx, key, ttl, value).Result()
 }
 
 // 删除一批key
 func (mr *KRedis) Del(keys ...string) (int64, error) {
-	return mr.Client.Del(mr.ctx.Context(), keys...).Result()
+	return mr.DelWithCtx(mr.ctx.Context(), keys...)
+}
+
+// DelWithCtx 同 Del, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) DelWithCtx(ctx context.Context, keys ...string) (int64, error) {
+	return mr.Client.Del(ctx, keys...).Result()
 }
 
 // 探测服务是否正常
 func (mr *KRedis) Ping() bool {
-	_, err := mr.Client.Ping(mr.ctx.Context()).Result()
+	return mr.PingWithCtx(mr.ctx.Context())
+}
+
+// PingWithCtx 同 Ping, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) PingWithCtx(ctx context.Context) bool {
+	_, err := mr.Client.Ping(ctx).Result()
 	return nil == err
 }
 
+// 会把所有 key 读入内存后在客户端过滤, 并逐个执行 TYPE, PTTL 与 DUMP; 数据量较大时使用 ScanKeys 或 ScanRecords
 func (mr *KRedis) ScanMatch(limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
+	return mr.ScanMatchWithCtx(mr.ctx.Context(), limit, aboutTypes, ignoreKeys, includeKeys, needDel, logf)
+}
+
+// ScanMatchWithCtx 同 ScanMatch, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) ScanMatchWithCtx(ctx context.Context, limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
 	cursor := uint64(0)
 	allKeys := make([]string, 0, 50000)
 
@@ -626,7 +924,7 @@ func (mr *KRedis) ScanMatch(limit int, aboutTypes []string, ignoreKeys []string,
 	for {
 		var keys []string
 		err := error(nil)
-		keys, cursor, err = mr.Client.Scan(mr.ctx.Context(), cursor, "", int64(limit)).Result()
+		keys, cursor, err = mr.Client.Scan(ctx, cursor, "", int64(limit)).Result()
 		if nil != err {
 			return nil, err
 		}
@@ -656,7 +954,7 @@ func (mr *KRedis) ScanMatch(limit int, aboutTypes []string, ignoreKeys []string,
 			}
 		}
 
-		dataType, err := mr.Type(key)
+		dataType, err := mr.TypeWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -670,12 +968,12 @@ func (mr *KRedis) ScanMatch(limit int, aboutTypes []string, ignoreKeys []string,
 			continue
 		}
 
-		ttl, err := mr.PTTL(key)
+		ttl, err := mr.PTTLWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
 
-		data, err := mr.Dump(key)
+		data, err := mr.DumpWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -686,7 +984,13 @@ func (mr *KRedis) ScanMatch(limit int, aboutTypes []string, ignoreKeys []string,
 	return dataList, nil
 }
 
+// 会把所有 key 读入内存后在客户端过滤, 并逐个执行 TYPE, PTTL 与 DUMP; 数据量较大时使用 ScanKeys 或 ScanRecords
 func (mr *KRedis) Scan(limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
+	return mr.ScanWithCtx(mr.ctx.Context(), limit, aboutTypes, ignoreKeys, includeKeys, needDel, logf)
+}
+
+// ScanWithCtx 同 Scan, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) ScanWithCtx(ctx context.Context, limit int, aboutTypes []string, ignoreKeys []string, includeKeys []string, needDel bool, logf klogger.AppLogFuncWithTag) ([]*RedisRecord, error) {
 	cursor := uint64(0)
 	allKeys := make([]string, 0, 50000)
 
@@ -696,7 +1000,7 @@ func (mr *KRedis) Scan(limit int, aboutTypes []string, ignoreKeys []string, incl
 	for {
 		var keys []string
 		err := error(nil)
-		keys, cursor, err = mr.Client.Scan(mr.ctx.Context(), cursor, "", int64(limit)).Result()
+		keys, cursor, err = mr.Client.Scan(ctx, cursor, "", int64(limit)).Result()
 		if nil != err {
 			return nil, err
 		}
@@ -728,7 +1032,7 @@ func (mr *KRedis) Scan(limit int, aboutTypes []string, ignoreKeys []string, incl
 			}
 		}
 
-		dataType, err := mr.Type(key)
+		dataType, err := mr.TypeWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -738,12 +1042,12 @@ func (mr *KRedis) Scan(limit int, aboutTypes []string, ignoreKeys []string, incl
 			continue
 		}
 
-		ttl, err := mr.PTTL(key)
+		ttl, err := mr.PTTLWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
 
-		data, err := mr.Dump(key)
+		data, err := mr.DumpWithCtx(ctx, key)
 		if nil != err {
 			return nil, err
 		}
@@ -760,7 +1064,12 @@ func (mr *KRedis) Scan(limit int, aboutTypes []string, ignoreKeys []string, incl
 
 // 向指定topic发布消息
 func (mr *KRedis) Publish(topic string, payload any) error {
-	return mr.Client.Publish(mr.ctx.Context(), topic, payload).Err()
+	return mr.PublishWithCtx(mr.ctx.Context(), topic, payload)
+}
+
+// PublishWithCtx 同 Publish, 使用调用方传入的 ctx, 可以为单次调用设置超时或单独取消
+func (mr *KRedis) PublishWithCtx(ctx context.Context, topic string, payload any) error {
+	return mr.Client.Publish(ctx, topic, payload).Err()
 }
 
 // 使用pipeline 向指定topic发布多条消息
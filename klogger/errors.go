@@ -0,0 +1,239 @@
+package klogger
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/khan-lau/kutils/container/kstrings"
+
+	"go.uber.org/zap"
+)
+
+const maxErrorCauses = 32 // 展开错误链时最多记录的原因数量, 防止环形错误链
+
+// WithStack 为 err 附加当前调用栈, 使用 Logger 记录该错误时会输出 errorVerbose 字段.
+// 已经携带调用栈的错误(包括 github.com/pkg/errors 创建的错误)原样返回
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errorStack(err) != "" {
+		return err
+	}
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	return &stackError{err: err, pcs: pcs[:n]}
+}
+
+// stackError 携带调用栈的错误
+type stackError struct {
+	err error
+	pcs []uintptr
+}
+
+func (that *stackError) Error() string { return that.err.Error() }
+func (that *stackError) Unwrap() error { return that.err }
+
+// StackTrace 返回创建错误时的调用栈, 每个栈帧两行: 函数名, 文件:行号
+func (that *stackError) StackTrace() string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(that.pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Format 与 github.com/pkg/errors 保持一致, %+v 输出错误信息与调用栈
+func (that *stackError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, that.Error())
+			io.WriteString(s, "\n")
+			io.WriteString(s, that.StackTrace())
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, that.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", that.Error())
+	}
+}
+
+///////////////////////////////////////////////////////////////
+
+// splitTrailingError 按 SLF4J 约定识别末尾的 error 参数.
+// 当最后一个参数是 error 时将其作为 throwable 返回; 如果它没有被模板中的占位符消费, 同时将其从参数中移除.
+// printf 为 true 时按 fmt 动词计算占位符, 否则按 `{}` 计算
+func splitTrailingError(template string, args []any, printf bool) ([]any, error) {
+	throwable := kstrings.ThrowableCandidate(args)
+	if throwable == nil {
+		return args, nil
+	}
+
+	consumed := 0
+	if printf {
+		consumed = countPrintfVerbs(template)
+	} else {
+		consumed = kstrings.CountPlaceholders(template)
+		if consumed == 0 { // 没有 `{}` 时 FormatString 退化为 fmt.Sprintf
+			consumed = countPrintfVerbs(template)
+		}
+	}
+
+	if consumed < len(args) {
+		return args[:len(args)-1], throwable
+	}
+	return args, throwable
+}
+
+// countPrintfVerbs 返回模板按 fmt 规则消费的参数个数: 宽度与精度中的 `*` 各消费一个参数,
+// `%[n]` 显式指定参数序号时取引用到的最大序号, `%%` 不消费参数
+func countPrintfVerbs(template string) int {
+	argNum, consumed := 0, 0
+	use := func() {
+		argNum++
+		consumed = max(consumed, argNum)
+	}
+	// argIndex 解析 `[n]`, 之后的参数从第 n 个开始
+	argIndex := func(i int) int {
+		if i >= len(template) || template[i] != '[' {
+			return i
+		}
+		end := strings.IndexByte(template[i:], ']')
+		if end < 0 {
+			return i
+		}
+		if n, err := strconv.Atoi(template[i+1 : i+end]); err == nil && n > 0 {
+			argNum = n - 1
+		}
+		return i + end + 1
+	}
+	// widthOrPrecision 解析数字或 `*`
+	widthOrPrecision := func(i int) int {
+		if i < len(template) && template[i] == '*' {
+			use()
+			return i + 1
+		}
+		for i < len(template) && template[i] >= '0' && template[i] <= '9' {
+			i++
+		}
+		return i
+	}
+
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(template) && strings.IndexByte("+-# 0", template[i]) >= 0 {
+			i++
+		}
+		i = widthOrPrecision(argIndex(i))
+		if i < len(template) && template[i] == '.' {
+			i = widthOrPrecision(argIndex(i + 1))
+		}
+		i = argIndex(i)
+		if i >= len(template) {
+			break
+		}
+		if template[i] != '%' {
+			use()
+		}
+	}
+	return consumed
+}
+
+// errorFields 将错误转换为结构化字段:
+//   - error: 错误信息
+//   - errorCauses: 展开 errors.Join 与 %w 包装链得到的各层原因
+//   - errorVerbose: 错误链中携带的调用栈
+func errorFields(err error) []zap.Field {
+	fields := make([]zap.Field, 0, 3)
+	fields = append(fields, zap.String("error", err.Error()))
+
+	if causes := errorCauses(err); len(causes) > 0 {
+		fields = append(fields, zap.Strings("errorCauses", causes))
+	}
+	if stack := errorStack(err); stack != "" {
+		fields = append(fields, zap.String("errorVerbose", stack))
+	}
+	return fields
+}
+
+// errorCauses 深度优先展开错误链, 与上一层信息相同的纯包装层(如 WithStack)被忽略
+func errorCauses(err error) []string {
+	causes := make([]string, 0, 4)
+
+	var walk func(parent error, depth int)
+	walk = func(parent error, depth int) {
+		if depth > maxErrorCauses {
+			return
+		}
+
+		var children []error
+		switch e := parent.(type) {
+		case interface{ Unwrap() []error }: // errors.Join, fmt.Errorf 多个 %w
+			children = e.Unwrap()
+		case interface{ Errors() []error }: // go.uber.org/multierr
+			children = e.Errors()
+		case interface{ Unwrap() error }:
+			children = []error{e.Unwrap()}
+		}
+
+		for _, child := range children {
+			if child == nil || len(causes) >= maxErrorCauses {
+				continue
+			}
+			if child.Error() != parent.Error() {
+				causes = append(causes, child.Error())
+			}
+			walk(child, depth+1)
+		}
+	}
+	walk(err, 0)
+	return causes
+}
+
+// errorStack 深度优先查找错误链中第一个携带调用栈的错误, 返回其详细信息,
+// 支持 WithStack 创建的错误, 以及 %+v 输出与 Error() 不同的错误(如 github.com/pkg/errors)
+func errorStack(err error) string {
+	var walk func(err error, depth int) string
+	walk = func(err error, depth int) string {
+		if err == nil || depth > maxErrorCauses {
+			return ""
+		}
+		if f, ok := err.(fmt.Formatter); ok {
+			if verbose := fmt.Sprintf("%+v", f); verbose != err.Error() {
+				return verbose
+			}
+		}
+
+		var children []error
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }: // errors.Join, fmt.Errorf 多个 %w
+			children = e.Unwrap()
+		case interface{ Errors() []error }: // go.uber.org/multierr
+			children = e.Errors()
+		case interface{ Unwrap() error }:
+			children = []error{e.Unwrap()}
+		}
+		for _, child := range children {
+			if stack := walk(child, depth+1); stack != "" {
+				return stack
+			}
+		}
+		return ""
+	}
+	return walk(err, 0)
+}
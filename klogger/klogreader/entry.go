@@ -0,0 +1,197 @@
+package klogreader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khan-lau/kutils/datetime"
+	"github.com/khan-lau/kutils/klogger"
+)
+
+var (
+	ErrNotLogLine = errors.New("not a klogger line")
+
+	ansiPattern   = regexp.MustCompile("\x1b\\[[0-9;]*m")            // 彩色输出的 ANSI 转义序列
+	callerPattern = regexp.MustCompile(`^[^\s]+\.[A-Za-z0-9]+:\d+$`) // dir/file.go:123
+)
+
+// Entry 一条解析后的日志
+type Entry struct {
+	Time    time.Time      // 日志时间
+	Level   klogger.Level  // 日志等级
+	Caller  string         // 调用位置, 如 `kredis/redisdb.go:120`, 没有时为空
+	Message string         // 日志内容
+	Fields  map[string]any // 结构化字段, 没有时为nil
+	Stack   string         // 跟随在日志后的多行内容, 如调用栈
+	Raw     string         // 原始文本, 多行日志包含所有行
+	Source  string         // 来源文件
+}
+
+// ParseLine 解析单行日志, 支持 GetLoggerWithConfig 输出的 console 格式以及 zap 的 JSON 格式.
+// console 格式为 `时间\t等级\t调用位置\t内容[\t{字段}]`, 等级可以带颜色
+func ParseLine(line string) (*Entry, error) {
+	line = strings.TrimRight(line, "\r\n")
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "{") {
+		return parseJSONLine(line)
+	}
+	return parseConsoleLine(line)
+}
+
+func parseConsoleLine(line string) (*Entry, error) {
+	parts := strings.SplitN(ansiPattern.ReplaceAllString(line, ""), "\t", 4)
+	if len(parts) < 3 {
+		return nil, ErrNotLogLine
+	}
+
+	tm, err := time.ParseInLocation(datetime.DATETIME_FORMATTER_Mill, parts[0], time.Local)
+	if err != nil {
+		return nil, ErrNotLogLine
+	}
+	lvl, err := klogger.ParseLevel(parts[1])
+	if err != nil {
+		return nil, ErrNotLogLine
+	}
+
+	entry := &Entry{Time: tm, Level: lvl, Raw: line}
+
+	rest := strings.Join(parts[2:], "\t")
+	if callerPattern.MatchString(parts[2]) {
+		entry.Caller = parts[2]
+		rest = ""
+		if len(parts) == 4 {
+			rest = parts[3]
+		}
+	}
+
+	// 结构化字段位于最后一个制表符之后, 以 `{` 开头并以 `}` 结尾
+	if pos := strings.LastIndex(rest, "\t"); pos >= 0 {
+		tail := rest[pos+1:]
+		if strings.HasPrefix(tail, "{") && strings.HasSuffix(tail, "}") {
+			fields := make(map[string]any)
+			if json.Unmarshal([]byte(tail), &fields) == nil {
+				entry.Fields = fields
+				rest = rest[:pos]
+			}
+		}
+	}
+	entry.Message = rest
+	return entry, nil
+}
+
+func parseJSONLine(line string) (*Entry, error) {
+	fields := make(map[string]any)
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, ErrNotLogLine
+	}
+
+	entry := &Entry{Raw: line}
+
+	levelVal, ok := fields["level"].(string)
+	if !ok {
+		return nil, ErrNotLogLine
+	}
+	lvl, err := klogger.ParseLevel(ansiPattern.ReplaceAllString(levelVal, ""))
+	if err != nil {
+		return nil, ErrNotLogLine
+	}
+	entry.Level = lvl
+	delete(fields, "level")
+
+	switch ts := fields["ts"].(type) {
+	case string:
+		tm, err := parseTimestamp(ts)
+		if err != nil {
+			return nil, ErrNotLogLine
+		}
+		entry.Time = tm
+	case float64: // zap 默认的 EpochTimeEncoder, 单位 秒
+		sec, frac := int64(ts), ts-float64(int64(ts))
+		entry.Time = time.Unix(sec, int64(frac*float64(time.Second)))
+	default:
+		return nil, ErrNotLogLine
+	}
+	delete(fields, "ts")
+
+	if caller, ok := fields["caller"].(string); ok {
+		entry.Caller = caller
+		delete(fields, "caller")
+	}
+	if msg, ok := fields["msg"].(string); ok {
+		entry.Message = msg
+		delete(fields, "msg")
+	}
+	if stack, ok := fields["stacktrace"].(string); ok {
+		entry.Stack = stack
+		delete(fields, "stacktrace")
+	}
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry, nil
+}
+
+// parseTimestamp 解析日志中的时间, 支持 DATETIME_FORMATTER_Mill, DATETIME_FORMATTER, RFC3339 以及 ISO8601
+func parseTimestamp(str string) (time.Time, error) {
+	layouts := []string{
+		datetime.DATETIME_FORMATTER_Mill,
+		datetime.DATETIME_FORMATTER,
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.000Z0700",
+	}
+	for _, layout := range layouts {
+		if tm, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return tm, nil
+		}
+	}
+	if sec, err := strconv.ParseFloat(str, 64); err == nil {
+		return time.Unix(int64(sec), int64((sec-float64(int64(sec)))*float64(time.Second))), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %s", str)
+}
+
+// appendLine 将不能独立解析的行(如调用栈)追加到日志中
+func (that *Entry) appendLine(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if that.Stack == "" {
+		that.Stack = line
+	} else {
+		that.Stack += "\n" + line
+	}
+	that.Raw += "\n" + line
+}
+
+// entryParser 按行组装日志, 不能解析的行被视为上一条日志的延续
+type entryParser struct {
+	source  string
+	pending *Entry
+}
+
+// feed 输入一行, 返回已组装完成的上一条日志, 没有时返回nil
+func (that *entryParser) feed(line string) *Entry {
+	entry, err := ParseLine(line)
+	if err != nil {
+		// 文件开头不属于任何日志的行被丢弃
+		if that.pending != nil && strings.TrimSpace(line) != "" {
+			that.pending.appendLine(line)
+		}
+		return nil
+	}
+	entry.Source = that.source
+
+	done := that.pending
+	that.pending = entry
+	return done
+}
+
+// flush 返回尚未输出的最后一条日志
+func (that *entryParser) flush() *Entry {
+	done := that.pending
+	that.pending = nil
+	return done
+}
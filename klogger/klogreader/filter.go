@@ -0,0 +1,110 @@
+package klogreader
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/khan-lau/kutils/klogger"
+)
+
+// Filter 日志过滤条件, 所有条件同时满足才算匹配, 未设置的条件不参与过滤
+type Filter struct {
+	since    time.Time
+	until    time.Time
+	minLevel *klogger.Level
+	levels   []klogger.Level
+	caller   string
+	pattern  *regexp.Regexp
+}
+
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+// 设置起始时间(包含)
+func (that *Filter) SetSince(since time.Time) *Filter {
+	that.since = since
+	return that
+}
+
+// 设置结束时间(不包含)
+func (that *Filter) SetUntil(until time.Time) *Filter {
+	that.until = until
+	return that
+}
+
+// 设置最低日志等级, 低于该等级的日志被过滤掉
+func (that *Filter) SetMinLevel(lvl klogger.Level) *Filter {
+	that.minLevel = &lvl
+	return that
+}
+
+// 设置允许的日志等级, 不在列表中的日志被过滤掉
+func (that *Filter) SetLevels(levels ...klogger.Level) *Filter {
+	that.levels = levels
+	return that
+}
+
+// 设置调用位置, 调用位置中不包含 caller 的日志被过滤掉, 例如 "kredis/" 或 "redisdb.go:120"
+func (that *Filter) SetCaller(caller string) *Filter {
+	that.caller = caller
+	return that
+}
+
+// 设置正则表达式, 原始文本(包括结构化字段与调用栈)不匹配的日志被过滤掉
+func (that *Filter) SetPattern(pattern *regexp.Regexp) *Filter {
+	that.pattern = pattern
+	return that
+}
+
+// Since 返回起始时间, 未设置时为零值
+func (that *Filter) Since() time.Time {
+	if that == nil {
+		return time.Time{}
+	}
+	return that.since
+}
+
+// Until 返回结束时间, 未设置时为零值
+func (that *Filter) Until() time.Time {
+	if that == nil {
+		return time.Time{}
+	}
+	return that.until
+}
+
+// Match 判断日志是否满足过滤条件, nil Filter 匹配所有日志
+func (that *Filter) Match(entry *Entry) bool {
+	if that == nil {
+		return true
+	}
+	if !that.since.IsZero() && entry.Time.Before(that.since) {
+		return false
+	}
+	if !that.until.IsZero() && !entry.Time.Before(that.until) {
+		return false
+	}
+	if that.minLevel != nil && entry.Level < *that.minLevel {
+		return false
+	}
+	if len(that.levels) > 0 {
+		found := false
+		for _, lvl := range that.levels {
+			if lvl == entry.Level {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if that.caller != "" && !strings.Contains(entry.Caller, that.caller) {
+		return false
+	}
+	if that.pattern != nil && !that.pattern.MatchString(entry.Raw) {
+		return false
+	}
+	return true
+}
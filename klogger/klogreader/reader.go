@@ -0,0 +1,309 @@
+package klogreader
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EntryCallback 处理一条日志, 返回 false 时停止读取
+type EntryCallback func(entry *Entry) bool
+
+// Scan 从 r 中逐条读取日志, 满足 filter 的日志交给 fn 处理, source 记录在 Entry.Source 中
+func Scan(r io.Reader, source string, filter *Filter, fn EntryCallback) error {
+	parser := &entryParser{source: source}
+	reader := bufio.NewReaderSize(r, 64*1024)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if entry := parser.feed(line); entry != nil && filter.Match(entry) {
+				if !fn(entry) {
+					return nil
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if entry := parser.flush(); entry != nil && filter.Match(entry) {
+		fn(entry)
+	}
+	return nil
+}
+
+// ReadFile 读取单个日志文件
+func ReadFile(path string, filter *Filter, fn EntryCallback) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return Scan(file, path, filter, fn)
+}
+
+// ReadFiles 按顺序读取多个日志文件, 最后修改时间早于 filter 起始时间的文件被跳过
+func ReadFiles(paths []string, filter *Filter, fn EntryCallback) error {
+	stopped := false
+	callback := func(entry *Entry) bool {
+		if !fn(entry) {
+			stopped = true
+			return false
+		}
+		return true
+	}
+
+	for _, path := range paths {
+		if since := filter.Since(); !since.IsZero() {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(since) {
+				continue
+			}
+		}
+		if err := ReadFile(path, filter, callback); err != nil {
+			return err
+		}
+		if stopped {
+			break
+		}
+	}
+	return nil
+}
+
+// RotatedFiles 返回 rotatelogs 软链接对应的所有滚动文件, 按修改时间从旧到新排序.
+// 例如 logs/app.log 对应 logs/app.202401011200.log, logs/app.202401021200.log ...
+// 如果 linkPath 不是软链接且没有滚动文件, 返回 linkPath 本身
+func RotatedFiles(linkPath string) ([]string, error) {
+	suffix := filepath.Ext(linkPath)
+	prefix := strings.TrimSuffix(linkPath, suffix)
+
+	matches, err := filepath.Glob(prefix + ".*" + suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	type fileItem struct {
+		path    string
+		modTime time.Time
+	}
+	items := make([]fileItem, 0, len(matches))
+	for _, match := range matches {
+		if match == linkPath {
+			continue
+		}
+		info, err := os.Lstat(match)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		items = append(items, fileItem{path: match, modTime: info.ModTime()})
+	}
+
+	if len(items) == 0 {
+		if _, err := os.Stat(linkPath); err != nil {
+			return nil, err
+		}
+		return []string{linkPath}, nil
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].modTime.Equal(items[j].modTime) {
+			return items[i].path < items[j].path
+		}
+		return items[i].modTime.Before(items[j].modTime)
+	})
+
+	files := make([]string, 0, len(items))
+	for _, item := range items {
+		files = append(files, item.path)
+	}
+	return files, nil
+}
+
+// Tail 返回文件中最后 n 条满足 filter 的日志, n <= 0 时返回所有满足 filter 的日志,
+// 同时返回读取结束时的文件偏移量, 可作为 Follow 的起点
+func Tail(path string, n int, filter *Filter) ([]*Entry, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	entries := make([]*Entry, 0, max(n, 16))
+	err = Scan(file, path, filter, func(entry *Entry) bool {
+		if n > 0 && len(entries) == n {
+			copy(entries, entries[1:])
+			entries = entries[:n-1]
+		}
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, offset, nil
+}
+
+// followIdleFlush 最后一行之后没有新数据的时间超过该值时, 认为多行日志(如调用栈)已写完并输出
+const followIdleFlush = time.Second
+
+// Follow 持续读取 path 新增的日志, 直到 ctx 被取消或 fn 返回 false.
+// path 可以是 rotatelogs 的软链接, 软链接指向新文件时, 读完旧文件剩余内容后自动切换到新文件;
+// 文件被截断时从头开始读取. offset < 0 表示从文件末尾开始, interval 为轮询周期.
+// 一条日志在读到下一条日志的首行, 或之后 1 秒内没有新数据时才交给 fn, 以免多行日志被拆开
+func Follow(ctx context.Context, path string, offset int64, filter *Filter, interval time.Duration, fn EntryCallback) error {
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+
+	follower := &follower{path: path, filter: filter, fn: fn}
+	if err := follower.open(offset); err != nil {
+		return err
+	}
+	defer follower.close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if !follower.drain() {
+			return nil
+		}
+		if !follower.checkRotate() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			follower.flush()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type follower struct {
+	path   string
+	filter *Filter
+	fn     EntryCallback
+
+	target   string
+	file     *os.File
+	reader   *bufio.Reader
+	offset   int64
+	partial  string
+	parser   *entryParser
+	lastRead time.Time // 最后一次读到完整行的时间
+}
+
+func (that *follower) open(offset int64) error {
+	target, err := filepath.EvalSymlinks(that.path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+
+	if offset < 0 {
+		offset, err = file.Seek(0, io.SeekEnd)
+	} else {
+		offset, err = file.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	that.close()
+	that.target = target
+	that.file = file
+	that.reader = bufio.NewReaderSize(file, 64*1024)
+	that.offset = offset
+	that.partial = ""
+	that.parser = &entryParser{source: target}
+	return nil
+}
+
+func (that *follower) close() {
+	if that.file != nil {
+		that.file.Close()
+		that.file = nil
+	}
+}
+
+// drain 读取当前文件新增的完整行, 返回 false 表示调用方要求停止
+func (that *follower) drain() bool {
+	for {
+		line, err := that.reader.ReadString('\n')
+		that.offset += int64(len(line))
+		if err != nil {
+			// 不完整的行保留到下次读取
+			that.partial += line
+			break
+		}
+		line = that.partial + line
+		that.partial = ""
+		that.lastRead = time.Now()
+		if entry := that.parser.feed(line); entry != nil && that.filter.Match(entry) {
+			if !that.fn(entry) {
+				return false
+			}
+		}
+	}
+
+	// 暂无新数据时后续行可能还在写入, 空闲一段时间后才输出尚未结束的日志
+	if time.Since(that.lastRead) < followIdleFlush {
+		return true
+	}
+	return that.flush()
+}
+
+// flush 输出尚未结束的日志, 返回 false 表示调用方要求停止
+func (that *follower) flush() bool {
+	if entry := that.parser.flush(); entry != nil && that.filter.Match(entry) {
+		return that.fn(entry)
+	}
+	return true
+}
+
+// checkRotate 检查软链接是否指向了新文件或当前文件是否被截断, 返回 false 表示调用方要求停止
+func (that *follower) checkRotate() bool {
+	target, err := filepath.EvalSymlinks(that.path)
+	if err != nil {
+		return true // 滚动过程中软链接可能短暂不存在
+	}
+
+	if target != that.target {
+		if !that.drain() || !that.flush() {
+			return false
+		}
+		if err := that.open(0); err != nil {
+			return true
+		}
+		return that.drain()
+	}
+
+	if info, err := that.file.Stat(); err == nil && info.Size() < that.offset {
+		if !that.flush() {
+			return false
+		}
+		if err := that.open(0); err == nil {
+			return that.drain()
+		}
+	}
+	return true
+}
@@ -31,17 +31,18 @@ package klogger
 // `
 
 type LoggerConfigure struct {
-	Level         Level  `json:"logLevel"` // 日志等级
-	Colorful      bool   `json:"colorful"` // 是否需要彩色
-	Async         bool   `json:"async"`    // 是否异步输出日志, 默认同步输出
-	flushInterval int64  // 强制刷盘周期, 单位 毫秒
-	bufferSize    int64  // 缓冲区大小, 单位 Byte
-	MaxAge        int    `json:"maxAge"`       // 日志最长保留时间, 单位 小时, MaxAge 与 MaxCount 必须只能设置一个
-	MaxSize       int64  `json:"maxSize"`      // 单文件最大滚动大小, 单位 byte, 超过后强制滚动
-	MaxCount      uint   `json:"maxCount"`     // 最多保留的备份文件数量, 默认50个, MaxAge 与 MaxCount 必须只能设置一个
-	RotationTime  int    `json:"rotationTime"` // 日志滚动周期, 单位 小时, 24小时滚动一个文件
-	ToConsole     bool   `json:"console"`      // 是否输出到控制台
-	LogFile       string `json:"logFile"`      // 输出到文件, 如果文件名为空, 则不输出到文件
+	Level         Level            `json:"logLevel"` // 日志等级
+	Colorful      bool             `json:"colorful"` // 是否需要彩色
+	Async         bool             `json:"async"`    // 是否异步输出日志, 默认同步输出
+	flushInterval int64            // 强制刷盘周期, 单位 毫秒
+	bufferSize    int64            // 缓冲区大小, 单位 Byte
+	MaxAge        int              `json:"maxAge"`       // 日志最长保留时间, 单位 小时, MaxAge 与 MaxCount 必须只能设置一个
+	MaxSize       int64            `json:"maxSize"`      // 单文件最大滚动大小, 单位 byte, 超过后强制滚动
+	MaxCount      uint             `json:"maxCount"`     // 最多保留的备份文件数量, 默认50个, MaxAge 与 MaxCount 必须只能设置一个
+	RotationTime  int              `json:"rotationTime"` // 日志滚动周期, 单位 小时, 24小时滚动一个文件
+	ToConsole     bool             `json:"console"`      // 是否输出到控制台
+	LogFile       string           `json:"logFile"`      // 输出到文件, 如果文件名为空, 则不输出到文件
+	Syslog        *SyslogConfigure `json:"syslog"`       // 输出到 syslog, 为空则不输出到 syslog
 }
 
 func NewConfigure() *LoggerConfigure {
@@ -55,6 +56,7 @@ func NewConfigure() *LoggerConfigure {
 		RotationTime: 24,                      // 日志滚动周期, 单位 小时, 24小时滚动一个文件
 		ToConsole:    false,                   // 是否输出到控制台
 		LogFile:      "",
+		Syslog:       nil,
 	}
 }
 
@@ -111,6 +113,12 @@ func (that *LoggerConfigure) SetLogFile(file string) *LoggerConfigure {
 	return that
 }
 
+// 设置 syslog 输出, 为 nil 则不输出到 syslog
+func (that *LoggerConfigure) SetSyslog(conf *SyslogConfigure) *LoggerConfigure {
+	that.Syslog = conf
+	return that
+}
+
 func (that *LoggerConfigure) FlushInterval() int64 {
 	return that.flushInterval
 }
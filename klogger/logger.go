@@ -1,10 +1,14 @@
 package klogger
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	rotatelogs "github.com/khan-lau/file-rotatelogs"
@@ -71,8 +75,31 @@ func (l Level) String() string {
 	panic("invalid LogLevel")
 }
 
+// ParseLevel 将日志等级名称转换为 Level, 不区分大小写, 同时支持 zap 输出的 "WARN" 与 String() 输出的 "WARNING"
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "DEBUG":
+		return DebugLevel, nil
+	case "INFO":
+		return InfoLevel, nil
+	case "WARN", "WARNING":
+		return WarnLevel, nil
+	case "ERROR":
+		return ErrorLevel, nil
+	case "DPANIC":
+		return DPanicLevel, nil
+	case "PANIC":
+		return PanicLevel, nil
+	case "FATAL":
+		return FatalLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("invalid log level: %s", name)
+}
+
 type Logger struct {
-	log *zap.Logger
+	log       *zap.Logger
+	closers   []io.Closer // Close 时按顺序关闭, 先关闭缓冲区再关闭底层输出
+	closeOnce sync.Once
 }
 
 // var log *zap.Logger
@@ -113,6 +140,8 @@ func GetLoggerWithConfig(conf *LoggerConfigure) *Logger {
 
 	// var multiSyncer zapcore.WriteSyncer
 	syncers := make([]zapcore.WriteSyncer, 0, 10)
+	closers := make([]io.Closer, 0, 3)
+	var fileCloser io.Closer
 
 	if len(filename) > 0 {
 		file_suffix := path.Ext(filename)                         // 获取文件扩展名
@@ -164,7 +193,10 @@ func GetLoggerWithConfig(conf *LoggerConfigure) *Logger {
 		}
 
 		logFilePattern := filen_prefix + ".%Y%m%d%H%M" + file_suffix
-		logFile, _ := rotatelogs.New(logFilePattern, options...)
+		logFile, err := rotatelogs.New(logFilePattern, options...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "klogger: %v\n", err)
+		}
 
 		// logFile := &lumberjack.Logger{
 		// 	Filename:   filen_prefix + file_suffix,
@@ -175,7 +207,10 @@ func GetLoggerWithConfig(conf *LoggerConfigure) *Logger {
 		// 	Compress:   true,        // 是否压缩
 		// }
 
-		syncers = append(syncers, zapcore.AddSync(logFile))
+		if logFile != nil {
+			syncers = append(syncers, zapcore.AddSync(logFile))
+			fileCloser = logFile
+		}
 	}
 
 	if conf.ToConsole {
@@ -183,33 +218,57 @@ func GetLoggerWithConfig(conf *LoggerConfigure) *Logger {
 		syncers = append(syncers, zapcore.AddSync(os.Stdout))
 	}
 
-	if len(syncers) == 0 {
+	var syslogWriter *SyslogWriter
+	if conf.Syslog != nil {
+		writer, err := NewSyslogWriter(conf.Syslog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "klogger: %v\n", err)
+		} else {
+			syslogWriter = writer
+		}
+	}
+
+	if len(syncers) == 0 && syslogWriter == nil {
 		syncers = append(syncers, zapcore.AddSync(os.Stdout))
 	}
 
-	// 1. 先合并所有的写入端
-	multiSyncer := zapcore.NewMultiWriteSyncer(syncers...)
+	cores := make([]zapcore.Core, 0, 2)
+	if len(syncers) > 0 {
+		// 1. 先合并所有的写入端
+		multiSyncer := zapcore.NewMultiWriteSyncer(syncers...)
 
-	var core zapcore.Core
-	if conf.Async {
-		// 2. 使用官方推荐的 BufferedWriteSyncer 实现异步批量写入
-		bufferedWriter := &zapcore.BufferedWriteSyncer{
-			WS:            multiSyncer,
-			Size:          int(conf.BufferSize()),                                 // 缓冲区大小, 默认4M
-			FlushInterval: time.Duration(conf.FlushInterval()) * time.Millisecond, // 强制刷盘周期, 默认1000ms
+		if conf.Async {
+			// 2. 使用官方推荐的 BufferedWriteSyncer 实现异步批量写入
+			bufferedWriter := &zapcore.BufferedWriteSyncer{
+				WS:            multiSyncer,
+				Size:          int(conf.BufferSize()),                                 // 缓冲区大小, 默认4M
+				FlushInterval: time.Duration(conf.FlushInterval()) * time.Millisecond, // 强制刷盘周期, 默认1000ms
 
+			}
+			closers = append(closers, closerFunc(bufferedWriter.Stop)) // Stop 会先将缓冲区写入底层输出
+
+			cores = append(cores, zapcore.NewCore(encoder, //NewJSONEncoder
+				bufferedWriter, //
+				zapcore.Level(zapcore.Level(conf.Level))))
+		} else {
+			cores = append(cores, zapcore.NewCore(encoder, multiSyncer, zapcore.Level(zapcore.Level(conf.Level))))
 		}
+	}
 
-		core = zapcore.NewCore(encoder, //NewJSONEncoder
-			bufferedWriter, //
-			zapcore.Level(zapcore.Level(conf.Level)))
-	} else {
-		core = zapcore.NewCore(encoder, multiSyncer, zapcore.Level(zapcore.Level(conf.Level)))
+	if fileCloser != nil {
+		closers = append(closers, fileCloser)
 	}
 
+	if syslogWriter != nil {
+		closers = append(closers, syslogWriter)
+		// 3. syslog 输出, 断线期间日志缓存在 SyslogWriter 中, 重连后补发
+		cores = append(cores, newSyslogCore(syslogWriter, zapcore.Level(conf.Level)))
+	}
+
+	core := zapcore.NewTee(cores...)
 	log := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1)) // AddCaller() 显示文件名与行号; zap.AddCallerSkip(1)打印的文件名与行号在调用栈往外跳一层
 
-	return &Logger{log: log}
+	return &Logger{log: log, closers: closers}
 }
 
 func (that *Logger) Sync() {
@@ -218,40 +277,59 @@ func (that *Logger) Sync() {
 	}
 }
 
+// Close 将缓冲区中的日志写入输出, 然后依次停止异步写入, 关闭日志文件与 syslog 连接.
+// 可以重复调用, 之后的调用直接返回 nil; Close 之后不应再使用该 Logger 输出日志
+func (that *Logger) Close() error {
+	if that == nil {
+		return nil
+	}
+
+	var errs []error
+	that.closeOnce.Do(func() {
+		that.log.Sync()
+		for _, closer := range that.closers {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
 ///////////////////////////////////////////////////////////////
 
 func (that *Logger) Debug(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Debugf(template, args...)
+		that.logf(zapcore.DebugLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) Info(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Infof(template, args...)
+		that.logf(zapcore.InfoLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) Warrn(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Warnf(template, args...)
+		that.logf(zapcore.WarnLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) Error(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Errorf(template, args...)
+		that.logf(zapcore.ErrorLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) DPanic(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().DPanicf(template, args...)
+		that.logf(zapcore.DPanicLevel, 0, template, args)
 	}
 }
 func (that *Logger) Fatal(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Errorf(template, args...)
+		that.logf(zapcore.ErrorLevel, 0, template, args)
 	}
 }
 
@@ -259,36 +337,36 @@ func (that *Logger) Fatal(template string, args ...any) {
 
 func (that *Logger) D(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Debugf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.DebugLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) I(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Infof(kstrings.FormatString(template, args...))
+		that.logk(zapcore.InfoLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) W(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Warnf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.WarnLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) E(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Errorf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.ErrorLevel, 0, template, args)
 	}
 }
 
 func (that *Logger) DP(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().DPanicf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.DPanicLevel, 0, template, args)
 	}
 }
 func (that *Logger) F(template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().Errorf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.ErrorLevel, 0, template, args)
 	}
 }
 
@@ -296,37 +374,37 @@ func (that *Logger) F(template string, args ...any) {
 
 func (that *Logger) KDebug(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Debugf(template, args...)
+		that.logf(zapcore.DebugLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KInfo(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Infof(template, args...)
+		that.logf(zapcore.InfoLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KWarrn(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Warnf(template, args...)
+		that.logf(zapcore.WarnLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KError(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Errorf(template, args...)
+		that.logf(zapcore.ErrorLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KDPanic(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).DPanicf(template, args...)
+		that.logf(zapcore.DPanicLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KFatal(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Errorf(template, args...)
+		that.logf(zapcore.ErrorLevel, skip, template, args)
 	}
 }
 
@@ -334,36 +412,78 @@ func (that *Logger) KFatal(skip int, template string, args ...any) {
 
 func (that *Logger) KD(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Debugf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.DebugLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KI(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Infof(kstrings.FormatString(template, args...))
+		that.logk(zapcore.InfoLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KW(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Warnf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.WarnLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KE(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Errorf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.ErrorLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KDP(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).DPanicf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.DPanicLevel, skip, template, args)
 	}
 }
 
 func (that *Logger) KF(skip int, template string, args ...any) {
 	if that != nil {
-		that.log.Sugar().WithOptions(zap.AddCallerSkip(skip)).Errorf(kstrings.FormatString(template, args...))
+		that.logk(zapcore.ErrorLevel, skip, template, args)
+	}
+}
+
+///////////////////////////////////////////////////////////////
+
+// logf 输出 fmt 风格的日志, 末尾的 error 参数会被输出为结构化字段, 参见 splitTrailingError
+func (that *Logger) logf(lvl zapcore.Level, skip int, template string, args []any) {
+	if !that.log.Core().Enabled(lvl) {
+		return
+	}
+	args, throwable := splitTrailingError(template, args, true)
+	msg := template
+	if len(args) > 0 {
+		msg = fmt.Sprintf(template, args...)
+	}
+	that.write(lvl, skip, msg, throwable)
+}
+
+// logk 输出 `{}` 风格的日志, 末尾的 error 参数会被输出为结构化字段, 参见 splitTrailingError
+func (that *Logger) logk(lvl zapcore.Level, skip int, template string, args []any) {
+	if !that.log.Core().Enabled(lvl) {
+		return
 	}
+	args, throwable := splitTrailingError(template, args, false)
+	that.write(lvl, skip, kstrings.FormatString(template, args...), throwable)
 }
+
+func (that *Logger) write(lvl zapcore.Level, skip int, msg string, throwable error) {
+	// 额外跳过 write 与 logf/logk 两层
+	ce := that.log.WithOptions(zap.AddCallerSkip(skip+2)).Check(lvl, msg)
+	if ce == nil {
+		return
+	}
+	if throwable != nil {
+		ce.Write(errorFields(throwable)...)
+	} else {
+		ce.Write()
+	}
+}
+
+// closerFunc 将 func() error 适配为 io.Closer
+type closerFunc func() error
+
+func (that closerFunc) Close() error { return that() }
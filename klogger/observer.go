@@ -0,0 +1,294 @@
+package klogger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TestingT 断言辅助函数所需的最小测试接口, *testing.T 与 *testing.B 均满足该接口
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// LoggedEntry 观察者记录到的一条日志
+type LoggedEntry struct {
+	Level   Level          // 日志等级
+	Time    time.Time      // 日志时间
+	Message string         // 经过 `{}` 格式化后的日志内容
+	Caller  string         // 调用位置, 格式为 `dir/file.go:line`
+	Fields  map[string]any // 结构化字段
+}
+
+// LoggedEntries 日志记录的集合, 提供链式过滤方法
+type LoggedEntries []LoggedEntry
+
+// Len 返回日志条数
+func (that LoggedEntries) Len() int {
+	return len(that)
+}
+
+// Messages 返回所有日志的内容
+func (that LoggedEntries) Messages() []string {
+	messages := make([]string, 0, len(that))
+	for _, entry := range that {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+// Filter 返回满足 fn 的日志记录
+func (that LoggedEntries) Filter(fn func(entry LoggedEntry) bool) LoggedEntries {
+	filtered := make(LoggedEntries, 0, len(that))
+	for _, entry := range that {
+		if fn(entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// FilterLevel 过滤出指定等级的日志
+func (that LoggedEntries) FilterLevel(lvl Level) LoggedEntries {
+	return that.Filter(func(entry LoggedEntry) bool {
+		return entry.Level == lvl
+	})
+}
+
+// FilterLevelAbove 过滤出等级大于等于 lvl 的日志
+func (that LoggedEntries) FilterLevelAbove(lvl Level) LoggedEntries {
+	return that.Filter(func(entry LoggedEntry) bool {
+		return entry.Level >= lvl
+	})
+}
+
+// FilterMessage 过滤出内容与 msg 完全一致的日志
+func (that LoggedEntries) FilterMessage(msg string) LoggedEntries {
+	return that.Filter(func(entry LoggedEntry) bool {
+		return entry.Message == msg
+	})
+}
+
+// FilterMessageSnippet 过滤出内容包含 snippet 的日志
+func (that LoggedEntries) FilterMessageSnippet(snippet string) LoggedEntries {
+	return that.Filter(func(entry LoggedEntry) bool {
+		return strings.Contains(entry.Message, snippet)
+	})
+}
+
+// FilterCaller 过滤出调用位置包含 snippet 的日志, 例如 "service.go" 或 "service.go:42"
+func (that LoggedEntries) FilterCaller(snippet string) LoggedEntries {
+	return that.Filter(func(entry LoggedEntry) bool {
+		return strings.Contains(entry.Caller, snippet)
+	})
+}
+
+// FilterFieldKey 过滤出包含指定字段的日志
+func (that LoggedEntries) FilterFieldKey(key string) LoggedEntries {
+	return that.Filter(func(entry LoggedEntry) bool {
+		_, ok := entry.Fields[key]
+		return ok
+	})
+}
+
+// FilterField 过滤出包含指定字段且字段值相等的日志, 字段值按 fmt 格式化后比较
+func (that LoggedEntries) FilterField(key string, value any) LoggedEntries {
+	expected := fmt.Sprint(value)
+	return that.Filter(func(entry LoggedEntry) bool {
+		val, ok := entry.Fields[key]
+		return ok && fmt.Sprint(val) == expected
+	})
+}
+
+///////////////////////////////////////////////////////////////
+
+// LogRecorder 记录 observer logger 输出的所有日志, 线程安全
+type LogRecorder struct {
+	mu      sync.RWMutex
+	entries LoggedEntries
+}
+
+// Len 返回已记录的日志条数
+func (that *LogRecorder) Len() int {
+	that.mu.RLock()
+	defer that.mu.RUnlock()
+	return len(that.entries)
+}
+
+// All 返回已记录日志的快照
+func (that *LogRecorder) All() LoggedEntries {
+	that.mu.RLock()
+	defer that.mu.RUnlock()
+	return append(LoggedEntries{}, that.entries...)
+}
+
+// TakeAll 返回已记录日志的快照并清空记录
+func (that *LogRecorder) TakeAll() LoggedEntries {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	entries := that.entries
+	that.entries = nil
+	return entries
+}
+
+// Reset 清空已记录的日志
+func (that *LogRecorder) Reset() {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.entries = nil
+}
+
+// FilterLevel 参见 LoggedEntries.FilterLevel
+func (that *LogRecorder) FilterLevel(lvl Level) LoggedEntries {
+	return that.All().FilterLevel(lvl)
+}
+
+// FilterMessage 参见 LoggedEntries.FilterMessage
+func (that *LogRecorder) FilterMessage(msg string) LoggedEntries {
+	return that.All().FilterMessage(msg)
+}
+
+// FilterMessageSnippet 参见 LoggedEntries.FilterMessageSnippet
+func (that *LogRecorder) FilterMessageSnippet(snippet string) LoggedEntries {
+	return that.All().FilterMessageSnippet(snippet)
+}
+
+// FilterCaller 参见 LoggedEntries.FilterCaller
+func (that *LogRecorder) FilterCaller(snippet string) LoggedEntries {
+	return that.All().FilterCaller(snippet)
+}
+
+// FilterField 参见 LoggedEntries.FilterField
+func (that *LogRecorder) FilterField(key string, value any) LoggedEntries {
+	return that.All().FilterField(key, value)
+}
+
+// AssertLogged 断言至少有一条指定等级且内容包含 snippet 的日志
+func (that *LogRecorder) AssertLogged(t TestingT, lvl Level, snippet string) bool {
+	t.Helper()
+	if that.All().FilterLevel(lvl).FilterMessageSnippet(snippet).Len() == 0 {
+		t.Errorf("expected a %s log containing %q, got: %s", lvl, snippet, that.dump())
+		return false
+	}
+	return true
+}
+
+// AssertNotLogged 断言不存在指定等级且内容包含 snippet 的日志
+func (that *LogRecorder) AssertNotLogged(t TestingT, lvl Level, snippet string) bool {
+	t.Helper()
+	if n := that.All().FilterLevel(lvl).FilterMessageSnippet(snippet).Len(); n > 0 {
+		t.Errorf("expected no %s log containing %q, found %d: %s", lvl, snippet, n, that.dump())
+		return false
+	}
+	return true
+}
+
+// AssertCount 断言指定等级的日志条数
+func (that *LogRecorder) AssertCount(t TestingT, lvl Level, count int) bool {
+	t.Helper()
+	if n := that.All().FilterLevel(lvl).Len(); n != count {
+		t.Errorf("expected %d %s logs, got %d: %s", count, lvl, n, that.dump())
+		return false
+	}
+	return true
+}
+
+// AssertField 断言至少有一条日志包含指定字段且字段值相等
+func (that *LogRecorder) AssertField(t TestingT, key string, value any) bool {
+	t.Helper()
+	if that.All().FilterField(key, value).Len() == 0 {
+		t.Errorf("expected a log with field %s=%v, got: %s", key, value, that.dump())
+		return false
+	}
+	return true
+}
+
+func (that *LogRecorder) add(entry LoggedEntry) {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	that.entries = append(that.entries, entry)
+}
+
+func (that *LogRecorder) dump() string {
+	entries := that.All()
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i, entry := range entries {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%s %s %q", entry.Level, entry.Caller, entry.Message)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+///////////////////////////////////////////////////////////////
+
+// NewObserverLogger 创建一个仅记录日志而不输出的 Logger, 用于在单元测试中断言日志行为.
+// 返回的 Logger 与 GetLoggerWithConfig 创建的 Logger 行为一致(包括调用栈跳过层数), 所有 >= level 的日志都会被 LogRecorder 记录
+func NewObserverLogger(level Level) (*Logger, *LogRecorder) {
+	if level < DebugLevel || level > FatalLevel {
+		level = InfoLevel
+	}
+
+	recorder := &LogRecorder{}
+	core := &observerCore{LevelEnabler: zapcore.Level(level), recorder: recorder}
+	log := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
+	return &Logger{log: log}, recorder
+}
+
+// observerCore 将日志写入 LogRecorder 的 zapcore.Core 实现
+type observerCore struct {
+	zapcore.LevelEnabler
+	recorder *LogRecorder
+	context  []zapcore.Field
+}
+
+func (that *observerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &observerCore{
+		LevelEnabler: that.LevelEnabler,
+		recorder:     that.recorder,
+		context:      append(append([]zapcore.Field{}, that.context...), fields...),
+	}
+}
+
+func (that *observerCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if that.Enabled(entry.Level) {
+		return ce.AddCore(entry, that)
+	}
+	return ce
+}
+
+func (that *observerCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range that.context {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	caller := ""
+	if entry.Caller.Defined {
+		caller = entry.Caller.TrimmedPath()
+	}
+
+	that.recorder.add(LoggedEntry{
+		Level:   Level(entry.Level),
+		Time:    entry.Time,
+		Message: entry.Message,
+		Caller:  caller,
+		Fields:  encoder.Fields,
+	})
+	return nil
+}
+
+func (that *observerCore) Sync() error {
+	return nil
+}
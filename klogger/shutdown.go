@@ -0,0 +1,86 @@
+package klogger
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Recover 记录 panic 信息与调用栈, 将日志写入输出后重新抛出 panic, 需要以 `defer logger.Recover()` 的方式调用.
+// 重新抛出保证进程仍按 panic 的默认行为退出, 只是不再丢失缓冲区中的日志
+//
+// 示例:
+//
+//	func worker(logger *klogger.Logger) {
+//		defer logger.Recover()
+//		...
+//	}
+func (that *Logger) Recover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if that == nil {
+		panic(r)
+	}
+
+	var throwable error
+	if err, ok := r.(error); ok {
+		throwable = err
+	}
+
+	// 调用栈为 Recover <- runtime.gopanic <- 发生 panic 的函数, 跳过 runtime.gopanic
+	if ce := that.log.WithOptions(zap.AddCallerSkip(1)).Check(zapcore.ErrorLevel, fmt.Sprintf("panic: %v", r)); ce != nil {
+		ce.Stack = string(debug.Stack())
+		if throwable != nil {
+			ce.Write(errorFields(throwable)...)
+		} else {
+			ce.Write()
+		}
+	}
+	that.Sync()
+	panic(r)
+}
+
+// BindShutdown 将日志的刷新绑定到上下文树的关闭流程:
+//   - 收到 signals (默认 SIGINT, SIGTERM) 时记录日志并关闭 tree, 通知所有节点退出
+//   - tree 的根节点被取消时(无论是否由信号触发)将缓冲区中的日志写入输出
+//
+// 钩子只生效一次, 触发后恢复信号的默认处理, 再次收到信号时进程按默认行为退出.
+// 返回的 stop 函数用于提前解除绑定; 退出前仍需调用 Close 关闭日志文件
+func (that *Logger) BindShutdown(tree *kcontext.ContextTree, signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+
+	done := make(chan struct{})
+	go func() {
+		defer signal.Stop(sigChan)
+
+		select {
+		case sig := <-sigChan:
+			that.I("received signal {}, shutting down", sig.String())
+			tree.Close()
+		case <-tree.GetRoot().Context().Done():
+		case <-done:
+			return
+		}
+		that.Sync()
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,543 @@
+package klogger
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SyslogFacility syslog 设施编号, 参见 RFC 5424 6.2.1
+type SyslogFacility int
+
+const (
+	FacilityKern SyslogFacility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFtp
+	FacilityNtp
+	FacilityAudit
+	FacilityAlert
+	FacilityClock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"ntp", "audit", "alert", "clock", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+func (that SyslogFacility) String() string {
+	if that < 0 || int(that) >= len(facilityNames) {
+		return "facility(" + strconv.Itoa(int(that)) + ")"
+	}
+	return facilityNames[that]
+}
+
+// ParseSyslogFacility 将设施名称(如 "local0", "daemon")转换为 SyslogFacility, 不区分大小写
+func ParseSyslogFacility(name string) (SyslogFacility, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, item := range facilityNames {
+		if item == name {
+			return SyslogFacility(i), nil
+		}
+	}
+	return FacilityUser, fmt.Errorf("unknown syslog facility: %s", name)
+}
+
+// SyslogFormat syslog 报文格式
+type SyslogFormat string
+
+const (
+	SyslogRFC5424 SyslogFormat = "rfc5424" // 默认格式
+	SyslogRFC3164 SyslogFormat = "rfc3164" // BSD syslog 格式
+)
+
+// syslog 传输方式
+const (
+	SyslogNetUDP      = "udp"
+	SyslogNetTCP      = "tcp"
+	SyslogNetTLS      = "tls"
+	SyslogNetUnix     = "unix"     // unix 流式套接字
+	SyslogNetUnixgram = "unixgram" // unix 数据报套接字, 例如 /dev/log
+)
+
+// SyslogConfigure syslog 输出配置
+type SyslogConfigure struct {
+	Network           string         `json:"network"`           // 传输方式, udp tcp tls unix unixgram
+	Addr              string         `json:"addr"`              // 服务端地址, 如 "10.0.0.1:514" 或 "/dev/log"
+	Format            SyslogFormat   `json:"format"`            // 报文格式, rfc5424 或 rfc3164, 默认 rfc5424
+	Facility          SyslogFacility `json:"facility"`          // 设施编号, 默认 user
+	AppName           string         `json:"appName"`           // 应用名称, 默认为当前可执行文件名
+	Hostname          string         `json:"hostname"`          // 主机名, 默认为 os.Hostname()
+	BufferCount       int            `json:"bufferCount"`       // 等待发送的日志最多缓存的条数, 超过后丢弃最旧的日志, 默认1000
+	ReconnectInterval int64          `json:"reconnectInterval"` // 重连最小间隔, 单位 毫秒, 默认1000
+	DialTimeout       int64          `json:"dialTimeout"`       // 建立连接超时时间, 单位 毫秒, 默认3000
+	WriteTimeout      int64          `json:"writeTimeout"`      // 单次写入超时时间, 超时视为连接断开, 单位 毫秒, 默认3000
+	TLSConfig         *tls.Config    `json:"-"`                 // tls 传输使用的证书配置
+}
+
+func NewSyslogConfigure(network string, addr string) *SyslogConfigure {
+	return &SyslogConfigure{
+		Network:           network,
+		Addr:              addr,
+		Format:            SyslogRFC5424,
+		Facility:          FacilityUser,
+		AppName:           "",
+		Hostname:          "",
+		BufferCount:       1000,
+		ReconnectInterval: 1000,
+		DialTimeout:       3000,
+		WriteTimeout:      3000,
+	}
+}
+
+// 设置报文格式, rfc5424 或 rfc3164
+func (that *SyslogConfigure) SetFormat(format SyslogFormat) *SyslogConfigure {
+	that.Format = format
+	return that
+}
+
+func (that *SyslogConfigure) SetFacility(facility SyslogFacility) *SyslogConfigure {
+	that.Facility = facility
+	return that
+}
+
+func (that *SyslogConfigure) SetAppName(name string) *SyslogConfigure {
+	that.AppName = name
+	return that
+}
+
+func (that *SyslogConfigure) SetHostname(hostname string) *SyslogConfigure {
+	that.Hostname = hostname
+	return that
+}
+
+// 设置连接断开期间最多缓存的日志条数
+func (that *SyslogConfigure) SetBufferCount(count int) *SyslogConfigure {
+	that.BufferCount = count
+	return that
+}
+
+// 设置重连最小间隔, 单位 毫秒
+func (that *SyslogConfigure) SetReconnectInterval(interval int64) *SyslogConfigure {
+	that.ReconnectInterval = interval
+	return that
+}
+
+// 设置建立连接超时时间, 单位 毫秒
+func (that *SyslogConfigure) SetDialTimeout(timeout int64) *SyslogConfigure {
+	that.DialTimeout = timeout
+	return that
+}
+
+// 设置单次写入超时时间, 单位 毫秒
+func (that *SyslogConfigure) SetWriteTimeout(timeout int64) *SyslogConfigure {
+	that.WriteTimeout = timeout
+	return that
+}
+
+func (that *SyslogConfigure) SetTLSConfig(conf *tls.Config) *SyslogConfigure {
+	that.TLSConfig = conf
+	return that
+}
+
+///////////////////////////////////////////////////////////////
+
+// SyslogWriter 负责 syslog 报文的组帧与发送, 线程安全.
+// 日志先写入有界缓存, 由后台 goroutine 建立连接并发送, 写日志的调用方不会因为网络阻塞;
+// 连接断开, 写入失败或超时时报文留在缓存中, 到达重连间隔后自动重连并补发
+type SyslogWriter struct {
+	conf     SyslogConfigure
+	hostname string
+	appName  string
+	pid      string
+
+	mu       sync.Mutex
+	pending  [][]byte // 等待发送的报文
+	inflight int      // 后台 goroutine 正在发送的报文数量
+	dropped  uint64
+	closed   bool
+
+	conn     net.Conn // 只在后台 goroutine 中访问
+	lastDial time.Time
+
+	wake  chan struct{}
+	syncs chan chan error
+	stop  chan struct{}
+	done  chan struct{}
+	err   error // Close 时最后一次补发的结果
+}
+
+// NewSyslogWriter 创建 SyslogWriter 并启动后台发送 goroutine, 不会立即建立连接, 第一条日志写入时才会连接服务端
+func NewSyslogWriter(conf *SyslogConfigure) (*SyslogWriter, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("syslog configure is nil")
+	}
+
+	switch conf.Network {
+	case SyslogNetUDP, SyslogNetTCP, SyslogNetTLS, SyslogNetUnix, SyslogNetUnixgram:
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", conf.Network)
+	}
+
+	if conf.Format == "" {
+		conf.Format = SyslogRFC5424
+	}
+	if conf.Format != SyslogRFC5424 && conf.Format != SyslogRFC3164 {
+		return nil, fmt.Errorf("unsupported syslog format: %s", conf.Format)
+	}
+
+	hostname := conf.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	appName := conf.AppName
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+
+	writer := &SyslogWriter{
+		conf:     *conf,
+		hostname: hostname,
+		appName:  appName,
+		pid:      strconv.Itoa(os.Getpid()),
+		wake:     make(chan struct{}, 1),
+		syncs:    make(chan chan error),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if writer.conf.BufferCount <= 0 {
+		writer.conf.BufferCount = 1000
+	}
+	if writer.conf.ReconnectInterval <= 0 {
+		writer.conf.ReconnectInterval = 1000
+	}
+	if writer.conf.DialTimeout <= 0 {
+		writer.conf.DialTimeout = 3000
+	}
+	if writer.conf.WriteTimeout <= 0 {
+		writer.conf.WriteTimeout = 3000
+	}
+	go writer.run()
+	return writer, nil
+}
+
+// WriteMessage 按配置的格式组帧后放入缓存, 由后台 goroutine 发送, lvl 决定报文的 severity
+func (that *SyslogWriter) WriteMessage(lvl Level, tm time.Time, msg []byte) error {
+	frame := that.frame(that.format(lvl, tm, msg))
+
+	that.mu.Lock()
+	if that.closed {
+		that.mu.Unlock()
+		return fmt.Errorf("syslog writer closed")
+	}
+	that.enqueue(frame)
+	that.mu.Unlock()
+
+	select {
+	case that.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Sync 立即尝试重连(忽略重连间隔)并补发缓存的日志, 等待补发完成或失败
+func (that *SyslogWriter) Sync() error {
+	result := make(chan error, 1)
+	select {
+	case that.syncs <- result:
+		return <-result
+	case <-that.done:
+		return nil
+	}
+}
+
+// Close 补发缓存的日志后关闭连接, 关闭后写入的日志将被丢弃
+func (that *SyslogWriter) Close() error {
+	that.mu.Lock()
+	if that.closed {
+		that.mu.Unlock()
+		return nil
+	}
+	that.closed = true
+	that.mu.Unlock()
+
+	close(that.stop)
+	<-that.done
+	return that.err
+}
+
+// Pending 返回当前等待发送的日志条数, 包括正在发送的日志
+func (that *SyslogWriter) Pending() int {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return len(that.pending) + that.inflight
+}
+
+// Dropped 返回因缓存已满而被丢弃的日志条数
+func (that *SyslogWriter) Dropped() uint64 {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	return that.dropped
+}
+
+// run 后台发送 goroutine, 新日志, Sync 与重连定时器触发发送, 停止前补发缓存的日志并关闭连接
+func (that *SyslogWriter) run() {
+	defer close(that.done)
+
+	interval := time.Duration(that.conf.ReconnectInterval) * time.Millisecond
+	retry := time.NewTimer(interval)
+	retry.Stop()
+	defer retry.Stop()
+
+	for {
+		var result chan error
+		select {
+		case <-that.wake:
+		case <-retry.C:
+		case result = <-that.syncs:
+		case <-that.stop:
+			that.err = that.flush(true)
+			that.resetConn()
+			return
+		}
+
+		err := that.flush(result != nil)
+		if result != nil {
+			result <- err
+		}
+		if err != nil { // 发送失败的日志留在缓存中, 到达重连间隔后重试
+			retry.Reset(interval)
+		}
+	}
+}
+
+// flush 发送缓存中的日志直到缓存为空或发送失败, 写入期间不持有锁, force 为 true 时忽略重连间隔
+func (that *SyslogWriter) flush(force bool) error {
+	for {
+		that.mu.Lock()
+		batch := that.pending
+		that.pending = nil
+		that.inflight = len(batch)
+		that.mu.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		sent, err := that.write(batch, force)
+		that.mu.Lock()
+		that.inflight = 0
+		if err != nil {
+			that.requeue(batch[sent:])
+		}
+		that.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// write 确保连接可用并依次发送报文, 每次写入前设置写超时, 返回成功发送的条数
+func (that *SyslogWriter) write(batch [][]byte, force bool) (int, error) {
+	if that.conn == nil {
+		if !force && time.Since(that.lastDial) < time.Duration(that.conf.ReconnectInterval)*time.Millisecond {
+			return 0, fmt.Errorf("syslog %s://%s disconnected", that.conf.Network, that.conf.Addr)
+		}
+		if err := that.dial(); err != nil {
+			return 0, err
+		}
+	}
+
+	timeout := time.Duration(that.conf.WriteTimeout) * time.Millisecond
+	for i, frame := range batch {
+		that.conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := that.conn.Write(frame); err != nil {
+			that.resetConn()
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+func (that *SyslogWriter) dial() error {
+	that.lastDial = time.Now()
+	timeout := time.Duration(that.conf.DialTimeout) * time.Millisecond
+
+	var conn net.Conn
+	var err error
+	if that.conf.Network == SyslogNetTLS {
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", that.conf.Addr, that.conf.TLSConfig)
+	} else {
+		conn, err = net.DialTimeout(that.conf.Network, that.conf.Addr, timeout)
+	}
+	if err != nil {
+		return err
+	}
+	that.conn = conn
+	return nil
+}
+
+func (that *SyslogWriter) resetConn() {
+	if that.conn != nil {
+		that.conn.Close()
+		that.conn = nil
+	}
+}
+
+// enqueue 追加报文, 缓存已满时丢弃最旧的日志, 调用方必须持有锁
+func (that *SyslogWriter) enqueue(frame []byte) {
+	that.pending = append(that.pending, frame)
+	that.trim()
+}
+
+// requeue 将发送失败的报文放回缓存头部, 调用方必须持有锁
+func (that *SyslogWriter) requeue(frames [][]byte) {
+	that.pending = append(frames, that.pending...)
+	that.trim()
+}
+
+// trim 保证缓存与正在发送的日志总数不超过 BufferCount
+func (that *SyslogWriter) trim() {
+	for len(that.pending) > 0 && len(that.pending)+that.inflight > that.conf.BufferCount {
+		that.pending[0] = nil
+		that.pending = that.pending[1:]
+		that.dropped++
+	}
+}
+
+// format 生成不含传输层分帧的 syslog 报文
+func (that *SyslogWriter) format(lvl Level, tm time.Time, msg []byte) []byte {
+	pri := int(that.conf.Facility)*8 + syslogSeverity(lvl)
+	msg = bytes.TrimRight(msg, "\r\n")
+
+	var buf bytes.Buffer
+	buf.Grow(len(msg) + 128)
+	if that.conf.Format == SyslogRFC3164 {
+		// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+		fmt.Fprintf(&buf, "<%d>%s %s %s[%s]: ", pri, tm.Format(time.Stamp), that.hostname, that.appName, that.pid)
+	} else {
+		// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - - ", pri, tm.Format("2006-01-02T15:04:05.000000Z07:00"), that.hostname, that.appName, that.pid)
+	}
+	buf.Write(msg)
+	return buf.Bytes()
+}
+
+// frame 按传输方式为报文分帧, 流式传输中 RFC 5424 使用 octet counting (RFC 6587), RFC 3164 使用换行分隔;
+// 换行分隔时报文中的换行(如错误堆栈)按 rsyslog 的方式转义为 #012, 回车转义为 #015, 避免一条日志被拆成多条
+func (that *SyslogWriter) frame(msg []byte) []byte {
+	switch that.conf.Network {
+	case SyslogNetTCP, SyslogNetTLS, SyslogNetUnix:
+		if that.conf.Format == SyslogRFC5424 {
+			return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if bytes.ContainsAny(msg, "\r\n") {
+			msg = []byte(syslogLineEscaper.Replace(string(msg)))
+		}
+		return append(msg, '\n')
+	default:
+		return msg
+	}
+}
+
+var syslogLineEscaper = strings.NewReplacer("\n", "#012", "\r", "#015")
+
+// syslogSeverity 将日志等级转换为 syslog severity
+func syslogSeverity(lvl Level) int {
+	switch lvl {
+	case DebugLevel:
+		return 7 // debug
+	case InfoLevel:
+		return 6 // informational
+	case WarnLevel:
+		return 4 // warning
+	case ErrorLevel:
+		return 3 // error
+	case DPanicLevel:
+		return 2 // critical
+	case PanicLevel:
+		return 1 // alert
+	case FatalLevel:
+		return 0 // emergency
+	}
+	return 5 // notice
+}
+
+///////////////////////////////////////////////////////////////
+
+// syslogCore 将日志编码后写入 SyslogWriter 的 zapcore.Core 实现
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *SyslogWriter
+}
+
+// newSyslogCore 创建 syslog 输出, 时间与等级已由 syslog 报文头携带, 报文体中只保留调用位置, 日志内容和字段
+func newSyslogCore(writer *SyslogWriter, level zapcore.LevelEnabler) zapcore.Core {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.TimeKey = ""
+	cfg.LevelKey = ""
+	return &syslogCore{
+		LevelEnabler: level,
+		encoder:      zapcore.NewConsoleEncoder(cfg),
+		writer:       writer,
+	}
+}
+
+func (that *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := that.encoder.Clone()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	return &syslogCore{LevelEnabler: that.LevelEnabler, encoder: encoder, writer: that.writer}
+}
+
+func (that *syslogCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if that.Enabled(entry.Level) {
+		return ce.AddCore(entry, that)
+	}
+	return ce
+}
+
+func (that *syslogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := that.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+	return that.writer.WriteMessage(Level(entry.Level), entry.Time, buf.Bytes())
+}
+
+func (that *syslogCore) Sync() error {
+	return that.writer.Sync()
+}
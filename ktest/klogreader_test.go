@@ -0,0 +1,217 @@
+package ktest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	klog "github.com/khan-lau/kutils/klogger"
+	"github.com/khan-lau/kutils/klogger/klogreader"
+)
+
+func TestParseLogLine(t *testing.T) {
+	line := "2024-03-21 11:51:24.038\t\x1b[33mWARN\x1b[0m\tkredis/redisdb.go:120\tscan\ttimeout\t{\"key\":\"device:1\",\"count\":3}"
+	entry, err := klogreader.ParseLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != klog.WarnLevel || entry.Caller != "kredis/redisdb.go:120" || entry.Message != "scan\ttimeout" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.Fields["key"] != "device:1" || entry.Fields["count"] != float64(3) {
+		t.Errorf("unexpected fields: %v", entry.Fields)
+	}
+	if entry.Time.Format("2006-01-02 15:04:05.000") != "2024-03-21 11:51:24.038" {
+		t.Errorf("unexpected time: %v", entry.Time)
+	}
+
+	jsonLine := `{"level":"ERROR","ts":"2024-03-21 11:51:25.000","caller":"ktest/a.go:1","msg":"boom","error":"EOF"}`
+	entry, err = klogreader.ParseLine(jsonLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != klog.ErrorLevel || entry.Message != "boom" || entry.Fields["error"] != "EOF" {
+		t.Errorf("unexpected json entry: %+v", entry)
+	}
+
+	if _, err := klogreader.ParseLine("\tat main.main()"); err == nil {
+		t.Error("continuation line should not be parsed as an entry")
+	}
+}
+
+func TestReadLoggerFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "app.log")
+
+	logger := klog.GetLoggerWithConfig(klog.NewConfigure().SetLogFile(logFile).SetLevel(klog.DebugLevel))
+	logger.D("debug {}", 1)
+	logger.I("device {} online", "W001")
+	logger.W("device {} timeout", "W002")
+	logger.E("device {} offline", "W003")
+	logger.Sync()
+
+	files, err := klogreader.RotatedFiles(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all []*klogreader.Entry
+	err = klogreader.ReadFiles(files, nil, func(entry *klogreader.Entry) bool {
+		all = append(all, entry)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(all))
+	}
+	if all[1].Message != "device W001 online" || all[1].Caller == "" {
+		t.Errorf("unexpected entry: %+v", all[1])
+	}
+
+	filter := klogreader.NewFilter().
+		SetMinLevel(klog.WarnLevel).
+		SetCaller("klogreader_test.go").
+		SetPattern(regexp.MustCompile(`W00[23]`)).
+		SetSince(time.Now().Add(-time.Minute))
+	var matched []string
+	klogreader.ReadFiles(files, filter, func(entry *klogreader.Entry) bool {
+		matched = append(matched, entry.Message)
+		return true
+	})
+	if len(matched) != 2 || matched[0] != "device W002 timeout" || matched[1] != "device W003 offline" {
+		t.Errorf("unexpected filtered entries: %v", matched)
+	}
+
+	entries, _, err := klogreader.Tail(files[len(files)-1], 1, nil)
+	if err != nil || len(entries) != 1 || entries[0].Message != "device W003 offline" {
+		t.Errorf("unexpected tail: %v, %v", entries, err)
+	}
+}
+
+// 验证跟踪模式下软链接切换到新文件后继续读取
+func TestFollowRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	link := filepath.Join(tmpDir, "app.log")
+	first := filepath.Join(tmpDir, "app.202601010000.log")
+	second := filepath.Join(tmpDir, "app.202601010100.log")
+
+	line := func(msg string) string {
+		return time.Now().Format("2006-01-02 15:04:05.000") + "\tINFO\tktest/a.go:1\t" + msg + "\n"
+	}
+	appendTo := func(path string, text string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(text)
+		f.Close()
+	}
+
+	appendTo(first, line("old"))
+	if err := os.Symlink(first, link); err != nil {
+		t.Skip("symlink not supported:", err)
+	}
+
+	var mu sync.Mutex
+	var got []string
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		klogreader.Follow(ctx, link, -1, nil, 20*time.Millisecond, func(entry *klogreader.Entry) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, entry.Message)
+			return len(got) < 3
+		})
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	appendTo(first, line("before rotate"))
+	time.Sleep(100 * time.Millisecond)
+
+	appendTo(first, line("tail of first"))
+	appendTo(second, line("after rotate"))
+	os.Remove(link)
+	os.Symlink(second, link)
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		cancel()
+		<-done
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"before rotate", "tail of first", "after rotate"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, got)
+			break
+		}
+	}
+}
+
+// 调用栈分多次写入时, 跟踪模式仍将其合并到同一条日志中
+func TestFollowMultilineAcrossPolls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	entries := make(chan *klogreader.Entry, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		klogreader.Follow(ctx, path, 0, nil, 10*time.Millisecond, func(entry *klogreader.Entry) bool {
+			entries <- entry
+			return true
+		})
+	}()
+
+	now := time.Now().Format("2006-01-02 15:04:05.000")
+	file.WriteString(now + "\tERROR\tktest/a.go:1\tpanic\n")
+	time.Sleep(50 * time.Millisecond) // 跨越多个轮询周期
+	file.WriteString("goroutine 1 [running]:\n")
+	time.Sleep(50 * time.Millisecond)
+	file.WriteString("\tktest/a.go:1 +0x1d\n")
+
+	select {
+	case entry := <-entries:
+		if entry.Message != "panic" || entry.Stack != "goroutine 1 [running]:\n\tktest/a.go:1 +0x1d" {
+			t.Errorf("unexpected entry %q, stack %q", entry.Message, entry.Stack)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("pending entry is not flushed after idle timeout")
+	}
+
+	// 下一条日志的首行使上一条立即输出, 最后一条在取消时输出
+	file.WriteString(now + "\tINFO\tktest/a.go:2\tfirst\n" + now + "\tINFO\tktest/a.go:3\tsecond\n")
+	select {
+	case entry := <-entries:
+		if entry.Message != "first" {
+			t.Errorf("expected first, got %q", entry.Message)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("entry should be flushed by the next header line")
+	}
+	cancel()
+	<-done
+	if len(entries) != 1 || (<-entries).Message != "second" {
+		t.Error("pending entry should be flushed on cancel")
+	}
+}
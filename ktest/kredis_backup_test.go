@@ -0,0 +1,162 @@
+package ktest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+// 导入前校验文件格式, 损坏的文件不会写入 Redis
+func TestRestoreBackupInvalid(t *testing.T) {
+	client := newUnreachableClient(t)
+
+	for name, content := range map[string][]byte{
+		"empty":     {},
+		"magic":     []byte("RDB0001\x00\x00\x00\x00\x00\x00\x00"),
+		"truncated": []byte("KRDB\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x10"),
+	} {
+		_, err := kredis.RestoreBackup(context.Background(), client, bytes.NewReader(content), kredis.NewRestoreOptions())
+		if !errors.Is(err, kredis.ErrBackupFormat) {
+			t.Errorf("%s: want ErrBackupFormat, got %v", name, err)
+		}
+	}
+
+	// 导出失败时不留下不完整的文件
+	path := filepath.Join(t.TempDir(), "dump.krdb")
+	if _, err := kredis.BackupToFile(context.Background(), client, path, kredis.NewBackupOptions()); err == nil {
+		t.Error("BackupToFile should fail")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 0 {
+		t.Errorf("backup left %d files", len(entries))
+	}
+}
+
+// 每种压缩算法导出后都能完整导入; 损坏的 block 与被篡改的记录总数在导入时报错
+func TestBackupRoundTrip(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	ctx := context.Background()
+
+	compressions := map[string]kredis.BackupCompression{
+		"none":    kredis.BackupCompressNone,
+		"deflate": kredis.BackupCompressDeflate,
+		"gzip":    kredis.BackupCompressGZip,
+		"zlib":    kredis.BackupCompressZlib,
+		"brotli":  kredis.BackupCompressBrotli,
+	}
+	for name, compression := range compressions {
+		t.Run(name, func(t *testing.T) {
+			srv.FlushAll()
+			client.Client.Set(ctx, "backup:str", "value", 0)
+			client.Client.HSet(ctx, "backup:hash", "f1", "v1", "f2", "v2")
+			client.Client.RPush(ctx, "backup:list", "a", "b", "c")
+			client.Client.Set(ctx, "backup:ttl", "expiring", time.Hour)
+
+			var buf bytes.Buffer
+			opts := kredis.NewBackupOptions().SetMatch("backup:*").SetBatch(10, 3).SetCompression(compression)
+			progress, err := kredis.Backup(ctx, client, &buf, opts)
+			if err != nil || progress.Exported != 4 {
+				t.Fatalf("Backup = %+v, %v", progress, err)
+			}
+
+			srv.FlushAll()
+			progress, err = kredis.RestoreBackup(ctx, client, bytes.NewReader(buf.Bytes()), kredis.NewRestoreOptions())
+			if err != nil || progress.Restored != 4 {
+				t.Fatalf("RestoreBackup = %+v, %v", progress, err)
+			}
+			if v, _ := client.Client.HGet(ctx, "backup:hash", "f2").Result(); v != "v2" {
+				t.Errorf("backup:hash f2 = %q", v)
+			}
+			if v, _ := client.Client.LRange(ctx, "backup:list", 0, -1).Result(); len(v) != 3 || v[2] != "c" {
+				t.Errorf("backup:list = %v", v)
+			}
+			if ttl, _ := client.Client.PTTL(ctx, "backup:ttl").Result(); ttl <= 0 || ttl > time.Hour {
+				t.Errorf("backup:ttl PTTL = %v", ttl)
+			}
+
+			// 第一个 block 的数据从 header(14B) 与 block 头(18B) 之后开始
+			corrupted := bytes.Clone(buf.Bytes())
+			corrupted[14+18] ^= 0xff
+			srv.FlushAll()
+			if _, err := kredis.RestoreBackup(ctx, client, bytes.NewReader(corrupted), kredis.NewRestoreOptions()); !errors.Is(err, kredis.ErrBackupChecksum) {
+				t.Errorf("corrupted block: want ErrBackupChecksum, got %v", err)
+			}
+			if keys := srv.Keys(); len(keys) != 0 {
+				t.Errorf("corrupted block restored %v", keys)
+			}
+
+			// 结束标记中的记录总数在文件最后 8 字节
+			truncated := bytes.Clone(buf.Bytes())
+			binary.BigEndian.PutUint64(truncated[len(truncated)-8:], 5)
+			if _, err := kredis.RestoreBackup(ctx, client, bytes.NewReader(truncated), kredis.NewRestoreOptions()); !errors.Is(err, kredis.ErrBackupFormat) {
+				t.Errorf("count mismatch: want ErrBackupFormat, got %v", err)
+			}
+		})
+	}
+}
+
+// RestoreTTLAbsolute 按各 block 的 DUMP 时间扣除经过的时间, 而不是文件的创建时间
+func TestRestoreTTLAbsolute(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	ctx := context.Background()
+
+	client.Client.Set(ctx, "backup:ttl", "expiring", time.Minute)
+	client.Client.Set(ctx, "backup:keep", "forever", 0)
+
+	var buf bytes.Buffer
+	opts := kredis.NewBackupOptions().SetMatch("backup:*").SetCompression(kredis.BackupCompressNone)
+	if _, err := kredis.Backup(ctx, client, &buf, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// 将唯一一个 block 的 DUMP 时间改为一小时前, 文件的创建时间不变
+	content := buf.Bytes()
+	binary.BigEndian.PutUint64(content[14+8:], uint64(time.Now().Add(-time.Hour).UnixMilli()))
+	srv.FlushAll()
+	restoreOpts := kredis.NewRestoreOptions().SetTTLMode(kredis.RestoreTTLAbsolute)
+	progress, err := kredis.RestoreBackup(ctx, client, bytes.NewReader(content), restoreOpts)
+	if err != nil || progress.Restored != 1 || progress.Skipped != 1 {
+		t.Fatalf("RestoreBackup = %+v, %v", progress, err)
+	}
+	if n, _ := client.Client.Exists(ctx, "backup:ttl").Result(); n != 0 {
+		t.Error("expired key should be skipped")
+	}
+}
+
+// 选项为 nil 时使用默认值
+func TestBackupNilOptions(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	ctx := context.Background()
+	client.Client.Set(ctx, "backup:str", "value", 0)
+
+	var buf bytes.Buffer
+	if progress, err := kredis.Backup(ctx, client, &buf, nil); err != nil || progress.Exported != 1 {
+		t.Fatalf("Backup = %+v, %v", progress, err)
+	}
+	srv.FlushAll()
+	if progress, err := kredis.RestoreBackup(ctx, client, &buf, nil); err != nil || progress.Restored != 1 {
+		t.Fatalf("RestoreBackup = %+v, %v", progress, err)
+	}
+
+	dst, err := kredis.NewKRedisWithOptions(kcontext.NewContextTree("mainCtx").GetRoot(), kredis.NewRedisOptions(srv.Addr()).SetDB(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Stop()
+	if progress, err := kredis.CopyKeys(ctx, client, dst, nil, nil); err != nil || progress.Restored != 1 {
+		t.Fatalf("CopyKeys = %+v, %v", progress, err)
+	}
+	if v, _ := dst.Client.Get(ctx, "backup:str").Result(); v != "value" {
+		t.Errorf("copied value %q", v)
+	}
+}
@@ -0,0 +1,135 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+type cacheUser struct {
+	ID   int
+	Name string
+	Tags []string
+}
+
+func TestCacheCodec(t *testing.T) {
+	user := &cacheUser{ID: 1001, Name: "khan", Tags: []string{"a", "b"}}
+	for name, codec := range map[string]kredis.Codec{
+		"json":      kredis.JSONCodec,
+		"gob":       kredis.GobCodec,
+		"deflate":   kredis.CompressedCodec(kredis.JSONCodec),
+		"deflate+g": kredis.CompressedCodec(kredis.GobCodec),
+	} {
+		raw, err := codec.Marshal(user)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		decoded := &cacheUser{}
+		if err := codec.Unmarshal(raw, decoded); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if decoded.ID != user.ID || decoded.Name != user.Name || len(decoded.Tags) != 2 {
+			t.Errorf("%s: got %+v", name, decoded)
+		}
+	}
+}
+
+// Redis 不可用时降级为直接调用 loader, 并发未命中只调用一次 loader
+func TestCacheGetOrLoadFallback(t *testing.T) {
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	client := newUnreachableClient(t)
+
+	var redisErrors atomic.Int32
+	cache := kredis.NewCache(root, client, kredis.NewCacheOptions().SetPrefix("cache:user:").SetErrorHandler(func(err error) { redisErrors.Add(1) }))
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func() (*cacheUser, error) {
+		calls.Add(1)
+		<-release
+		return &cacheUser{ID: 1001, Name: "khan"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := kredis.GetOrLoad(cache, "1001", time.Minute, loader)
+			if err != nil || user == nil || user.Name != "khan" {
+				t.Errorf("GetOrLoad: %+v, %v", user, err)
+			}
+		}()
+	}
+	time.Sleep(300 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+	if redisErrors.Load() == 0 {
+		t.Error("redis errors should be reported through OnError")
+	}
+
+	_, err := kredis.GetOrLoad(cache, "404", time.Minute, func() (*cacheUser, error) { return nil, kredis.ErrCacheNotFound })
+	if !errors.Is(err, kredis.ErrCacheNotFound) {
+		t.Errorf("want ErrCacheNotFound, got %v", err)
+	}
+}
+
+// 未命中返回 ErrCacheMiss; 第一个调用方取消不影响共享同一次加载的其他调用方
+func TestCacheMissAndCancel(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	opts := kredis.NewCacheOptions().SetCodec(nil)
+	cache := kredis.NewCache(root, client, opts)
+	if opts.Codec != nil {
+		t.Error("NewCache should not modify the caller's options")
+	}
+	if _, err := kredis.CacheGet[*cacheUser](cache, "1001"); !errors.Is(err, kredis.ErrCacheMiss) {
+		t.Fatalf("want ErrCacheMiss, got %v", err)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	loader := func(ctx context.Context) (*cacheUser, error) {
+		close(started)
+		<-release
+		return &cacheUser{ID: 1001, Name: "khan"}, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := kredis.GetOrLoadWithCtx(ctx, cache, "1001", time.Minute, loader)
+		leader <- err
+	}()
+	<-started
+
+	waiter := make(chan *cacheUser, 1)
+	go func() {
+		user, _ := kredis.GetOrLoadWithCtx(context.Background(), cache, "1001", time.Minute, loader)
+		waiter <- user
+	}()
+	time.Sleep(50 * time.Millisecond) // 等待第二个调用方加入同一次加载
+	cancel()
+	close(release)
+
+	if err := <-leader; err != nil {
+		t.Errorf("leader: %v", err)
+	}
+	if user := <-waiter; user == nil || user.Name != "khan" {
+		t.Errorf("waiter got %+v", user)
+	}
+	if user, err := kredis.CacheGet[*cacheUser](cache, "1001"); err != nil || user.ID != 1001 {
+		t.Errorf("cached value: %+v, %v", user, err)
+	}
+}
@@ -0,0 +1,169 @@
+package ktest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisOptionsMode(t *testing.T) {
+	cases := []struct {
+		opts *kredis.RedisOptions
+		mode kredis.RedisMode
+	}{
+		{kredis.NewRedisOptions("127.0.0.1:6379"), kredis.RedisModeStandalone},
+		{kredis.NewRedisOptions("127.0.0.1:7000", "127.0.0.1:7001"), kredis.RedisModeCluster},
+		{kredis.NewRedisOptions("127.0.0.1:26379").SetSentinel("mymaster", "", ""), kredis.RedisModeSentinel},
+		{kredis.NewRedisOptions("127.0.0.1:7000").SetMode(kredis.RedisModeCluster), kredis.RedisModeCluster},
+	}
+	for _, c := range cases {
+		if mode := c.opts.ResolveMode(); mode != c.mode {
+			t.Errorf("%v: expected %s, got %s", c.opts.Addrs, c.mode, mode)
+		}
+	}
+
+	if mode, err := kredis.ParseRedisMode("Sentinel"); err != nil || mode != kredis.RedisModeSentinel {
+		t.Errorf("unexpected mode: %v, %v", mode, err)
+	}
+	if _, err := kredis.ParseRedisMode("ring"); !errors.Is(err, kredis.ErrInvalidMode) {
+		t.Errorf("expected ErrInvalidMode, got %v", err)
+	}
+}
+
+func TestNewKRedisClient(t *testing.T) {
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	client, err := kredis.NewKRedisClient(root, kredis.NewRedisOptions("127.0.0.1:7000", "127.0.0.1:7001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(*kredis.KRedisCluster); !ok {
+		t.Errorf("expected *KRedisCluster, got %T", client)
+	}
+	client.Stop()
+
+	client, err = kredis.NewKRedisClient(root, kredis.NewRedisOptions("127.0.0.1:26379").SetSentinel("mymaster", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(*kredis.KRedis); !ok {
+		t.Errorf("expected *KRedis, got %T", client)
+	}
+	client.Stop()
+
+	if _, err := kredis.NewKRedisClient(root, kredis.NewRedisOptions()); !errors.Is(err, kredis.ErrNoAddrs) {
+		t.Errorf("expected ErrNoAddrs, got %v", err)
+	}
+	if _, err := kredis.NewKRedisClient(root, kredis.NewRedisOptions("127.0.0.1:26379").SetMode(kredis.RedisModeSentinel)); !errors.Is(err, kredis.ErrInvalidMode) {
+		t.Errorf("expected ErrInvalidMode, got %v", err)
+	}
+}
+
+type recordHook struct {
+	commands []string
+}
+
+func (that *recordHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (that *recordHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		that.commands = append(that.commands, cmd.Name())
+		return next(ctx, cmd)
+	}
+}
+
+func (that *recordHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestKRedisWithOptions(t *testing.T) {
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	hook := &recordHook{}
+	opts := kredis.NewRedisOptions("127.0.0.1:1").
+		SetPool(32, 4, 8, 64).
+		SetRetry(-1, 0, 0).
+		SetTimeouts(200, 1000, 1000).
+		SetTLSConfig(&tls.Config{ServerName: "redis.local"}).
+		AddHook(hook)
+	client, err := kredis.NewKRedisWithOptions(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	clientOpts := client.Client.Options()
+	if clientOpts.PoolSize != 32 || clientOpts.MaxActiveConns != 64 || clientOpts.DialTimeout != 200*time.Millisecond {
+		t.Errorf("unexpected pool options: %+v", clientOpts)
+	}
+	if clientOpts.TLSConfig == nil || clientOpts.TLSConfig.ServerName != "redis.local" {
+		t.Errorf("tls config should be applied")
+	}
+
+	client.Get("device:W001") // 连接失败, 但钩子仍会被调用
+	if len(hook.commands) != 1 || hook.commands[0] != "get" {
+		t.Errorf("hook should observe the command, got %v", hook.commands)
+	}
+
+	legacy := kredis.NewKRedis(root, "127.0.0.1:1", "", "", 0)
+	defer legacy.Stop()
+	if legacyOpts := legacy.Client.Options(); legacyOpts.ContextTimeoutEnabled || legacyOpts.ReadTimeout != 30*time.Second {
+		t.Errorf("NewKRedis should keep its defaults: %+v", legacyOpts)
+	}
+
+	cluster := kredis.NewKRedisCluster(root, []string{"127.0.0.1:7000"}, "", "", 0)
+	defer cluster.Stop()
+	if clusterOpts := cluster.Client.Options(); clusterOpts.MaxRedirects != 8 || clusterOpts.ReadTimeout != 4*time.Second {
+		t.Errorf("NewKRedisCluster should keep its defaults: %+v", clusterOpts)
+	}
+
+	tlsOpts := &kredis.RedisTLSOptions{CertFile: "missing.crt", KeyFile: "missing.key"}
+	if _, err := kredis.NewKRedisWithOptions(root, kredis.NewRedisOptions("127.0.0.1:6379").SetTLS(tlsOpts)); err == nil {
+		t.Error("missing client certificate should fail")
+	}
+}
+
+// 服务端接受连接但不响应, 单次调用的截止时间应生效而不是等待30s的读超时
+func TestKRedisCallDeadline(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client, err := kredis.NewKRedisClient(root, kredis.NewRedisOptions(listener.Addr().String()).SetRetry(-1, 0, 0).SetContextTimeoutEnabled(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	_, err = client.GetWithCtx(ctx, "device:W001")
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("deadline should be honoured, took %v", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package ktest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+// 两个副本竞选同一个 name: Leader 的网络中断后, 其任期在另一个副本当选之前取消, 新任期更大
+func TestElectionFailover(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	proxy := newTestProxy(t, srv.Addr())
+
+	var (
+		mu      sync.Mutex
+		terms   []int64
+		elected = make(chan string, 4)
+		aCtx    *kcontext.ContextNode
+	)
+	newOpts := func(id string) *kredis.ElectionOptions {
+		return kredis.NewElectionOptions().SetID(id).SetTTL(400, 20).SetCallbacks(func(ctx *kcontext.ContextNode, term int64) {
+			mu.Lock()
+			if id == "a" {
+				aCtx = ctx
+			} else if aCtx != nil && aCtx.Context().Err() == nil {
+				t.Error("a's term should be cancelled before b is elected")
+			}
+			terms = append(terms, term)
+			mu.Unlock()
+			elected <- id
+		}, nil)
+	}
+
+	a := kredis.NewElection(root, newProxyClient(t, proxy), "daily-statistics", newOpts("a"))
+	a.Start()
+	defer a.Stop()
+	if id := <-elected; id != "a" {
+		t.Fatalf("first leader: %s", id)
+	}
+
+	b := kredis.NewElection(root, newTestClient(t, srv.Addr(), 2), "daily-statistics", newOpts("b"))
+	b.Start()
+	defer b.Stop()
+	time.Sleep(500 * time.Millisecond) // a 持续续期, b 不会当选
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatal("a should stay leader while renewing")
+	}
+	if leader, _ := b.Leader(context.Background()); leader != "a" {
+		t.Errorf("Leader: %q", leader)
+	}
+
+	proxy.Close()
+	select {
+	case id := <-elected:
+		if id != "b" {
+			t.Fatalf("unexpected leader %s", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("b should take over")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(terms) != 2 || terms[1] <= terms[0] {
+		t.Errorf("terms should increase: %v", terms)
+	}
+}
@@ -0,0 +1,202 @@
+package ktest
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+	"github.com/khan-lau/kutils/db/kredis/kredistest"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// testServer 测试使用的 Redis 服务.
+// 设置了环境变量 KREDIS_TEST_ADDR 时使用该地址的实例, 每个测试开始前清空其中的数据; 否则启动进程内的 kredistest 服务
+type testServer struct {
+	addr  string
+	admin *redis.Client
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	addr := os.Getenv("KREDIS_TEST_ADDR")
+	if addr == "" {
+		addr = kredistest.Run(t).Addr()
+	}
+	that := &testServer{addr: addr, admin: redis.NewClient(&redis.Options{Addr: addr})}
+	t.Cleanup(func() { that.admin.Close() })
+	that.FlushAll()
+	return that
+}
+
+func (that *testServer) Addr() string {
+	return that.addr
+}
+
+// FlushAll 清空所有数据库
+func (that *testServer) FlushAll() {
+	that.admin.FlushAll(context.Background())
+}
+
+// Keys 返回 0 号数据库中的所有 key
+func (that *testServer) Keys() []string {
+	keys, _ := that.admin.Keys(context.Background(), "*").Result()
+	return keys
+}
+
+// newTestClient 连接测试服务的客户端, 测试结束时停止
+func newTestClient(t *testing.T, addr string, protocol int) *kredis.KRedis {
+	t.Helper()
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client, err := kredis.NewKRedisWithOptions(root, kredis.NewRedisOptions(addr).SetProtocol(protocol))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	return client
+}
+
+// newUnreachableClient 指向不可达地址的客户端, 不重试且超时很短, 用于测试 Redis 不可用时的行为
+func newUnreachableClient(t *testing.T, hooks ...redis.Hook) *kredis.KRedis {
+	t.Helper()
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	opts := kredis.NewRedisOptions("127.0.0.1:1").SetRetry(-1, 0, 0).SetTimeouts(100, 100, 100).AddHook(hooks...)
+	client, err := kredis.NewKRedisWithOptions(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	return client
+}
+
+// testProxy 转发到测试服务的 TCP 代理, Close 后客户端的请求全部失败, 用于模拟单个客户端的网络中断
+type testProxy struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    []net.Conn
+	closed   bool
+}
+
+func newTestProxy(t *testing.T, target string) *testProxy {
+	return listenTestProxy(t, "127.0.0.1:0", target)
+}
+
+// listenTestProxy 在指定地址上启动代理, 可用于模拟 Redis 在原地址上恢复
+func listenTestProxy(t *testing.T, addr string, target string) *testProxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	that := &testProxy{listener: listener}
+	t.Cleanup(that.Close)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			if !that.track(conn, upstream) {
+				return
+			}
+			go func() { io.Copy(upstream, conn); upstream.Close() }()
+			go func() { io.Copy(conn, upstream); conn.Close() }()
+		}
+	}()
+	return that
+}
+
+func (that *testProxy) Addr() string {
+	return that.listener.Addr().String()
+}
+
+func (that *testProxy) track(conns ...net.Conn) bool {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if that.closed {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+	that.conns = append(that.conns, conns...)
+	return true
+}
+
+// Interrupt 断开当前所有连接, 之后的新连接仍然可以建立, 用于模拟短暂的网络中断
+func (that *testProxy) Interrupt() {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	for _, conn := range that.conns {
+		conn.Close()
+	}
+	that.conns = nil
+}
+
+// Close 停止代理并断开所有连接
+func (that *testProxy) Close() {
+	that.mu.Lock()
+	defer that.mu.Unlock()
+	if that.closed {
+		return
+	}
+	that.closed = true
+	that.listener.Close()
+	for _, conn := range that.conns {
+		conn.Close()
+	}
+}
+
+// newProxyClient 经过代理连接测试服务的客户端, 不重试, 以便代理关闭后请求立即失败
+func newProxyClient(t *testing.T, proxy *testProxy) *kredis.KRedis {
+	t.Helper()
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client, err := kredis.NewKRedisWithOptions(root, kredis.NewRedisOptions(proxy.Addr()).SetRetry(-1, 0, 0).SetTimeouts(100, 200, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	return client
+}
+
+// waitSubscribers 等待频道的订阅者数量
+func waitSubscribers(t *testing.T, srv *testServer, channel string, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		counts, _ := srv.admin.PubSubNumSub(context.Background(), channel).Result()
+		if counts[channel] == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has %d subscribers, want %d", channel, counts[channel], n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitPatternSubscribers 等待模式订阅的数量至少为 n
+func waitPatternSubscribers(t *testing.T, srv *testServer, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		count, _ := srv.admin.PubSubNumPat(context.Background()).Result()
+		if count >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d pattern subscriptions, want %d", count, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,239 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/khan-lau/kutils/db/kredis"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// jsonReplyHook 代替 Redis 回复 JSON.* 命令, 记录发送的参数; reply 为 nil 时回复 redis.Nil (key 不存在)
+type jsonReplyHook struct {
+	reply any
+	args  []any
+}
+
+func (that *jsonReplyHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (that *jsonReplyHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		reply, ok := cmd.(*redis.Cmd)
+		if !ok || !strings.HasPrefix(cmd.Name(), "json.") {
+			return next(ctx, cmd)
+		}
+		that.args = cmd.Args()
+		if that.reply == nil {
+			reply.SetErr(redis.Nil)
+		} else {
+			reply.SetVal(that.reply)
+		}
+		return reply.Err()
+	}
+}
+
+func (that *jsonReplyHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+// `$` 路径的数组回复与 legacy 路径的单值回复都解析为切片, 数组中的 nil 按命令转换, key 不存在时返回 nil
+func TestJsonReplies(t *testing.T) {
+	hook := &jsonReplyHook{}
+	client := newUnreachableClient(t, hook)
+
+	tests := []struct {
+		name  string
+		reply any
+		call  func() (any, error)
+		args  []any
+		want  any
+	}{
+		{"ArrAppend $", []any{int64(3), nil}, func() (any, error) { return client.JsonArrAppend("device:1", "$..tags", `"north"`) },
+			[]any{"JSON.ARRAPPEND", "device:1", "$..tags", `"north"`}, []int64{3, -1}},
+		{"ArrAppend legacy", int64(3), func() (any, error) { return client.JsonArrAppend("device:1", ".tags", `"north"`, `"south"`) },
+			[]any{"JSON.ARRAPPEND", "device:1", ".tags", `"north"`, `"south"`}, []int64{3}},
+		{"ArrAppend nil", nil, func() (any, error) { return client.JsonArrAppend("device:1", "", `1`) },
+			[]any{"JSON.ARRAPPEND", "device:1", "$", `1`}, []int64(nil)},
+		{"ArrInsert $", []any{int64(2)}, func() (any, error) { return client.JsonArrInsert("device:1", "$.tags", -1, `"east"`) },
+			[]any{"JSON.ARRINSERT", "device:1", "$.tags", int64(-1), `"east"`}, []int64{2}},
+		{"ArrLen $", []any{int64(2), nil}, func() (any, error) { return client.JsonArrLen("device:1", "$..tags") },
+			[]any{"JSON.ARRLEN", "device:1", "$..tags"}, []int64{2, -1}},
+		{"ArrLen legacy", int64(2), func() (any, error) { return client.JsonArrLen("device:1", ".tags") },
+			[]any{"JSON.ARRLEN", "device:1", ".tags"}, []int64{2}},
+		{"ArrLen nil", nil, func() (any, error) { return client.JsonArrLen("device:1", "") },
+			[]any{"JSON.ARRLEN", "device:1", "$"}, []int64(nil)},
+		{"ArrPop $", []any{`"north"`, nil}, func() (any, error) { return client.JsonArrPop("device:1", "$..tags", -1) },
+			[]any{"JSON.ARRPOP", "device:1", "$..tags", int64(-1)}, []string{`"north"`, ""}},
+		{"ArrPop legacy", `{"id":1}`, func() (any, error) { return client.JsonArrPop("device:1", ".items", 0) },
+			[]any{"JSON.ARRPOP", "device:1", ".items", int64(0)}, []string{`{"id":1}`}},
+		{"ArrPop nil", nil, func() (any, error) { return client.JsonArrPop("device:1", "$.tags", -1) },
+			[]any{"JSON.ARRPOP", "device:1", "$.tags", int64(-1)}, []string(nil)},
+		{"NumIncrBy $", "[3,null]", func() (any, error) { return client.JsonNumIncrBy("device:1", "$..count", 2) },
+			[]any{"JSON.NUMINCRBY", "device:1", "$..count", float64(2)}, []float64{3, math.NaN()}},
+		{"NumIncrBy legacy", "2.5", func() (any, error) { return client.JsonNumIncrBy("device:1", ".count", 1.5) },
+			[]any{"JSON.NUMINCRBY", "device:1", ".count", 1.5}, []float64{2.5}},
+		{"NumIncrBy nil", nil, func() (any, error) { return client.JsonNumIncrBy("device:1", "$.count", 1) },
+			[]any{"JSON.NUMINCRBY", "device:1", "$.count", float64(1)}, []float64(nil)},
+		{"StrAppend $", []any{int64(9), nil}, func() (any, error) { return client.JsonStrAppend("device:1", "$..name", `-"x"`) },
+			[]any{"JSON.STRAPPEND", "device:1", "$..name", `"-\"x\""`}, []int64{9, -1}},
+		{"StrAppend legacy", int64(9), func() (any, error) { return client.JsonStrAppend("device:1", ".name", "-x") },
+			[]any{"JSON.STRAPPEND", "device:1", ".name", `"-x"`}, []int64{9}},
+		{"Toggle $", []any{int64(1), int64(0), nil}, func() (any, error) { return client.JsonToggle("device:1", "$..online") },
+			[]any{"JSON.TOGGLE", "device:1", "$..online"}, []int64{1, 0, -1}},
+		{"Toggle legacy", int64(0), func() (any, error) { return client.JsonToggle("device:1", ".online") },
+			[]any{"JSON.TOGGLE", "device:1", ".online"}, []int64{0}},
+		{"Clear", int64(2), func() (any, error) { return client.JsonClear("device:1", "") },
+			[]any{"JSON.CLEAR", "device:1", "$"}, int64(2)},
+		{"MGet", []any{`["on"]`, nil}, func() (any, error) { return client.JsonMGet("$.status", "device:1", "device:2") },
+			[]any{"JSON.MGET", "device:1", "device:2", "$.status"}, []string{`["on"]`, ""}},
+	}
+	for _, tt := range tests {
+		hook.reply, hook.args = tt.reply, nil
+		got, err := tt.call()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprintf("%#v", hook.args) != fmt.Sprintf("%#v", tt.args) {
+			t.Errorf("%s: args = %#v, want %#v", tt.name, hook.args, tt.args)
+		}
+		if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+
+	// 无法识别的回复返回错误
+	for _, reply := range []any{"3", []any{"3"}, []any{int64(1), []any{}}} {
+		hook.reply = reply
+		if got, err := client.JsonArrLen("device:1", "$.tags"); err == nil {
+			t.Errorf("ArrLen reply %#v: got %v, want error", reply, got)
+		}
+	}
+	hook.reply = []any{int64(1)}
+	if got, err := client.JsonArrPop("device:1", "$.tags", -1); err == nil {
+		t.Errorf("ArrPop reply %#v: got %v, want error", hook.reply, got)
+	}
+	hook.reply = "not json"
+	if got, err := client.JsonNumIncrBy("device:1", "$.count", 1); err == nil {
+		t.Errorf("NumIncrBy reply %#v: got %v, want error", hook.reply, got)
+	}
+}
+
+// JsonSetValue 序列化后写入, JsonGetAs 反序列化, key 或路径不存在时返回 ErrJsonNotFound; 连接错误原样返回
+func TestJsonGetSetValue(t *testing.T) {
+	hook := &jsonReplyHook{}
+	client := newUnreachableClient(t, hook)
+
+	hook.reply = "OK"
+	if err := kredis.JsonSetValue(client, "device:1001", "", map[string]any{"online": true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{"JSON.SET", "device:1001", "$", `{"online":true}`}; fmt.Sprint(hook.args) != fmt.Sprint(want) {
+		t.Errorf("JSON.SET args = %v, want %v", hook.args, want)
+	}
+	hook.args = nil
+	if err := kredis.JsonSetValue(client, "device:1001", "$.status", make(chan int)); err == nil || hook.args != nil {
+		t.Errorf("unsupported type should fail before sending: %v, %v", err, hook.args)
+	}
+
+	hook.reply = `["on"]`
+	if status, err := kredis.JsonGetAs[[]string](client, "device:1001", "$.status"); err != nil || len(status) != 1 || status[0] != "on" {
+		t.Errorf("JsonGetAs $: %v, %v", status, err)
+	}
+	if want := []any{"JSON.GET", "device:1001", "$.status"}; fmt.Sprint(hook.args) != fmt.Sprint(want) {
+		t.Errorf("JSON.GET args = %v, want %v", hook.args, want)
+	}
+	hook.reply = `"on"`
+	if status, err := kredis.JsonGetAs[string](client, "device:1001", ".status"); err != nil || status != "on" {
+		t.Errorf("JsonGetAs legacy: %v, %v", status, err)
+	}
+	hook.reply = `{"status":"on"}`
+	if doc, err := kredis.JsonGetAs[map[string]string](client, "device:1001", ""); err != nil || doc["status"] != "on" {
+		t.Errorf("JsonGetAs document: %v, %v", doc, err)
+	}
+	if want := []any{"JSON.GET", "device:1001"}; fmt.Sprint(hook.args) != fmt.Sprint(want) {
+		t.Errorf("JSON.GET args = %v, want %v", hook.args, want)
+	}
+
+	hook.reply = `[]`
+	if _, err := kredis.JsonGetAs[[]string](client, "device:1001", "$.missing"); !errors.Is(err, kredis.ErrJsonNotFound) {
+		t.Errorf("unmatched path: %v", err)
+	}
+	hook.reply = nil
+	if _, err := kredis.JsonGetAs[string](client, "device:1002", ".status"); !errors.Is(err, kredis.ErrJsonNotFound) {
+		t.Errorf("missing key: %v", err)
+	}
+
+	hook.args = nil
+	if values, err := client.JsonMGet("$.status"); err != nil || values != nil || hook.args != nil {
+		t.Errorf("JsonMGet without keys: %v, %v, %v", values, err, hook.args)
+	}
+
+	unreachable := newUnreachableClient(t)
+	if _, err := kredis.JsonGetAs[string](unreachable, "device:1001", ".status"); err == nil || errors.Is(err, kredis.ErrJsonNotFound) {
+		t.Errorf("unavailable redis: %v", err)
+	}
+}
+
+// 在支持 RedisJSON 的服务上执行各命令, 服务不支持时跳过
+func TestJsonCommands(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 2)
+
+	doc := map[string]any{"name": "W001", "tags": []string{"a"}, "count": 1, "online": false, "meta": map[string]int{"x": 1}}
+	if err := kredis.JsonSetValue(client, "device:1001", "", doc); err != nil {
+		if strings.Contains(err.Error(), "unknown command") {
+			t.Skip("RedisJSON is not available")
+		}
+		t.Fatal(err)
+	}
+
+	if name, err := kredis.JsonGetAs[string](client, "device:1001", ".name"); err != nil || name != "W001" {
+		t.Errorf("JsonGetAs legacy: %v, %v", name, err)
+	}
+	if _, err := kredis.JsonGetAs[[]any](client, "device:1001", "$.missing"); !errors.Is(err, kredis.ErrJsonNotFound) {
+		t.Errorf("unmatched path: %v", err)
+	}
+	if _, err := kredis.JsonGetAs[map[string]any](client, "device:1002", ""); !errors.Is(err, kredis.ErrJsonNotFound) {
+		t.Errorf("missing key: %v", err)
+	}
+
+	check := func(name string, got any, err error, want any) {
+		t.Helper()
+		if err != nil || fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", want) {
+			t.Errorf("%s: got %#v, %v, want %#v", name, got, err, want)
+		}
+	}
+	got, err := client.JsonArrAppend("device:1001", "$.tags", `"c"`)
+	check("ArrAppend", got, err, []int64{2})
+	got, err = client.JsonArrInsert("device:1001", "$.tags", 1, `"b"`)
+	check("ArrInsert", got, err, []int64{3})
+	got, err = client.JsonArrLen("device:1001", "$.tags")
+	check("ArrLen", got, err, []int64{3})
+	got, err = client.JsonArrLen("device:1001", "$.name")
+	check("ArrLen not array", got, err, []int64{-1})
+	popped, err := client.JsonArrPop("device:1001", "$.tags", -1)
+	check("ArrPop", popped, err, []string{`"c"`})
+	numbers, err := client.JsonNumIncrBy("device:1001", "$.count", 2)
+	check("NumIncrBy $", numbers, err, []float64{3})
+	numbers, err = client.JsonNumIncrBy("device:1001", ".count", 1.5)
+	check("NumIncrBy legacy", numbers, err, []float64{4.5})
+	got, err = client.JsonStrAppend("device:1001", "$.name", "-x")
+	check("StrAppend", got, err, []int64{6})
+	got, err = client.JsonToggle("device:1001", "$.online")
+	check("Toggle", got, err, []int64{1})
+	values, err := client.JsonMGet("$.name", "device:1001", "device:1002")
+	check("MGet", values, err, []string{`["W001-x"]`, ""})
+	cleared, err := client.JsonClear("device:1001", "$.meta")
+	check("Clear", cleared, err, int64(1))
+
+	final, err := kredis.JsonGetAs[map[string]any](client, "device:1001", "")
+	want := `map[string]interface {}{"count":4.5, "meta":map[string]interface {}{}, "name":"W001-x", "online":true, "tags":[]interface {}{"a", "b"}}`
+	if err != nil || fmt.Sprintf("%#v", final) != want {
+		t.Errorf("final document: %#v, %v", final, err)
+	}
+}
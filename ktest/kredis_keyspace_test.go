@@ -0,0 +1,79 @@
+package ktest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+func TestKeyspaceNotifyFlags(t *testing.T) {
+	for _, tc := range []struct {
+		events []kredis.KeyspaceEventType
+		flags  string
+	}{
+		{[]kredis.KeyspaceEventType{kredis.KeyspaceExpired}, "Ex"},
+		{[]kredis.KeyspaceEventType{kredis.KeyspaceSet, kredis.KeyspaceDel, kredis.KeyspaceHSet}, "E$gh"},
+		{[]kredis.KeyspaceEventType{kredis.KeyspaceSet, kredis.KeyspaceIncrBy}, "E$"},
+		{[]kredis.KeyspaceEventType{"json.set"}, "EA"},
+	} {
+		if flags := kredis.KeyspaceNotifyFlags(tc.events...); flags != tc.flags {
+			t.Errorf("%v: got %q, want %q", tc.events, flags, tc.flags)
+		}
+	}
+}
+
+// 启动时在保留已有标志的前提下开启通知, 事件按 key 过滤后交给处理函数
+func TestKeyspaceListenerEvents(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	ctx := context.Background()
+	client.Client.ConfigSet(ctx, "notify-keyspace-events", "Kl")
+
+	events := make(chan *kredis.KeyspaceEvent, 8)
+	opts := kredis.NewKeyspaceOptions(kredis.KeyspaceExpired, kredis.KeyspaceSet).
+		SetKeyFilter([]string{"device:*"}, []string{"device:tmp:*"}).
+		SetSubscriptionOptions(nil).
+		SetErrorHandler(func(err error) { t.Errorf("unexpected error %v", err) })
+	listener := kredis.NewKeyspaceListener(root, client, opts, func(event *kredis.KeyspaceEvent) { events <- event })
+	if opts.Subscription != nil {
+		t.Error("NewKeyspaceListener should not modify the caller's options")
+	}
+	if err := listener.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Stop()
+
+	flags, _ := client.Client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	for _, flag := range "KlEx$" {
+		if !strings.ContainsRune(flags["notify-keyspace-events"], flag) {
+			t.Errorf("notify-keyspace-events = %q, missing %q", flags["notify-keyspace-events"], flag)
+		}
+	}
+	waitPatternSubscribers(t, srv, 1)
+
+	client.Client.Set(ctx, "other:1", "v", 0)
+	client.Client.Set(ctx, "device:tmp:1", "v", 0)
+	client.Client.Set(ctx, "device:1", "v", 0)
+	client.Client.Set(ctx, "device:heartbeat", "v", 100*time.Millisecond)
+
+	expected := []kredis.KeyspaceEvent{
+		{Type: kredis.KeyspaceSet, Key: "device:1"},
+		{Type: kredis.KeyspaceSet, Key: "device:heartbeat"},
+		{Type: kredis.KeyspaceExpired, Key: "device:heartbeat"},
+	}
+	for _, want := range expected {
+		select {
+		case event := <-events:
+			if event.Type != want.Type || event.Key != want.Key || event.DB != 0 || event.Node != srv.Addr() {
+				t.Errorf("expected %+v, got %+v", want, *event)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("missing event %+v", want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package ktest
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+// Redlock 在多数实例可用时获取成功; 可用实例不足半数时获取失败, Lock 在等待期限内返回
+func TestRedLockQuorum(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	// 同一个服务的不同数据库相互独立, 可以作为 Redlock 的多个实例
+	instances := make([]kredis.KRedisClient, 0, 2)
+	for db := 0; db < 2; db++ {
+		client, err := kredis.NewKRedisWithOptions(root, kredis.NewRedisOptions(srv.Addr()).SetDB(db))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(client.Stop)
+		instances = append(instances, client)
+	}
+	opts := kredis.NewLockOptions().SetTTL(1000).SetRetryInterval(20)
+
+	quorum := kredis.NewRedLocker([]kredis.KRedisClient{instances[0], instances[1], newUnreachableClient(t)}, opts)
+	lock, err := quorum.TryLock(root, "lock:collector")
+	if err != nil {
+		t.Fatalf("TryLock with 2 of 3 instances: %v", err)
+	}
+	if _, err := quorum.TryLock(root, "lock:collector"); !errors.Is(err, kredis.ErrLockNotObtained) {
+		t.Errorf("second TryLock: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Error(err)
+	}
+
+	minority := kredis.NewRedLocker([]kredis.KRedisClient{instances[0], newUnreachableClient(t), newUnreachableClient(t)}, opts)
+	begin := time.Now()
+	if _, err := minority.Lock(root, "lock:collector", 200*time.Millisecond); err == nil {
+		t.Fatal("Lock with 1 of 3 instances should fail")
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("Lock should honour the timeout, took %v", elapsed)
+	}
+	if keys := srv.Keys(); slices.Contains(keys, "lock:collector") {
+		t.Errorf("failed Redlock should release the acquired instances: %v", keys)
+	}
+}
+
+// 并发获取同一把锁时互斥, 隔离令牌按获取顺序单调递增
+func TestLockMutualExclusion(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	locker := kredis.NewLocker(newTestClient(t, srv.Addr(), 2), kredis.NewLockOptions().SetTTL(2000).SetRetryInterval(5))
+
+	var (
+		mu      sync.Mutex
+		holders int
+		tokens  []int64
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				lock, err := locker.Lock(root, "lock:collector", 5*time.Second)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				holders++
+				if holders != 1 {
+					t.Errorf("%d holders at the same time", holders)
+				}
+				tokens = append(tokens, lock.Token())
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				holders--
+				mu.Unlock()
+				if err := lock.Unlock(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(tokens) != 20 {
+		t.Fatalf("acquired %d times", len(tokens))
+	}
+	for i := 1; i < len(tokens); i++ {
+		if tokens[i] <= tokens[i-1] {
+			t.Fatalf("tokens should increase: %v", tokens)
+		}
+	}
+}
+
+// 自动续期使锁在 TTL 之后仍然有效; 锁被删除后续期失败, 节点随即取消
+func TestLockRenewAndLost(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 2)
+	locker := kredis.NewLocker(client, kredis.NewLockOptions().SetTTL(300).SetRenewInterval(50))
+
+	lock, err := locker.TryLock(root, "lock:collector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(600 * time.Millisecond)
+	if lock.Lost() || lock.Context().Context().Err() != nil {
+		t.Fatal("renewed lock should still be held")
+	}
+	if _, err := locker.TryLock(root, "lock:collector"); !errors.Is(err, kredis.ErrLockNotObtained) {
+		t.Fatalf("second TryLock: %v", err)
+	}
+
+	client.Del("lock:collector")
+	select {
+	case <-lock.Context().Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("lock context should be cancelled after the lock is taken away")
+	}
+	if !lock.Lost() || !errors.Is(lock.Unlock(), kredis.ErrLockNotHeld) {
+		t.Error("lock should be reported as lost")
+	}
+}
+
+// 续期持续失败时节点在有效期到期时取消, 不晚于服务端删除锁的时刻
+func TestLockExpiresOnRenewFailure(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	proxy := newTestProxy(t, srv.Addr())
+	locker := kredis.NewLocker(newProxyClient(t, proxy), kredis.NewLockOptions().SetTTL(400).SetRenewInterval(100))
+
+	lock, err := locker.TryLock(root, "lock:collector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond) // 至少续期一次
+	proxy.Close()
+	closed := time.Now()
+
+	select {
+	case <-lock.Context().Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("lock context should be cancelled when the lease expires")
+	}
+	// 最后一次成功的续期在代理关闭之前发出, 有效期从发出请求时计算
+	if elapsed := time.Since(closed); elapsed > 400*time.Millisecond {
+		t.Errorf("cancelled %v after renewals started failing, want <= ttl", elapsed)
+	}
+	if !lock.Lost() {
+		t.Error("lock should be lost")
+	}
+	if keys := srv.Keys(); !slices.Contains(keys, "lock:collector") {
+		t.Errorf("lock should still exist on the server when the holder gives up: %v", keys)
+	}
+}
+
+// Redis 暂时不可用时 Lock 在等待期限内重试
+func TestLockRetriesTransientErrors(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	// 先占用一个地址再释放, 客户端连接该地址时失败, 直到代理在该地址上启动
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	client, err := kredis.NewKRedisWithOptions(root, kredis.NewRedisOptions(addr).SetRetry(-1, 0, 0).SetTimeouts(100, 200, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	type result struct {
+		lock *kredis.Lock
+		err  error
+	}
+	acquired := make(chan result, 1)
+	go func() {
+		locker := kredis.NewLocker(client, kredis.NewLockOptions().SetTTL(1000).SetRetryInterval(20))
+		lock, err := locker.Lock(root, "lock:collector", 3*time.Second)
+		acquired <- result{lock, err}
+	}()
+	time.Sleep(200 * time.Millisecond)
+	listenTestProxy(t, addr, srv.Addr())
+
+	got := <-acquired
+	if got.err != nil {
+		t.Fatalf("Lock should succeed after redis recovers: %v", got.err)
+	}
+	got.lock.Unlock()
+}
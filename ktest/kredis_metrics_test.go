@@ -0,0 +1,181 @@
+package ktest
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis 不可用时命令与连接失败被计入统计, 事件中的 key 被替换为摘要
+func TestCommandMetrics(t *testing.T) {
+	var mu sync.Mutex
+	events := make([]*kredis.CommandEvent, 0)
+	metrics := kredis.NewCommandMetrics(kredis.NewMetricsOptions().SetRedactKeys(true).
+		SetCommandHandler(func(ctx context.Context, event *kredis.CommandEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		}))
+
+	client := newUnreachableClient(t, metrics)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		client.UniversalClient().Get(ctx, "device:1001")
+	}
+	client.UniversalClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Get(ctx, "device:1001")
+		pipe.Get(ctx, "device:1002")
+		return nil
+	})
+
+	stats := make(map[string]kredis.CommandStats)
+	for _, item := range metrics.Snapshot() {
+		stats[item.Name] = item
+	}
+	if get := stats["get"]; get.Calls != 3 || get.Errors != 3 {
+		t.Errorf("get stats: %+v", get)
+	}
+	if pipeline := stats["pipeline"]; pipeline.Calls != 1 || pipeline.Errors != 1 {
+		t.Errorf("pipeline stats: %+v", pipeline)
+	}
+	if stats["dial"].Errors == 0 {
+		t.Errorf("dial errors should be recorded: %+v", stats["dial"])
+	}
+
+	mu.Lock()
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+	for _, event := range events {
+		if event.Err == nil || strings.Contains(strings.Join(event.Args, " "), "device:") {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+	if events[3].Cmds != 2 {
+		t.Errorf("pipeline event: %+v", events[3])
+	}
+	mu.Unlock()
+
+	var sb strings.Builder
+	if err := metrics.WritePrometheus(&sb, "app_redis"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`app_redis_command_duration_seconds_count{cmd="get"} 3`,
+		`app_redis_command_duration_seconds_bucket{cmd="get",le="+Inf"} 3`,
+		`app_redis_command_errors_total{cmd="get"} 3`,
+	} {
+		if !strings.Contains(sb.String(), line) {
+			t.Errorf("missing %q in:\n%s", line, sb.String())
+		}
+	}
+
+	metrics.Reset()
+	if len(metrics.Snapshot()) != 0 {
+		t.Error("Reset should clear stats")
+	}
+}
+
+// 直方图上界排序后使用, 不修改调用方的 opts; opts 为空时使用默认上界
+func TestCommandMetricsOptions(t *testing.T) {
+	srv := newTestServer(t)
+
+	opts := kredis.NewMetricsOptions().SetBuckets(100, 10)
+	for _, metrics := range []*kredis.CommandMetrics{kredis.NewCommandMetrics(opts), kredis.NewCommandMetrics(nil)} {
+		client, err := kredis.NewKRedisWithOptions(kcontext.NewContextTree("mainCtx").GetRoot(), kredis.NewRedisOptions(srv.Addr()).AddHook(metrics))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Stop()
+		client.Client.Set(context.Background(), "device:1001", "on", 0)
+
+		snapshot := metrics.Snapshot()
+		var set kredis.CommandStats
+		for _, item := range snapshot {
+			if item.Name == "set" {
+				set = item
+			}
+		}
+		if set.Calls != 1 || len(set.Bounds) == 0 || !slices.IsSorted(set.Bounds) || set.Buckets[len(set.Buckets)-1] != 1 {
+			t.Errorf("set stats: %+v", set)
+		}
+	}
+	if !slices.Equal(opts.Buckets, []int64{100, 10}) {
+		t.Errorf("caller's options should not be modified: %v", opts.Buckets)
+	}
+}
+
+type spanKey struct{}
+
+// key 的位置来自 COMMAND, 非 key 参数保持原样; OnStart 返回的 ctx 传给 OnCommand
+func TestCommandMetricsRedactKeys(t *testing.T) {
+	srv := newTestServer(t)
+
+	var mu sync.Mutex
+	events := make(map[string]string)
+	metrics := kredis.NewCommandMetrics(kredis.NewMetricsOptions().SetRedactKeys(true).
+		SetStartHandler(func(ctx context.Context, name string) context.Context {
+			return context.WithValue(ctx, spanKey{}, "span:"+name)
+		}).
+		SetCommandHandler(func(ctx context.Context, event *kredis.CommandEvent) {
+			if span, _ := ctx.Value(spanKey{}).(string); span != "span:"+event.Name {
+				t.Errorf("OnCommand got span %q for %s", span, event.Name)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			events[event.Name] = strings.Join(event.Args, " ")
+		}))
+	client, err := kredis.NewKRedisWithOptions(kcontext.NewContextTree("mainCtx").GetRoot(), kredis.NewRedisOptions(srv.Addr()).AddHook(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	ctx := context.Background()
+	rdb := client.Client
+	rdb.Set(ctx, "device:1001", "on", 0)
+	rdb.Publish(ctx, "alerts", "device offline")
+	rdb.Eval(ctx, "return 1", []string{"device:1001"}, "threshold")
+	rdb.XRead(ctx, &redis.XReadArgs{Streams: []string{"device:s1", "device:s2", "0", "0"}, Count: 1, Block: -1})
+	rdb.RPush(ctx, "device:l1", "reboot", "reset")
+	rdb.BLPop(ctx, time.Second, "device:l1", "device:l2")
+	rdb.LMove(ctx, "device:l1", "device:l2", "LEFT", "RIGHT")
+	rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Get(ctx, "device:1001")
+		return nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	for name, want := range map[string]string{
+		"set":      "set key# on",
+		"rpush":    "rpush key# reboot reset",
+		"publish":  "publish alerts device offline",
+		"eval":     "eval return 1 1 key# threshold",
+		"xread":    "xread count 1 streams key# key# 0 0",
+		"blpop":    "blpop key# key# 1",
+		"lmove":    "lmove key# key# LEFT RIGHT",
+		"pipeline": "get key#",
+	} {
+		got, ok := events[name]
+		if !ok {
+			t.Errorf("missing event %s", name)
+			continue
+		}
+		if strings.Contains(got, "device:") || redactedKey.ReplaceAllString(got, "key#") != want {
+			t.Errorf("%s args = %q, want %q", name, got, want)
+		}
+	}
+}
+
+var redactedKey = regexp.MustCompile(`key#[0-9a-f]{8}`)
@@ -0,0 +1,135 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// 通过 NearCache 修改数据时其他进程的本地副本失效
+func TestNearCacheInvalidation(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	opts := kredis.NewNearCacheOptions().SetTTL(60000)
+	client := newTestClient(t, srv.Addr(), 2)
+	a := kredis.NewNearCache(root, newTestClient(t, srv.Addr(), 3), opts)
+	b := kredis.NewNearCache(root, client, opts)
+	for _, cache := range []*kredis.NearCache{a, b} {
+		if err := cache.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer cache.Stop()
+	}
+	waitSubscribers(t, srv, opts.Channel, 2)
+
+	client.Client.Set(context.Background(), "device:1001", "online", 0)
+	if value, err := a.Get("device:1001"); err != nil || value != "online" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+	a.Get("device:1001")
+	if stats := a.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	b.Set("device:1001", "offline", 0)
+	waitNearValue(t, a, "device:1001", "offline")
+	if stats := a.Stats(); stats.Invalidations != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// 订阅断开期间错过的失效通知不会留下旧值: 重新订阅后清空本地缓存
+func TestNearCachePurgeOnResubscribe(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	proxy := newTestProxy(t, srv.Addr())
+	disconnected := make(chan struct{}, 1)
+	opts := kredis.NewNearCacheOptions().SetTTL(60000).SetErrorHandler(func(err error) {
+		select {
+		case disconnected <- struct{}{}:
+		default:
+		}
+	})
+	a := kredis.NewNearCache(root, newProxyClient(t, proxy), opts)
+	b := kredis.NewNearCache(root, newTestClient(t, srv.Addr(), 3), kredis.NewNearCacheOptions())
+	for _, cache := range []*kredis.NearCache{a, b} {
+		if err := cache.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer cache.Stop()
+	}
+	waitSubscribers(t, srv, opts.Channel, 2)
+	b.Set("device:1001", "online", 0)
+	waitNearValue(t, a, "device:1001", "online")
+
+	proxy.Interrupt()
+	select {
+	case <-disconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("disconnect was not reported")
+	}
+	waitNearValue(t, a, "device:1001", "online") // 断开期间重新读取并缓存
+	b.Set("device:1001", "offline", 0)           // a 错过这条失效通知
+	waitNearValue(t, a, "device:1001", "offline")
+}
+
+// waitNearValue 等待本地缓存读到期望的值
+func waitNearValue(t *testing.T, cache *kredis.NearCache, key string, want string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		value, err := cache.Get(key)
+		if err == nil && value == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Get(%s) = %q, %v, want %q", key, value, err, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 超过容量时淘汰最久未访问的 key; 不存在的 key 不缓存; 合并的加载不受第一个调用方取消的影响
+func TestNearCacheEvictionAndLoad(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+	cache := kredis.NewNearCache(root, client, kredis.NewNearCacheOptions().SetMaxEntries(2))
+	if err := cache.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Stop()
+
+	ctx := context.Background()
+	for _, key := range []string{"device:1", "device:2", "device:3"} {
+		client.Client.Set(ctx, key, key, 0)
+	}
+	cache.Get("device:1")
+	cache.Get("device:2")
+	cache.Get("device:1") // device:2 成为最久未访问的 key
+	cache.Get("device:3")
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 || stats.Size != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	cache.Get("device:1")
+	if stats := cache.Stats(); stats.Hits != 2 {
+		t.Errorf("device:1 should stay cached: %+v", stats)
+	}
+
+	if _, err := cache.Get("device:missing"); !errors.Is(err, redis.Nil) {
+		t.Errorf("missing key: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if value, err := cache.GetWithCtx(cancelled, "device:2"); err != nil || value != "device:2" {
+		t.Errorf("GetWithCtx with cancelled ctx = %q, %v", value, err)
+	}
+	if stats := cache.Stats(); stats.Misses != 5 || stats.Size != 2 || stats.HitRate() != 2.0/7 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
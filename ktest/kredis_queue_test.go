@@ -0,0 +1,173 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// 失败的任务按退避时间重试, 超过重试次数后进入死信队列并释放去重键
+func TestQueueWorkerRetryAndDead(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+
+	opts := kredis.NewQueueOptions().SetPollInterval(10).SetRetry(1, 10, 20).SetConcurrency(1)
+	queue := kredis.NewQueue(client, "device:commands", opts)
+	id, err := queue.Enqueue(context.Background(), `{"cmd":"reboot"}`, 0, "device:1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dup, _ := queue.Enqueue(context.Background(), `{"cmd":"reboot"}`, 0, "device:1001"); dup != id {
+		t.Errorf("duplicate unique key should return %s, got %s", id, dup)
+	}
+
+	var attempts atomic.Int64
+	worker := kredis.NewQueueWorker(root, queue, func(ctx context.Context, job *kredis.Job) error {
+		attempts.Add(1)
+		return errors.New("device offline")
+	})
+	worker.Start()
+	defer worker.Stop()
+
+	waitQueueStats(t, queue, func(stats *kredis.QueueStats) bool { return stats.Dead == 1 })
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("job should run 2 times, ran %d", n)
+	}
+	jobs, _ := queue.DeadJobs(context.Background(), 10)
+	if len(jobs) != 1 || jobs[0].ID != id || jobs[0].LastError != "device offline" {
+		t.Errorf("unexpected dead jobs %+v", jobs)
+	}
+	if again, _ := queue.Enqueue(context.Background(), `{"cmd":"reboot"}`, time.Hour, "device:1001"); again == id {
+		t.Error("unique key should be released after the job is dead")
+	}
+}
+
+// worker 停止时中断的任务放回 ready, 不计入执行次数
+func TestQueueWorkerStopReleasesJob(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+
+	queue := kredis.NewQueue(client, "device:commands", kredis.NewQueueOptions().SetPollInterval(10).SetConcurrency(1))
+	id, _ := queue.Enqueue(context.Background(), `{"cmd":"reboot"}`, 0, "")
+
+	started := make(chan struct{})
+	worker := kredis.NewQueueWorker(root, queue, func(ctx context.Context, job *kredis.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	worker.Start()
+	<-started
+	worker.Stop()
+
+	stats, err := queue.Stats(context.Background())
+	if err != nil || stats.Ready != 1 || stats.Active != 0 || stats.Delayed != 0 {
+		t.Fatalf("interrupted job should be ready again: %+v %v", stats, err)
+	}
+
+	resumed := make(chan *kredis.Job, 1)
+	worker = kredis.NewQueueWorker(root, queue, func(ctx context.Context, job *kredis.Job) error {
+		resumed <- job
+		return nil
+	})
+	worker.Start()
+	defer worker.Stop()
+	select {
+	case job := <-resumed:
+		if job.ID != id || job.Attempts != 1 || job.LastError != "" {
+			t.Errorf("interrupted attempt should not be counted: %+v", job)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not resumed")
+	}
+}
+
+// 可见性超时后重新投递同样受重试次数限制
+func TestQueueVisibilityTimeoutDead(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+
+	opts := kredis.NewQueueOptions().SetPollInterval(10).SetRetry(0, 10, 10).SetVisibility(50)
+	queue := kredis.NewQueue(client, "device:commands", opts)
+	id, _ := queue.Enqueue(context.Background(), `{"cmd":"reboot"}`, 0, "device:1001")
+
+	// 模拟取出任务后崩溃的 worker
+	ctx := context.Background()
+	client.Client.LPop(ctx, "{device:commands}:ready")
+	client.Client.ZAdd(ctx, "{device:commands}:active", redis.Z{Score: float64(time.Now().UnixMilli()), Member: id})
+	client.Client.HIncrBy(ctx, "{device:commands}:attempts", id, 1)
+
+	worker := kredis.NewQueueWorker(root, queue, func(ctx context.Context, job *kredis.Job) error {
+		t.Errorf("job %s exceeded retries and should not run again", job.ID)
+		return nil
+	})
+	worker.Start()
+	defer worker.Stop()
+
+	waitQueueStats(t, queue, func(stats *kredis.QueueStats) bool { return stats.Dead == 1 && stats.Active == 0 })
+	if again, _ := queue.Enqueue(ctx, `{"cmd":"reboot"}`, time.Hour, "device:1001"); again == id {
+		t.Error("unique key should be released after the job is dead")
+	}
+}
+
+// 复制参数, 创建后修改 opts 不影响队列; 重复调用 Start 不会增加处理 goroutine
+func TestQueueWorkerStartOnce(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+
+	opts := kredis.NewQueueOptions().SetPollInterval(10).SetConcurrency(1)
+	queue := kredis.NewQueue(client, "device:commands", opts)
+	opts.SetConcurrency(4)
+	for i := 0; i < 3; i++ {
+		queue.Enqueue(context.Background(), `{"cmd":"reboot"}`, 0, "")
+	}
+
+	var running, done atomic.Int64
+	var overlapped atomic.Bool
+	worker := kredis.NewQueueWorker(root, queue, func(ctx context.Context, job *kredis.Job) error {
+		if running.Add(1) > 1 {
+			overlapped.Store(true)
+		}
+		time.Sleep(30 * time.Millisecond)
+		running.Add(-1)
+		done.Add(1)
+		return nil
+	})
+	worker.Start()
+	worker.Start()
+	defer worker.Stop()
+
+	waitQueueStats(t, queue, func(stats *kredis.QueueStats) bool { return done.Load() == 3 && stats.Active == 0 })
+	if overlapped.Load() {
+		t.Error("jobs should not run concurrently with Concurrency 1")
+	}
+
+	if stats, err := kredis.NewQueue(client, "device:other", nil).Stats(context.Background()); err != nil || stats.Ready != 0 {
+		t.Errorf("queue with default options: %+v, %v", stats, err)
+	}
+}
+
+func waitQueueStats(t *testing.T, queue *kredis.Queue, done func(stats *kredis.QueueStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		stats, err := queue.Stats(context.Background())
+		if err == nil && done(stats) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected queue stats %+v %v", stats, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package ktest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+// Redis 不可用时按 Fallback 策略给出结果
+func TestRateLimiterFallback(t *testing.T) {
+	client := newUnreachableClient(t)
+
+	errors := 0
+	limiter := newRateLimiter(t, client, kredis.NewRateLimitOptions(kredis.RateSlidingLog, 10, 60000).
+		SetFallback(kredis.RateFallbackLocal, 3).
+		SetErrorHandler(func(err error) { errors++ }))
+
+	for i := 0; i < 4; i++ {
+		result, err := limiter.Allow(context.Background(), "tenant:1001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Fallback || result.Allowed != (i < 3) {
+			t.Errorf("request %d: %+v", i, result)
+		}
+		if i == 3 && result.RetryAfter <= 0 {
+			t.Errorf("denied request should have RetryAfter, got %v", result.RetryAfter)
+		}
+	}
+	if errors != 4 {
+		t.Errorf("OnError called %d times, want 4", errors)
+	}
+
+	// 其他 key 不受影响
+	if result, _ := limiter.Allow(context.Background(), "tenant:1002"); !result.Allowed {
+		t.Errorf("tenant:1002 should be allowed: %+v", result)
+	}
+
+	deny := newRateLimiter(t, client, kredis.NewRateLimitOptions(kredis.RateTokenBucket, 10, 1000).SetFallback(kredis.RateFallbackDeny, 0))
+	if result, _ := deny.Allow(context.Background(), "tenant:1001"); result.Allowed || !result.Fallback {
+		t.Errorf("deny fallback: %+v", result)
+	}
+
+	allow := newRateLimiter(t, client, kredis.NewRateLimitOptions(kredis.RateFixedWindow, 10, 1000).SetFallback(kredis.RateFallbackAllow, 0))
+	if result, _ := allow.AllowN(context.Background(), "tenant:1001", 100); !result.Allowed || !result.Fallback {
+		t.Errorf("allow fallback: %+v", result)
+	}
+}
+
+// 各算法在实例之间共享配额, 超出上限时拒绝且不消耗配额, 窗口过去后恢复
+func TestRateLimiterAlgorithms(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	other := newTestClient(t, srv.Addr(), 2)
+	ctx := context.Background()
+
+	for _, algorithm := range []kredis.RateLimitAlgorithm{kredis.RateFixedWindow, kredis.RateSlidingLog, kredis.RateTokenBucket} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			opts := kredis.NewRateLimitOptions(algorithm, 3, 200).SetErrorHandler(func(err error) { t.Errorf("unexpected error %v", err) })
+			limiters := []*kredis.RateLimiter{newRateLimiter(t, client, opts), newRateLimiter(t, other, opts)}
+
+			for i := 0; i < 3; i++ {
+				result, err := limiters[i%2].Allow(ctx, "tenant:1001")
+				if err != nil || !result.Allowed || result.Fallback || result.Remaining != int64(2-i) {
+					t.Fatalf("request %d: %+v, %v", i, result, err)
+				}
+			}
+			result, _ := limiters[1].Allow(ctx, "tenant:1001")
+			if result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > 200*time.Millisecond {
+				t.Errorf("request over limit: %+v", result)
+			}
+			if result, _ := limiters[0].AllowN(ctx, "tenant:1001", 4); result.Allowed {
+				t.Errorf("AllowN over capacity: %+v", result)
+			}
+			if result, _ := limiters[0].Allow(ctx, "tenant:1002"); !result.Allowed {
+				t.Errorf("tenant:1002 should be allowed: %+v", result)
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			if result, _ := limiters[0].AllowN(ctx, "tenant:1001", 3); !result.Allowed {
+				t.Errorf("quota should recover after the window: %+v", result)
+			}
+		})
+	}
+}
+
+// 复制参数, 不修改调用方的 opts; 缺少参数或 Limit <= 0 时返回错误
+func TestRateLimiterOptions(t *testing.T) {
+	client := newUnreachableClient(t)
+
+	opts := kredis.NewRateLimitOptions(kredis.RateTokenBucket, 10, 0)
+	newRateLimiter(t, client, opts)
+	if opts.Window != 0 {
+		t.Errorf("caller's options should not be modified: %+v", opts)
+	}
+	if _, err := kredis.NewRateLimiter(client, nil); err == nil {
+		t.Error("nil options should be rejected")
+	}
+	for _, limit := range []int64{0, -1} {
+		if _, err := kredis.NewRateLimiter(client, kredis.NewRateLimitOptions(kredis.RateTokenBucket, limit, 1000)); err == nil {
+			t.Errorf("limit %d should be rejected", limit)
+		}
+	}
+}
+
+func newRateLimiter(t *testing.T, client kredis.KRedisClient, opts *kredis.RateLimitOptions) *kredis.RateLimiter {
+	t.Helper()
+	limiter, err := kredis.NewRateLimiter(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return limiter
+}
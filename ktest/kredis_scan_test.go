@@ -0,0 +1,60 @@
+package ktest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+// MATCH 与 TYPE 在服务端过滤, 跨多次 SCAN 与多个批次时每个 key 都被遍历到, 提前 break 时停止扫描
+func TestScanKeysFilters(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	ctx := context.Background()
+
+	expected := make([]string, 0, 25)
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("device:%02d", i)
+		client.Client.HSet(ctx, key, "status", "online")
+		expected = append(expected, key)
+	}
+	client.Client.Set(ctx, "device:string", "v", 0)
+	client.Client.HSet(ctx, "other:1", "status", "online")
+
+	opts := kredis.NewScanOptions("device:*").SetType("hash").SetCount(4).SetBatchSize(3)
+	keys := make([]string, 0, len(expected))
+	for key, err := range kredis.ScanKeys(ctx, client, opts) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(slices.Compact(keys), expected) {
+		t.Errorf("ScanKeys = %v", keys)
+	}
+
+	count := 0
+	for record, err := range kredis.ScanRecords(ctx, client, opts) {
+		if err != nil || record.DataType != "hash" || record.Data == "" {
+			t.Fatalf("ScanRecords: %+v, %v", record, err)
+		}
+		if count++; count == 5 {
+			break
+		}
+	}
+	if count != 5 {
+		t.Errorf("ScanRecords yielded %d records before break", count)
+	}
+
+	deleted, err := kredis.DeleteMatch(ctx, client, opts)
+	if err != nil || deleted != 25 {
+		t.Errorf("DeleteMatch = %d, %v", deleted, err)
+	}
+	if remain := srv.Keys(); len(remain) != 2 {
+		t.Errorf("remaining keys %v", remain)
+	}
+}
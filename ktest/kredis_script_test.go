@@ -0,0 +1,95 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+func TestScriptRegistry(t *testing.T) {
+	script := kredis.NewScript("ping", "return 'PONG'")
+	if script.Hash() != "7814fe8768dc7e582b000899dbd910a0a03a95b4" {
+		t.Errorf("unexpected hash %s", script.Hash())
+	}
+
+	fsys := fstest.MapFS{
+		"lua/incr_if_below.lua": {Data: []byte("local v = redis.call('INCR', KEYS[1])\nreturn v")},
+		"lua/device.lua":        {Data: []byte("#!lua name=device\nredis.register_function('device_touch', function(keys, args) return 1 end)")},
+		"lua/readme.txt":        {Data: []byte("ignored")},
+	}
+	registry := kredis.NewScriptRegistry()
+	if err := registry.RegisterFS(fsys, "lua/*.lua"); err != nil {
+		t.Fatal(err)
+	}
+	if names := registry.Names(); len(names) != 1 || names[0] != "incr_if_below" {
+		t.Errorf("scripts %v", names)
+	}
+	if libraries := registry.Libraries(); len(libraries) != 1 || libraries[0] != "device" {
+		t.Errorf("libraries %v", libraries)
+	}
+	if registry.Get("incr_if_below").Hash() != kredis.NewScript("", "local v = redis.call('INCR', KEYS[1])\nreturn v").Hash() {
+		t.Error("hash should only depend on the source")
+	}
+
+	if _, err := kredis.NewFunctionLibrary("return 1"); err == nil {
+		t.Error("library without '#!lua name=' should be rejected")
+	}
+
+	if err := registry.Run(context.Background(), nil, "missing", nil).Err(); !errors.Is(err, kredis.ErrScriptNotFound) {
+		t.Errorf("want ErrScriptNotFound, got %v", err)
+	}
+}
+
+// 脚本未缓存时 EVALSHA 回复 NOSCRIPT, Run 改用 EVAL 执行并缓存脚本, 之后只发送 EVALSHA
+func TestScriptRun(t *testing.T) {
+	srv := newTestServer(t)
+	hook := &recordHook{}
+	client, err := kredis.NewKRedisWithOptions(kcontext.NewContextTree("mainCtx").GetRoot(), kredis.NewRedisOptions(srv.Addr()).AddHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+	ctx := context.Background()
+
+	registry := kredis.NewScriptRegistry()
+	registry.Register("incr_if_below", `
+local v = redis.call('INCR', KEYS[1])
+if v > tonumber(ARGV[1]) then
+	redis.call('DECR', KEYS[1])
+	return 0
+end
+return v`)
+
+	client.Client.ScriptFlush(ctx) // 共用的 Redis 实例中其他测试可能已经缓存了同样的脚本
+	hook.commands = nil
+	for i, want := range []int64{1, 2, 0} {
+		if v, err := registry.Run(ctx, client, "incr_if_below", []string{"device:counter"}, 2).Int64(); err != nil || v != want {
+			t.Errorf("run %d: %v, %v, want %v", i, v, err, want)
+		}
+	}
+	if want := []string{"evalsha", "eval", "evalsha", "evalsha"}; !slices.Equal(hook.commands, want) {
+		t.Errorf("commands %v, want %v", hook.commands, want)
+	}
+
+	client.Client.ScriptFlush(ctx)
+	if err := registry.LoadAll(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	hook.commands = nil
+	script := registry.Get("incr_if_below")
+	if v, err := script.RunRO(ctx, client, []string{"device:counter"}, 2).Int64(); err == nil {
+		t.Errorf("read only script should not write: %v", v)
+	}
+	if v, err := script.Run(ctx, client, []string{"device:counter"}, 3).Int64(); err != nil || v != 3 {
+		t.Errorf("run after LoadAll: %v, %v", v, err)
+	}
+	if want := []string{"evalsha_ro", "evalsha"}; !slices.Equal(hook.commands, want) {
+		t.Errorf("commands %v, want %v", hook.commands, want)
+	}
+}
@@ -0,0 +1,392 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+	"github.com/khan-lau/kutils/db/kredis/kredistest"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// 同一组命令在 RESP2 与 RESP3 下结果相同
+func TestServerDataTypes(t *testing.T) {
+	srv := kredistest.Run(t)
+	for _, protocol := range []int{2, 3} {
+		srv.FlushAll()
+		client := newTestClient(t, srv.Addr(), protocol)
+		rdb := client.UniversalClient()
+		ctx := context.Background()
+
+		if !client.Ping() {
+			t.Fatalf("RESP%d: ping failed", protocol)
+		}
+		if ok, err := client.Set("counter", 41, 0); !ok || err != nil {
+			t.Fatalf("RESP%d: set: %v %v", protocol, ok, err)
+		}
+		if n, err := rdb.Incr(ctx, "counter").Result(); n != 42 || err != nil {
+			t.Errorf("RESP%d: incr: %d %v", protocol, n, err)
+		}
+		if _, err := rdb.Get(ctx, "missing").Result(); !errors.Is(err, redis.Nil) {
+			t.Errorf("RESP%d: get missing: %v", protocol, err)
+		}
+		if _, err := rdb.HGet(ctx, "counter", "f").Result(); err == nil || err.Error()[:9] != "WRONGTYPE" {
+			t.Errorf("RESP%d: hget on string: %v", protocol, err)
+		}
+
+		client.HSetAll("device:1", map[string]any{"name": "pump", "state": "on"})
+		if fields, err := client.HGetAll("device:1"); err != nil || len(fields) != 2 || fields["state"] != "on" {
+			t.Errorf("RESP%d: hgetall: %v %v", protocol, fields, err)
+		}
+
+		rdb.RPush(ctx, "jobs", "a", "b", "c")
+		if values, err := client.LRange("jobs", 0, -1); err != nil || !slices.Equal(values, []string{"a", "b", "c"}) {
+			t.Errorf("RESP%d: lrange: %v %v", protocol, values, err)
+		}
+		if value, err := client.LPop("jobs"); value != "a" || err != nil {
+			t.Errorf("RESP%d: lpop: %q %v", protocol, value, err)
+		}
+
+		rdb.SAdd(ctx, "tags", "x", "y", "x")
+		if members, err := rdb.SMembers(ctx, "tags").Result(); err != nil || !slices.Equal(members, []string{"x", "y"}) {
+			t.Errorf("RESP%d: smembers: %v %v", protocol, members, err)
+		}
+
+		rdb.ZAdd(ctx, "rank", redis.Z{Score: 3, Member: "c"}, redis.Z{Score: 1.5, Member: "a"}, redis.Z{Score: 2, Member: "b"})
+		zs, err := rdb.ZRangeWithScores(ctx, "rank", 0, -1).Result()
+		if err != nil || len(zs) != 3 || zs[0].Member != "a" || zs[0].Score != 1.5 || zs[2].Member != "c" {
+			t.Errorf("RESP%d: zrange withscores: %v %v", protocol, zs, err)
+		}
+		if members, err := rdb.ZRangeByScore(ctx, "rank", &redis.ZRangeBy{Min: "(1.5", Max: "+inf"}).Result(); err != nil || !slices.Equal(members, []string{"b", "c"}) {
+			t.Errorf("RESP%d: zrangebyscore: %v %v", protocol, members, err)
+		}
+		if score, err := rdb.ZIncrBy(ctx, "rank", 0.25, "a").Result(); score != 1.75 || err != nil {
+			t.Errorf("RESP%d: zincrby: %v %v", protocol, score, err)
+		}
+		if popped, err := rdb.ZPopMax(ctx, "rank").Result(); err != nil || len(popped) != 1 || popped[0].Member != "c" {
+			t.Errorf("RESP%d: zpopmax: %v %v", protocol, popped, err)
+		}
+	}
+}
+
+// FastForward 拨动服务端时钟, 到期的 key 立即删除
+func TestServerExpire(t *testing.T) {
+	srv := kredistest.Run(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	rdb := client.UniversalClient()
+	ctx := context.Background()
+
+	client.Set("session", "token", 10*time.Second)
+	if ttl, err := rdb.TTL(ctx, "session").Result(); ttl != 10*time.Second || err != nil {
+		t.Errorf("ttl: %v %v", ttl, err)
+	}
+	srv.FastForward(9 * time.Second)
+	if ok, _ := client.Exist("session"); !ok {
+		t.Error("session expired too early")
+	}
+	srv.FastForward(time.Second)
+	if ok, _ := client.Exist("session"); ok {
+		t.Error("session should have expired")
+	}
+	if ttl, _ := rdb.TTL(ctx, "session").Result(); ttl != -2 {
+		t.Errorf("ttl of missing key: %v", ttl)
+	}
+}
+
+// ScanKeys 的 MATCH 与 TYPE 过滤, 以及跨多页的游标
+func TestServerScan(t *testing.T) {
+	srv := kredistest.Run(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	rdb := client.UniversalClient()
+	ctx := context.Background()
+
+	var want []string
+	for i := 0; i < 25; i++ {
+		key := "device:" + string(rune('a'+i))
+		rdb.HSet(ctx, key, "id", i)
+		want = append(want, key)
+	}
+	rdb.Set(ctx, "device:z:name", "not a hash", 0)
+	rdb.Set(ctx, "other", "1", 0)
+
+	var keys []string
+	for key, err := range kredis.ScanKeys(ctx, client, kredis.NewScanOptions("device:*").SetType("hash").SetCount(7)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if !slices.Equal(keys, want) {
+		t.Errorf("scan: %v", keys)
+	}
+
+	if deleted, err := kredis.DeleteMatch(ctx, client, kredis.NewScanOptions("device:*")); deleted != 26 || err != nil {
+		t.Errorf("DeleteMatch: %d %v", deleted, err)
+	}
+	if keys := srv.Keys(0); !slices.Equal(keys, []string{"other"}) {
+		t.Errorf("remaining keys: %v", keys)
+	}
+}
+
+// 频道与模式订阅都能收到消息
+func TestServerPubSub(t *testing.T) {
+	srv := kredistest.Run(t)
+	client := newTestClient(t, srv.Addr(), 3)
+
+	var mu sync.Mutex
+	received := make(map[string]string)
+	done := make(chan struct{}, 4)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	sub := kredis.NewSubscription(root, client, kredis.NewSubscriptionOptions(), func(msg *kredis.SubscriptionMessage) {
+		mu.Lock()
+		received[msg.Pattern+"|"+msg.Channel] = msg.Payload
+		mu.Unlock()
+		done <- struct{}{}
+	})
+	sub.Start()
+	defer sub.Stop()
+	if err := sub.Subscribe("alarm"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.PSubscribe("device:*"); err != nil {
+		t.Fatal(err)
+	}
+
+	client.Publish("alarm", "fire")
+	client.Publish("device:1", "online")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %v", received)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if received["|alarm"] != "fire" || received["device:*|device:1"] != "online" {
+		t.Errorf("received %v", received)
+	}
+}
+
+// KeyspaceListener 自动开启通知, key 到期时收到 expired 事件
+func TestServerKeyspaceExpired(t *testing.T) {
+	srv := kredistest.Run(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	events := make(chan *kredis.KeyspaceEvent, 4)
+	listener := kredis.NewKeyspaceListener(root, client, kredis.NewKeyspaceOptions(kredis.KeyspaceExpired), func(event *kredis.KeyspaceEvent) {
+		events <- event
+	})
+	if err := listener.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Stop()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) { // 等待订阅建立
+		if n, _ := client.UniversalClient().PubSubNumPat(context.Background()).Result(); n > 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	client.Set("device:heartbeat:1", "1", time.Minute)
+	srv.FastForward(time.Minute)
+	select {
+	case event := <-events:
+		if event.Type != kredis.KeyspaceExpired || event.Key != "device:heartbeat:1" || event.DB != 0 {
+			t.Errorf("event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no expired event")
+	}
+}
+
+// WATCH 的 key 被其他连接修改时事务失败; BLPOP 在其他连接写入后返回
+func TestServerTransactionAndBlocking(t *testing.T) {
+	srv := kredistest.Run(t)
+	client := newTestClient(t, srv.Addr(), 2)
+	rdb := client.UniversalClient()
+	ctx := context.Background()
+
+	rdb.Set(ctx, "balance", 100, 0)
+	err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+		rdb.Set(ctx, "balance", 0, 0) // 其他连接修改
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.DecrBy(ctx, "balance", 10)
+			return nil
+		})
+		return err
+	}, "balance")
+	if !errors.Is(err, redis.TxFailedErr) {
+		t.Errorf("watch: %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		rdb.RPush(ctx, "tasks", "t1")
+	}()
+	if values, err := rdb.BLPop(ctx, 2*time.Second, "tasks").Result(); err != nil || !slices.Equal(values, []string{"tasks", "t1"}) {
+		t.Errorf("blpop: %v %v", values, err)
+	}
+	if _, err := rdb.BLPop(ctx, time.Second, "tasks").Result(); !errors.Is(err, redis.Nil) {
+		t.Errorf("blpop timeout: %v", err)
+	}
+}
+
+// EVAL 由内置的 Lua 子集解释器执行, 返回值与错误的转换规则与 Redis 相同
+func TestServerScripting(t *testing.T) {
+	srv := kredistest.Run(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	rdb := client.UniversalClient()
+	ctx := context.Background()
+
+	script := redis.NewScript(`
+local total = 0
+for i, key in ipairs(KEYS) do
+	total = total + tonumber(redis.call('INCRBY', key, ARGV[i]))
+end
+local t = {total, 'sum:' .. total, 3.9, nil, 'ignored'}
+return t
+`)
+	values, err := script.Run(ctx, rdb, []string{"a", "b"}, 2, 5).Slice()
+	if err != nil || len(values) != 3 || values[0] != int64(7) || values[1] != "sum:7" || values[2] != int64(3) {
+		t.Fatalf("script: %v %v", values, err)
+	}
+	if exists, _ := rdb.ScriptExists(ctx, script.Hash()).Result(); !slices.Equal(exists, []bool{true}) {
+		t.Errorf("script should be cached after EVAL: %v", exists)
+	}
+	rdb.ScriptFlush(ctx)
+	if err := rdb.EvalSha(ctx, script.Hash(), nil).Err(); err == nil || err.Error()[:8] != "NOSCRIPT" {
+		t.Errorf("evalsha after flush: %v", err)
+	}
+
+	rdb.HSet(ctx, "h", "f", "v")
+	if err := rdb.Eval(ctx, "return redis.call('INCR', KEYS[1])", []string{"h"}).Err(); err == nil || err.Error()[:9] != "WRONGTYPE" {
+		t.Errorf("redis.call error should propagate: %v", err)
+	}
+	caught, err := rdb.Eval(ctx, `
+local ok, reply = pcall(redis.call, 'INCR', KEYS[1])
+local r = redis.pcall('INCR', KEYS[1])
+if not ok and type(r) == 'table' and r.err then return redis.status_reply('caught') end
+return 'not caught'`, []string{"h"}).Result()
+	if caught != "caught" || err != nil {
+		t.Errorf("pcall: %v %v", caught, err)
+	}
+	if err := rdb.Eval(ctx, "undefined_var = 1", nil).Err(); err == nil {
+		t.Error("scripts should not create globals")
+	}
+	if err := rdb.Eval(ctx, "return {err = 'BUSY custom'}", nil).Err(); err == nil || err.Error() != "BUSY custom" {
+		t.Errorf("error table: %v", err)
+	}
+	if err := rdb.Eval(ctx, "return 1 +", nil).Err(); err == nil || err.Error()[:30] != "ERR Error compiling script (ne" {
+		t.Errorf("compile error: %v", err)
+	}
+	if err := rdb.EvalRO(ctx, "return redis.call('SET', KEYS[1], 1)", []string{"ro"}).Err(); err == nil {
+		t.Error("EVAL_RO should reject writes")
+	}
+}
+
+// 消费组: 投递、待确认、确认与超时转移
+func TestServerStreams(t *testing.T) {
+	srv := kredistest.Run(t)
+	for _, protocol := range []int{2, 3} {
+		client := newTestClient(t, srv.Addr(), protocol)
+		rdb := client.UniversalClient()
+		ctx := context.Background()
+		stream := fmt.Sprintf("s%d", protocol)
+
+		if err := rdb.XGroupCreateMkStream(ctx, stream, "g", "$").Err(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rdb.XGroupCreate(ctx, stream, "g", "$").Err(); err == nil || err.Error()[:9] != "BUSYGROUP" {
+			t.Errorf("duplicate group: %v", err)
+		}
+		id1, _ := rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, ID: "1-1", Values: []any{"n", "1"}}).Result()
+		rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, ID: "1-*", Values: []any{"n", "2"}})
+		if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, ID: "1-1", Values: []any{"n", "x"}}).Err(); err == nil {
+			t.Error("XADD should reject smaller IDs")
+		}
+		if entries, _ := rdb.XRange(ctx, stream, "(1-1", "+").Result(); len(entries) != 1 || entries[0].ID != "1-2" {
+			t.Errorf("xrange exclusive: %v", entries)
+		}
+
+		read, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g", Consumer: "a", Streams: []string{stream, ">"}, Count: 1, Block: -1}).Result()
+		if err != nil || len(read) != 1 || len(read[0].Messages) != 1 || read[0].Messages[0].ID != id1 || read[0].Messages[0].Values["n"] != "1" {
+			t.Fatalf("xreadgroup: %v %v", read, err)
+		}
+		// 历史 ID 只返回自己的待确认消息
+		if read, _ := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g", Consumer: "a", Streams: []string{stream, "0"}, Block: -1}).Result(); len(read) != 1 || len(read[0].Messages) != 1 {
+			t.Errorf("pending history: %v", read)
+		}
+		if summary, _ := rdb.XPending(ctx, stream, "g").Result(); summary.Count != 1 || summary.Consumers["a"] != 1 {
+			t.Errorf("xpending: %+v", summary)
+		}
+
+		srv.FastForward(time.Minute)
+		claimed, _, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{Stream: stream, Group: "g", Consumer: "b", MinIdle: 30 * time.Second, Start: "0"}).Result()
+		if err != nil || len(claimed) != 1 || claimed[0].ID != id1 {
+			t.Fatalf("xautoclaim: %v %v", claimed, err)
+		}
+		if ext, _ := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{Stream: stream, Group: "g", Start: "-", End: "+", Count: 10}).Result(); len(ext) != 1 || ext[0].Consumer != "b" || ext[0].RetryCount != 3 {
+			t.Errorf("xpending ext: %+v", ext)
+		}
+		if n, _ := rdb.XAck(ctx, stream, "g", id1).Result(); n != 1 {
+			t.Errorf("xack: %d", n)
+		}
+
+		// 阻塞读取在新消息写入后返回
+		rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g", Consumer: "a", Streams: []string{stream, ">"}, Block: -1})
+		done := make(chan []redis.XStream, 1)
+		go func() {
+			read, _ := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g", Consumer: "a", Streams: []string{stream, ">"}, Block: 5 * time.Second}).Result()
+			done <- read
+		}()
+		time.Sleep(50 * time.Millisecond)
+		other := newTestClient(t, srv.Addr(), protocol).UniversalClient()
+		other.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: []any{"n", "3"}})
+		if read := <-done; len(read) != 1 || len(read[0].Messages) != 1 || read[0].Messages[0].Values["n"] != "3" {
+			t.Errorf("blocking xreadgroup: %v", read)
+		}
+	}
+}
+
+// DUMP 的结果可以 RESTORE 到另一个 key, 损坏的数据被拒绝
+func TestServerDumpRestore(t *testing.T) {
+	srv := kredistest.Run(t)
+	rdb := newTestClient(t, srv.Addr(), 3).UniversalClient()
+	ctx := context.Background()
+
+	rdb.ZAdd(ctx, "z", redis.Z{Score: 1.5, Member: "a"}, redis.Z{Score: 2, Member: "b"})
+	rdb.XAdd(ctx, &redis.XAddArgs{Stream: "s", ID: "1-1", Values: []any{"k", "v"}})
+	for _, key := range []string{"z", "s"} {
+		payload, err := rdb.Dump(ctx, key).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rdb.Restore(ctx, key, 0, payload).Err(); err == nil || err.Error()[:7] != "BUSYKEY" {
+			t.Errorf("restore over existing key: %v", err)
+		}
+		if err := rdb.Restore(ctx, key+":copy", time.Minute, payload).Err(); err != nil {
+			t.Fatal(err)
+		}
+		if ttl, _ := rdb.PTTL(ctx, key+":copy").Result(); ttl <= 0 {
+			t.Errorf("%s: ttl %v", key, ttl)
+		}
+		if err := rdb.Restore(ctx, key+":bad", 0, payload[:len(payload)-1]+"x").Err(); err == nil {
+			t.Errorf("%s: corrupted payload should be rejected", key)
+		}
+	}
+	if members, _ := rdb.ZRangeWithScores(ctx, "z:copy", 0, -1).Result(); len(members) != 2 || members[0].Score != 1.5 {
+		t.Errorf("zset copy: %v", members)
+	}
+	if entries, _ := rdb.XRange(ctx, "s:copy", "-", "+").Result(); len(entries) != 1 || entries[0].Values["k"] != "v" {
+		t.Errorf("stream copy: %v", entries)
+	}
+}
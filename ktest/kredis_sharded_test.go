@@ -0,0 +1,79 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+func TestKeySlot(t *testing.T) {
+	for key, slot := range map[string]int{
+		"foo":                  12182,
+		"123456789":            12739,
+		"{user1000}.following": kredis.KeySlot("user1000"),
+		"{}.following":         kredis.KeySlot("{}.following"),
+	} {
+		if got := kredis.KeySlot(key); got != slot {
+			t.Errorf("KeySlot(%q) = %d, want %d", key, got, slot)
+		}
+	}
+	if kredis.KeySlot("{device:1001}:status") != kredis.KeySlot("{device:1001}:commands") {
+		t.Error("keys with the same hash tag should be in the same slot")
+	}
+}
+
+// 订阅多个分片频道并接收消息, 客户端停止后以 ErrUnSubscribe 结束
+func TestSSubscribeMessages(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+
+	channels := []string{"device:1001:commands", "device:1002:commands", "{device:1003}:commands"}
+	received := make(chan string, 8)
+	done := make(chan struct{})
+	client.SSubscribe(func(err error, topic string, payload any) {
+		switch {
+		case errors.Is(err, kredis.ErrUnSubscribe):
+			close(done)
+		case err != nil:
+			t.Errorf("unexpected error: %v", err)
+		default:
+			received <- topic + "=" + payload.(string)
+		}
+	}, channels...)
+
+	want := make(map[string]bool)
+	deadline := time.Now().Add(2 * time.Second)
+	for _, channel := range channels {
+		want[channel+"=reboot"] = true
+		for { // 订阅是异步建立的, 发布到有订阅者为止
+			if n, _ := client.Client.SPublish(context.Background(), channel, "reboot").Result(); n > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("no subscriber for %s", channel)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	for len(want) > 0 {
+		select {
+		case got := <-received:
+			if !want[got] {
+				t.Errorf("unexpected message %s", got)
+			}
+			delete(want, got)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("missing messages %v", want)
+		}
+	}
+
+	client.Stop()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SSubscribe did not stop")
+	}
+}
@@ -0,0 +1,155 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// 启动时分批处理本消费者未确认的消息, 之后读取新消息; 处理失败的消息不会导致空转
+func TestStreamWorkerPendingAndNew(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 2)
+
+	// 模拟崩溃前已投递但未确认的消息
+	if err := client.XGroupCreate("stream:events", "collector", "0"); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		id, err := client.XAdd("stream:events", 0, map[string]any{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	client.Client.XReadGroup(context.Background(), &redis.XReadGroupArgs{Group: "collector", Consumer: "host-1", Streams: []string{"stream:events", ">"}, Block: -1})
+
+	var (
+		mu        sync.Mutex
+		handled   []string
+		failures  atomic.Int32
+		processed = make(chan struct{}, 16)
+	)
+	opts := kredis.NewStreamWorkerOptions("collector", "host-1").SetBatchSize(2).SetBlock(50).SetRetryInterval(200).SetClaim(60000, 60000)
+	worker := kredis.NewStreamWorker(root, client, "stream:events", opts, func(msg *kredis.StreamMessage) error {
+		if msg.ID == ids[0] {
+			failures.Add(1)
+			return errors.New("downstream unavailable")
+		}
+		mu.Lock()
+		handled = append(handled, msg.ID)
+		mu.Unlock()
+		processed <- struct{}{}
+		return nil
+	})
+	if err := worker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer worker.Stop()
+
+	for i := 0; i < 4; i++ {
+		<-processed
+	}
+	newID, _ := client.XAdd("stream:events", 0, map[string]any{"n": 5})
+	select {
+	case <-processed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("new messages should be read after pending messages are drained")
+	}
+	time.Sleep(300 * time.Millisecond)
+	worker.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := append(slices.Clone(ids[1:]), newID); !slices.Equal(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if n := failures.Load(); n != 1 {
+		t.Errorf("failing message should not be re-read in a loop, handled %d times", n)
+	}
+	pending, _ := client.Client.XPending(context.Background(), "stream:events", "collector").Result()
+	if pending.Count != 1 || pending.Lower != ids[0] {
+		t.Errorf("only the failed message should stay pending: %+v", pending)
+	}
+}
+
+// 其他消费者长时间未确认的消息被认领并处理
+func TestStreamWorkerClaim(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+
+	client.XGroupCreate("stream:events", "collector", "0")
+	id, _ := client.XAdd("stream:events", 0, map[string]any{"n": 1})
+	client.Client.XReadGroup(context.Background(), &redis.XReadGroupArgs{Group: "collector", Consumer: "host-2", Streams: []string{"stream:events", ">"}, Block: -1})
+	time.Sleep(150 * time.Millisecond) // 消息在 host-2 的待确认列表中闲置超过认领时间
+
+	claimed := make(chan string, 1)
+	opts := kredis.NewStreamWorkerOptions("collector", "host-1").SetBlock(50).SetClaim(100, 50)
+	worker := kredis.NewStreamWorker(root, client, "stream:events", opts, func(msg *kredis.StreamMessage) error {
+		claimed <- msg.ID
+		return nil
+	})
+	if err := worker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer worker.Stop()
+
+	select {
+	case got := <-claimed:
+		if got != id {
+			t.Errorf("claimed %s, want %s", got, id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle message should be claimed")
+	}
+}
+
+// 复制参数, 创建后修改 opts 不影响 worker; 未设置消费组与消费者名称时分别使用 stream 与主机名
+func TestStreamWorkerDefaultOptions(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+
+	received := make(chan string, 1)
+	opts := kredis.NewStreamWorkerOptions("", "").SetBlock(50)
+	worker := kredis.NewStreamWorker(root, client, "stream:events", opts, func(msg *kredis.StreamMessage) error {
+		received <- msg.ID
+		return errors.New("downstream unavailable") // 保留在待确认列表中, 用于检查消费者名称
+	})
+	opts.Group = "changed"
+	if err := worker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer worker.Stop()
+
+	id, _ := client.XAdd("stream:events", 0, map[string]any{"n": 1})
+	select {
+	case got := <-received:
+		if got != id {
+			t.Errorf("received %s, want %s", got, id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message should be received")
+	}
+	worker.Stop()
+
+	host, _ := os.Hostname()
+	pending, err := client.Client.XPending(context.Background(), "stream:events", "stream:events").Result()
+	if err != nil || pending.Count != 1 || pending.Consumers[host] != 1 {
+		t.Errorf("pending %+v, %v; want one message for consumer %s", pending, err, host)
+	}
+
+	kredis.NewStreamWorker(root, client, "stream:other", nil, func(msg *kredis.StreamMessage) error { return nil }).Stop()
+}
@@ -0,0 +1,119 @@
+package ktest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+)
+
+// 频道与模式的消息按顺序处理; 连接中断后发出断开与重新订阅事件, 重新订阅后继续接收
+func TestSubscriptionReconnect(t *testing.T) {
+	srv := newTestServer(t)
+	proxy := newTestProxy(t, srv.Addr())
+	client := newProxyClient(t, proxy)
+	publisher := newTestClient(t, srv.Addr(), 3)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	ctx := context.Background()
+
+	events := make(chan kredis.SubscriptionEvent, 8)
+	messages := make(chan *kredis.SubscriptionMessage, 16)
+	opts := kredis.NewSubscriptionOptions().SetIntervals(100, 20).SetEventHandler(func(event kredis.SubscriptionEvent) { events <- event })
+	sub := kredis.NewSubscription(root, client, opts, func(msg *kredis.SubscriptionMessage) { messages <- msg })
+	defer sub.Stop()
+	if err := sub.Subscribe("device:status"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.PSubscribe("alarm:*"); err != nil {
+		t.Fatal(err)
+	}
+	sub.Start()
+	waitSubscriptionEvent(t, events, kredis.SubscriptionConnected)
+	waitSubscribers(t, srv, "device:status", 1)
+
+	publisher.Client.Publish(ctx, "device:status", "online")
+	publisher.Client.Publish(ctx, "alarm:W001", "overheat")
+	publisher.Client.Publish(ctx, "device:status", "offline")
+	expected := []kredis.SubscriptionMessage{
+		{Channel: "device:status", Payload: "online"},
+		{Channel: "alarm:W001", Pattern: "alarm:*", Payload: "overheat"},
+		{Channel: "device:status", Payload: "offline"},
+	}
+	for _, want := range expected {
+		select {
+		case msg := <-messages:
+			if *msg != want {
+				t.Errorf("expected %+v, got %+v", want, *msg)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("missing message %+v", want)
+		}
+	}
+
+	proxy.Interrupt()
+	waitSubscriptionEvent(t, events, kredis.SubscriptionDisconnected)
+	waitSubscriptionEvent(t, events, kredis.SubscriptionResubscribed)
+	waitSubscribers(t, srv, "device:status", 1)
+
+	publisher.Client.Publish(ctx, "alarm:W002", "smoke")
+	select {
+	case msg := <-messages:
+		if msg.Pattern != "alarm:*" || msg.Payload != "smoke" {
+			t.Errorf("unexpected message after resubscribe %+v", *msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("patterns should be resubscribed after reconnect")
+	}
+}
+
+// QueueDropNewest 在处理阻塞时丢弃新消息并计数, 不影响接收
+func TestSubscriptionDropNewest(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	opts := kredis.NewSubscriptionOptions().SetQueue(1, kredis.QueueDropNewest)
+	sub := kredis.NewSubscription(root, client, opts, func(msg *kredis.SubscriptionMessage) {
+		<-release
+		handled.Add(1)
+	})
+	sub.Subscribe("device:status")
+	sub.Start()
+	waitSubscribers(t, srv, "device:status", 1)
+
+	// 第一条进入处理函数, 第二条留在队列中, 之后的消息被丢弃
+	for i := 0; i < 5; i++ {
+		client.Client.Publish(context.Background(), "device:status", "msg")
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for sub.Dropped() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	sub.Stop()
+
+	if dropped, n := sub.Dropped(), handled.Load(); dropped != 3 || n != 2 {
+		t.Errorf("dropped %d, handled %d", dropped, n)
+	}
+}
+
+// waitSubscriptionEvent 等待指定类型的订阅事件, 跳过其他事件
+func waitSubscriptionEvent(t *testing.T, events <-chan kredis.SubscriptionEvent, kind kredis.SubscriptionEventKind) {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Kind == kind {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("missing %s event", kind)
+		}
+	}
+}
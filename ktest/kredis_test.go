@@ -1,28 +1,65 @@
 package ktest
 
 import (
-	"fmt"
+	"context"
+	"os"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/khan-lau/kutils/container/kcontext"
 	"github.com/khan-lau/kutils/db/kredis"
+	"github.com/khan-lau/kutils/db/kredis/kredistest"
 	klog "github.com/khan-lau/kutils/klogger"
 )
 
-var (
-	redisHd *kredis.KRedis
-	// glog    *klog.Logger
-)
+// jsonTestAddr RedisJSON 测试使用的服务地址, kredistest 不支持 RedisJSON, 需要真实的服务
+func jsonTestAddr() string {
+	if addr := os.Getenv("KREDIS_JSON_ADDR"); addr != "" {
+		return addr
+	}
+	return "10.50.145.10:16379"
+}
 
-func init() {
-	// glog = nil
-	mainRoot := kcontext.NewContextTree("mainCtx").GetRoot()
-	ctx := mainRoot.NewChild("redis_ctx")
+// jsonTestClient 连接 RedisJSON 测试服务, 服务不可用时跳过测试
+func jsonTestClient(t *testing.T) *kredis.KRedis {
+	t.Helper()
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client, err := kredis.NewKRedisWithOptions(root, kredis.NewRedisOptions(jsonTestAddr()).SetRetry(-1, 0, 0).SetTimeouts(500, 3000, 3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	if !client.Ping() {
+		t.Skipf("redis %s not available", jsonTestAddr())
+	}
+	return client
+}
 
-	redisHd = kredis.NewKRedis(ctx, "10.50.145.10:16379", "", "", 0)
-	fmt.Println("init redis success!")
+// newScanTestClient 启动测试服务并写入 Scan/ScanMatch 的测试数据
+func newScanTestClient(t *testing.T) *kredis.KRedis {
+	t.Helper()
+	client := newTestClient(t, kredistest.Run(t).Addr(), 2)
+	ctx := context.Background()
+	client.Client.HSet(ctx, "windRtEvent", "p", "1")
+	client.Client.Expire(ctx, "windRtEvent", time.Hour)
+	client.Client.HSet(ctx, "windRtEvent:DTNXJK:HSBFC:Q1:W009", "p", "1")
+	client.Client.Set(ctx, "windRtEvent:counter", "1", 0)
+	client.Client.RPush(ctx, "NSFC", "a", "b")
+	client.Client.RPush(ctx, "DTNXJK:NSFC", "a")
+	client.Client.SAdd(ctx, "other", "a")
+	return client
+}
+
+func recordKeys(records []*kredis.RedisRecord) []string {
+	keys := make([]string, 0, len(records))
+	for _, record := range records {
+		keys = append(keys, record.Key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func Test(t *testing.T) {
@@ -47,163 +84,149 @@ func Test_MatchArray(t *testing.T) {
 	}
 }
 
+// 黑白名单按 key 精确匹配, 只返回指定类型, 同时带回 TTL 与 DUMP 数据
 func Test_RedisScan(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisScan begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		ret, err := redisHd.Scan(500, []string{"rejson", "rejson-rl", "hash", "list"}, []string{"JSON$DTHYJK"}, []string{"windRtEvent", "NSFC"}, false, nil)
-		endTime := time.Now()
-		t.Log("redisScan end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisScan cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		} else {
-			t.Log("key count:", len(ret))
+	redisHd := newScanTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisScan begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	ret, err := redisHd.Scan(500, []string{"rejson", "rejson-rl", "hash", "list"}, []string{"JSON$DTHYJK"}, []string{"windRtEvent", "NSFC"}, false, nil)
+	endTime := time.Now()
+	t.Log("redisScan end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisScan cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := recordKeys(ret); !slices.Equal(keys, []string{"NSFC", "windRtEvent"}) {
+		t.Fatalf("keys: %v", keys)
+	}
+	for _, record := range ret {
+		if len(record.Data) == 0 {
+			t.Errorf("%s: dump data should not be empty", record.Key)
+		}
+		if want := record.Key == "windRtEvent"; want != (record.PTtl > 0) {
+			t.Errorf("%s: unexpected ttl %v", record.Key, record.PTtl)
 		}
-	} else {
-		t.Fatal("redis not connect!")
 	}
 }
 
+// 白名单支持 pattern*, *pattern 两种通配, 只返回指定类型
 func Test_RedisScanMatch(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisScanMatch begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		ret, err := redisHd.ScanMatch(500, []string{"rejson", "rejson-rl", "hash", "list"}, []string{"JSON$DTHYJK"}, []string{"windRtEvent*", "*NSFC"}, false, LogFunc)
-		endTime := time.Now()
-		t.Log("redisScanMatch end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisScanMatch cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		} else {
-			t.Log("key count:", len(ret))
-		}
-	} else {
-		t.Fatal("redis not connect!")
+	redisHd := newScanTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisScanMatch begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	ret, err := redisHd.ScanMatch(500, []string{"rejson", "rejson-rl", "hash", "list"}, []string{"JSON$DTHYJK"}, []string{"windRtEvent*", "*NSFC"}, false, LogFunc)
+	endTime := time.Now()
+	t.Log("redisScanMatch end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisScanMatch cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DTNXJK:NSFC", "NSFC", "windRtEvent", "windRtEvent:DTNXJK:HSBFC:Q1:W009"}
+	if keys := recordKeys(ret); !slices.Equal(keys, want) {
+		t.Fatalf("keys: %v, want %v", keys, want)
 	}
 }
 
 func Test_JsonSet(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisJsonSet begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		err := redisHd.JsonSet("testJson", ".", `{"name":"khan","age":30}`)
-		endTime := time.Now()
-		t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		}
-	} else {
-		t.Fatal("redis not connect!")
+	redisHd := jsonTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisJsonSet begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	err := redisHd.JsonSet("testJson", ".", `{"name":"khan","age":30}`)
+	endTime := time.Now()
+	t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
 func Test_JsonGet(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisJsonSet begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		r, err := redisHd.JsonGet("testJson", ".")
-		endTime := time.Now()
-		t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		} else {
-			t.Log("json:", r)
-		}
+	redisHd := jsonTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisJsonSet begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	r, err := redisHd.JsonGet("testJson", ".")
+	endTime := time.Now()
+	t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
 	} else {
-		t.Fatal("redis not connect!")
+		t.Log("json:", r)
 	}
 }
 
 func Test_JsonType(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisJsonType begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		r, err := redisHd.JsonType("testJson", "$.name")
-		endTime := time.Now()
-		t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		} else {
-			t.Log("json type:", strings.Join(r, ","))
-		}
+	redisHd := jsonTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisJsonType begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	r, err := redisHd.JsonType("testJson", "$.name")
+	endTime := time.Now()
+	t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
 	} else {
-		t.Fatal("redis not connect!")
+		t.Log("json type:", strings.Join(r, ","))
 	}
 }
 
 func Test_JsonObjKeys(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisJsonObjKeys begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		r, err := redisHd.JsonObjKeys("testJson", ".")
-		endTime := time.Now()
-		t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		} else {
-			t.Log("json keys:", strings.Join(r, ","))
-		}
+	redisHd := jsonTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisJsonObjKeys begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	r, err := redisHd.JsonObjKeys("testJson", ".")
+	endTime := time.Now()
+	t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
 	} else {
-		t.Fatal("redis not connect!")
+		t.Log("json keys:", strings.Join(r, ","))
 	}
 }
 
 func Test_JsonDel(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisJsonDel begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		r, err := redisHd.JsonDel("testJson", "$.age")
-		endTime := time.Now()
-		t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		} else {
-			t.Logf("json del count %d keys", r)
-		}
+	redisHd := jsonTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisJsonDel begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	r, err := redisHd.JsonDel("testJson", "$.age")
+	endTime := time.Now()
+	t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
 	} else {
-		t.Fatal("redis not connect!")
+		t.Logf("json del count %d keys", r)
 	}
 }
 
 func Test_JsonMerge(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisJsonMerge begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		// err := redisHd.JsonMerge("testJson", ".", `{"age":30}`)
-		err := redisHd.JsonMerge("testJson", ".", `{"another":{}}`)
-		endTime := time.Now()
-		t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		}
-	} else {
-		t.Fatal("redis not connect!")
+	redisHd := jsonTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisJsonMerge begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	// err := redisHd.JsonMerge("testJson", ".", `{"age":30}`)
+	err := redisHd.JsonMerge("testJson", ".", `{"another":{}}`)
+	endTime := time.Now()
+	t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
 func Test_JsonObjLen(t *testing.T) {
-	if redisHd.Ping() {
-		beginTime := time.Now()
-		t.Log("redisJsonObjLen begin ", beginTime.Format("2006-01-02 15:04:05.000"))
-		r, err := redisHd.JsonObjLen("testJson", "$.another")
-		// r, err := redisHd.JsonObjLen("docJson", "$..a")
-		endTime := time.Now()
-		t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
-		t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
-		if err != nil {
-			t.Fatal(err)
-		} else {
-			t.Logf("json obj len %d keys", r)
-		}
+	redisHd := jsonTestClient(t)
+	beginTime := time.Now()
+	t.Log("redisJsonObjLen begin ", beginTime.Format("2006-01-02 15:04:05.000"))
+	r, err := redisHd.JsonObjLen("testJson", "$.another")
+	// r, err := redisHd.JsonObjLen("docJson", "$..a")
+	endTime := time.Now()
+	t.Log("redisJsonSet end", endTime.Format("2006-01-02 15:04:05.000"))
+	t.Log("redisJsonSet cost ", endTime.Sub(beginTime).Milliseconds())
+	if err != nil {
+		t.Fatal(err)
 	} else {
-		t.Fatal("redis not connect!")
+		t.Logf("json obj len %d keys", r)
 	}
 }
 
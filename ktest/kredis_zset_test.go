@@ -0,0 +1,140 @@
+package ktest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	"github.com/khan-lau/kutils/db/kredis"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// ZAddFlags 的条件标志与各种范围查询
+func TestZSetCommands(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Addr(), 3)
+
+	if n, err := client.ZAdd("scores", redis.Z{Score: 10, Member: "a"}, redis.Z{Score: 20, Member: "b"}, redis.Z{Score: 30, Member: "c"}); err != nil || n != 3 {
+		t.Fatalf("ZAdd = %v, %v", n, err)
+	}
+	flagTests := []struct {
+		flags kredis.ZAddFlag
+		score float64
+		want  int64 // 设置 ZAddCH 时为变化的数量
+		final float64
+	}{
+		{kredis.ZAddNX, 99, 0, 10},
+		{kredis.ZAddXX | kredis.ZAddCH, 15, 1, 15},
+		{kredis.ZAddGT | kredis.ZAddCH, 12, 0, 15},
+		{kredis.ZAddLT | kredis.ZAddCH, 12, 1, 12},
+	}
+	for _, tt := range flagTests {
+		n, err := client.ZAddFlags("scores", tt.flags, redis.Z{Score: tt.score, Member: "a"})
+		score, _ := client.ZScore("scores", "a")
+		if err != nil || n != tt.want || score != tt.final {
+			t.Errorf("ZAddFlags(%b, %v) = %v, %v, score %v; want %v, score %v", tt.flags, tt.score, n, err, score, tt.want, tt.final)
+		}
+	}
+	if n, _ := client.ZAddFlags("scores", kredis.ZAddXX, redis.Z{Score: 1, Member: "d"}); n != 0 {
+		t.Error("XX should not add new members")
+	}
+	if _, err := client.ZScore("scores", "d"); !errors.Is(err, redis.Nil) {
+		t.Errorf("ZScore of missing member: %v", err)
+	}
+
+	if members, _ := client.ZRangeByScore("scores", "(12", "+inf", 0, 1); !slices.Equal(members, []string{"b"}) {
+		t.Errorf("ZRangeByScore = %v", members)
+	}
+	if members, _ := client.ZRevRange("scores", 0, 1); !slices.Equal(members, []string{"c", "b"}) {
+		t.Errorf("ZRevRange = %v", members)
+	}
+	if rank, _ := client.ZRevRank("scores", "a"); rank != 2 {
+		t.Errorf("ZRevRank = %v", rank)
+	}
+	if n, _ := client.ZRangeStore("top", redis.ZRangeArgs{Key: "scores", Start: "20", Stop: "+inf", ByScore: true}); n != 2 {
+		t.Errorf("ZRangeStore = %v", n)
+	}
+	if popped, _ := client.ZPopMax("top", 1); len(popped) != 1 || popped[0].Member != "c" || popped[0].Score != 30 {
+		t.Errorf("ZPopMax = %v", popped)
+	}
+
+	client.ZAdd("names", redis.Z{Member: "alpha"}, redis.Z{Member: "beta"}, redis.Z{Member: "gamma"})
+	if members, _ := client.ZRangeByLex("names", "[b", "+", 0, -1); !slices.Equal(members, []string{"beta", "gamma"}) {
+		t.Errorf("ZRangeByLex = %v", members)
+	}
+}
+
+// 启动时全量加载, 之后通过镜像的写入同步到其他进程的镜像
+func TestZSetMirrorSync(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+	client.Client.ZAdd(context.Background(), "whitelist", redis.Z{Score: 1000, Member: "point_A"})
+
+	opts := kredis.NewZSetMirrorOptions()
+	a := kredis.NewZSetMirror(root, client, "whitelist", nil, opts)
+	b := kredis.NewZSetMirror(root, newTestClient(t, srv.Addr(), 2), "whitelist", nil, nil)
+	for _, mirror := range []*kredis.ZSetMirror{a, b} {
+		if err := mirror.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer mirror.Stop()
+	}
+	if opts.Channel != "" {
+		t.Errorf("caller's options should not be modified: %+v", opts)
+	}
+	waitMirror(t, b, "point_A")
+
+	a.Add("point_B", 2000)
+	waitMirror(t, b, "point_A", "point_B")
+	b.Remove("point_A")
+	waitMirror(t, a, "point_B")
+}
+
+// 开启 WatchKeyspace 后绕过镜像的写入也会及时反映到本地副本
+func TestZSetMirrorWatchKeyspace(t *testing.T) {
+	srv := newTestServer(t)
+	root := kcontext.NewContextTree("mainCtx").GetRoot()
+	client := newTestClient(t, srv.Addr(), 3)
+
+	mirror := kredis.NewZSetMirror(root, client, "whitelist", nil, kredis.NewZSetMirrorOptions().SetResyncInterval(0).SetWatchKeyspace(true))
+	if err := mirror.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer mirror.Stop()
+	ctx := context.Background()
+	waitPatternSubscribers(t, srv, 1)
+
+	client.Client.ZIncrBy(ctx, "whitelist", 3000, "point_A")
+	client.Client.ZIncrBy(ctx, "whitelist", 1000, "point_B")
+	waitMirror(t, mirror, "point_B", "point_A")
+	client.Client.ZPopMin(ctx, "whitelist")
+	waitMirror(t, mirror, "point_A")
+
+	client.Client.ZAdd(ctx, "candidates", redis.Z{Score: 1, Member: "point_C"}, redis.Z{Score: 2, Member: "point_D"})
+	client.Client.ZRangeStore(ctx, "whitelist", redis.ZRangeArgs{Key: "candidates", Start: 0, Stop: -1})
+	waitMirror(t, mirror, "point_C", "point_D")
+	client.Client.Rename(ctx, "whitelist", "whitelist:old")
+	waitMirror(t, mirror)
+}
+
+// waitMirror 等待本地副本按分数排列的成员
+func waitMirror(t *testing.T, mirror *kredis.ZSetMirror, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		members := mirror.ZSet().ZRangeByScore(math.MaxInt64)
+		if slices.Equal(members, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("mirror members %v, want %v", members, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
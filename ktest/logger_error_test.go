@@ -0,0 +1,74 @@
+package ktest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	klog "github.com/khan-lau/kutils/klogger"
+)
+
+func TestLoggerTrailingError(t *testing.T) {
+	logger, recorder := klog.NewObserverLogger(klog.DebugLevel)
+
+	wrapped := fmt.Errorf("read device W001: %w", io.EOF)
+	logger.E("device {} offline", "W001", wrapped)
+	logger.Error("query %s failed", "W002", errors.Join(io.ErrUnexpectedEOF, io.ErrClosedPipe))
+	logger.D("placeholder consumes {}", io.EOF)
+	logger.W("stack", klog.WithStack(io.EOF))
+
+	recorder.AssertLogged(t, klog.ErrorLevel, "device W001 offline")
+	recorder.AssertField(t, "error", "read device W001: EOF")
+	recorder.AssertLogged(t, klog.ErrorLevel, "query W002 failed")
+
+	entries := recorder.FilterMessage("device W001 offline")
+	if entries.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %v", recorder.All().Messages())
+	}
+	causes, _ := entries[0].Fields["errorCauses"].([]any)
+	if len(causes) != 1 || causes[0] != any("EOF") {
+		t.Errorf("unexpected causes: %v", entries[0].Fields["errorCauses"])
+	}
+
+	joined := recorder.FilterMessage("query W002 failed")
+	if causes, _ := joined[0].Fields["errorCauses"].([]any); len(causes) != 2 {
+		t.Errorf("unexpected joined causes: %v", joined[0].Fields["errorCauses"])
+	}
+
+	// 被占位符消费的 error 仍保留在日志内容中
+	recorder.AssertLogged(t, klog.DebugLevel, "placeholder consumes EOF")
+	recorder.AssertField(t, "error", "EOF")
+
+	stacked := recorder.FilterMessage("stack")
+	verbose, _ := stacked[0].Fields["errorVerbose"].(string)
+	if !strings.Contains(verbose, "logger_error_test.go") {
+		t.Errorf("errorVerbose should contain the stack trace: %q", verbose)
+	}
+
+	if recorder.FilterCaller("logger_error_test.go").Len() != recorder.Len() {
+		t.Errorf("caller should point to the test file")
+	}
+}
+
+// `*` 宽度与 `%[n]` 参数序号按 fmt 规则计算消费的参数; 多个错误合并时仍能找到其中的调用栈
+func TestLoggerTrailingErrorVerbs(t *testing.T) {
+	logger, recorder := klog.NewObserverLogger(klog.DebugLevel)
+
+	logger.Error("retry %*d: %v", 3, 7, io.EOF)
+	logger.Error("device %[1]s (%[1]q) offline", "W001", io.EOF)
+	logger.Error("batch failed", errors.Join(io.ErrUnexpectedEOF, klog.WithStack(io.ErrClosedPipe)))
+
+	recorder.AssertLogged(t, klog.ErrorLevel, "retry   7: EOF")
+	recorder.AssertLogged(t, klog.ErrorLevel, `device W001 ("W001") offline`)
+
+	batch := recorder.FilterMessage("batch failed")
+	if batch.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %v", recorder.All().Messages())
+	}
+	verbose, _ := batch[0].Fields["errorVerbose"].(string)
+	if !strings.Contains(verbose, "logger_error_test.go") {
+		t.Errorf("errorVerbose should contain the stack trace of the joined error: %q", verbose)
+	}
+}
@@ -0,0 +1,68 @@
+package ktest
+
+import (
+	"testing"
+
+	klog "github.com/khan-lau/kutils/klogger"
+)
+
+func TestObserverLogger(t *testing.T) {
+	logger, recorder := klog.NewObserverLogger(klog.InfoLevel)
+
+	logger.D("debug {} should be dropped", 1)
+	logger.I("device {} online", "W001")
+	logger.W("device {} timeout after {}ms", "W002", 300)
+	logger.E("device {} offline", "W003")
+	logger.Info("printf %s style", "message")
+
+	if recorder.Len() != 4 {
+		t.Fatalf("expected 4 entries, got %d: %v", recorder.Len(), recorder.All().Messages())
+	}
+
+	recorder.AssertLogged(t, klog.InfoLevel, "device W001 online")
+	recorder.AssertLogged(t, klog.WarnLevel, "timeout after 300ms")
+	recorder.AssertNotLogged(t, klog.DebugLevel, "dropped")
+	recorder.AssertCount(t, klog.InfoLevel, 2)
+
+	errs := recorder.FilterLevel(klog.ErrorLevel)
+	if errs.Len() != 1 || errs[0].Message != "device W003 offline" {
+		t.Errorf("unexpected error entries: %v", errs.Messages())
+	}
+
+	// 调用位置应为本文件, 而不是 klogger 内部
+	if recorder.FilterCaller("logger_observer_test.go").Len() != 4 {
+		for _, entry := range recorder.All() {
+			t.Logf("caller: %s", entry.Caller)
+		}
+		t.Errorf("caller should point to the test file")
+	}
+
+	if n := recorder.All().FilterLevelAbove(klog.WarnLevel).Len(); n != 2 {
+		t.Errorf("expected 2 entries >= WARN, got %d", n)
+	}
+
+	taken := recorder.TakeAll()
+	if taken.Len() != 4 || recorder.Len() != 0 {
+		t.Errorf("TakeAll should drain the recorder, taken %d, left %d", taken.Len(), recorder.Len())
+	}
+}
+
+func TestObserverLoggerSkip(t *testing.T) {
+	logger, recorder := klog.NewObserverLogger(klog.DebugLevel)
+
+	wrapper := func(msg string) {
+		logger.KD(1, "wrapped: {}", msg)
+	}
+	wrapper("hello")
+
+	entries := recorder.FilterMessage("wrapped: hello")
+	if entries.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %d", entries.Len())
+	}
+	if entries[0].Level != klog.DebugLevel {
+		t.Errorf("expected DEBUG, got %s", entries[0].Level)
+	}
+	if recorder.FilterCaller("logger_observer_test.go").Len() != 1 {
+		t.Errorf("caller should skip the wrapper, got %s", entries[0].Caller)
+	}
+}
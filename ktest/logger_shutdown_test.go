@@ -0,0 +1,115 @@
+package ktest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khan-lau/kutils/container/kcontext"
+	klog "github.com/khan-lau/kutils/klogger"
+	"github.com/khan-lau/kutils/klogger/klogreader"
+)
+
+func readLogEntries(t *testing.T, logFile string) []*klogreader.Entry {
+	t.Helper()
+	var entries []*klogreader.Entry
+	err := klogreader.ReadFile(logFile, nil, func(entry *klogreader.Entry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestLoggerClose(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+
+	// 刷盘周期足够长, 只有 Close 才会将日志写入文件
+	logger := klog.GetLoggerWithConfig(klog.NewConfigure().SetLogFile(logFile).SetAsync(true, 60*1000, 4*1024*1024))
+	logger.I("device {} online", "W001")
+
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("second Close should be a no-op: %v", err)
+	}
+
+	entries := readLogEntries(t, logFile)
+	if len(entries) != 1 || entries[0].Message != "device W001 online" {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+}
+
+func TestLoggerRecover(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger := klog.GetLoggerWithConfig(klog.NewConfigure().SetLogFile(logFile).SetAsync(true, 60*1000, 4*1024*1024))
+
+	var repanicked any
+	func() {
+		defer func() { repanicked = recover() }()
+		func() {
+			defer logger.Recover()
+			panic("device W001 crashed")
+		}()
+	}()
+
+	if repanicked != "device W001 crashed" {
+		t.Fatalf("Recover should re-panic with the original value, got %v", repanicked)
+	}
+
+	entries := readLogEntries(t, logFile)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Level != klog.ErrorLevel || entries[0].Message != "panic: device W001 crashed" {
+		t.Errorf("unexpected entry: %+v", entries[0])
+	}
+	if !strings.Contains(entries[0].Stack, "logger_shutdown_test.go") {
+		t.Errorf("stack should be logged: %q", entries[0].Stack)
+	}
+	if !strings.Contains(entries[0].Caller, "logger_shutdown_test.go") {
+		t.Errorf("caller should point to the panicking function: %s", entries[0].Caller)
+	}
+}
+
+func TestLoggerBindShutdown(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger := klog.GetLoggerWithConfig(klog.NewConfigure().SetLogFile(logFile).SetAsync(true, 60*1000, 4*1024*1024))
+	defer logger.Close()
+
+	tree := kcontext.NewContextTree("app")
+	stop := logger.BindShutdown(tree, os.Interrupt)
+	defer stop()
+
+	logger.I("service started")
+	process, _ := os.FindProcess(os.Getpid())
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skip("signal not supported:", err)
+	}
+
+	select {
+	case <-tree.GetRoot().Context().Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("tree should be closed after signal")
+	}
+
+	// 等待刷新完成
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if entries := readLogEntries(t, logFile); len(entries) == 2 {
+			if entries[1].Message != "received signal interrupt, shutting down" {
+				t.Errorf("unexpected entry: %+v", entries[1])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("logs should be flushed on shutdown")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
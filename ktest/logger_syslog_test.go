@@ -0,0 +1,359 @@
+package ktest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	klog "github.com/khan-lau/kutils/klogger"
+)
+
+// 验证 RFC 5424 格式通过 UDP 发送
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sysConf := klog.NewSyslogConfigure(klog.SyslogNetUDP, conn.LocalAddr().String()).
+		SetFacility(klog.FacilityLocal0).
+		SetAppName("kutils-test").
+		SetHostname("field-01")
+	logger := klog.GetLoggerWithConfig(klog.NewConfigure().SetLevel(klog.DebugLevel).SetSyslog(sysConf))
+
+	logger.W("device {} timeout", "W001")
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	t.Logf("recv: %s", msg)
+
+	// local0(16) * 8 + warning(4) = 132
+	if !strings.HasPrefix(msg, "<132>1 ") {
+		t.Errorf("unexpected PRI/VERSION: %s", msg)
+	}
+	if !strings.Contains(msg, " field-01 kutils-test ") {
+		t.Errorf("hostname or app-name missing: %s", msg)
+	}
+	if !strings.Contains(msg, "device W001 timeout") || !strings.Contains(msg, "logger_syslog_test.go") {
+		t.Errorf("message or caller missing: %s", msg)
+	}
+}
+
+// 验证 RFC 3164 格式通过 TCP 发送, 服务端重启期间的日志在重连后补发
+func TestSyslogTCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	lines := make(chan string, 100)
+	conns := make(chan net.Conn, 10)
+	serve := func(ln net.Listener) {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- c
+			go func(c net.Conn) {
+				defer c.Close()
+				scanner := bufio.NewScanner(c)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}(c)
+		}
+	}
+	go serve(ln)
+
+	sysConf := klog.NewSyslogConfigure(klog.SyslogNetTCP, addr).
+		SetFormat(klog.SyslogRFC3164).
+		SetFacility(klog.FacilityDaemon).
+		SetAppName("kutils-test").
+		SetReconnectInterval(50)
+	writer, err := klog.NewSyslogWriter(sysConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	writer.WriteMessage(klog.ErrorLevel, time.Now(), []byte("first"))
+	select {
+	case line := <-lines:
+		// daemon(3) * 8 + error(3) = 27
+		if !strings.HasPrefix(line, "<27>") || !strings.Contains(line, " kutils-test[") || !strings.HasSuffix(line, "]: first") {
+			t.Errorf("unexpected rfc3164 line: %s", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("first message not received")
+	}
+
+	// 关闭服务端, 后续写入失败的日志进入缓存
+	ln.Close()
+	(<-conns).Close()
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		writer.WriteMessage(klog.InfoLevel, time.Now(), []byte("buffered"))
+		time.Sleep(30 * time.Millisecond)
+	}
+	if writer.Pending() == 0 {
+		t.Fatal("messages should be buffered while syslog server is down")
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serve(ln)
+
+	if err := writer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if writer.Pending() != 0 {
+		t.Errorf("pending should be flushed after reconnect, left %d", writer.Pending())
+	}
+
+	select {
+	case line := <-lines:
+		if !strings.HasSuffix(line, ": buffered") {
+			t.Errorf("unexpected line after reconnect: %s", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("buffered message not received after reconnect")
+	}
+}
+
+// 服务端不读取数据时写日志不会阻塞, 超出缓存的日志被丢弃, 关闭时的补发受写超时限制
+func TestSyslogSlowServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close() // 接受连接但从不读取
+		}
+	}()
+
+	sysConf := klog.NewSyslogConfigure(klog.SyslogNetTCP, ln.Addr().String()).
+		SetBufferCount(10).
+		SetWriteTimeout(100)
+	writer, err := klog.NewSyslogWriter(sysConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte(strings.Repeat("x", 64*1024))
+	start := time.Now()
+	for i := 0; i < 500; i++ {
+		writer.WriteMessage(klog.InfoLevel, time.Now(), msg)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WriteMessage blocked for %v", elapsed)
+	}
+	if writer.Dropped() == 0 || writer.Pending() > 10 {
+		t.Errorf("buffer should be bounded: pending %d, dropped %d", writer.Pending(), writer.Dropped())
+	}
+
+	start = time.Now()
+	writer.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close blocked for %v", elapsed)
+	}
+}
+
+// RFC 3164 通过 TCP 发送时, 报文中的换行被转义, 带堆栈的日志仍是一条记录
+func TestSyslogTCPMultiline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := serveSyslogLines(ln)
+
+	sysConf := klog.NewSyslogConfigure(klog.SyslogNetTCP, ln.Addr().String()).
+		SetFormat(klog.SyslogRFC3164).
+		SetAppName("kutils-test")
+	writer, err := klog.NewSyslogWriter(sysConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	writer.WriteMessage(klog.ErrorLevel, time.Now(), []byte("panic: boom\r\ngoroutine 1 [running]:\n\tmain.go:10\n"))
+	writer.WriteMessage(klog.InfoLevel, time.Now(), []byte("next"))
+	for _, suffix := range []string{"]: panic: boom#015#012goroutine 1 [running]:#012\tmain.go:10", "]: next"} {
+		select {
+		case line := <-lines:
+			if !strings.HasSuffix(line, suffix) {
+				t.Errorf("line %q, want suffix %q", line, suffix)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("message %q not received", suffix)
+		}
+	}
+}
+
+// RFC 5424 通过 unix 流式套接字发送, 使用 octet counting 分帧, 报文中的换行原样保留
+func TestSyslogUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	frames := make(chan string, 10)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		reader := bufio.NewReader(c)
+		for {
+			size, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+			if err != nil {
+				frames <- "bad frame length " + size
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			frames <- string(msg)
+		}
+	}()
+
+	writer, err := klog.NewSyslogWriter(klog.NewSyslogConfigure(klog.SyslogNetUnix, path).SetAppName("kutils-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	writer.WriteMessage(klog.ErrorLevel, time.Now(), []byte("panic: boom\ngoroutine 1 [running]:\n"))
+	writer.WriteMessage(klog.InfoLevel, time.Now(), []byte("next"))
+	for _, suffix := range []string{" - - panic: boom\ngoroutine 1 [running]:", " - - next"} {
+		select {
+		case frame := <-frames:
+			if !strings.HasPrefix(frame, "<") || !strings.HasSuffix(frame, suffix) {
+				t.Errorf("frame %q, want suffix %q", frame, suffix)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("message %q not received", suffix)
+		}
+	}
+}
+
+// 通过 TLS 发送, 使用配置的根证书校验服务端
+func TestSyslogTLS(t *testing.T) {
+	cert, pool := newSyslogTestCert(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := serveSyslogLines(ln)
+
+	sysConf := klog.NewSyslogConfigure(klog.SyslogNetTLS, ln.Addr().String()).
+		SetFormat(klog.SyslogRFC3164).
+		SetAppName("kutils-test").
+		SetTLSConfig(&tls.Config{RootCAs: pool, ServerName: "127.0.0.1"})
+	writer, err := klog.NewSyslogWriter(sysConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	writer.WriteMessage(klog.WarnLevel, time.Now(), []byte("device W001 timeout"))
+	select {
+	case line := <-lines:
+		// user(1) * 8 + warning(4) = 12
+		if !strings.HasPrefix(line, "<12>") || !strings.HasSuffix(line, "]: device W001 timeout") {
+			t.Errorf("unexpected line: %s", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+// serveSyslogLines 接受连接并按行读取报文
+func serveSyslogLines(ln net.Listener) <-chan string {
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				scanner := bufio.NewScanner(c)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}(c)
+		}
+	}()
+	return lines
+}
+
+// newSyslogTestCert 生成 127.0.0.1 的自签名证书, 返回证书与包含它的根证书池
+func newSyslogTestCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "syslog-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}